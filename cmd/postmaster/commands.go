@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/openware/postmaster/pkg/consumer"
+	"github.com/openware/postmaster/pkg/env"
+	"github.com/openware/postmaster/pkg/eventapi"
+	"github.com/openware/postmaster/pkg/suppression"
+)
+
+// loadFlags registers flags of consumer.LoadOptions on set.
+func loadFlags(set *flag.FlagSet) *consumer.LoadOptions {
+	opts := new(consumer.LoadOptions)
+	set.BoolVar(&opts.Lenient, "lenient", false, "Ignore unknown config fields instead of failing")
+	set.BoolVar(&opts.NormalizeLanguages, "normalize-languages", false, "Upper-case language codes instead of failing on lower cased ones")
+	set.BoolVar(&opts.LintTemplates, "lint-templates", false, "Fail on likely-unintended template trim markers")
+	return opts
+}
+
+// validateCommand runs "postmaster validate [flags] <config.yml>".
+func validateCommand(args []string) {
+	set := flag.NewFlagSet("validate", flag.ExitOnError)
+	set.Usage = func() {
+		fmt.Fprintln(set.Output(), "Usage: postmaster validate [flags] <config.yml>")
+		set.PrintDefaults()
+	}
+	opts := loadFlags(set)
+	set.Parse(args)
+
+	if set.NArg() != 1 {
+		set.Usage()
+		os.Exit(2)
+	}
+
+	path := set.Arg(0)
+	if err := consumer.ValidateConfig(path, *opts); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s is valid\n", path)
+}
+
+// previewCommand runs "postmaster preview [flags]".
+func previewCommand(args []string) {
+	set := flag.NewFlagSet("preview", flag.ExitOnError)
+	configPath := set.String("config", "config/postmaster.yml", "Path to postmaster config file")
+	event := set.String("event", "", "Key of event to render")
+	lang := set.String("lang", "", "Language to render in, the one of payload when empty")
+	dataPath := set.String("data", "", "JSON payload of message, preview data of the event when empty")
+	out := set.String("out", "", "Write email to this file instead of stdout")
+	opts := loadFlags(set)
+	set.Parse(args)
+
+	if *event == "" {
+		fmt.Fprintln(set.Output(), "-event is required")
+		set.Usage()
+		os.Exit(2)
+	}
+
+	var payload eventapi.Event
+	if *dataPath != "" {
+		content, err := ioutil.ReadFile(*dataPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := json.Unmarshal(content, &payload); err != nil {
+			log.Fatalf("%s: %s", *dataPath, err.Error())
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := consumer.WritePreview(*configPath, *opts, *event, *lang, payload, w); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// suppressionsCommand runs "postmaster suppressions [flags] list|remove <address>"
+// against suppressions API of running consumer.
+func suppressionsCommand(args []string) {
+	set := flag.NewFlagSet("suppressions", flag.ExitOnError)
+	set.Usage = func() {
+		fmt.Fprintln(set.Output(), "Usage: postmaster suppressions [flags] list|remove <address>")
+		set.PrintDefaults()
+	}
+	configPath := set.String("config", "config/postmaster.yml", "Path to postmaster config file")
+	apiURL := set.String("url", "", "URL of suppressions API, http://localhost with port of suppression.addr when empty")
+	opts := loadFlags(set)
+	set.Parse(args)
+
+	conf, err := consumer.LoadConfig(*configPath, *opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if conf.Suppression == nil || conf.Suppression.Addr == "" {
+		log.Fatal("suppression.addr is not configured")
+	}
+
+	if *apiURL == "" {
+		_, port, _ := net.SplitHostPort(conf.Suppression.Addr)
+		*apiURL = "http://localhost:" + port
+	}
+
+	client := &suppression.Client{URL: *apiURL, Token: env.Must(env.Fetch(conf.Suppression.TokenEnv))}
+
+	switch {
+	case set.NArg() == 1 && set.Arg(0) == "list":
+		entries, err := client.List()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, entry := range entries {
+			fmt.Printf("%s\t%s\t%s\t%s\n", entry.Address, entry.Reason, entry.Provider, entry.At.Format(time.RFC3339))
+		}
+	case set.NArg() == 2 && set.Arg(0) == "remove":
+		if err := client.Remove(set.Arg(1)); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		set.Usage()
+		os.Exit(2)
+	}
+}
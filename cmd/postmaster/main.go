@@ -1,18 +1,158 @@
 package main
 
 import (
+	"expvar"
 	"flag"
+	"log"
+	"net/http"
+	"os"
 
+	"github.com/openware/postmaster/pkg/amqp"
 	"github.com/openware/postmaster/pkg/consumer"
 )
 
 func main() {
-	config := flag.String(
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			validateCommand(os.Args[2:])
+			return
+		case "preview":
+			previewCommand(os.Args[2:])
+			return
+		case "suppressions":
+			suppressionsCommand(os.Args[2:])
+			return
+		}
+	}
+
+	configPath := flag.String(
 		"config",
 		"config/postmaster.yml",
 		"Path to postmaster config file",
 	)
+	lenient := flag.Bool(
+		"lenient",
+		false,
+		"Ignore unknown config fields instead of failing",
+	)
+	normalize := flag.Bool(
+		"normalize-languages",
+		false,
+		"Upper-case language codes instead of failing on lower cased ones",
+	)
+	lintTemplates := flag.Bool(
+		"lint-templates",
+		false,
+		"Fail on likely-unintended template trim markers",
+	)
+	printConfig := flag.Bool(
+		"print-config",
+		false,
+		"Print effective config with secrets redacted and exit",
+	)
+	coverage := flag.Bool(
+		"coverage",
+		false,
+		"Print event keys lacking template per language as JSON and exit",
+	)
+	preflight := flag.Bool(
+		"preflight",
+		false,
+		"Check broker exchange and SMTP connectivity and exit",
+	)
+	replay := flag.Bool(
+		"replay",
+		false,
+		"Re-send dead-lettered messages with current config and exit",
+	)
+	replayLimit := flag.Int(
+		"replay-limit",
+		0,
+		"Replay at most this many messages, all when zero",
+	)
+	metricsAddr := flag.String(
+		"metrics-addr",
+		"",
+		"Serve metrics as JSON on this address, e.g. :9090",
+	)
+	maxMessages := flag.Int(
+		"max-messages",
+		0,
+		"Exit once this many messages are processed, run forever when zero",
+	)
+	watchInterval := flag.Duration(
+		"watch-interval",
+		0,
+		"Reload config once it or template files changed, checking with this interval, e.g. 5s",
+	)
 	flag.Parse()
 
-	consumer.Run(*config)
+	opts := consumer.LoadOptions{
+		Lenient:            *lenient,
+		NormalizeLanguages: *normalize,
+		LintTemplates:      *lintTemplates,
+	}
+
+	if *printConfig {
+		conf, err := consumer.LoadConfig(*configPath, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		content, err := conf.Marshal()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		os.Stdout.Write(content)
+		return
+	}
+
+	if *coverage {
+		conf, err := consumer.LoadConfig(*configPath, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := conf.WriteCoverageReport(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *preflight {
+		if err := consumer.Preflight(*configPath, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *replay {
+		if err := consumer.Replay(*configPath, opts, *replayLimit); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var options []consumer.Option
+	if *metricsAddr != "" {
+		metrics := amqp.NewMetrics()
+		metrics.Publish("postmaster")
+		options = append(options, consumer.WithMetrics(metrics))
+
+		go func() {
+			log.Fatal(http.ListenAndServe(*metricsAddr, expvar.Handler()))
+		}()
+	}
+
+	if *maxMessages > 0 {
+		options = append(options, consumer.WithMaxMessages(*maxMessages))
+	}
+
+	if *watchInterval > 0 {
+		options = append(options, consumer.WithWatchInterval(*watchInterval))
+	}
+
+	consumer.Run(*configPath, opts, options...)
 }
@@ -3,9 +3,13 @@ module github.com/openware/postmaster
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/go-yaml/yaml v2.1.0+incompatible
+	github.com/microcosm-cc/bluemonday v1.0.2
 	github.com/mitchellh/mapstructure v1.1.2
 	github.com/sendgrid/rest v2.4.1+incompatible
 	github.com/sendgrid/sendgrid-go v3.4.1+incompatible
+	github.com/shamaton/msgpack v1.2.1
 	github.com/streadway/amqp v0.0.0-20181205114330-a314942b2fd9
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/net v0.0.0-20181220203305-927f97764cc3
+	golang.org/x/text v0.3.0 // indirect
 )
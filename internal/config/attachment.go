@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Attachment is a file rendered from message data, e.g. an ICS calendar invite.
+// Filename and body are text templates.
+type Attachment struct {
+	Filename    string `yaml:"filename"`
+	ContentType string `yaml:"content_type"`
+	Template    string `yaml:"template"`
+}
+
+// RenderedAttachment is Attachment rendered against message data.
+type RenderedAttachment struct {
+	Filename    string
+	ContentType string
+	Body        []byte
+}
+
+func (a *Attachment) parse() (*texttemplate.Template, *texttemplate.Template, error) {
+	funcs := texttemplate.FuncMap(templateFuncs())
+
+	filename, err := texttemplate.New("filename").Funcs(funcs).Option("missingkey=error").Parse(a.Filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("filename: %s", err.Error())
+	}
+
+	body, err := texttemplate.New("body").Funcs(funcs).Parse(a.Template)
+	if err != nil {
+		return nil, nil, fmt.Errorf("template: %s", err.Error())
+	}
+
+	return filename, body, nil
+}
+
+// Render renders filename and body of the attachment against data.
+// Filename must render to a base name without line breaks, missing keys fail it.
+func (a *Attachment) Render(data interface{}) (RenderedAttachment, error) {
+	filenameTpl, bodyTpl, err := a.parse()
+	if err != nil {
+		return RenderedAttachment{}, err
+	}
+
+	flag := texttemplate.FuncMap{"flag": flagFunc(data)}
+	filenameTpl.Funcs(flag)
+	bodyTpl.Funcs(flag)
+
+	buff := new(bytes.Buffer)
+	if err := filenameTpl.Execute(buff, data); err != nil {
+		return RenderedAttachment{}, err
+	}
+
+	filename := strings.TrimSpace(buff.String())
+	if err := validFilename(filename); err != nil {
+		return RenderedAttachment{}, err
+	}
+
+	body := new(bytes.Buffer)
+	if err := bodyTpl.Execute(body, data); err != nil {
+		return RenderedAttachment{}, err
+	}
+
+	return RenderedAttachment{
+		Filename:    filename,
+		ContentType: a.ContentType,
+		Body:        body.Bytes(),
+	}, nil
+}
+
+// validFilename accepts base names without line breaks and quotes.
+func validFilename(filename string) error {
+	if filename == "" || strings.ContainsAny(filename, "\r\n\"/\\") || path.Base(filename) != filename {
+		return fmt.Errorf("attachment filename %q is not valid", filename)
+	}
+
+	return nil
+}
+
+// RenderAttachments renders attachments of the event against data.
+func (e *Event) RenderAttachments(data interface{}) ([]RenderedAttachment, error) {
+	var attachments []RenderedAttachment
+
+	for i := range e.Attachments {
+		attachment, err := e.Attachments[i].Render(data)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %d: %s", i, err.Error())
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+func validateAttachments(attachments []Attachment) error {
+	for i, attachment := range attachments {
+		if strings.TrimSpace(attachment.Filename) == "" {
+			return fmt.Errorf("attachment %d: filename is empty", i)
+		}
+
+		if _, _, err := mime.ParseMediaType(attachment.ContentType); err != nil {
+			return fmt.Errorf("attachment %d: content type %q: %s", i, attachment.ContentType, err.Error())
+		}
+
+		if _, _, err := attachment.parse(); err != nil {
+			return fmt.Errorf("attachment %d: %s", i, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func icsAttachment() Attachment {
+	return Attachment{
+		Filename:    "{{ .record.id }}.ics",
+		ContentType: "text/calendar; charset=utf-8",
+		Template: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:{{ .record.id }}@postmaster\r\n" +
+			"SUMMARY:{{ .record.title }}\r\nDTSTART:{{ .record.starts_at }}\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	}
+}
+
+func TestEvent_RenderAttachments(t *testing.T) {
+	data := map[string]interface{}{
+		"record": map[string]interface{}{
+			"id":        "meetup-42",
+			"title":     "Trading <basics>",
+			"starts_at": "20261020T180000Z",
+		},
+	}
+
+	t.Run("ics invite", func(t *testing.T) {
+		event := Event{Attachments: []Attachment{icsAttachment()}}
+
+		attachments, err := event.RenderAttachments(data)
+		assert.NoError(t, err)
+		assert.Equal(t, []RenderedAttachment{{
+			Filename:    "meetup-42.ics",
+			ContentType: "text/calendar; charset=utf-8",
+			Body: []byte("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:meetup-42@postmaster\r\n" +
+				"SUMMARY:Trading <basics>\r\nDTSTART:20261020T180000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"),
+		}}, attachments)
+	})
+
+	t.Run("no attachments", func(t *testing.T) {
+		attachments, err := (&Event{}).RenderAttachments(data)
+		assert.NoError(t, err)
+		assert.Empty(t, attachments)
+	})
+
+	t.Run("invalid filename", func(t *testing.T) {
+		for _, filename := range []string{"{{ .missing }}", "../{{ .record.id }}.ics", "a\r\nBcc: x.ics", `a".ics`} {
+			attachment := icsAttachment()
+			attachment.Filename = filename
+			event := Event{Attachments: []Attachment{attachment}}
+
+			_, err := event.RenderAttachments(data)
+			assert.Error(t, err, filename)
+		}
+	})
+}
+
+func TestValidate_Attachments(t *testing.T) {
+	load := func(attachment Attachment) error {
+		conf := SampleConfig()
+		conf.Events[0].Attachments = []Attachment{attachment}
+
+		configAsBytes, err := yaml.Marshal(conf)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		return err
+	}
+
+	assert.NoError(t, load(icsAttachment()))
+
+	attachment := icsAttachment()
+	attachment.Filename = " "
+	assert.EqualError(t, load(attachment), "event \"Example\": attachment 0: filename is empty")
+
+	attachment = icsAttachment()
+	attachment.ContentType = ""
+	assert.EqualError(t, load(attachment), "event \"Example\": attachment 0: content type \"\": mime: no media type")
+
+	attachment = icsAttachment()
+	attachment.Template = "{{ .record.id "
+	assert.Error(t, load(attachment))
+}
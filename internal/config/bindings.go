@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// bindingWarnings cross-checks event keys against bindings of exchange, when
+// they're configured. Exchange is direct, so binding matches equal key only.
+func bindingWarnings(conf Config) []Warning {
+	if len(conf.AMQP.Bindings) == 0 {
+		return nil
+	}
+
+	bound := map[string]bool{}
+	for _, binding := range conf.AMQP.Bindings {
+		bound[binding] = true
+	}
+
+	keys := make([]string, 0, len(conf.Events))
+	handled := map[string]bool{}
+	for _, event := range conf.Events {
+		keys = append(keys, event.Key)
+		handled[event.Key] = true
+	}
+
+	var result []Warning
+
+	for _, event := range conf.Events {
+		if !bound[event.Key] {
+			result = append(result, Warning{"binding", fmt.Sprintf("event \"%s\" key \"%s\" doesn't match any of bindings %s",
+				event.Name, event.Key, strings.Join(conf.AMQP.Bindings, ", "))})
+		}
+	}
+
+	for _, binding := range conf.AMQP.Bindings {
+		if !handled[binding] {
+			result = append(result, Warning{"binding", fmt.Sprintf("binding \"%s\" doesn't match any of event keys %s",
+				binding, strings.Join(keys, ", "))})
+		}
+	}
+
+	return result
+}
+
+func validateBindings(bindings []string) error {
+	seen := map[string]bool{}
+
+	for _, binding := range bindings {
+		if strings.TrimSpace(binding) == "" {
+			return errors.New("binding should not be empty")
+		}
+
+		if seen[binding] {
+			return fmt.Errorf("binding \"%s\" is duplicated", binding)
+		}
+		seen[binding] = true
+	}
+
+	return nil
+}
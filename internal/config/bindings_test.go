@@ -0,0 +1,60 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindingWarnings(t *testing.T) {
+	load := func(conf Config) ([]Warning, error) {
+		configAsBytes, err := yaml.Marshal(conf)
+		assert.NoError(t, err)
+
+		_, warnings, err := Load(bytes.NewReader(configAsBytes), LoadOptions{})
+		return warnings, err
+	}
+
+	t.Run("unchecked without bindings", func(t *testing.T) {
+		warnings, err := load(SampleConfig())
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("matching bindings", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.AMQP.Bindings = []string{"example"}
+
+		warnings, err := load(tmp)
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("mismatch names both sides", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.AMQP.Bindings = []string{"user.signup", "user.login"}
+
+		warnings, err := load(tmp)
+		assert.NoError(t, err)
+		assert.Equal(t, []Warning{
+			{"binding", "event \"Example\" key \"example\" doesn't match any of bindings user.signup, user.login"},
+			{"binding", "binding \"user.signup\" doesn't match any of event keys example"},
+			{"binding", "binding \"user.login\" doesn't match any of event keys example"},
+		}, warnings)
+	})
+
+	t.Run("invalid bindings", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.AMQP.Bindings = []string{"example", " "}
+
+		_, err := load(tmp)
+		assert.EqualError(t, err, "binding should not be empty")
+
+		tmp.AMQP.Bindings = []string{"example", "example"}
+
+		_, err = load(tmp)
+		assert.EqualError(t, err, "binding \"example\" is duplicated")
+	})
+}
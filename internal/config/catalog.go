@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// catalog holds strings of "t" template func in language template is
+// rendered in, and strings of the default language.
+type catalog struct {
+	strings  map[string]string
+	fallback map[string]string
+}
+
+// lookup returns string of key, falling back to the default language.
+func (c catalog) lookup(key string) (string, error) {
+	if str, ok := c.strings[key]; ok {
+		return str, nil
+	}
+
+	if str, ok := c.fallback[key]; ok {
+		return str, nil
+	}
+
+	return "", fmt.Errorf("catalog string \"%s\" is not defined", key)
+}
+
+// catalog returns catalog of language.
+func (c *Config) catalog(lang string) catalog {
+	return catalog{strings: c.Catalogs[strings.ToUpper(lang)], fallback: c.Catalogs[c.DefaultLanguage]}
+}
+
+// renderFuncs binds template funcs to rendered data and language of template.
+func (t *Template) renderFuncs(data interface{}) template.FuncMap {
+	return template.FuncMap{"flag": flagFunc(data), "t": t.catalog.lookup}
+}
+
+// catalogRefs collects keys passed to "t" as string literals in every part of template.
+func (t *Template) catalogRefs() ([]string, error) {
+	parts, err := t.parts()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, part := range parts {
+		for _, tree := range part.trees {
+			if tree != nil {
+				refs = funcRefs(tree.Root, "t", refs)
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+// eventTemplates returns every template of event: base, tenant and rollout ones.
+func eventTemplates(event Event) []Template {
+	var templates []Template
+	for _, tpl := range event.Templates {
+		templates = append(templates, tpl)
+	}
+
+	for _, overrides := range event.Tenants {
+		for _, tpl := range overrides {
+			templates = append(templates, tpl)
+		}
+	}
+
+	if event.Rollout != nil {
+		for _, tpl := range event.Rollout.Templates {
+			templates = append(templates, tpl)
+		}
+	}
+
+	return templates
+}
+
+// validateCatalogs checks catalogs belong to configured languages and strings
+// referenced by templates are defined in every enabled language.
+func validateCatalogs(conf Config) error {
+	for lang := range conf.Catalogs {
+		if lang != strings.ToUpper(lang) {
+			return fmt.Errorf("catalog language \"%s\" should be uppercased", lang)
+		}
+
+		if !conf.ContainsLanguage(lang) {
+			return fmt.Errorf("catalog language \"%s\" is not configured", lang)
+		}
+	}
+
+	for _, event := range conf.Events {
+		keys := map[string]bool{}
+		for _, tpl := range eventTemplates(event) {
+			// Broken templates are reported with their language by validate.
+			refs, _ := tpl.catalogRefs()
+			for _, key := range refs {
+				keys[key] = true
+			}
+		}
+
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			for _, lang := range conf.Languages {
+				if _, ok := conf.Catalogs[lang.Code][key]; !ok && !lang.Disabled {
+					return fmt.Errorf("event \"%s\": catalog string \"%s\" is missing in language %s", event.Name, key, lang.Code)
+				}
+			}
+		}
+	}
+
+	return nil
+}
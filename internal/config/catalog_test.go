@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func catalogConfig() Config {
+	conf := SampleConfig()
+	conf.Languages = append(conf.Languages, Language{Code: "DE", Name: "German"})
+	conf.Catalogs = map[string]map[string]string{
+		"EN": {"cta.upgrade": "Upgrade now", "cta.help": "Get help"},
+		"DE": {"cta.upgrade": "Jetzt upgraden", "cta.help": "Hilfe"},
+	}
+
+	shared := Template{
+		Subject:   "{{ t \"cta.help\" }}",
+		Template:  "<a>{{ t \"cta.upgrade\" }}</a>",
+		Text:      "{{ t \"cta.upgrade\" }}",
+		Preheader: "{{ t \"cta.help\" }}",
+	}
+	conf.Events[0].Templates = map[string]Template{"EN": shared, "DE": shared}
+
+	return conf
+}
+
+func TestTemplate_Catalog(t *testing.T) {
+	conf := catalogConfig()
+	conf.DefaultLanguage = "EN"
+
+	t.Run("renders one template in two languages", func(t *testing.T) {
+		en, _ := conf.ResolveTemplate(&conf.Events[0], "", "EN", "", "")
+		de, _ := conf.ResolveTemplate(&conf.Events[0], "", "DE", "", "")
+
+		content, err := en.Content(nil)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "<a>Upgrade now</a>")
+
+		content, err = de.Content(nil)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "<a>Jetzt upgraden</a>")
+		assert.Contains(t, string(content), ">Hilfe</div>")
+
+		text, err := de.TextContent(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "Jetzt upgraden", string(text))
+
+		subject, err := de.RenderSubject(nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hilfe", subject)
+	})
+
+	t.Run("falls back to the default language", func(t *testing.T) {
+		delete(conf.Catalogs["DE"], "cta.help")
+		defer func() { conf.Catalogs["DE"]["cta.help"] = "Hilfe" }()
+
+		de, _ := conf.ResolveTemplate(&conf.Events[0], "", "DE", "", "")
+		subject, err := de.RenderSubject(nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "Get help", subject)
+	})
+
+	t.Run("fails on undefined string", func(t *testing.T) {
+		tpl := Template{Subject: "Example", Template: "{{ t \"missing\" }}"}
+		_, err := tpl.Content(nil)
+		assert.Contains(t, err.Error(), "catalog string \"missing\" is not defined")
+	})
+}
+
+func TestValidateCatalogs(t *testing.T) {
+	cases := map[string]func(conf *Config){
+		"": func(conf *Config) {},
+		"event \"Example\": catalog string \"cta.help\" is missing in language DE": func(conf *Config) {
+			delete(conf.Catalogs["DE"], "cta.help")
+		},
+		"event \"Example\": catalog string \"cta.help\" is missing in language EN": func(conf *Config) {
+			conf.Catalogs = nil
+		},
+		"catalog language \"FR\" is not configured": func(conf *Config) {
+			conf.Catalogs["FR"] = map[string]string{}
+		},
+		"catalog language \"de\" should be uppercased": func(conf *Config) {
+			conf.Catalogs["de"] = conf.Catalogs["DE"]
+			delete(conf.Catalogs, "DE")
+		},
+	}
+
+	for expected, change := range cases {
+		change := change
+		t.Run(expected, func(t *testing.T) {
+			conf := catalogConfig()
+			change(&conf)
+
+			configAsBytes, err := yaml.Marshal(conf)
+			assert.NoError(t, err)
+
+			_, err = Validate(bytes.NewReader(configAsBytes))
+			if expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, expected)
+		})
+	}
+
+	t.Run("skips disabled languages", func(t *testing.T) {
+		conf := catalogConfig()
+		conf.Languages[1].Disabled = true
+		conf.Catalogs["DE"] = map[string]string{}
+
+		assert.NoError(t, validateCatalogs(conf))
+	})
+}
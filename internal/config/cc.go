@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+func ccTemplate(i int, entry string) (*texttemplate.Template, error) {
+	return texttemplate.New(fmt.Sprintf("cc %d", i)).Funcs(texttemplate.FuncMap(templateFuncs())).Parse(entry)
+}
+
+// RenderCC renders CC addresses of the event against data.
+// Entries rendered empty are dropped, e.g. "{{ with .manager }}{{ .email }}{{ end }}".
+func (e *Event) RenderCC(data interface{}) ([]string, error) {
+	return e.renderCC(data, true)
+}
+
+// RenderCCUnchecked is RenderCC keeping addresses, which don't parse, as rendered,
+// so recipients sent separately are rejected one by one, see PerRecipient.
+func (e *Event) RenderCCUnchecked(data interface{}) ([]string, error) {
+	return e.renderCC(data, false)
+}
+
+func (e *Event) renderCC(data interface{}, strict bool) ([]string, error) {
+	var addresses []string
+
+	for i, entry := range e.CC {
+		tpl, err := ccTemplate(i, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		buff := new(bytes.Buffer)
+		if err := tpl.Funcs(texttemplate.FuncMap{"flag": flagFunc(data)}).Execute(buff, data); err != nil {
+			return nil, err
+		}
+
+		rendered := strings.TrimSpace(buff.String())
+		if rendered == "" {
+			continue
+		}
+
+		address, err := mail.ParseAddress(rendered)
+		if err != nil && !strict {
+			addresses = append(addresses, rendered)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cc address %q: %s", rendered, err.Error())
+		}
+
+		addresses = append(addresses, address.Address)
+	}
+
+	return addresses, nil
+}
+
+func validateCC(entries []string) error {
+	for i, entry := range entries {
+		tpl, err := ccTemplate(i, entry)
+		if err != nil {
+			return err
+		}
+
+		// Entries without actions are validated right away.
+		if len(tpl.Tree.Root.Nodes) == 1 && tpl.Tree.Root.Nodes[0].Type() == parse.NodeText {
+			if _, err := mail.ParseAddress(strings.TrimSpace(entry)); err != nil {
+				return fmt.Errorf("cc address %q: %s", entry, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
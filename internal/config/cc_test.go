@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_RenderCC(t *testing.T) {
+	data := map[string]interface{}{
+		"user": map[string]interface{}{
+			"manager": map[string]interface{}{"email": "boss@example.com"},
+			"deputy":  map[string]interface{}{"email": ""},
+		},
+	}
+
+	t.Run("renders address from nested data", func(t *testing.T) {
+		event := Event{CC: []string{"{{ .user.manager.email }}", "audit@example.com"}}
+
+		cc, err := event.RenderCC(data)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"boss@example.com", "audit@example.com"}, cc)
+	})
+
+	t.Run("skips empty results", func(t *testing.T) {
+		event := Event{CC: []string{"{{ .user.deputy.email }}", "{{ with .user.assistant }}{{ .email }}{{ end }}"}}
+
+		cc, err := event.RenderCC(data)
+		assert.NoError(t, err)
+		assert.Empty(t, cc)
+	})
+
+	t.Run("rejects invalid address", func(t *testing.T) {
+		event := Event{CC: []string{"{{ .user.manager.email }}\r\nBcc: x@example.com"}}
+
+		_, err := event.RenderCC(data)
+		assert.Error(t, err)
+	})
+
+	t.Run("keeps invalid address unchecked", func(t *testing.T) {
+		event := Event{CC: []string{"{{ .user.manager.email }}", "boss@", "{{ .user.deputy.email }}"}}
+
+		cc, err := event.RenderCCUnchecked(data)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"boss@example.com", "boss@"}, cc)
+	})
+}
+
+func TestValidate_CC(t *testing.T) {
+	cases := map[string]struct {
+		cc  []string
+		err string
+	}{
+		"broken template": {
+			[]string{"{{ .user.email"},
+			"event \"Example\": template: cc 0:1: unclosed action",
+		},
+		"invalid static address": {
+			[]string{"not an address"},
+			"event \"Example\": cc address \"not an address\": mail: no angle-addr",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tmp := SampleConfig()
+			tmp.Events[0].CC = tc.cc
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			_, err = Validate(bytes.NewReader(configAsBytes))
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+
+	t.Run("templated and static addresses", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].CC = []string{"{{ .user.manager }}", "Audit <audit@example.com>"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+		assert.NoError(t, err)
+		assert.True(t, res)
+	})
+}
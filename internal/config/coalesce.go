@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// CoalesceWindow returns how long messages of event are accumulated per
+// recipient before they are sent as one digest, zero when they aren't.
+func (e *Event) CoalesceWindow() time.Duration {
+	window, err := time.ParseDuration(e.Coalesce)
+	if err != nil {
+		return 0
+	}
+
+	return window
+}
+
+func validateCoalesce(window string) error {
+	if window == "" {
+		return nil
+	}
+
+	if d, err := time.ParseDuration(window); err != nil || d <= 0 {
+		return fmt.Errorf("coalesce window \"%s\" is not valid", window)
+	}
+
+	return nil
+}
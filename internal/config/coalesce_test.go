@@ -0,0 +1,36 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-yaml/yaml"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_CoalesceWindow(t *testing.T) {
+	assert.Equal(t, time.Duration(0), (&Event{}).CoalesceWindow())
+	assert.Equal(t, 90*time.Second, (&Event{Coalesce: "1m30s"}).CoalesceWindow())
+}
+
+func TestValidateCoalesce(t *testing.T) {
+	assert.NoError(t, validateCoalesce(""))
+	assert.NoError(t, validateCoalesce("5m"))
+
+	for _, window := range []string{"5", "soon", "0s", "-1m"} {
+		assert.EqualError(t, validateCoalesce(window), "coalesce window \""+window+"\" is not valid")
+	}
+}
+
+func TestValidate_Prefetch(t *testing.T) {
+	tmp := SampleConfig()
+	tmp.Events[0].Prefetch = -1
+
+	configAsBytes, err := yaml.Marshal(tmp)
+	assert.NoError(t, err)
+
+	_, err = Validate(bytes.NewReader(configAsBytes))
+	assert.EqualError(t, err, "event \"Example\": prefetch should not be negative")
+}
@@ -2,11 +2,17 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"html/template"
 	"io"
+	"io/ioutil"
 	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+	"time"
 
 	"github.com/go-yaml/yaml"
 )
@@ -15,23 +21,133 @@ import (
 type AMQP struct {
 	Exchange string `yaml:"exchange"`
 	Tag      string `yaml:"tag"`
+	// Message headers starting with this prefix are copied onto the email.
+	HeadersPrefix string `yaml:"headers_prefix,omitempty"`
+	// Messages failed to process are published to this exchange.
+	DeadLetterExchange string `yaml:"dead_letter_exchange,omitempty"`
+	// Depth of this queue is reported in metrics.
+	Queue string `yaml:"queue,omitempty"`
+	// Number of messages processed in parallel per event.
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// Either AckModeManual or AckModeAuto.
+	AckMode string `yaml:"ack_mode,omitempty"`
+	// Format of message body, either "json" or "msgpack".
+	PayloadFormat string `yaml:"payload_format,omitempty"`
+	// Dot separated path of object in payload, e.g. "data", templates are rendered
+	// with, see PayloadData. Templates get whole payload, when empty.
+	DataPath string `yaml:"data_path,omitempty"`
+	// Temporarily failed messages are retried through delayed exchange, when set.
+	Retry *Retry `yaml:"retry,omitempty"`
+	// Routing keys bound on exchange by publishers, event keys are checked
+	// against when set, see Warning.
+	Bindings []string `yaml:"bindings,omitempty"`
+	// Broker isn't consumed, e.g. when events come from Kafka only.
+	Disabled bool `yaml:"disabled,omitempty"`
 }
 
+// Modes of message acknowledgement.
+const (
+	// AckModeManual acknowledges message once it's sent or dead-lettered.
+	AckModeManual = "manual"
+	// AckModeAuto makes broker treat message acknowledged once it's delivered
+	// to postmaster, trading safety for throughput.
+	AckModeAuto = "auto"
+)
+
 type Language struct {
-	Code string `yaml:"code"`
-	Name string `yaml:"name"`
+	Code     string `yaml:"code"`
+	Name     string `yaml:"name"`
+	Disabled bool   `yaml:"disabled,omitempty"`
+	// Language, whose templates are used when this one has none.
+	Fallback string `yaml:"fallback,omitempty"`
+	// Sender identity of emails in the language, default one is used when nil.
+	Sender *Identity `yaml:"sender,omitempty"`
+	// IANA time zone of recipients in the language, e.g. "Europe/Berlin". UTC when empty.
+	TimeZone string `yaml:"time_zone,omitempty"`
 }
 
 type Template struct {
 	Subject      string `yaml:"subject"`
 	TemplatePath string `yaml:"template_path,omitempty"`
 	Template     string `yaml:"template,omitempty"`
+	// Item is rendered per entry of digest, template is the shell
+	// around them, see RenderDigest.
+	Item string `yaml:"item,omitempty"`
+	// Plain text variant, either inline or path to file.
+	Text     string `yaml:"text,omitempty"`
+	TextPath string `yaml:"text_path,omitempty"`
+	// HTML and plain text variants in one field each, either inline or path
+	// to file with template extension, e.g. "templates/welcome.html".
+	// They are expanded into the fields above at load, see expandVariants.
+	HTMLTemplate string `yaml:"html_template,omitempty"`
+	TextTemplate string `yaml:"text_template,omitempty"`
+	// Preheader is inbox preview text, injected hidden at the top of HTML body.
+	Preheader string `yaml:"preheader,omitempty"`
+	// ProviderTemplateID references template hosted by provider of event sender,
+	// which is given the data instead of rendered body.
+	ProviderTemplateID string `yaml:"provider_template_id,omitempty"`
+
+	// Strings of "t" func in resolved language, see ResolveTemplate.
+	catalog catalog
+	// Partials and parsed templates of loaded config, see Config.Partials.
+	registry *registry
+}
+
+// BaseVersion names the version of event templates when rollout isn't in effect.
+const BaseVersion = "base"
+
+// Rollout gradually replaces event templates with a new version,
+// sending it to the given percent of recipients.
+type Rollout struct {
+	Version   string              `yaml:"version"`
+	Percent   int                 `yaml:"percent"`
+	Templates map[string]Template `yaml:"templates"`
 }
 
 type Event struct {
 	Name      string              `yaml:"name"`
 	Key       string              `yaml:"key"`
 	Templates map[string]Template `yaml:"templates"`
+	Rollout   *Rollout            `yaml:"rollout,omitempty"`
+	// Disabled events are kept in config, but their messages are ignored.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Name of sender delivering emails of the event, default one is used when empty.
+	Sender string `yaml:"sender,omitempty"`
+	// Templates of addresses copied on every email of the event, see RenderCC.
+	CC []string `yaml:"cc,omitempty"`
+	// Static headers added to every email of the event.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Template overrides keyed by tenant, then like Templates.
+	Tenants map[string]map[string]Template `yaml:"tenants,omitempty"`
+	// Files generated from message data and attached to every email of the event.
+	Attachments []Attachment `yaml:"attachments,omitempty"`
+	// Files carried by messages are attached as well, when set, see PayloadAttachments.
+	PayloadAttachments *PayloadAttachments `yaml:"payload_attachments,omitempty"`
+	// Non-urgent emails of the event are deferred during quiet hours.
+	QuietHours *QuietHours `yaml:"quiet_hours,omitempty"`
+	// Recipients, CC included, must be in one of these domains, when set.
+	AllowedDomains []string `yaml:"allowed_domains,omitempty"`
+	// Messages for the same recipient within the window, e.g. "1m",
+	// are sent as one digest, see CoalesceWindow.
+	Coalesce string `yaml:"coalesce,omitempty"`
+	// Prefetch is how many messages of the event broker delivers unacknowledged
+	// at once, amqp.concurrency by default. Coalesced messages count against it.
+	Prefetch int `yaml:"prefetch,omitempty"`
+	// Recipients, CC included, are sent separately, when set, see PerRecipientAny.
+	PerRecipient string `yaml:"per_recipient,omitempty"`
+	// Retry of temporarily failed messages, overriding amqp.retry, see Config.RetryOf.
+	Retry *Retry `yaml:"retry,omitempty"`
+	// Post-processors of HTML body, replacing global ones, see ProcessHTML.
+	PostProcess []string `yaml:"post_process,omitempty"`
+	// Links of HTML body are rewritten to tracking redirects, see Config.TrackLinks.
+	LinkTracking bool `yaml:"link_tracking,omitempty"`
+	// HTML body gets tracking pixel, see Config.TrackOpens.
+	OpenTracking bool `yaml:"open_tracking,omitempty"`
+	// Default render data of the event, see RenderData.
+	Data map[string]interface{} `yaml:"data,omitempty"`
+	// Sample data for previews, either inline JSON or path to JSON file.
+	Preview     string `yaml:"preview_data,omitempty"`
+	PreviewPath string `yaml:"preview_data_path,omitempty"`
 }
 
 // General application configuration.
@@ -39,33 +155,340 @@ type Config struct {
 	AMQP      AMQP       `yaml:"amqp"`
 	Languages []Language `yaml:"languages"`
 	Events    []Event    `yaml:"events"`
+	// Named snippets available to subjects via {{ frag "name" }}.
+	Subjects map[string]string `yaml:"subjects,omitempty"`
+	// Sender display name per language code.
+	FromNames map[string]string `yaml:"from_names,omitempty"`
+	// Named senders, which events can deliver through.
+	Senders map[string]Sender `yaml:"senders,omitempty"`
+	// Delivers emails of events without sender instead of SMTP server of environment.
+	Delivery *Sender `yaml:"delivery,omitempty"`
+	// Language of events, which omit it.
+	DefaultLanguage string `yaml:"default_language,omitempty"`
+	// Content type and charset of email body.
+	ContentType string `yaml:"content_type,omitempty"`
+	Charset     string `yaml:"charset,omitempty"`
+	// Messages addressing more recipients, CC included, are rejected. Zero is unlimited.
+	MaxRecipients int `yaml:"max_recipients,omitempty"`
+	// StrictVariants requires all languages of an event to have the same
+	// template variants, e.g. both html and text.
+	StrictVariants bool `yaml:"strict_variants,omitempty"`
+	// ValidateHTML fails messages, whose rendered HTML has unbalanced tags, see ValidateHTML.
+	ValidateHTML bool `yaml:"validate_html,omitempty"`
+	// Rendering of a single email taking longer, e.g. "2s", fails permanently,
+	// see RenderBudget. Unlimited when empty.
+	RenderTimeout string `yaml:"render_timeout,omitempty"`
+	// RateLimit paces sends per recipient domain, unlimited when nil.
+	RateLimit *RateLimit `yaml:"rate_limit,omitempty"`
+	// Render data shared by all events and render data per tenant, see RenderData.
+	Data       map[string]interface{}            `yaml:"data,omitempty"`
+	TenantData map[string]map[string]interface{} `yaml:"tenant_data,omitempty"`
+	// DKIM signs emails sent through SMTP, unsigned when nil.
+	DKIM *DKIM `yaml:"dkim,omitempty"`
+	// Strings looked up by {{ t "key" }}, keyed by language, then by key.
+	Catalogs map[string]map[string]string `yaml:"catalogs,omitempty"`
+	// Names of registered post-processors run over HTML body of every email in order,
+	// see RegisterPostProcessor.
+	PostProcess []string `yaml:"post_process,omitempty"`
+	// Files of templates and preview data must resolve inside TemplateRoot,
+	// when set, so config can't read arbitrary files.
+	TemplateRoot string `yaml:"template_root,omitempty"`
+	// Tracking of messages, which events opt in, off when nil.
+	Tracking *Tracking `yaml:"tracking,omitempty"`
+	// Serves metrics and health checks, when set.
+	Monitoring *Monitoring `yaml:"monitoring,omitempty"`
+	// Recipients, which bounced or complained, are skipped, when set.
+	Suppression *Suppression `yaml:"suppression,omitempty"`
+	// Consumes events from Kafka as well, when set.
+	Kafka *Kafka `yaml:"kafka,omitempty"`
+
+	// Directory of partials and layouts included by templates, e.g. {{ template "footer" . }}.
+	Partials string `yaml:"partials,omitempty"`
+
+	// Runtime template overrides, see Override.
+	overrides map[overrideKey]Template
+	// Parses templates once, see registry.
+	registry *registry
+}
+
+// Sender is SMTP server or provider API of a named sender.
+type Sender struct {
+	// Either ProviderSMTP, when empty, ProviderSES, ProviderSendGrid or ProviderMailgun.
+	Provider string `yaml:"provider,omitempty"`
+	// Address of SMTP server.
+	Host string `yaml:"host,omitempty"`
+	Port string `yaml:"port,omitempty"`
+	// AWS region of SES, e.g. "eu-west-1", or Mailgun region, MailgunUS or MailgunEU.
+	Region string `yaml:"region,omitempty"`
+	// Sending domain of Mailgun.
+	Domain string `yaml:"domain,omitempty"`
+	// SMTP username, or access key id of SES.
+	Username string `yaml:"username,omitempty"`
+	// Password must be a secret reference, e.g. "secret://smtp/bulk",
+	// plaintext passwords are kept in environment instead.
+	// It's the secret access key of SES and API key of SendGrid and Mailgun.
+	Password string `yaml:"password,omitempty"`
+	// Name of environment variable holding the password.
+	PasswordEnv string `yaml:"password_env,omitempty"`
 }
 
+// SecretScheme prefixes credentials resolved by consumer, e.g. "secret://smtp/password".
+const SecretScheme = "secret://"
+
+// DefaultTemplate keys event template used when no language template matches.
+const DefaultTemplate = "DEFAULT"
+
+// TemplateKey builds template key from language code and optional recipient segment.
+func TemplateKey(lang, segment string) string {
+	if strings.TrimSpace(segment) == "" {
+		return strings.ToUpper(lang)
+	}
+
+	return strings.ToUpper(lang) + ":" + strings.ToLower(segment)
+}
+
+// canonicalKey brings "lang:SEGMENT" key to the "LANG:segment" form.
+func canonicalKey(key string) string {
+	parts := strings.SplitN(key, ":", 2)
+	if len(parts) == 1 {
+		return strings.ToUpper(key)
+	}
+
+	return TemplateKey(parts[0], parts[1])
+}
+
+// lookupTemplate resolves template key most-specific-first:
+// "LANG:segment", then "LANG", then DefaultTemplate.
+func lookupTemplate(templates map[string]Template, key string) (Template, bool) {
+	if tpl, ok := languageTemplate(templates, key); ok {
+		return tpl, true
+	}
+
+	tpl, ok := templates[DefaultTemplate]
+	return tpl, ok
+}
+
+// languageTemplate is lookupTemplate without DefaultTemplate.
+func languageTemplate(templates map[string]Template, key string) (Template, bool) {
+	key = canonicalKey(key)
+	if tpl, ok := templates[key]; ok {
+		return tpl, true
+	}
+
+	if i := strings.Index(key, ":"); i != -1 {
+		if tpl, ok := templates[key[:i]]; ok {
+			return tpl, true
+		}
+	}
+
+	return Template{}, false
+}
+
+// Template returns template for key, built with TemplateKey.
+// See lookupTemplate for resolution order.
 func (e *Event) Template(key string) Template {
-	return e.Templates[strings.ToUpper(key)]
+	tpl, _ := lookupTemplate(e.Templates, key)
+	return tpl
 }
 
-func (t *Template) Content(data interface{}) ([]byte, error) {
-	var err error
+// PreviewData returns sample data of the event, if any.
+func (e *Event) PreviewData() (map[string]interface{}, error) {
+	content := []byte(e.Preview)
 
-	buff := new(bytes.Buffer)
-	tpl := new(template.Template)
+	if strings.TrimSpace(e.PreviewPath) != "" {
+		var err error
+		if content, err = ioutil.ReadFile(e.PreviewPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, nil
+	}
 
-	if strings.TrimSpace(t.Template) != "" {
-		tpl, err = template.New(t.Subject).Parse(t.Template)
-	} else {
-		tpl, err = template.ParseFiles(t.TemplatePath)
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, err
 	}
 
+	return data, nil
+}
+
+// TemplateFor picks template version for recipient during rollout.
+// The choice is based on recipient hash, so it's stable between messages.
+// Languages missing from rollout always get the base version.
+func (e *Event) TemplateFor(key, recipient string) (Template, string) {
+	if e.Rollout != nil {
+		tpl, ok := lookupTemplate(e.Rollout.Templates, key)
+		if ok && rolloutBucket(e.Key, recipient) < e.Rollout.Percent {
+			return tpl, e.Rollout.Version
+		}
+	}
+
+	return e.Template(key), BaseVersion
+}
+
+// rolloutBucket maps recipient of the event into [0, 100).
+func rolloutBucket(eventKey, recipient string) int {
+	h := fnv.New32a()
+	h.Write([]byte(eventKey + ":" + strings.ToLower(recipient)))
+
+	return int(h.Sum32() % 100)
+}
+
+func (t *Template) Content(data interface{}) ([]byte, error) {
+	tpl, err := t.parse(templateFuncs())
 	if err != nil {
 		return nil, err
 	}
 
-	if err := tpl.Execute(buff, &data); err != nil {
+	buff := new(bytes.Buffer)
+	if err := tpl.Funcs(t.renderFuncs(data)).Execute(buff, &data); err != nil {
 		return nil, err
 	}
 
-	return buff.Bytes(), nil
+	return t.withPreheader(buff.Bytes(), data)
+}
+
+// parse parses either inline template or template file.
+func (t *Template) parse(funcs template.FuncMap) (*template.Template, error) {
+	return t.registry.parseHTML(variantKey(t.Subject, t.Template, t.TemplatePath, funcs), funcs)
+}
+
+// subjectTemplate parses subject with "frag" helper rendering named
+// fragments against the same data. Fragments can't include other fragments.
+func subjectTemplate(subject string, fragments map[string]string, data interface{}) (*texttemplate.Template, error) {
+	frag := func(name string) (string, error) {
+		fragment, ok := fragments[name]
+		if !ok {
+			return "", fmt.Errorf("subject fragment \"%s\" is not defined", name)
+		}
+
+		tpl, err := texttemplate.New(name).Parse(fragment)
+		if err != nil {
+			return "", err
+		}
+
+		buff := new(bytes.Buffer)
+		if err := tpl.Execute(buff, data); err != nil {
+			return "", err
+		}
+
+		return buff.String(), nil
+	}
+
+	return texttemplate.New("subject").
+		Funcs(texttemplate.FuncMap{"frag": frag, "flag": flagFunc(data), "t": catalog{}.lookup}).
+		Parse(subject)
+}
+
+// RenderSubject renders subject against data, resolving fragments by name.
+func (t *Template) RenderSubject(data interface{}, fragments map[string]string) (string, error) {
+	tpl, err := subjectTemplate(t.Subject, fragments, data)
+	if err != nil {
+		return "", err
+	}
+
+	buff := new(bytes.Buffer)
+	if err := tpl.Funcs(texttemplate.FuncMap{"t": t.catalog.lookup}).Execute(buff, data); err != nil {
+		return "", err
+	}
+
+	// Subject is written into email header, so data must not break out of it.
+	if strings.ContainsAny(buff.String(), "\r\n") {
+		return "", errors.New("rendered subject contains line break")
+	}
+
+	return buff.String(), nil
+}
+
+// funcRefs collects arguments passed to template func fn as string literals.
+func funcRefs(node parse.Node, fn string, refs []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return refs
+		}
+		for _, child := range n.Nodes {
+			refs = funcRefs(child, fn, refs)
+		}
+	case *parse.ActionNode:
+		refs = funcRefs(n.Pipe, fn, refs)
+	case *parse.IfNode:
+		refs = funcRefs(n.Pipe, fn, refs)
+		refs = funcRefs(n.List, fn, refs)
+		refs = funcRefs(n.ElseList, fn, refs)
+	case *parse.RangeNode:
+		refs = funcRefs(n.Pipe, fn, refs)
+		refs = funcRefs(n.List, fn, refs)
+		refs = funcRefs(n.ElseList, fn, refs)
+	case *parse.WithNode:
+		refs = funcRefs(n.Pipe, fn, refs)
+		refs = funcRefs(n.List, fn, refs)
+		refs = funcRefs(n.ElseList, fn, refs)
+	case *parse.PipeNode:
+		if n == nil {
+			return refs
+		}
+		for _, cmd := range n.Cmds {
+			refs = funcRefs(cmd, fn, refs)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) > 1 {
+			if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == fn {
+				if name, ok := n.Args[1].(*parse.StringNode); ok {
+					refs = append(refs, name.Text)
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			refs = funcRefs(arg, fn, refs)
+		}
+	}
+
+	return refs
+}
+
+// validateSubject parses subject and checks, that referenced fragments exist.
+func validateSubject(subject string, fragments map[string]string) error {
+	tpl, err := subjectTemplate(subject, fragments, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range funcRefs(tpl.Tree.Root, "frag", nil) {
+		if _, ok := fragments[name]; !ok {
+			return fmt.Errorf("subject fragment \"%s\" is not defined", name)
+		}
+	}
+
+	if strings.ContainsAny(subject, "\r\n") {
+		return errors.New("subject contains line break")
+	}
+
+	return nil
+}
+
+// Event returns enabled event by key.
+func (config *Config) Event(key string) (*Event, bool) {
+	for i := range config.Events {
+		if config.Events[i].Key == key && !config.Events[i].Disabled {
+			return &config.Events[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// LanguageDisabled reports whether language is configured, but disabled.
+func (config *Config) LanguageDisabled(code string) bool {
+	for _, lang := range config.Languages {
+		if strings.EqualFold(lang.Code, code) {
+			return lang.Disabled
+		}
+	}
+
+	return false
 }
 
 func (config *Config) ContainsLanguage(code string) bool {
@@ -78,6 +501,12 @@ func (config *Config) ContainsLanguage(code string) bool {
 	return false
 }
 
+// FromName returns localized sender name for language, if configured.
+func (config *Config) FromName(code string) (string, bool) {
+	name, ok := config.FromNames[strings.ToUpper(code)]
+	return name, ok && strings.TrimSpace(name) != ""
+}
+
 func (lang *Language) Valid() bool {
 	notEmpty := len(strings.TrimSpace(lang.Code)) != 0
 	isUp := lang.Code == strings.ToUpper(lang.Code)
@@ -85,6 +514,44 @@ func (lang *Language) Valid() bool {
 	return notEmpty && isUp
 }
 
+// ValidHeaderName reports whether name is a legal RFC 5322 header field name.
+func ValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, c := range name {
+		if c < 33 || c > 126 || c == ':' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Headers written by postmaster itself, which can't be set from config or message.
+var reservedHeaders = map[string]bool{
+	"from": true, "to": true, "cc": true, "bcc": true, "subject": true,
+	"content-type": true, "content-transfer-encoding": true, "mime-version": true,
+}
+
+// ValidHeader returns error, if header can't be safely written to email.
+func ValidHeader(name, value string) error {
+	if !ValidHeaderName(name) {
+		return fmt.Errorf("header \"%s\" is not a valid header name", name)
+	}
+
+	if reservedHeaders[strings.ToLower(name)] {
+		return fmt.Errorf("header \"%s\" is reserved", name)
+	}
+
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("header \"%s\" value contains line break", name)
+	}
+
+	return nil
+}
+
 func validateLanguages(conf Config) (bool, error) {
 	for _, lang := range conf.Languages {
 		if !lang.Valid() {
@@ -95,40 +562,498 @@ func validateLanguages(conf Config) (bool, error) {
 	return true, nil
 }
 
-// Validate configuration file.
-func Validate(r io.Reader) (bool, error) {
+// LoadOptions tweak the way configuration is decoded.
+type LoadOptions struct {
+	// Lenient ignores unknown fields instead of failing on them, which
+	// keeps configs written for newer versions loadable.
+	Lenient bool
+	// NormalizeLanguages upper-cases language codes and template keys
+	// instead of rejecting lower cased ones.
+	NormalizeLanguages bool
+	// LintTemplates rejects templates with likely-unintended
+	// trim markers, see LintTrimMarkers.
+	LintTemplates bool
+	// Kafka accepts the kafka section, which needs a Kafka client
+	// to be served, see consumer.WithKafkaDialer.
+	Kafka bool
+}
+
+func decode(r io.Reader, opts LoadOptions) (Config, error) {
 	conf := Config{}
 
-	if err := yaml.NewDecoder(r).Decode(&conf); err != nil {
-		return false, err
+	dec := yaml.NewDecoder(r)
+	dec.SetStrict(!opts.Lenient)
+
+	if err := dec.Decode(&conf); err != nil {
+		return conf, err
+	}
+
+	return conf, nil
+}
+
+// validateTemplate checks template body source and subject.
+// Validate checks template in isolation: either body or file is specified and
+// parses, subject parses and fits into header. Subject fragments are checked
+// against configuration by config Validate.
+func (t *Template) Validate() error {
+	if t.Hosted() {
+		return t.validateHosted()
+	}
+
+	strippedTpl := strings.TrimSpace(t.Template)
+	strippedTplPath := strings.TrimSpace(t.TemplatePath)
+
+	if strippedTpl != "" && strippedTplPath != "" {
+		return errors.New("template and template path is specified")
+	}
+
+	if strings.TrimSpace(t.Text) != "" && strings.TrimSpace(t.TextPath) != "" {
+		return errors.New("text and text path is specified")
+	}
+
+	if !t.HasHTML() && !t.HasText() {
+		return errors.New("neither template nor template path is specified")
+	}
+
+	if t.HasText() {
+		text, err := t.parseText()
+		if err != nil {
+			return fmt.Errorf("text: %s", err.Error())
+		}
+		if name := undefinedRef(text.Name(), textTrees(text)); name != "" {
+			return fmt.Errorf("text: template \"%s\" is not defined", name)
+		}
+	}
+
+	if !t.HasHTML() {
+		return t.validateSubject()
+	}
+
+	if _, err := t.parsePreheader(); err != nil {
+		return fmt.Errorf("preheader: %s", err.Error())
+	}
+
+	funcs := templateFuncs()
+	if t.Item != "" {
+		if _, err := template.New("item").Funcs(funcs).Parse(t.Item); err != nil {
+			return fmt.Errorf("digest item: %s", err.Error())
+		}
+
+		// Placeholder of rendered items, see RenderDigest.
+		funcs["items"] = func() template.HTML { return "" }
+	}
+
+	tpl, err := t.parse(funcs)
+	if err != nil {
+		return err
+	}
+
+	if cycle := templateCycle(tpl); cycle != nil {
+		return fmt.Errorf("template cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	if name := undefinedRef(tpl.Name(), htmlTrees(tpl)); name != "" {
+		return fmt.Errorf("template \"%s\" is not defined", name)
+	}
+
+	return t.validateSubject()
+}
+
+func (t *Template) validateSubject() error {
+	if _, err := subjectTemplate(t.Subject, nil, nil); err != nil {
+		return fmt.Errorf("subject: %s", err.Error())
+	}
+
+	if strings.ContainsAny(t.Subject, "\r\n") {
+		return errors.New("subject: subject contains line break")
+	}
+
+	return nil
+}
+
+func validateTemplate(tpl Template, fragments map[string]string) error {
+	if err := tpl.Validate(); err != nil {
+		return err
+	}
+
+	if err := validateSubject(tpl.Subject, fragments); err != nil {
+		return fmt.Errorf("subject: %s", err.Error())
+	}
+
+	return nil
+}
+
+func validate(conf Config) error {
+	if err := validateTemplateRoot(conf); err != nil {
+		return err
 	}
 
 	if _, err := validateLanguages(conf); err != nil {
-		return false, err
+		return err
+	}
+
+	if err := validateDefaults(conf); err != nil {
+		return err
+	}
+
+	if err := validateFallbacks(conf); err != nil {
+		return err
+	}
+
+	if err := validatePostProcessors(conf.PostProcess); err != nil {
+		return err
+	}
+
+	if err := validateTracking(conf); err != nil {
+		return err
+	}
+
+	if err := validateCatalogs(conf); err != nil {
+		return err
+	}
+
+	for _, lang := range conf.Languages {
+		if err := lang.Sender.validate(); err != nil {
+			return fmt.Errorf("sender of language \"%s\": %s", lang.Code, err.Error())
+		}
+
+		if _, err := time.LoadLocation(lang.TimeZone); err != nil {
+			return fmt.Errorf("time zone of language \"%s\": %s", lang.Code, err.Error())
+		}
+	}
+
+	for tenant := range conf.TenantData {
+		if strings.TrimSpace(tenant) == "" {
+			return errors.New("tenant name in tenant data is empty")
+		}
+	}
+
+	if err := conf.DKIM.validate(); err != nil {
+		return fmt.Errorf("dkim: %s", err.Error())
+	}
+
+	if err := conf.RateLimit.validate(); err != nil {
+		return fmt.Errorf("rate limit: %s", err.Error())
+	}
+
+	for name, sender := range conf.Senders {
+		if err := validateSender(fmt.Sprintf("sender \"%s\"", name), sender); err != nil {
+			return err
+		}
+	}
+
+	if conf.Delivery != nil {
+		if err := validateSender("delivery", *conf.Delivery); err != nil {
+			return err
+		}
+	}
+
+	if err := validateRetry(conf); err != nil {
+		return err
+	}
+
+	if err := validateMonitoring(conf.Monitoring); err != nil {
+		return err
+	}
+
+	if err := validateKafka(conf); err != nil {
+		return err
+	}
+
+	if err := validateSuppression(conf.Suppression); err != nil {
+		return err
+	}
+
+	if err := validateBindings(conf.AMQP.Bindings); err != nil {
+		return err
+	}
+
+	if prefix := conf.AMQP.HeadersPrefix; prefix != "" && !ValidHeaderName(prefix) {
+		return fmt.Errorf("headers prefix \"%s\" is not a valid header name", prefix)
+	}
+
+	for code, name := range conf.FromNames {
+		if code != strings.ToUpper(code) {
+			return fmt.Errorf("language \"%s\" in from names should be uppercased", code)
+		}
+
+		// Name goes into From header.
+		if strings.ContainsAny(name, "\r\n\"") {
+			return fmt.Errorf("from name for language \"%s\" should not contain line breaks or quotes", code)
+		}
+
+		if !conf.ContainsLanguage(code) {
+			return fmt.Errorf("language \"%s\" in from names is not configured", code)
+		}
+	}
+
+	if len(conf.FromNames) != 0 {
+		for _, lang := range conf.Languages {
+			if _, ok := conf.FromName(lang.Code); !ok {
+				return fmt.Errorf("from name for language \"%s\" is not defined", lang.Code)
+			}
+		}
+	}
+
+	for name, fragment := range conf.Subjects {
+		if _, err := texttemplate.New(name).Parse(fragment); err != nil {
+			return fmt.Errorf("subject fragment \"%s\": %s", name, err.Error())
+		}
+
+		if strings.ContainsAny(fragment, "\r\n") {
+			return fmt.Errorf("subject fragment \"%s\" contains line break", name)
+		}
 	}
 
 	for _, event := range conf.Events {
+		if strings.TrimSpace(event.Preview) != "" && strings.TrimSpace(event.PreviewPath) != "" {
+			return fmt.Errorf("preview data and preview data path in event \"%s\" is specified", event.Name)
+		}
+
+		for name, value := range event.Headers {
+			if err := ValidHeader(name, value); err != nil {
+				return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+			}
+		}
+
+		if _, ok := conf.Senders[event.Sender]; event.Sender != "" && !ok {
+			return fmt.Errorf("sender \"%s\" of event \"%s\" is not configured", event.Sender, event.Name)
+		}
+
+		if err := validateCC(event.CC); err != nil {
+			return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+		}
+
+		if err := validateAttachments(event.Attachments); err != nil {
+			return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+		}
+
+		if err := event.PayloadAttachments.validate(); err != nil {
+			return fmt.Errorf("event \"%s\": payload attachments: %s", event.Name, err.Error())
+		}
+
+		if err := validateProvider(event, conf.Delivery); err != nil {
+			return err
+		}
+
+		if err := validateAllowedDomains(event.AllowedDomains); err != nil {
+			return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+		}
+
+		if err := event.QuietHours.validate(); err != nil {
+			return fmt.Errorf("event \"%s\": quiet hours: %s", event.Name, err.Error())
+		}
+
+		// Deferred messages return through the delayed exchange instead of waiting in workers.
+		if event.QuietHours != nil && conf.AMQP.Retry == nil {
+			return fmt.Errorf("event \"%s\" has quiet hours, which need amqp retry", event.Name)
+		}
+
+		if err := validateCoalesce(event.Coalesce); err != nil {
+			return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+		}
+
+		if event.Prefetch < 0 {
+			return fmt.Errorf("event \"%s\": prefetch should not be negative", event.Name)
+		}
+
+		if err := validatePerRecipient(event.PerRecipient); err != nil {
+			return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+		}
+
+		if err := validatePostProcessors(event.PostProcess); err != nil {
+			return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+		}
+
+		if len(event.Templates) == 0 && !event.Disabled {
+			return fmt.Errorf("event \"%s\" has no templates", event.Name)
+		}
+
 		for lang, tpl := range event.Templates {
-			strippedTpl := strings.TrimSpace(tpl.Template)
-			strippedTplPath := strings.TrimSpace(tpl.TemplatePath)
+			if err := validateTemplate(tpl, conf.Subjects); err != nil {
+				return fmt.Errorf("language \"%s\" in event \"%s\": %s", lang, event.Name, err.Error())
+			}
+
+			if lang != canonicalKey(lang) {
+				return fmt.Errorf("language \"%s\" in event \"%s\" should be uppercased", lang, event.Name)
+			}
+		}
 
-			if strippedTpl != "" && strippedTplPath != "" {
-				return false, errors.New("template and template path is specified")
+		if err := validateTenants(event, conf.Subjects); err != nil {
+			return err
+		}
+
+		if rollout := event.Rollout; rollout != nil {
+			if strings.TrimSpace(rollout.Version) == "" || rollout.Version == BaseVersion {
+				return fmt.Errorf("rollout version in event \"%s\" should be set and differ from \"%s\"", event.Name, BaseVersion)
 			}
 
-			if lang != strings.ToUpper(lang) {
-				err := fmt.Errorf("language \"%s\" in event \"%s\" should be uppercased", lang, event.Name)
-				return false, err
+			if rollout.Percent < 0 || rollout.Percent > 100 {
+				return fmt.Errorf("rollout percent in event \"%s\" should be between 0 and 100", event.Name)
+			}
+
+			for lang, tpl := range rollout.Templates {
+				if err := validateTemplate(tpl, conf.Subjects); err != nil {
+					return fmt.Errorf("rollout language \"%s\" in event \"%s\": %s", lang, event.Name, err.Error())
+				}
+
+				if _, exists := event.Templates[lang]; !exists {
+					return fmt.Errorf("rollout language \"%s\" in event \"%s\" is not defined", lang, event.Name)
+				}
 			}
 		}
 
 		for _, lang := range conf.Languages {
-			if _, exists := event.Templates[lang.Code]; !exists {
-				err := fmt.Errorf(
+			if event.Disabled || lang.Disabled {
+				continue
+			}
+
+			if !event.hasTemplate(conf.FallbackChain(lang.Code)) {
+				return fmt.Errorf(
 					"language \"%s\" in event \"%s\" is not defined", lang.Code, event.Name)
-				return false, err
 			}
 		}
+
+		// Events in languages, which aren't configured, are sent in default one.
+		if code := conf.DefaultLanguage; code != "" && !event.Disabled && !event.hasTemplate(conf.FallbackChain(code)) {
+			return fmt.Errorf("default language \"%s\" in event \"%s\" is not defined", code, event.Name)
+		}
+	}
+
+	if conf.StrictVariants {
+		return validateVariants(conf)
+	}
+
+	return nil
+}
+
+// upperKeys returns templates keyed by upper-cased language codes.
+func upperKeys(templates map[string]Template) (map[string]Template, error) {
+	if templates == nil {
+		return nil, nil
+	}
+
+	result := make(map[string]Template, len(templates))
+	for lang, tpl := range templates {
+		code := canonicalKey(lang)
+		if _, exists := result[code]; exists {
+			return nil, fmt.Errorf("language \"%s\" is defined more than once", code)
+		}
+		result[code] = tpl
+	}
+
+	return result, nil
+}
+
+func normalizeLanguages(conf *Config) error {
+	for i := range conf.Languages {
+		conf.Languages[i].Code = strings.ToUpper(conf.Languages[i].Code)
+		conf.Languages[i].Fallback = strings.ToUpper(conf.Languages[i].Fallback)
+	}
+
+	for i := range conf.Events {
+		event := &conf.Events[i]
+
+		templates, err := upperKeys(event.Templates)
+		if err != nil {
+			return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+		}
+		event.Templates = templates
+
+		for tenant, overrides := range event.Tenants {
+			templates, err := upperKeys(overrides)
+			if err != nil {
+				return fmt.Errorf("tenant \"%s\" of event \"%s\": %s", tenant, event.Name, err.Error())
+			}
+			event.Tenants[tenant] = templates
+		}
+
+		if event.Rollout != nil {
+			templates, err := upperKeys(event.Rollout.Templates)
+			if err != nil {
+				return fmt.Errorf("rollout of event \"%s\": %s", event.Name, err.Error())
+			}
+			event.Rollout.Templates = templates
+		}
+	}
+
+	if conf.FromNames != nil {
+		names := make(map[string]string, len(conf.FromNames))
+		for lang, name := range conf.FromNames {
+			code := strings.ToUpper(lang)
+			if _, exists := names[code]; exists {
+				return fmt.Errorf("from names: language \"%s\" is defined more than once", code)
+			}
+			names[code] = name
+		}
+		conf.FromNames = names
+	}
+
+	if conf.Catalogs != nil {
+		catalogs := make(map[string]map[string]string, len(conf.Catalogs))
+		for lang, strs := range conf.Catalogs {
+			code := strings.ToUpper(lang)
+			if _, exists := catalogs[code]; exists {
+				return fmt.Errorf("catalogs: language \"%s\" is defined more than once", code)
+			}
+			catalogs[code] = strs
+		}
+		conf.Catalogs = catalogs
+	}
+
+	conf.DefaultLanguage = strings.ToUpper(conf.DefaultLanguage)
+
+	return nil
+}
+
+// Load decodes and validates configuration.
+// Unknown fields are rejected, unless lenient mode is requested.
+// Problems, which don't break sending, are returned as warnings of valid config.
+func Load(r io.Reader, opts LoadOptions) (*Config, []Warning, error) {
+	conf, err := decode(r, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.NormalizeLanguages {
+		if err := normalizeLanguages(&conf); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := expandVariants(&conf); err != nil {
+		return nil, nil, err
+	}
+
+	conf = *conf.WithDefaults()
+
+	registry, err := newRegistry(conf.TemplateRoot, conf.Partials)
+	if err != nil {
+		return nil, nil, err
+	}
+	conf.attachRegistry(registry)
+
+	if err := validate(conf); err != nil {
+		return nil, nil, err
+	}
+
+	if conf.Kafka != nil && !opts.Kafka {
+		return nil, nil, errors.New("kafka is not supported without a client, see consumer.WithKafkaDialer")
+	}
+
+	if opts.LintTemplates {
+		if err := lint(conf); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &conf, warnings(conf), nil
+}
+
+// Validate configuration file. Warnings don't make it invalid.
+func Validate(r io.Reader) (bool, error) {
+	if _, _, err := Load(r, LoadOptions{}); err != nil {
+		return false, err
 	}
 
 	return true, nil
@@ -2,6 +2,8 @@ package config
 
 import (
 	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
@@ -13,6 +15,7 @@ import (
 var (
 	configPath   = "../../config/postmaster.yml"
 	templatePath = "../../test/test.tpl"
+	previewPath  = "../../test/preview.json"
 )
 
 type FakeData struct {
@@ -46,6 +49,18 @@ func FakeTemplate() Template {
 	}
 }
 
+// chdir changes working directory until returned func is called,
+// so template paths of default config resolve.
+func chdir(t *testing.T, dir string) func() {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+
+	return func() {
+		assert.NoError(t, os.Chdir(wd))
+	}
+}
+
 func DefaultConfig() Config {
 	config := Config{}
 
@@ -55,7 +70,7 @@ func DefaultConfig() Config {
 	return config
 }
 
-func ExampleConfig() Config {
+func SampleConfig() Config {
 	return Config{
 		Languages: []Language{
 			{
@@ -97,6 +112,36 @@ func TestConfig_ContainsLanguage(t *testing.T) {
 	})
 }
 
+func TestConfig_FromName(t *testing.T) {
+	config := FakeConfig()
+	config.FromNames = map[string]string{"EN": "Support Team", "FR": " "}
+
+	name, ok := config.FromName("en")
+	assert.True(t, ok)
+	assert.Equal(t, "Support Team", name)
+
+	_, ok = config.FromName("FR")
+	assert.False(t, ok)
+
+	_, ok = config.FromName("UA")
+	assert.False(t, ok)
+}
+
+func TestConfig_Event(t *testing.T) {
+	config := SampleConfig()
+	config.Events = append(config.Events, Event{Name: "Sunset", Key: "sunset", Disabled: true})
+
+	event, ok := config.Event("example")
+	assert.True(t, ok)
+	assert.Equal(t, "Example", event.Name)
+
+	_, ok = config.Event("sunset")
+	assert.False(t, ok)
+
+	_, ok = config.Event("missing")
+	assert.False(t, ok)
+}
+
 func TestEvent_Template(t *testing.T) {
 	code := "RU"
 
@@ -119,6 +164,139 @@ func TestEvent_Template(t *testing.T) {
 	)
 }
 
+func TestEvent_Template_Segment(t *testing.T) {
+	event := Event{
+		Templates: map[string]Template{
+			"EN:premium":    {Subject: "Premium"},
+			"EN":            {Subject: "English"},
+			DefaultTemplate: {Subject: "Default"},
+		},
+	}
+
+	t.Run("exact segment match", func(t *testing.T) {
+		assert.Equal(t, "Premium", event.Template(TemplateKey("en", "Premium")).Subject)
+	})
+
+	t.Run("language fallback", func(t *testing.T) {
+		assert.Equal(t, "English", event.Template(TemplateKey("EN", "free")).Subject)
+		assert.Equal(t, "English", event.Template(TemplateKey("EN", "")).Subject)
+	})
+
+	t.Run("default fallback", func(t *testing.T) {
+		assert.Equal(t, "Default", event.Template(TemplateKey("RU", "premium")).Subject)
+		assert.Equal(t, "Default", event.Template("RU").Subject)
+	})
+}
+
+func TestEvent_PreviewData(t *testing.T) {
+	t.Run("from fixtures file", func(t *testing.T) {
+		event := Event{
+			PreviewPath: previewPath,
+			Templates: map[string]Template{
+				"EN": {
+					Subject:      "Registration Confirmation",
+					TemplatePath: "../../templates/en/email_confirmation.tpl",
+				},
+			},
+		}
+
+		data, err := event.PreviewData()
+		assert.NoError(t, err)
+		assert.Equal(t, "ixj717iex", data["token"])
+
+		tpl := event.Template("EN")
+		result, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Contains(t, string(result), "Hello john@doe.com!")
+		assert.Contains(t, string(result), "http://www.example.com/ixj717iex")
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		event := Event{Preview: `{"token": "xyz"}`}
+
+		data, err := event.PreviewData()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"token": "xyz"}, data)
+	})
+
+	t.Run("without preview data", func(t *testing.T) {
+		event := Event{}
+
+		data, err := event.PreviewData()
+		assert.NoError(t, err)
+		assert.Nil(t, data)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		event := Event{Preview: `{"token": `}
+
+		_, err := event.PreviewData()
+		assert.Error(t, err)
+	})
+}
+
+func TestEvent_TemplateFor(t *testing.T) {
+	event := Event{
+		Key: "example",
+		Templates: map[string]Template{
+			"EN": {Subject: "Old"},
+			"RU": {Subject: "Старый"},
+		},
+		Rollout: &Rollout{
+			Version: "v2",
+			Percent: 10,
+			Templates: map[string]Template{
+				"EN": {Subject: "New"},
+			},
+		},
+	}
+
+	t.Run("splits recipients by percent", func(t *testing.T) {
+		rolled := 0
+		for i := 0; i < 10000; i++ {
+			tpl, version := event.TemplateFor("EN", fmt.Sprintf("user%d@example.com", i))
+			if version == "v2" {
+				assert.Equal(t, "New", tpl.Subject)
+				rolled++
+			} else {
+				assert.Equal(t, BaseVersion, version)
+				assert.Equal(t, "Old", tpl.Subject)
+			}
+		}
+
+		assert.InDelta(t, 1000, rolled, 150)
+	})
+
+	t.Run("consistent per recipient", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			recipient := fmt.Sprintf("user%d@example.com", i)
+			_, first := event.TemplateFor("EN", recipient)
+			_, second := event.TemplateFor("en", strings.ToUpper(recipient))
+
+			assert.Equal(t, first, second)
+		}
+	})
+
+	t.Run("language without rollout", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			tpl, version := event.TemplateFor("RU", fmt.Sprintf("user%d@example.com", i))
+
+			assert.Equal(t, BaseVersion, version)
+			assert.Equal(t, "Старый", tpl.Subject)
+		}
+	})
+
+	t.Run("without rollout", func(t *testing.T) {
+		noRollout := event
+		noRollout.Rollout = nil
+
+		tpl, version := noRollout.TemplateFor("EN", "john@doe.com")
+
+		assert.Equal(t, BaseVersion, version)
+		assert.Equal(t, "Old", tpl.Subject)
+	})
+}
+
 func TestTemplate_Content(t *testing.T) {
 	t.Run("has only template", func(t *testing.T) {
 		temp := FakeTemplate()
@@ -143,6 +321,20 @@ func TestTemplate_Content(t *testing.T) {
 		assert.Equal(t, "OpenWare", string(result))
 	})
 
+	t.Run("has deleted template path", func(t *testing.T) {
+		file, err := ioutil.TempFile("", "postmaster")
+		assert.NoError(t, err)
+		file.Close()
+		assert.NoError(t, os.Remove(file.Name()))
+
+		temp := FakeTemplate()
+		temp.TemplatePath = file.Name()
+
+		result, err := temp.Content(NewFakeData("OpenWare"))
+		assert.Nil(t, result)
+		assert.EqualError(t, err, fmt.Sprintf("file %q not found", file.Name()))
+	})
+
 	t.Run("has both template and template path", func(t *testing.T) {
 		temp := FakeTemplate()
 		temp.TemplatePath = templatePath
@@ -156,6 +348,116 @@ func TestTemplate_Content(t *testing.T) {
 	})
 }
 
+func TestTemplate_Validate(t *testing.T) {
+	t.Run("valid templates", func(t *testing.T) {
+		templates := []Template{
+			{Subject: "Hello {{ .name }}", Template: "Hi {{ .name }}"},
+			{Subject: "Hello", TemplatePath: templatePath},
+			{Subject: "Digest", Template: "<ul>{{ items }}</ul>", Item: "<li>{{ .title }}</li>"},
+		}
+
+		for _, tpl := range templates {
+			assert.NoError(t, tpl.Validate())
+		}
+	})
+
+	cases := map[string]struct {
+		tpl Template
+		err string
+	}{
+		"both body and file": {
+			Template{Subject: "Hello", Template: "Hi", TemplatePath: templatePath},
+			"template and template path is specified",
+		},
+		"neither body nor file": {
+			Template{Subject: "Hello"},
+			"neither template nor template path is specified",
+		},
+		"broken body": {
+			Template{Subject: "Hello", Template: "Hi {{ .name"},
+			"template: Hello:1: unclosed action",
+		},
+		"missing file": {
+			Template{Subject: "Hello", TemplatePath: "../../test/missing.tpl"},
+			"file \"../../test/missing.tpl\" not found",
+		},
+		"broken subject": {
+			Template{Subject: "Hello {{ .name", Template: "Hi"},
+			"subject: template: subject:1: unclosed action",
+		},
+		"subject with line break": {
+			Template{Subject: "Hello\nBcc: x@y.com", Template: "Hi"},
+			"subject: subject contains line break",
+		},
+		"broken digest item": {
+			Template{Subject: "Digest", Template: "{{ items }}", Item: "{{ .title"},
+			"digest item: template: item:1: unclosed action",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.EqualError(t, tc.tpl.Validate(), tc.err)
+		})
+	}
+}
+
+func TestTemplate_RenderSubject(t *testing.T) {
+	fragments := map[string]string{
+		"greeting": "Hi {{ .Name }}",
+		"nested":   `{{ frag "greeting" }}`,
+	}
+	data := map[string]interface{}{"Name": "John", "ID": 42}
+
+	t.Run("plain subject", func(t *testing.T) {
+		temp := FakeTemplate()
+
+		subject, err := temp.RenderSubject(data, fragments)
+		assert.NoError(t, err)
+		assert.Equal(t, "Fake", subject)
+	})
+
+	t.Run("composed from shared fragment", func(t *testing.T) {
+		temp := Template{Subject: `{{ frag "greeting" }} - Order {{ .ID }}`}
+
+		subject, err := temp.RenderSubject(data, fragments)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hi John - Order 42", subject)
+	})
+
+	t.Run("undefined fragment", func(t *testing.T) {
+		temp := Template{Subject: `{{ frag "missing" }}`}
+
+		_, err := temp.RenderSubject(data, fragments)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `subject fragment "missing" is not defined`)
+	})
+
+	t.Run("rejects line breaks injected by data", func(t *testing.T) {
+		temp := Template{Subject: "Order {{ .ID }}"}
+		injected := map[string]interface{}{"ID": "1\r\nBcc: x@y.z\r\n\r\nphish"}
+
+		subject, err := temp.RenderSubject(injected, fragments)
+		assert.Equal(t, "", subject)
+		assert.EqualError(t, err, "rendered subject contains line break")
+	})
+
+	t.Run("rejects line breaks injected through fragment", func(t *testing.T) {
+		temp := Template{Subject: `{{ frag "greeting" }}`}
+		injected := map[string]interface{}{"Name": "John\nBcc: x@y.z"}
+
+		_, err := temp.RenderSubject(injected, fragments)
+		assert.EqualError(t, err, "rendered subject contains line break")
+	})
+
+	t.Run("fragments can't include fragments", func(t *testing.T) {
+		temp := Template{Subject: `{{ frag "nested" }}`}
+
+		_, err := temp.RenderSubject(data, fragments)
+		assert.Error(t, err)
+	})
+}
+
 func TestLanguage_Valid(t *testing.T) {
 	name := "French"
 	code := "FR"
@@ -184,9 +486,25 @@ func TestLanguage_Valid(t *testing.T) {
 	})
 }
 
+func TestValidHeaderName(t *testing.T) {
+	assert.True(t, ValidHeaderName("X-Campaign-ID"))
+	assert.False(t, ValidHeaderName(""))
+	assert.False(t, ValidHeaderName("X Campaign"))
+	assert.False(t, ValidHeaderName("X-Campaign:"))
+	assert.False(t, ValidHeaderName("X-Кампания"))
+}
+
+func TestValidHeader(t *testing.T) {
+	assert.NoError(t, ValidHeader("Precedence", "bulk"))
+	assert.EqualError(t, ValidHeader("Auto Submitted", "auto-generated"), "header \"Auto Submitted\" is not a valid header name")
+	assert.EqualError(t, ValidHeader("Precedence", "bulk\r\nBcc: x@y.z"), "header \"Precedence\" value contains line break")
+	assert.EqualError(t, ValidHeader("Subject", "Hi"), "header \"Subject\" is reserved")
+	assert.EqualError(t, ValidHeader("content-type", "text/plain"), "header \"content-type\" is reserved")
+}
+
 func TestValidate(t *testing.T) {
 	t.Run("lower cased language codes", func(t *testing.T) {
-		tmp := ExampleConfig()
+		tmp := SampleConfig()
 		tmp.Languages[0].Code = strings.ToLower(tmp.Languages[0].Code)
 
 		configAsBytes, err := yaml.Marshal(tmp)
@@ -198,7 +516,7 @@ func TestValidate(t *testing.T) {
 	})
 
 	t.Run("event without templates", func(t *testing.T) {
-		tmp := ExampleConfig()
+		tmp := SampleConfig()
 		tmp.Events[0].Templates = make(map[string]Template, 0)
 
 		configAsBytes, err := yaml.Marshal(tmp)
@@ -206,12 +524,354 @@ func TestValidate(t *testing.T) {
 
 		res, err := Validate(bytes.NewReader(configAsBytes))
 
+		assert.EqualError(t, err, "event \"Example\" has no templates")
+		assert.False(t, res)
+	})
+
+	t.Run("event without templates and languages", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Languages = nil
+		tmp.Events[0].Templates = make(map[string]Template, 0)
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "event \"Example\" has no templates")
+		assert.False(t, res)
+	})
+
+	t.Run("invalid headers prefix", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.AMQP.HeadersPrefix = "X Custom"
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.Error(t, err)
+		assert.False(t, res)
+	})
+
+	t.Run("malformed subject", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Templates["EN"] = Template{Subject: "{{ .ID", Template: "Yo"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
 		assert.Error(t, err)
 		assert.False(t, res)
 	})
 
+	t.Run("undefined subject fragment", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Subjects = map[string]string{"greeting": "Hi"}
+		tmp.Events[0].Templates["EN"] = Template{
+			Subject:  `{{ if .ID }}{{ frag "greting" | printf "%s!" }}{{ end }}`,
+			Template: "Yo",
+		}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, `language "EN" in event "Example": subject: subject fragment "greting" is not defined`)
+		assert.False(t, res)
+	})
+
+	t.Run("malformed subject fragment", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Subjects = map[string]string{"greeting": "{{ .Name"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.Error(t, err)
+		assert.False(t, res)
+	})
+
+	t.Run("rollout percent out of range", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Rollout = &Rollout{Version: "v2", Percent: 120}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.Error(t, err)
+		assert.False(t, res)
+	})
+
+	t.Run("rollout with broken subject", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Rollout = &Rollout{
+			Version:   "v2",
+			Percent:   10,
+			Templates: map[string]Template{"EN": {Subject: "{{ .ID", Template: "Yo"}},
+		}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "rollout language \"EN\" in event \"Example\": subject:")
+		assert.False(t, res)
+	})
+
+	t.Run("rollout without template body", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Rollout = &Rollout{
+			Version:   "v2",
+			Percent:   10,
+			Templates: map[string]Template{"EN": {Subject: "Example"}},
+		}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "rollout language \"EN\" in event \"Example\": neither template nor template path is specified")
+		assert.False(t, res)
+	})
+
+	t.Run("rollout of undefined language", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Rollout = &Rollout{
+			Version:   "v2",
+			Percent:   10,
+			Templates: map[string]Template{"RU": {Subject: "Пример", Template: "Йо"}},
+		}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.Error(t, err)
+		assert.False(t, res)
+	})
+
+	t.Run("from name missing for language", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Languages = append(tmp.Languages, Language{Code: "RU", Name: "Russian"})
+		tmp.Events[0].Templates["RU"] = Template{Subject: "Пример", Template: "Йо"}
+		tmp.FromNames = map[string]string{"RU": "Поддержка"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "from name for language \"EN\" is not defined")
+		assert.False(t, res)
+	})
+
+	t.Run("subject with line break", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Templates["EN"] = Template{Subject: "Example\nBcc: x@y.z", Template: "Yo"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "language \"EN\" in event \"Example\": subject: subject contains line break")
+		assert.False(t, res)
+	})
+
+	t.Run("subject fragment with line break", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Subjects = map[string]string{"greeting": "Hi\r\nBcc: x@y.z"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "subject fragment \"greeting\" contains line break")
+		assert.False(t, res)
+	})
+
+	t.Run("invalid sender identity", func(t *testing.T) {
+		cases := map[string]Identity{
+			"sender of language \"EN\": from address \"support\": mail: missing '@' or angle-addr":        {FromAddress: "support"},
+			"sender of language \"EN\": reply to \"Support <help@example.com>\" should be a bare address": {ReplyTo: "Support <help@example.com>"},
+			"sender of language \"EN\": from address and domain is specified":                             {FromAddress: "a@example.com", Domain: "example.com"},
+			"sender of language \"EN\": domain \"a@example.com\" is not valid":                            {Domain: "a@example.com"},
+			"sender of language \"EN\": domain \"example.com\\r\\nBcc: spy@evil.com\" is not valid":       {Domain: "example.com\r\nBcc: spy@evil.com"},
+		}
+
+		for expected, identity := range cases {
+			identity := identity
+			tmp := SampleConfig()
+			tmp.Languages[0].Sender = &identity
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			res, err := Validate(bytes.NewReader(configAsBytes))
+
+			assert.EqualError(t, err, expected)
+			assert.False(t, res)
+		}
+	})
+
+	t.Run("malformed preview data", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Preview = "{"
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		conf, warnings, err := Load(bytes.NewReader(configAsBytes), LoadOptions{})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, conf)
+		assert.Equal(t, []Warning{{"preview_data", "event \"Example\": unexpected end of JSON input"}}, warnings)
+	})
+
+	t.Run("missing preview data file", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].PreviewPath = "../../test/missing.json"
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		conf, warnings, err := Load(bytes.NewReader(configAsBytes), LoadOptions{})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, conf)
+		assert.Equal(t, []Warning{{"preview_data", "event \"Example\": open ../../test/missing.json: no such file or directory"}}, warnings)
+	})
+
+	t.Run("reserved static header", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Headers = map[string]string{"From": "evil@example.com"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "event \"Example\": header \"From\" is reserved")
+		assert.False(t, res)
+	})
+
+	t.Run("invalid static header", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Headers = map[string]string{"Precedence:": "bulk"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "event \"Example\": header \"Precedence:\" is not a valid header name")
+		assert.False(t, res)
+	})
+
+	t.Run("lower cased from name language", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.FromNames = map[string]string{"en": "Support Team"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "language \"en\" in from names should be uppercased")
+		assert.False(t, res)
+	})
+
+	t.Run("from name injecting headers", func(t *testing.T) {
+		for _, name := range []string{"Support\r\nBcc: spy@evil.com", "Support\" <spy@evil.com>"} {
+			tmp := SampleConfig()
+			tmp.FromNames = map[string]string{"EN": name}
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			res, err := Validate(bytes.NewReader(configAsBytes))
+
+			assert.EqualError(t, err, "from name for language \"EN\" should not contain line breaks or quotes")
+			assert.False(t, res)
+		}
+	})
+
+	t.Run("from name for unknown language", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.FromNames = map[string]string{"EN": "Support Team", "DE": "Support-Team"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "language \"DE\" in from names is not configured")
+		assert.False(t, res)
+	})
+
+	t.Run("segment template keys", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Templates["EN:premium"] = Template{Subject: "Premium", Template: "Yo"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+		assert.NoError(t, err)
+		assert.True(t, res)
+
+		tmp.Events[0].Templates["en:premium"] = Template{Subject: "Premium", Template: "Yo"}
+		configAsBytes, err = yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err = Validate(bytes.NewReader(configAsBytes))
+		assert.Error(t, err)
+		assert.False(t, res)
+	})
+
+	t.Run("disabled event without templates", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Disabled = true
+		tmp.Events[0].Templates = nil
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.NoError(t, err)
+		assert.True(t, res)
+	})
+
+	t.Run("disabled language without templates", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Languages = append(tmp.Languages, Language{Code: "DE", Name: "German", Disabled: true})
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.NoError(t, err)
+		assert.True(t, res)
+	})
+
 	t.Run("no lower cased language codes", func(t *testing.T) {
-		configAsBytes, err := yaml.Marshal(ExampleConfig())
+		configAsBytes, err := yaml.Marshal(SampleConfig())
 		assert.NoError(t, err)
 
 		res, err := Validate(bytes.NewReader(configAsBytes))
@@ -220,9 +880,76 @@ func TestValidate(t *testing.T) {
 		assert.True(t, res)
 	})
 
+	t.Run("event with unknown sender", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Sender = "bulk"
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "sender \"bulk\" of event \"Example\" is not configured")
+
+		tmp.Senders = map[string]Sender{"bulk": {Host: "smtp.bulk.io", Port: "587", PasswordEnv: "BULK_SMTP_PASSWORD"}}
+		configAsBytes, err = yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+		assert.NoError(t, err)
+		assert.True(t, res)
+	})
+
+	t.Run("incomplete sender", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Senders = map[string]Sender{"bulk": {Host: "smtp.bulk.io", Port: "587"}}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "sender \"bulk\" should have either password or password_env")
+	})
+
+	t.Run("sender with both passwords", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Senders = map[string]Sender{"bulk": {
+			Host: "smtp.bulk.io", Port: "587", Password: "secret://smtp/bulk", PasswordEnv: "BULK_SMTP_PASSWORD",
+		}}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "sender \"bulk\" should have either password or password_env")
+	})
+
+	t.Run("sender with plaintext password", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Senders = map[string]Sender{"bulk": {Host: "smtp.bulk.io", Port: "587", Password: "hunter2"}}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "sender \"bulk\" password should be a secret:// reference")
+	})
+
+	t.Run("sender with secret password", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Senders = map[string]Sender{"bulk": {Host: "smtp.bulk.io", Port: "587", Password: "secret://smtp/bulk"}}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		valid, err := Validate(bytes.NewReader(configAsBytes))
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	})
+
 	t.Run("default should be valid", func(t *testing.T) {
 		file, err := os.Open(configPath)
 		assert.NoError(t, err)
+		defer chdir(t, "../..")()
 
 		valid, err := Validate(file)
 
@@ -230,3 +957,114 @@ func TestValidate(t *testing.T) {
 		assert.True(t, valid)
 	})
 }
+
+func TestLoad(t *testing.T) {
+	misspelled := `
+languages:
+- code: EN
+  name: English
+events:
+- name: Example
+  key: example
+  templates:
+    EN:
+      subject: Example
+      tempalte_path: ../../test/test.tpl
+`
+
+	t.Run("strict mode rejects unknown fields", func(t *testing.T) {
+		conf, _, err := Load(strings.NewReader(misspelled), LoadOptions{})
+
+		assert.Nil(t, conf)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "field tempalte_path not found")
+	})
+
+	t.Run("lenient mode ignores unknown fields", func(t *testing.T) {
+		future := strings.Replace(misspelled, "tempalte_path", "template_path", 1) + "      future_field: x\n"
+		conf, _, err := Load(strings.NewReader(future), LoadOptions{Lenient: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, templatePath, conf.Events[0].Templates["EN"].TemplatePath)
+	})
+
+	t.Run("lenient mode still reports misspelled template path", func(t *testing.T) {
+		conf, _, err := Load(strings.NewReader(misspelled), LoadOptions{Lenient: true})
+
+		assert.Nil(t, conf)
+		assert.EqualError(t, err, "language \"EN\" in event \"Example\": neither template nor template path is specified")
+	})
+
+	lowercased := `
+languages:
+- code: en
+  name: English
+events:
+- name: Example
+  key: example
+  templates:
+    en:
+      subject: Example
+      template: Yo
+`
+
+	t.Run("rejects lower cased language by default", func(t *testing.T) {
+		conf, _, err := Load(strings.NewReader(lowercased), LoadOptions{})
+
+		assert.Nil(t, conf)
+		assert.EqualError(t, err, "language \"en\" should be uppercased")
+	})
+
+	t.Run("normalizes lower cased language", func(t *testing.T) {
+		conf, _, err := Load(strings.NewReader(lowercased), LoadOptions{NormalizeLanguages: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "EN", conf.Languages[0].Code)
+		assert.Contains(t, conf.Events[0].Templates, "EN")
+		assert.NotContains(t, conf.Events[0].Templates, "en")
+	})
+
+	t.Run("normalization rejects duplicated from names", func(t *testing.T) {
+		duplicated := lowercased + "from_names:\n  en: Support\n  EN: Support Team\n"
+		conf, _, err := Load(strings.NewReader(duplicated), LoadOptions{NormalizeLanguages: true})
+
+		assert.Nil(t, conf)
+		assert.EqualError(t, err, "from names: language \"EN\" is defined more than once")
+	})
+
+	t.Run("normalizes catalog languages", func(t *testing.T) {
+		catalogs := lowercased + "catalogs:\n  en:\n    cta: Go\n"
+		conf, _, err := Load(strings.NewReader(catalogs), LoadOptions{NormalizeLanguages: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]map[string]string{"EN": {"cta": "Go"}}, conf.Catalogs)
+
+		duplicated := catalogs + "  EN:\n    cta: Go\n"
+		_, _, err = Load(strings.NewReader(duplicated), LoadOptions{NormalizeLanguages: true})
+		assert.EqualError(t, err, "catalogs: language \"EN\" is defined more than once")
+	})
+
+	t.Run("normalization rejects duplicates", func(t *testing.T) {
+		duplicated := lowercased + "    EN:\n      subject: Example\n      template: Yo\n"
+		conf, _, err := Load(strings.NewReader(duplicated), LoadOptions{NormalizeLanguages: true})
+
+		assert.Nil(t, conf)
+		assert.EqualError(t, err, "event \"Example\": language \"EN\" is defined more than once")
+	})
+
+	t.Run("default config", func(t *testing.T) {
+		file, err := os.Open(configPath)
+		assert.NoError(t, err)
+		defer file.Close()
+
+		expected := DefaultConfig()
+		defer chdir(t, "../..")()
+
+		conf, _, err := Load(file, LoadOptions{})
+
+		assert.NoError(t, err)
+		// Parsed templates aren't part of config data.
+		conf.attachRegistry(nil)
+		assert.Equal(t, *expected.WithDefaults(), *conf)
+	})
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// LanguageCoverage lists keys of enabled events lacking template of language, see Coverage.
+type LanguageCoverage struct {
+	Language string   `json:"language"`
+	Missing  []string `json:"missing"`
+}
+
+// Coverage returns keys of enabled events lacking template of every
+// configured language, disabled ones included, e.g. to track localization progress.
+// Only templates keyed by the language itself, optionally with segment, count:
+// fallbacks and DEFAULT template aren't translations.
+// Languages and event keys are sorted, fully translated languages have empty lists.
+func (c *Config) Coverage() []LanguageCoverage {
+	events := make(map[string]*Event, len(c.Events))
+	for i := range c.Events {
+		if !c.Events[i].Disabled {
+			events[c.Events[i].Key] = &c.Events[i]
+		}
+	}
+
+	codes := c.LanguageCodes()
+	coverage := make([]LanguageCoverage, 0, len(codes))
+	for _, code := range codes {
+		missing := []string{}
+
+		for _, key := range c.EventKeys() {
+			if !events[key].translated(code) {
+				missing = append(missing, key)
+			}
+		}
+
+		coverage = append(coverage, LanguageCoverage{Language: code, Missing: missing})
+	}
+
+	return coverage
+}
+
+// CoverageReport is Coverage keyed by language code.
+func (c *Config) CoverageReport() map[string][]string {
+	report := make(map[string][]string, len(c.Languages))
+	for _, lang := range c.Coverage() {
+		report[lang.Language] = lang.Missing
+	}
+
+	return report
+}
+
+// WriteCoverageReport writes CoverageReport as indented JSON, which is
+// the same byte for byte for the same config.
+func (c *Config) WriteCoverageReport(w io.Writer) error {
+	// Encoder sorts map keys.
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c.CoverageReport())
+}
+
+// EventKeys returns sorted keys of enabled events.
+func (c *Config) EventKeys() []string {
+	keys := make([]string, 0, len(c.Events))
+	for _, event := range c.Events {
+		if !event.Disabled {
+			keys = append(keys, event.Key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// LanguageCodes returns sorted codes of configured languages, disabled ones included.
+func (c *Config) LanguageCodes() []string {
+	codes := make([]string, 0, len(c.Languages))
+	for _, lang := range c.Languages {
+		codes = append(codes, lang.Code)
+	}
+
+	sort.Strings(codes)
+	return codes
+}
+
+// translated reports whether event has template of language, segmented or not.
+func (e *Event) translated(code string) bool {
+	for key := range e.Templates {
+		if strings.EqualFold(key, code) || strings.HasPrefix(strings.ToUpper(key), strings.ToUpper(code)+":") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func coverageConfig() Config {
+	return Config{
+		Languages: []Language{
+			{Code: "EN", Name: "English"},
+			{Code: "DE", Name: "German"},
+			{Code: "PT", Name: "Portuguese", Fallback: "EN"},
+			{Code: "FR", Name: "French", Disabled: true},
+		},
+		Events: []Event{
+			{
+				Key: "user.password.reset",
+				Templates: map[string]Template{
+					"EN": {Subject: "Reset"},
+					"DE": {Subject: "Zurücksetzen"},
+					"FR": {Subject: "Réinitialiser"},
+				},
+			},
+			{
+				Key: "user.email.confirmation",
+				Templates: map[string]Template{
+					"EN":         {Subject: "Confirm"},
+					"DE:premium": {Subject: "Bestätigen"},
+				},
+			},
+			{
+				Key: "user.newsletter",
+				Templates: map[string]Template{
+					DefaultTemplate: {Subject: "News"},
+					"EN":            {Subject: "News"},
+				},
+			},
+			{
+				Key:       "user.legacy",
+				Disabled:  true,
+				Templates: map[string]Template{"EN": {Subject: "Legacy"}},
+			},
+		},
+	}
+}
+
+func TestConfig_CoverageReport(t *testing.T) {
+	conf := coverageConfig()
+
+	assert.Equal(t, map[string][]string{
+		"EN": {},
+		"DE": {"user.newsletter"},
+		"PT": {"user.email.confirmation", "user.newsletter", "user.password.reset"},
+		"FR": {"user.email.confirmation", "user.newsletter"},
+	}, conf.CoverageReport())
+}
+
+func TestConfig_Coverage(t *testing.T) {
+	conf := coverageConfig()
+
+	assert.Equal(t, []LanguageCoverage{
+		{"DE", []string{"user.newsletter"}},
+		{"EN", []string{}},
+		{"FR", []string{"user.email.confirmation", "user.newsletter"}},
+		{"PT", []string{"user.email.confirmation", "user.newsletter", "user.password.reset"}},
+	}, conf.Coverage())
+	assert.Equal(t, []string{"user.email.confirmation", "user.newsletter", "user.password.reset"}, conf.EventKeys())
+	assert.Equal(t, []string{"DE", "EN", "FR", "PT"}, conf.LanguageCodes())
+}
+
+func TestConfig_WriteCoverageReport(t *testing.T) {
+	conf := coverageConfig()
+
+	expected := `{
+  "DE": [
+    "user.newsletter"
+  ],
+  "EN": [],
+  "FR": [
+    "user.email.confirmation",
+    "user.newsletter"
+  ],
+  "PT": [
+    "user.email.confirmation",
+    "user.newsletter",
+    "user.password.reset"
+  ]
+}
+`
+
+	for i := 0; i < 20; i++ {
+		buff := new(bytes.Buffer)
+		assert.NoError(t, conf.WriteCoverageReport(buff))
+		assert.Equal(t, expected, buff.String())
+	}
+}
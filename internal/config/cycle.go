@@ -0,0 +1,97 @@
+package config
+
+import (
+	"html/template"
+	"sort"
+	"text/template/parse"
+)
+
+// templateRefs collects names of templates included with {{ template "name" }}.
+func templateRefs(node parse.Node, refs []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return refs
+		}
+		for _, child := range n.Nodes {
+			refs = templateRefs(child, refs)
+		}
+	case *parse.IfNode:
+		refs = templateRefs(n.List, refs)
+		refs = templateRefs(n.ElseList, refs)
+	case *parse.RangeNode:
+		refs = templateRefs(n.List, refs)
+		refs = templateRefs(n.ElseList, refs)
+	case *parse.WithNode:
+		refs = templateRefs(n.List, refs)
+		refs = templateRefs(n.ElseList, refs)
+	case *parse.TemplateNode:
+		refs = append(refs, n.Name)
+	}
+
+	return refs
+}
+
+// templateCycle returns the first cycle of references between partials and
+// layouts defined with {{ define }}, e.g. [footer header footer], if any.
+func templateCycle(tpl *template.Template) []string {
+	deps := make(map[string][]string)
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil {
+			deps[t.Name()] = templateRefs(t.Tree.Root, nil)
+		}
+	}
+
+	// Partials are walked by name, then the template itself.
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		if name != tpl.Name() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	names = append(names, tpl.Name())
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+
+		for _, dep := range deps[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Validate_Cycle(t *testing.T) {
+	t.Run("two partials", func(t *testing.T) {
+		tpl := Template{
+			Subject: "Hello",
+			Template: `{{ define "footer" }}Bye {{ template "header" }}{{ end }}` +
+				`{{ define "header" }}{{ if .Name }}Hi {{ template "footer" }}{{ end }}{{ end }}` +
+				`{{ template "header" . }}`,
+		}
+
+		assert.EqualError(t, tpl.Validate(), "template cycle: footer -> header -> footer")
+	})
+
+	t.Run("partial including itself", func(t *testing.T) {
+		tpl := Template{
+			Subject:  "Hello",
+			Template: `{{ define "tree" }}{{ range . }}{{ template "tree" . }}{{ end }}{{ end }}{{ template "tree" . }}`,
+		}
+
+		assert.EqualError(t, tpl.Validate(), "template cycle: tree -> tree")
+	})
+
+	t.Run("shared partials without cycle", func(t *testing.T) {
+		tpl := Template{
+			Subject: "Hello",
+			Template: `{{ define "logo" }}<img>{{ end }}` +
+				`{{ define "header" }}{{ template "logo" }}{{ end }}` +
+				`{{ define "footer" }}{{ template "logo" }}{{ end }}` +
+				`{{ template "header" }}Body{{ template "footer" }}`,
+		}
+
+		assert.NoError(t, tpl.Validate())
+	})
+
+	t.Run("rejected on load", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].Templates["EN"] = Template{
+			Subject:  "Hello",
+			Template: `{{ define "a" }}{{ template "b" }}{{ end }}{{ define "b" }}{{ template "a" }}{{ end }}{{ template "a" }}`,
+		}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "language \"EN\" in event \"Example\": template cycle: a -> b -> a")
+	})
+}
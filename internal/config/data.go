@@ -0,0 +1,68 @@
+package config
+
+import "fmt"
+
+// MergeData deep-merges layers of render data, later layers win:
+// nested maps are merged key by key, while scalars and slices of later layers
+// replace earlier values. Layers are left intact and nil layers are skipped.
+// Maps decoded from YAML are converted to map[string]interface{} on the way.
+func MergeData(layers ...map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	for _, layer := range layers {
+		mergeInto(result, layer)
+	}
+
+	return result
+}
+
+func mergeInto(dst, src map[string]interface{}) {
+	for key, value := range src {
+		value = stringKeys(value)
+
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			dst[key] = value
+			continue
+		}
+
+		existing, ok := dst[key].(map[string]interface{})
+		if !ok {
+			existing = make(map[string]interface{})
+			dst[key] = existing
+		}
+		mergeInto(existing, nested)
+	}
+}
+
+// stringKeys returns copy of value with YAML maps keyed by strings.
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			result[fmt.Sprint(key)] = stringKeys(nested)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			result[key] = stringKeys(nested)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, nested := range v {
+			result[i] = stringKeys(nested)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// RenderData returns data, which templates of event are rendered with, merged from,
+// by increasing precedence: global data, event data, data of tenant and message payload.
+func (c *Config) RenderData(event *Event, tenant string, payload map[string]interface{}) map[string]interface{} {
+	return MergeData(c.Data, event.Data, c.TenantData[tenant], payload)
+}
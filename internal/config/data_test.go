@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeData(t *testing.T) {
+	t.Run("deep merges nested maps across layers", func(t *testing.T) {
+		defaults := map[string]interface{}{
+			"company": map[string]interface{}{"name": "Postmaster", "address": map[string]interface{}{"city": "Kyiv", "zip": "01001"}},
+			"tags":    []interface{}{"a", "b"},
+			"color":   "blue",
+		}
+		tenant := map[string]interface{}{
+			"company": map[string]interface{}{"name": "Acme", "address": map[string]interface{}{"city": "Berlin"}},
+			"tags":    []interface{}{"c"},
+		}
+		payload := map[string]interface{}{
+			"company": map[string]interface{}{"address": map[string]interface{}{"zip": "10115"}},
+			"color":   "red",
+			"user":    map[string]interface{}{"email": "john@doe.com"},
+		}
+
+		assert.Equal(t, map[string]interface{}{
+			"company": map[string]interface{}{"name": "Acme", "address": map[string]interface{}{"city": "Berlin", "zip": "10115"}},
+			"tags":    []interface{}{"c"},
+			"color":   "red",
+			"user":    map[string]interface{}{"email": "john@doe.com"},
+		}, MergeData(defaults, tenant, payload))
+
+		assert.Equal(t, "Postmaster", defaults["company"].(map[string]interface{})["name"])
+		assert.Equal(t, "Kyiv", defaults["company"].(map[string]interface{})["address"].(map[string]interface{})["city"])
+	})
+
+	t.Run("replaces map with scalar and back", func(t *testing.T) {
+		assert.Equal(t,
+			map[string]interface{}{"a": "scalar", "b": map[string]interface{}{"c": 1}},
+			MergeData(
+				map[string]interface{}{"a": map[string]interface{}{"x": 1}, "b": 2},
+				map[string]interface{}{"a": "scalar", "b": map[string]interface{}{"c": 1}},
+			))
+	})
+
+	t.Run("converts YAML maps", func(t *testing.T) {
+		yamlLayer := map[string]interface{}{
+			"company": map[interface{}]interface{}{"name": "Postmaster", 1: []interface{}{map[interface{}]interface{}{"k": "v"}}},
+		}
+
+		assert.Equal(t, map[string]interface{}{
+			"company": map[string]interface{}{"name": "Acme", "1": []interface{}{map[string]interface{}{"k": "v"}}},
+		}, MergeData(yamlLayer, nil, map[string]interface{}{"company": map[string]interface{}{"name": "Acme"}}))
+	})
+}
+
+func TestConfig_RenderData(t *testing.T) {
+	const raw = `
+data:
+  company:
+    name: Postmaster
+    support: support@postmaster.com
+tenant_data:
+  acme:
+    company:
+      name: Acme
+events:
+- data:
+    company:
+      support: reset@postmaster.com
+    action: reset
+`
+	conf, err := decode(strings.NewReader(raw), LoadOptions{})
+	assert.NoError(t, err)
+
+	payload := map[string]interface{}{"action": "confirm"}
+
+	assert.Equal(t, map[string]interface{}{
+		"company": map[string]interface{}{"name": "Acme", "support": "reset@postmaster.com"},
+		"action":  "confirm",
+	}, conf.RenderData(&conf.Events[0], "acme", payload))
+
+	assert.Equal(t, map[string]interface{}{
+		"company": map[string]interface{}{"name": "Postmaster", "support": "reset@postmaster.com"},
+		"action":  "reset",
+	}, conf.RenderData(&conf.Events[0], "", nil))
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/openware/postmaster/pkg/eventapi"
+)
+
+// Defaults of optional fields, see Config.WithDefaults.
+const (
+	DefaultTag         = "postmaster"
+	DefaultConcurrency = 1
+	DefaultContentType = "text/html"
+	DefaultCharset     = "iso-8859-1"
+)
+
+// WithDefaults returns copy of config with unset optional fields filled:
+//
+//   - amqp.tag is DefaultTag;
+//   - amqp.concurrency is DefaultConcurrency;
+//   - amqp.ack_mode is AckModeManual;
+//   - amqp.payload_format is JSON;
+//   - default_language is the first configured language;
+//   - content_type is DefaultContentType;
+//   - charset is DefaultCharset.
+//
+// Load applies defaults, configs constructed in code should call it explicitly.
+func (c *Config) WithDefaults() *Config {
+	conf := *c
+
+	if conf.AMQP.Tag == "" {
+		conf.AMQP.Tag = DefaultTag
+	}
+
+	if conf.AMQP.Concurrency == 0 {
+		conf.AMQP.Concurrency = DefaultConcurrency
+	}
+
+	if conf.AMQP.AckMode == "" {
+		conf.AMQP.AckMode = AckModeManual
+	}
+
+	if conf.AMQP.PayloadFormat == "" {
+		conf.AMQP.PayloadFormat = eventapi.FormatJSON
+	}
+
+	if conf.DefaultLanguage == "" && len(conf.Languages) != 0 {
+		conf.DefaultLanguage = conf.Languages[0].Code
+	}
+
+	if conf.ContentType == "" {
+		conf.ContentType = DefaultContentType
+	}
+
+	if conf.Charset == "" {
+		conf.Charset = DefaultCharset
+	}
+
+	return &conf
+}
+
+// RenderBudget returns how long rendering of a single email may take, zero when unlimited.
+func (c *Config) RenderBudget() time.Duration {
+	budget, err := time.ParseDuration(c.RenderTimeout)
+	if err != nil {
+		return 0
+	}
+
+	return budget
+}
+
+func validateDefaults(conf Config) error {
+	if conf.AMQP.Concurrency < 0 {
+		return errors.New("amqp concurrency should be positive")
+	}
+
+	if mode := conf.AMQP.AckMode; mode != "" && mode != AckModeManual && mode != AckModeAuto {
+		return fmt.Errorf("amqp ack mode \"%s\" should be either %s or %s", mode, AckModeManual, AckModeAuto)
+	}
+
+	if format := conf.AMQP.PayloadFormat; format != "" {
+		if _, ok := eventapi.PayloadDecoderFor(format); !ok {
+			return fmt.Errorf("amqp payload format \"%s\" is not supported", format)
+		}
+	}
+
+	if err := validateDataPath(conf.AMQP.DataPath); err != nil {
+		return err
+	}
+
+	if conf.MaxRecipients < 0 {
+		return errors.New("max recipients should not be negative")
+	}
+
+	if d, err := time.ParseDuration(conf.RenderTimeout); conf.RenderTimeout != "" && (err != nil || d <= 0) {
+		return fmt.Errorf("render timeout \"%s\" is not valid", conf.RenderTimeout)
+	}
+
+	if conf.DefaultLanguage != "" && !conf.ContainsLanguage(conf.DefaultLanguage) {
+		return fmt.Errorf("default language \"%s\" is not configured", conf.DefaultLanguage)
+	}
+
+	if conf.ContentType == "" && conf.Charset == "" {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(fmt.Sprintf("%s; charset=%s", conf.ContentType, conf.Charset))
+	if err != nil || !strings.EqualFold(mediaType, conf.ContentType) || params["charset"] != conf.Charset {
+		return fmt.Errorf("content type \"%s\" with charset \"%s\" is invalid", conf.ContentType, conf.Charset)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_WithDefaults(t *testing.T) {
+	t.Run("fills unset fields", func(t *testing.T) {
+		conf := SampleConfig()
+		result := conf.WithDefaults()
+
+		assert.Equal(t, DefaultTag, result.AMQP.Tag)
+		assert.Equal(t, DefaultConcurrency, result.AMQP.Concurrency)
+		assert.Equal(t, AckModeManual, result.AMQP.AckMode)
+		assert.Equal(t, "json", result.AMQP.PayloadFormat)
+		assert.Equal(t, "EN", result.DefaultLanguage)
+		assert.Equal(t, DefaultContentType, result.ContentType)
+		assert.Equal(t, DefaultCharset, result.Charset)
+
+		// Original config is left untouched.
+		assert.Equal(t, "", conf.AMQP.Tag)
+		assert.Equal(t, "", conf.DefaultLanguage)
+	})
+
+	t.Run("keeps set fields", func(t *testing.T) {
+		conf := SampleConfig()
+		conf.AMQP.Tag = "mailer"
+		conf.AMQP.Concurrency = 4
+		conf.AMQP.AckMode = AckModeAuto
+		conf.AMQP.PayloadFormat = "msgpack"
+		conf.Languages = append(conf.Languages, Language{Code: "RU", Name: "Russian"})
+		conf.DefaultLanguage = "RU"
+		conf.ContentType = "text/plain"
+		conf.Charset = "utf-8"
+
+		assert.Equal(t, conf, *conf.WithDefaults())
+	})
+
+	t.Run("applied by load", func(t *testing.T) {
+		configAsBytes, err := yaml.Marshal(SampleConfig())
+		assert.NoError(t, err)
+
+		conf, _, err := Load(bytes.NewReader(configAsBytes), LoadOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultContentType, conf.ContentType)
+		assert.Equal(t, "EN", conf.DefaultLanguage)
+	})
+}
+
+func TestValidate_Defaults(t *testing.T) {
+	cases := map[string]struct {
+		tweak func(*Config)
+		err   string
+	}{
+		"negative concurrency": {
+			func(c *Config) { c.AMQP.Concurrency = -1 },
+			"amqp concurrency should be positive",
+		},
+		"unknown ack mode": {
+			func(c *Config) { c.AMQP.AckMode = "never" },
+			"amqp ack mode \"never\" should be either manual or auto",
+		},
+		"unknown payload format": {
+			func(c *Config) { c.AMQP.PayloadFormat = "xml" },
+			"amqp payload format \"xml\" is not supported",
+		},
+		"invalid data path": {
+			func(c *Config) { c.AMQP.DataPath = "data." },
+			"amqp data path \"data.\" is not valid",
+		},
+		"negative max recipients": {
+			func(c *Config) { c.MaxRecipients = -1 },
+			"max recipients should not be negative",
+		},
+		"invalid render timeout": {
+			func(c *Config) { c.RenderTimeout = "fast" },
+			"render timeout \"fast\" is not valid",
+		},
+		"negative render timeout": {
+			func(c *Config) { c.RenderTimeout = "-1s" },
+			"render timeout \"-1s\" is not valid",
+		},
+		"unknown default language": {
+			func(c *Config) { c.DefaultLanguage = "DE" },
+			"default language \"DE\" is not configured",
+		},
+		"invalid content type": {
+			func(c *Config) { c.ContentType = "text/html\r\nBcc: x@y.com" },
+			"content type \"text/html\r\nBcc: x@y.com\" with charset \"iso-8859-1\" is invalid",
+		},
+		"invalid charset": {
+			func(c *Config) { c.Charset = "utf-8; format=flowed" },
+			"content type \"text/html\" with charset \"utf-8; format=flowed\" is invalid",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tmp := SampleConfig()
+			tc.tweak(&tmp)
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			res, err := Validate(bytes.NewReader(configAsBytes))
+			assert.False(t, res)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestConfig_RenderBudget(t *testing.T) {
+	assert.Equal(t, time.Duration(0), (&Config{}).RenderBudget())
+	assert.Equal(t, 250*time.Millisecond, (&Config{RenderTimeout: "250ms"}).RenderBudget())
+}
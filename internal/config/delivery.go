@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Providers of Sender.Provider.
+const (
+	ProviderSMTP     = "smtp"
+	ProviderSES      = "ses"
+	ProviderSendGrid = "sendgrid"
+	ProviderMailgun  = "mailgun"
+)
+
+// Mailgun regions of Sender.Region.
+const (
+	MailgunUS = "us"
+	MailgunEU = "eu"
+)
+
+// API reports whether sender delivers through provider API instead of SMTP.
+func (s *Sender) API() bool {
+	return s != nil && s.Provider != "" && s.Provider != ProviderSMTP
+}
+
+// validateSender checks sender has settings of its provider, label names it in errors.
+func validateSender(label string, sender Sender) error {
+	switch sender.Provider {
+	case "", ProviderSMTP:
+		if sender.Host == "" || sender.Port == "" {
+			return fmt.Errorf("%s should have host and port", label)
+		}
+	case ProviderSES:
+		if sender.Region == "" || sender.Username == "" {
+			return fmt.Errorf("%s should have region and username", label)
+		}
+	case ProviderSendGrid:
+	case ProviderMailgun:
+		if sender.Domain == "" {
+			return fmt.Errorf("%s should have domain", label)
+		}
+
+		if sender.Region != "" && sender.Region != MailgunUS && sender.Region != MailgunEU {
+			return fmt.Errorf("%s region \"%s\" should be %s or %s", label, sender.Region, MailgunUS, MailgunEU)
+		}
+	default:
+		return fmt.Errorf("%s provider \"%s\" should be %s", label, sender.Provider,
+			strings.Join([]string{ProviderSMTP, ProviderSES, ProviderSendGrid, ProviderMailgun}, ", "))
+	}
+
+	if (sender.Password == "") == (sender.PasswordEnv == "") {
+		return fmt.Errorf("%s should have either password or password_env", label)
+	}
+
+	if sender.Password != "" && !strings.HasPrefix(sender.Password, SecretScheme) {
+		return fmt.Errorf("%s password should be a %s reference", label, SecretScheme)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSender_API(t *testing.T) {
+	var nilSender *Sender
+	assert.False(t, nilSender.API())
+	assert.False(t, (&Sender{}).API())
+	assert.False(t, (&Sender{Provider: ProviderSMTP}).API())
+	assert.True(t, (&Sender{Provider: ProviderSES}).API())
+}
+
+func TestValidateSender(t *testing.T) {
+	cases := map[string]Sender{
+		"":                                   {Provider: ProviderSES, Region: "eu-west-1", Username: "AKID", PasswordEnv: "SES_KEY"},
+		"delivery should have host and port": {PasswordEnv: "SMTP_PASSWORD"},
+		"delivery should have region and username":             {Provider: ProviderSES, PasswordEnv: "SES_KEY"},
+		"delivery should have either password or password_env": {Provider: ProviderSendGrid},
+		"delivery should have domain":                          {Provider: ProviderMailgun, PasswordEnv: "MAILGUN_KEY"},
+		"delivery region \"ap\" should be us or eu": {
+			Provider: ProviderMailgun, Region: "ap", Domain: "mg.example.com", PasswordEnv: "MAILGUN_KEY",
+		},
+		"delivery provider \"postmark\" should be smtp, ses, sendgrid, mailgun": {Provider: "postmark"},
+		"delivery password should be a secret:// reference":                     {Provider: ProviderSendGrid, Password: "SG.key"},
+	}
+
+	for expected, sender := range cases {
+		sender := sender
+		t.Run(expected, func(t *testing.T) {
+			tmp := SampleConfig()
+			tmp.Delivery = &sender
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			_, err = Validate(bytes.NewReader(configAsBytes))
+			if expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, expected)
+		})
+	}
+
+	t.Run("named sender", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Senders = map[string]Sender{"mailgun": {Provider: ProviderMailgun, PasswordEnv: "MAILGUN_KEY"}}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "sender \"mailgun\" should have domain")
+	})
+}
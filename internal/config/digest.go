@@ -0,0 +1,51 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+)
+
+// RenderDigest renders digest template of language, see Template.RenderDigest.
+func (e *Event) RenderDigest(lang string, items []interface{}, shellData interface{}) ([]byte, error) {
+	tpl := e.Template(lang)
+	return tpl.RenderDigest(items, shellData)
+}
+
+// RenderDigest renders item template per entry and injects them into
+// the shell, which places rendered items with {{ items }}.
+func (t *Template) RenderDigest(items []interface{}, shellData interface{}) ([]byte, error) {
+	if t.Item == "" {
+		return nil, errors.New("template has no digest item")
+	}
+
+	item, err := template.New("item").Funcs(templateFuncs()).Parse(t.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := new(bytes.Buffer)
+	for _, data := range items {
+		if err := item.Funcs(t.renderFuncs(data)).Execute(rendered, data); err != nil {
+			return nil, err
+		}
+	}
+
+	funcs := templateFuncs()
+	funcs["items"] = func() template.HTML {
+		// Items are escaped while rendered.
+		return template.HTML(rendered.String())
+	}
+
+	shell, err := t.parse(funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	buff := new(bytes.Buffer)
+	if err := shell.Funcs(t.renderFuncs(shellData)).Execute(buff, shellData); err != nil {
+		return nil, err
+	}
+
+	return t.withPreheader(buff.Bytes(), shellData)
+}
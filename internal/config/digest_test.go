@@ -0,0 +1,61 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_RenderDigest(t *testing.T) {
+	event := Event{
+		Templates: map[string]Template{
+			"EN": {
+				Subject:  "Weekly digest",
+				Template: "<h1>Hi {{ .name }}</h1><ul>{{ items }}</ul>",
+				Item:     "<li>{{ .title }}</li>",
+			},
+			"RU": {
+				Subject:  "Дайджест",
+				Template: "Привет",
+			},
+		},
+	}
+
+	items := []interface{}{
+		map[string]interface{}{"title": "Deposit"},
+		map[string]interface{}{"title": "Trade <BTC>"},
+		map[string]interface{}{"title": "Withdrawal"},
+	}
+
+	t.Run("renders items into shell", func(t *testing.T) {
+		content, err := event.RenderDigest("EN", items, map[string]interface{}{"name": "John"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "<h1>Hi John</h1><ul><li>Deposit</li><li>Trade &lt;BTC&gt;</li><li>Withdrawal</li></ul>", string(content))
+	})
+
+	t.Run("renders empty digest", func(t *testing.T) {
+		content, err := event.RenderDigest("EN", nil, map[string]interface{}{"name": "John"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "<h1>Hi John</h1><ul></ul>", string(content))
+	})
+
+	t.Run("fails without item template", func(t *testing.T) {
+		_, err := event.RenderDigest("RU", items, nil)
+		assert.EqualError(t, err, "template has no digest item")
+	})
+}
+
+func TestValidate_DigestItem(t *testing.T) {
+	tmp := SampleConfig()
+	tmp.Events[0].Templates["EN"] = Template{Subject: "Digest", Template: "{{ items }}", Item: "{{ .title"}
+
+	configAsBytes, err := yaml.Marshal(tmp)
+	assert.NoError(t, err)
+
+	_, err = Validate(bytes.NewReader(configAsBytes))
+	assert.EqualError(t, err, "language \"EN\" in event \"Example\": digest item: template: item:1: unclosed action")
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// DefaultDKIMHeaders are signed, when DKIM doesn't list headers.
+var DefaultDKIMHeaders = []string{"From", "To", "Cc", "Subject", "Reply-To", "MIME-Version", "Content-Type"}
+
+// DKIM signs outgoing emails with the private key, published in DNS
+// as TXT record of "<selector>._domainkey.<domain>".
+type DKIM struct {
+	Domain         string `yaml:"domain"`
+	Selector       string `yaml:"selector"`
+	PrivateKeyPath string `yaml:"private_key_path"`
+	// Headers to sign, DefaultDKIMHeaders when empty. From must be signed.
+	Headers []string `yaml:"headers,omitempty"`
+}
+
+// SignedHeaders returns headers to sign.
+func (d *DKIM) SignedHeaders() []string {
+	if len(d.Headers) == 0 {
+		return DefaultDKIMHeaders
+	}
+
+	return d.Headers
+}
+
+// PrivateKey reads RSA private key in either PKCS #1 or PKCS #8 PEM form.
+func (d *DKIM) PrivateKey() (*rsa.PrivateKey, error) {
+	content, err := ioutil.ReadFile(d.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+
+	return rsaKey, nil
+}
+
+func (d *DKIM) validate() error {
+	if d == nil {
+		return nil
+	}
+
+	// Both are written into DKIM-Signature header as is.
+	for name, value := range map[string]string{"domain": d.Domain, "selector": d.Selector} {
+		if value == "" || strings.ContainsAny(value, "; \t\r\n") {
+			return fmt.Errorf("%s \"%s\" is not valid", name, value)
+		}
+	}
+
+	from := false
+	for _, header := range d.SignedHeaders() {
+		if !ValidHeaderName(header) {
+			return fmt.Errorf("header \"%s\" is not a valid header name", header)
+		}
+		from = from || strings.EqualFold(header, "From")
+	}
+
+	if !from {
+		return errors.New("headers should include From")
+	}
+
+	if _, err := d.PrivateKey(); err != nil {
+		return fmt.Errorf("private key: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDKIM_Validate(t *testing.T) {
+	valid := func() DKIM {
+		return DKIM{Domain: "postmaster.com", Selector: "mail", PrivateKeyPath: "../../test/sample.key"}
+	}
+
+	cases := map[string]func(d *DKIM){
+		"dkim: domain \"\" is not valid":                       func(d *DKIM) { d.Domain = "" },
+		"dkim: selector \"mail; h=x\" is not valid":            func(d *DKIM) { d.Selector = "mail; h=x" },
+		"dkim: header \"Sub ject\" is not a valid header name": func(d *DKIM) { d.Headers = []string{"From", "Sub ject"} },
+		"dkim: headers should include From":                    func(d *DKIM) { d.Headers = []string{"Subject"} },
+		"dkim: private key: open ../../test/missing.key: no such file or directory": func(d *DKIM) {
+			d.PrivateKeyPath = "../../test/missing.key"
+		},
+		"dkim: private key: private key is not PEM encoded": func(d *DKIM) { d.PrivateKeyPath = "../../test/test.tpl" },
+	}
+
+	for expected, change := range cases {
+		change := change
+		t.Run(expected, func(t *testing.T) {
+			dkim := valid()
+			change(&dkim)
+			tmp := SampleConfig()
+			tmp.DKIM = &dkim
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			res, err := Validate(bytes.NewReader(configAsBytes))
+
+			assert.EqualError(t, err, expected)
+			assert.False(t, res)
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		dkim := valid()
+		tmp := SampleConfig()
+		tmp.DKIM = &dkim
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.NoError(t, err)
+		assert.True(t, res)
+		assert.Equal(t, DefaultDKIMHeaders, dkim.SignedHeaders())
+
+		key, err := dkim.PrivateKey()
+		assert.NoError(t, err)
+		assert.Equal(t, 2048, key.N.BitLen())
+	})
+}
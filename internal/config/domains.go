@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// AllowsRecipient reports whether event may be sent to address.
+// Every address is allowed, when event has no allowed domains.
+func (e *Event) AllowsRecipient(address string) bool {
+	if len(e.AllowedDomains) == 0 {
+		return true
+	}
+
+	domain := DomainKey(address[strings.LastIndex(address, "@")+1:])
+	for _, allowed := range e.AllowedDomains {
+		if DomainKey(allowed) == domain {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateAllowedDomains(domains []string) error {
+	for _, domain := range domains {
+		if _, err := idna.Lookup.ToASCII(domain); err != nil || domain == "" || strings.ContainsAny(domain, "@ ") {
+			return fmt.Errorf("allowed domain \"%s\" is not valid", domain)
+		}
+	}
+
+	return nil
+}
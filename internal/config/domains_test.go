@@ -0,0 +1,34 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvent_AllowsRecipient(t *testing.T) {
+	event := Event{AllowedDomains: []string{"example.com", "müller.de"}}
+
+	assert.True(t, event.AllowsRecipient("john@example.com"))
+	assert.True(t, event.AllowsRecipient("john@Example.COM"))
+	assert.True(t, event.AllowsRecipient("john@xn--mller-kva.de"))
+	assert.False(t, event.AllowsRecipient("john@mail.example.com"))
+	assert.False(t, event.AllowsRecipient("john@gmail.com"))
+
+	assert.True(t, (&Event{}).AllowsRecipient("john@gmail.com"))
+}
+
+func TestValidateAllowedDomains(t *testing.T) {
+	tmp := SampleConfig()
+	tmp.Events[0].AllowedDomains = []string{"example.com", "@example.com"}
+
+	configAsBytes, err := yaml.Marshal(tmp)
+	assert.NoError(t, err)
+
+	res, err := Validate(bytes.NewReader(configAsBytes))
+
+	assert.EqualError(t, err, "event \"Example\": allowed domain \"@example.com\" is not valid")
+	assert.False(t, res)
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PayloadData returns object at data path of payload, see AMQP.DataPath.
+// Payload itself is returned, when data path is empty.
+func (a *AMQP) PayloadData(payload map[string]interface{}) (map[string]interface{}, error) {
+	if a.DataPath == "" {
+		return payload, nil
+	}
+
+	data := payload
+	for _, key := range strings.Split(a.DataPath, ".") {
+		nested, ok := data[key].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("payload has no object at data path \"%s\"", a.DataPath)
+		}
+		data = nested
+	}
+
+	return data, nil
+}
+
+// WithPayloadData returns copy of payload with object at data path replaced by data.
+// Payload isn't modified.
+func (a *AMQP) WithPayloadData(payload, data map[string]interface{}) map[string]interface{} {
+	if a.DataPath == "" {
+		return data
+	}
+
+	return withData(payload, strings.Split(a.DataPath, "."), data)
+}
+
+func withData(payload map[string]interface{}, path []string, data map[string]interface{}) map[string]interface{} {
+	if len(path) == 0 {
+		return data
+	}
+
+	dup := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		dup[k] = v
+	}
+
+	nested, _ := payload[path[0]].(map[string]interface{})
+	dup[path[0]] = withData(nested, path[1:], data)
+
+	return dup
+}
+
+func validateDataPath(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("amqp data path \"%s\" is not valid", path)
+		}
+	}
+
+	return nil
+}
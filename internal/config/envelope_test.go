@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAMQP_PayloadData(t *testing.T) {
+	payload := map[string]interface{}{
+		"language": "EN",
+		"meta":     map[string]interface{}{"source": "billing"},
+		"body": map[string]interface{}{
+			"data": map[string]interface{}{"plan": "pro"},
+		},
+	}
+
+	t.Run("returns payload without data path", func(t *testing.T) {
+		data, err := (&AMQP{}).PayloadData(payload)
+		assert.NoError(t, err)
+		assert.Equal(t, payload, data)
+	})
+
+	t.Run("returns nested object", func(t *testing.T) {
+		data, err := (&AMQP{DataPath: "body.data"}).PayloadData(payload)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"plan": "pro"}, data)
+	})
+
+	t.Run("fails on missing object", func(t *testing.T) {
+		for _, path := range []string{"data", "body.data.plan", "language"} {
+			_, err := (&AMQP{DataPath: path}).PayloadData(payload)
+			assert.EqualError(t, err, "payload has no object at data path \""+path+"\"")
+		}
+	})
+
+	t.Run("replaces nested object in copy", func(t *testing.T) {
+		conf := &AMQP{DataPath: "body.data"}
+		replaced := conf.WithPayloadData(payload, map[string]interface{}{"plan": "team"})
+
+		data, err := conf.PayloadData(replaced)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"plan": "team"}, data)
+		assert.Equal(t, "EN", replaced["language"])
+
+		data, _ = conf.PayloadData(payload)
+		assert.Equal(t, map[string]interface{}{"plan": "pro"}, data)
+	})
+}
+
+func TestValidateDataPath(t *testing.T) {
+	assert.NoError(t, validateDataPath(""))
+	assert.NoError(t, validateDataPath("data"))
+	assert.NoError(t, validateDataPath("body.data"))
+
+	for _, path := range []string{" ", ".", "data.", ".data", "body..data"} {
+		assert.EqualError(t, validateDataPath(path), "amqp data path \""+path+"\" is not valid")
+	}
+}
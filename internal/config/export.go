@@ -0,0 +1,66 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/go-yaml/yaml"
+)
+
+// Redacted replaces values of secret fields in exported configuration.
+const Redacted = "REDACTED"
+
+// Marshal exports effective configuration as YAML.
+// String fields tagged with `secret:"true"` are replaced with Redacted.
+func (c *Config) Marshal() ([]byte, error) {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work on a copy, so redaction doesn't touch the loaded config.
+	dup := Config{}
+	if err := yaml.Unmarshal(content, &dup); err != nil {
+		return nil, err
+	}
+
+	redact(reflect.ValueOf(&dup).Elem())
+
+	return yaml.Marshal(&dup)
+}
+
+func redact(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			redact(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+
+			if v.Type().Field(i).Tag.Get("secret") == "true" && field.Kind() == reflect.String {
+				if field.String() != "" {
+					field.SetString(Redacted)
+				}
+				continue
+			}
+
+			redact(field)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redact(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// Map values aren't addressable, so redact a copy and put it back.
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			redact(value)
+			v.SetMapIndex(key, value)
+		}
+	}
+}
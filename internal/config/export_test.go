@@ -0,0 +1,63 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Marshal(t *testing.T) {
+	t.Run("round trips loaded config", func(t *testing.T) {
+		file, err := os.Open(configPath)
+		assert.NoError(t, err)
+		defer file.Close()
+		defer chdir(t, "../..")()
+
+		conf, _, err := Load(file, LoadOptions{})
+		assert.NoError(t, err)
+
+		content, err := conf.Marshal()
+		assert.NoError(t, err)
+
+		reloaded, _, err := Load(bytes.NewReader(content), LoadOptions{})
+		assert.NoError(t, err)
+		conf.attachRegistry(nil)
+		reloaded.attachRegistry(nil)
+		assert.Equal(t, conf, reloaded)
+	})
+
+	t.Run("is stable across runs", func(t *testing.T) {
+		conf := catalogConfig()
+		conf.Senders = map[string]Sender{
+			"ses":      {Host: "email-smtp.eu-west-1.amazonaws.com", Port: "587", PasswordEnv: "SES_KEY"},
+			"sendgrid": {Host: "smtp.sendgrid.net", Port: "587", PasswordEnv: "SENDGRID_KEY"},
+			"bulk":     {Host: "bulk.example.com", Port: "25", PasswordEnv: "BULK_KEY"},
+		}
+
+		expected, err := conf.Marshal()
+		assert.NoError(t, err)
+
+		for i := 0; i < 20; i++ {
+			content, err := conf.Marshal()
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), string(content))
+		}
+	})
+
+	t.Run("redacts secret fields", func(t *testing.T) {
+		type credentials struct {
+			Username string
+			Password string `secret:"true"`
+		}
+		creds := map[string]credentials{"smtp": {Username: "apikey", Password: "hunter2"}}
+
+		v := reflect.ValueOf(&creds).Elem()
+		redact(v)
+
+		assert.Equal(t, "apikey", creds["smtp"].Username)
+		assert.Equal(t, Redacted, creds["smtp"].Password)
+	})
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizeLanguage brings language code of incoming event to the configured form,
+// e.g. "en_gb" to "EN-GB".
+func NormalizeLanguage(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "_", "-"))
+}
+
+// baseLanguage returns language of regional variant, e.g. "EN" of "EN-GB",
+// empty for codes without region.
+func baseLanguage(code string) string {
+	if i := strings.Index(code, "-"); i > 0 {
+		return code[:i]
+	}
+
+	return ""
+}
+
+// ResolveLanguage maps language code of incoming event onto configured language:
+// normalized code, when it's configured, then its base language, e.g. "EN" of "en-GB",
+// then default language.
+func (c *Config) ResolveLanguage(code string) (string, error) {
+	code = NormalizeLanguage(code)
+
+	for _, candidate := range []string{code, baseLanguage(code), c.DefaultLanguage} {
+		if candidate != "" && c.ContainsLanguage(candidate) {
+			return strings.ToUpper(candidate), nil
+		}
+	}
+
+	return "", fmt.Errorf("language %s is not supported", code)
+}
+
+// FallbackChain returns code followed by its fallback languages, nearest first.
+// Regional variant without fallback falls back to its base language, when it's configured.
+func (c *Config) FallbackChain(code string) []string {
+	chain := []string{strings.ToUpper(code)}
+	seen := map[string]bool{chain[0]: true}
+
+	for {
+		fallback := c.fallback(chain[len(chain)-1])
+		if fallback == "" || seen[fallback] {
+			return chain
+		}
+
+		seen[fallback] = true
+		chain = append(chain, fallback)
+	}
+}
+
+func (c *Config) fallback(code string) string {
+	for _, lang := range c.Languages {
+		if !strings.EqualFold(lang.Code, code) {
+			continue
+		}
+
+		if lang.Fallback != "" {
+			return lang.Fallback
+		}
+
+		if base := baseLanguage(strings.ToUpper(code)); base != "" && c.ContainsLanguage(base) {
+			return base
+		}
+
+		return ""
+	}
+
+	return ""
+}
+
+// ResolveTemplate picks template of event for recipient in the first language of
+// the fallback chain, which has one. Override of tenant wins over base template of
+// the same language, see Event.TemplateFor for the latter. DefaultTemplate is used,
+// when no language of the chain has a template. Runtime override, see Override,
+// wins over all of them. Template looks strings up in catalog of lang.
+func (c *Config) ResolveTemplate(event *Event, tenant, lang, segment, recipient string) (Template, string) {
+	tpl, version := c.resolveTemplate(event, tenant, lang, segment, recipient)
+	tpl.catalog = c.catalog(lang)
+	return tpl, version
+}
+
+func (c *Config) resolveTemplate(event *Event, tenant, lang, segment, recipient string) (Template, string) {
+	if tpl, ok := c.override(event.Key, lang); ok {
+		return tpl, OverrideVersion
+	}
+
+	overrides := event.Tenants[tenant]
+
+	for _, code := range c.FallbackChain(lang) {
+		key := TemplateKey(code, segment)
+		if tpl, ok := languageTemplate(overrides, key); ok {
+			return tpl, BaseVersion
+		}
+
+		if _, ok := languageTemplate(event.Templates, key); ok {
+			return event.TemplateFor(key, recipient)
+		}
+	}
+
+	if tpl, ok := overrides[DefaultTemplate]; ok {
+		return tpl, BaseVersion
+	}
+
+	return event.TemplateFor(TemplateKey(lang, segment), recipient)
+}
+
+// ResolveTemplate returns template of language code, falling back from regional
+// variant to its base language, e.g. from "en-GB" to "EN", then to DefaultTemplate.
+// Config.ResolveTemplate also follows configured fallbacks, tenants and rollout.
+func (e *Event) ResolveTemplate(code string) (Template, error) {
+	code = NormalizeLanguage(code)
+
+	for _, key := range []string{code, baseLanguage(code), DefaultTemplate} {
+		if tpl, ok := e.Templates[key]; key != "" && ok {
+			return tpl, nil
+		}
+	}
+
+	return Template{}, fmt.Errorf("event \"%s\" has no template of language \"%s\"", e.Key, code)
+}
+
+// hasTemplate reports whether event defines template of any language in chain.
+func (e *Event) hasTemplate(chain []string) bool {
+	for _, code := range chain {
+		if _, ok := e.Templates[code]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateFallbacks checks fallbacks reference configured languages
+// and every chain terminates.
+func validateFallbacks(conf Config) error {
+	for _, lang := range conf.Languages {
+		if lang.Fallback == "" {
+			continue
+		}
+
+		if lang.Fallback != strings.ToUpper(lang.Fallback) {
+			return fmt.Errorf("fallback \"%s\" of language \"%s\" should be uppercased", lang.Fallback, lang.Code)
+		}
+
+		if !conf.ContainsLanguage(lang.Fallback) {
+			return fmt.Errorf("fallback \"%s\" of language \"%s\" is not configured", lang.Fallback, lang.Code)
+		}
+
+		chain := conf.FallbackChain(lang.Code)
+		if next := conf.fallback(chain[len(chain)-1]); next != "" {
+			return fmt.Errorf("fallback chain of language \"%s\" does not terminate: %s -> %s",
+				lang.Code, strings.Join(chain, " -> "), next)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func fallbackConfig() Config {
+	conf := SampleConfig()
+	conf.Languages = []Language{
+		{Code: "EN", Name: "English"},
+		{Code: "PT", Name: "Portuguese", Fallback: "EN"},
+		{Code: "PT-BR", Name: "Brazilian Portuguese", Fallback: "PT"},
+	}
+
+	return conf
+}
+
+func TestConfig_FallbackChain(t *testing.T) {
+	conf := fallbackConfig()
+
+	assert.Equal(t, []string{"PT-BR", "PT", "EN"}, conf.FallbackChain("pt-br"))
+	assert.Equal(t, []string{"EN"}, conf.FallbackChain("EN"))
+	assert.Equal(t, []string{"RU"}, conf.FallbackChain("RU"))
+
+	t.Run("regional variant without fallback", func(t *testing.T) {
+		conf.Languages = append(conf.Languages, Language{Code: "EN-GB", Name: "British English"})
+
+		assert.Equal(t, []string{"EN-GB", "EN"}, conf.FallbackChain("en-gb"))
+	})
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	assert.Equal(t, "EN-GB", NormalizeLanguage(" en_gb "))
+	assert.Equal(t, "PT-BR", NormalizeLanguage("pt-BR"))
+	assert.Equal(t, "", NormalizeLanguage(""))
+}
+
+func TestConfig_ResolveLanguage(t *testing.T) {
+	conf := fallbackConfig()
+	conf.DefaultLanguage = "EN"
+
+	for code, expected := range map[string]string{
+		"pt_br": "PT-BR",
+		"PT-PT": "PT",
+		"en-GB": "EN",
+		"RU":    "EN",
+		"":      "EN",
+	} {
+		lang, err := conf.ResolveLanguage(code)
+		assert.NoError(t, err, code)
+		assert.Equal(t, expected, lang, code)
+	}
+
+	t.Run("without default", func(t *testing.T) {
+		conf.DefaultLanguage = ""
+
+		_, err := conf.ResolveLanguage("ru-RU")
+		assert.EqualError(t, err, "language RU-RU is not supported")
+	})
+}
+
+func TestEvent_ResolveTemplate(t *testing.T) {
+	en := Template{Subject: "Hello"}
+	def := Template{Subject: "Default"}
+	event := Event{Key: "example", Templates: map[string]Template{"EN": en}}
+
+	for _, code := range []string{"EN", "en", "en_GB", "EN-US"} {
+		tpl, err := event.ResolveTemplate(code)
+		assert.NoError(t, err, code)
+		assert.Equal(t, en, tpl, code)
+	}
+
+	_, err := event.ResolveTemplate("ru-RU")
+	assert.EqualError(t, err, "event \"example\" has no template of language \"RU-RU\"")
+
+	event.Templates[DefaultTemplate] = def
+	tpl, err := event.ResolveTemplate("ru-RU")
+	assert.NoError(t, err)
+	assert.Equal(t, def, tpl)
+}
+
+func TestConfig_ResolveTemplate(t *testing.T) {
+	en := Template{Subject: "Hello", Template: "Hello"}
+	pt := Template{Subject: "Olá", Template: "Olá"}
+
+	t.Run("resolves pt-BR to pt", func(t *testing.T) {
+		conf := fallbackConfig()
+		event := &conf.Events[0]
+		event.Templates = map[string]Template{"EN": en, "PT": pt}
+
+		tpl, version := conf.ResolveTemplate(event, "", "PT-BR", "", "john@example.com")
+		assert.Equal(t, pt, tpl)
+		assert.Equal(t, BaseVersion, version)
+	})
+
+	t.Run("resolves pt-BR to EN", func(t *testing.T) {
+		conf := fallbackConfig()
+		event := &conf.Events[0]
+		event.Templates = map[string]Template{"EN": en}
+
+		tpl, _ := conf.ResolveTemplate(event, "", "PT-BR", "", "john@example.com")
+		assert.Equal(t, en, tpl)
+	})
+
+	t.Run("prefers segment template of nearer language", func(t *testing.T) {
+		conf := fallbackConfig()
+		event := &conf.Events[0]
+		vip := Template{Subject: "VIP", Template: "VIP"}
+		event.Templates = map[string]Template{"EN": en, "PT:vip": vip}
+
+		tpl, _ := conf.ResolveTemplate(event, "", "PT-BR", "vip", "john@example.com")
+		assert.Equal(t, vip, tpl)
+	})
+
+	t.Run("falls back to default template", func(t *testing.T) {
+		conf := fallbackConfig()
+		event := &conf.Events[0]
+		event.Templates = map[string]Template{DefaultTemplate: en}
+
+		tpl, _ := conf.ResolveTemplate(event, "", "PT-BR", "", "john@example.com")
+		assert.Equal(t, en, tpl)
+	})
+}
+
+func TestValidate_Fallbacks(t *testing.T) {
+	load := func(conf Config) error {
+		configAsBytes, err := yaml.Marshal(conf)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		return err
+	}
+
+	t.Run("languages without templates use chain", func(t *testing.T) {
+		assert.NoError(t, load(fallbackConfig()))
+	})
+
+	t.Run("chain without templates", func(t *testing.T) {
+		conf := fallbackConfig()
+		conf.Languages[0].Fallback = ""
+		conf.Languages = append(conf.Languages, Language{Code: "RU", Name: "Russian"})
+
+		assert.EqualError(t, load(conf), "language \"RU\" in event \"Example\" is not defined")
+	})
+
+	t.Run("default language without template", func(t *testing.T) {
+		conf := SampleConfig()
+		conf.Languages = []Language{{Code: "EN", Name: "English", Disabled: true}, {Code: "RU", Name: "Russian"}}
+		conf.Events[0].Templates = map[string]Template{"RU": {Subject: "Пример", Template: "Йо"}}
+
+		assert.EqualError(t, load(conf), "default language \"EN\" in event \"Example\" is not defined")
+	})
+
+	t.Run("unknown language", func(t *testing.T) {
+		conf := fallbackConfig()
+		conf.Languages[1].Fallback = "ES"
+
+		assert.EqualError(t, load(conf), "fallback \"ES\" of language \"PT\" is not configured")
+	})
+
+	t.Run("lower cased fallback", func(t *testing.T) {
+		conf := fallbackConfig()
+		conf.Languages[2].Fallback = "pt"
+
+		assert.EqualError(t, load(conf), "fallback \"pt\" of language \"PT-BR\" should be uppercased")
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		conf := fallbackConfig()
+		conf.Languages[0].Fallback = "PT-BR"
+
+		assert.EqualError(t, load(conf), "fallback chain of language \"EN\" does not terminate: EN -> PT-BR -> PT -> EN")
+	})
+
+	t.Run("self reference", func(t *testing.T) {
+		conf := fallbackConfig()
+		conf.Languages[0].Fallback = "EN"
+
+		assert.EqualError(t, load(conf), "fallback chain of language \"EN\" does not terminate: EN -> EN")
+	})
+}
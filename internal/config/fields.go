@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// CheckFields reports fields, which html, text and preheader bodies or subjects
+// of event templates reference, but type returned by typeOf for event key
+// doesn't declare, e.g. type registered with eventapi.Registry. Events without
+// type and untyped parts of data, e.g. maps of interfaces, aren't checked.
+func (c *Config) CheckFields(typeOf func(eventKey string) (reflect.Type, bool)) error {
+	var problems []string
+
+	for _, event := range c.Events {
+		typ, ok := typeOf(event.Key)
+		if !ok {
+			continue
+		}
+
+		templates := labeledTemplates(event)
+		labels := make([]string, 0, len(templates))
+		for label := range templates {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+
+		for _, label := range labels {
+			tpl := templates[label]
+			// Broken templates are reported by validate.
+			parts, _ := tpl.parts()
+
+			for _, part := range parts {
+				if part.name == "item" {
+					// Items aren't rendered with event data.
+					continue
+				}
+
+				for _, chain := range fieldRefs(part.root.Root, true, nil) {
+					if field := undeclaredField(typ, chain); field != "" {
+						problems = append(problems, fmt.Sprintf("event \"%s\" template %s: %s references undeclared field %s",
+							event.Key, label, part.name, field))
+					}
+				}
+
+				if part.name == "subject" {
+					problems = append(problems, c.checkFragmentFields(typ, event.Key, label, part.root)...)
+				}
+			}
+		}
+	}
+
+	if len(problems) != 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// checkFragmentFields reports undeclared fields of fragments, which subject references.
+func (c *Config) checkFragmentFields(typ reflect.Type, eventKey, label string, subject *parse.Tree) []string {
+	var problems []string
+
+	for _, name := range funcRefs(subject.Root, "frag", nil) {
+		fragment, err := texttemplate.New(name).Parse(c.Subjects[name])
+		if err != nil {
+			continue
+		}
+
+		for _, chain := range fieldRefs(fragment.Tree.Root, true, nil) {
+			if field := undeclaredField(typ, chain); field != "" {
+				problems = append(problems, fmt.Sprintf("event \"%s\" template %s: subject fragment \"%s\" references undeclared field %s",
+					eventKey, label, name, field))
+			}
+		}
+	}
+
+	return problems
+}
+
+// labeledTemplates returns templates of event keyed by language key,
+// prefixed with tenant or rollout for their templates.
+func labeledTemplates(event Event) map[string]Template {
+	templates := make(map[string]Template, len(event.Templates))
+	for key, tpl := range event.Templates {
+		templates[key] = tpl
+	}
+
+	for tenant, overrides := range event.Tenants {
+		for key, tpl := range overrides {
+			templates[fmt.Sprintf("%s of tenant %s", key, tenant)] = tpl
+		}
+	}
+
+	if event.Rollout != nil {
+		for key, tpl := range event.Rollout.Templates {
+			templates[fmt.Sprintf("%s of rollout %s", key, event.Rollout.Version)] = tpl
+		}
+	}
+
+	return templates
+}
+
+// fieldRefs collects chains of fields evaluated against data template is
+// executed with, e.g. {{ .User.Email }} and {{ $.User.Email }}. Inside range
+// and with dot is other data, so only chains starting with $ are collected there.
+func fieldRefs(node parse.Node, root bool, refs [][]string) [][]string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return refs
+		}
+		for _, child := range n.Nodes {
+			refs = fieldRefs(child, root, refs)
+		}
+	case *parse.ActionNode:
+		refs = fieldRefs(n.Pipe, root, refs)
+	case *parse.IfNode:
+		refs = fieldRefs(n.Pipe, root, refs)
+		refs = fieldRefs(n.List, root, refs)
+		refs = fieldRefs(n.ElseList, root, refs)
+	case *parse.RangeNode:
+		refs = fieldRefs(n.Pipe, root, refs)
+		refs = fieldRefs(n.List, false, refs)
+		refs = fieldRefs(n.ElseList, root, refs)
+	case *parse.WithNode:
+		refs = fieldRefs(n.Pipe, root, refs)
+		refs = fieldRefs(n.List, false, refs)
+		refs = fieldRefs(n.ElseList, root, refs)
+	case *parse.TemplateNode:
+		refs = fieldRefs(n.Pipe, root, refs)
+	case *parse.PipeNode:
+		if n == nil {
+			return refs
+		}
+		for _, cmd := range n.Cmds {
+			refs = fieldRefs(cmd, root, refs)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			refs = fieldRefs(arg, root, refs)
+		}
+	case *parse.FieldNode:
+		if root {
+			refs = append(refs, n.Ident)
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			refs = append(refs, n.Ident[1:])
+		}
+	}
+
+	return refs
+}
+
+// undeclaredField returns chain up to the first field, which typ doesn't declare
+// either as exported field or method, empty when the whole chain is declared
+// or can't be checked.
+func undeclaredField(typ reflect.Type, chain []string) string {
+	for i, name := range chain {
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		if method, ok := reflect.PtrTo(typ).MethodByName(name); ok {
+			if method.Type.NumOut() == 0 {
+				return ""
+			}
+			typ = method.Type.Out(0)
+			continue
+		}
+
+		switch typ.Kind() {
+		case reflect.Struct:
+			field, ok := typ.FieldByName(name)
+			if !ok || field.PkgPath != "" {
+				return "." + strings.Join(chain[:i+1], ".")
+			}
+			typ = field.Type
+		case reflect.Map:
+			typ = typ.Elem()
+		default:
+			return ""
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type signupUser struct {
+	Email string
+	Name  string
+}
+
+type signupEvent struct {
+	User    signupUser
+	Friends []signupUser
+	Extra   map[string]interface{}
+	secret  string
+}
+
+func (e *signupEvent) Greeting() string {
+	return "Hi " + e.User.Name
+}
+
+func TestConfig_CheckFields(t *testing.T) {
+	typeOf := func(key string) (reflect.Type, bool) {
+		if key != "user.signup" {
+			return nil, false
+		}
+		return reflect.TypeOf(&signupEvent{}), true
+	}
+
+	check := func(tpl Template) error {
+		conf := Config{
+			Subjects: map[string]string{"hello": "Hello {{ .User.Nickname }}"},
+			Events: []Event{
+				{Key: "user.signup", Templates: map[string]Template{"EN": tpl}},
+				{Key: "user.untyped", Templates: map[string]Template{"EN": {Subject: "{{ .Anything }}", Template: "{{ .Goes }}"}}},
+			},
+		}
+		return conf.CheckFields(typeOf)
+	}
+
+	t.Run("accepts declared fields", func(t *testing.T) {
+		assert.NoError(t, check(Template{
+			Subject: "Welcome {{ .User.Name }}",
+			Template: "{{ .Greeting }} {{ .User.Email }} {{ .Extra.anything.goes }}" +
+				"{{ range .Friends }}{{ .Name }} {{ $.User.Name }}{{ end }}" +
+				"{{ with .User }}{{ .Email }}{{ end }}",
+			Text: "{{ $user := .User }}{{ $user.Email }}",
+		}))
+	})
+
+	t.Run("reports undeclared field of subject", func(t *testing.T) {
+		assert.EqualError(t, check(Template{Subject: "Welcome {{ .User.Nmae }}", Template: "{{ .User.Name }}"}),
+			"event \"user.signup\" template EN: subject references undeclared field .User.Nmae")
+	})
+
+	t.Run("reports undeclared fields of subject fragments and bodies", func(t *testing.T) {
+		err := check(Template{
+			Subject:   "{{ frag \"hello\" }}",
+			Template:  "{{ .Plan }}{{ range .Friends }}{{ $.Missing }}{{ end }}",
+			Text:      "{{ .secret }}",
+			Preheader: "{{ .User.Phone }}",
+		})
+
+		assert.EqualError(t, err, "event \"user.signup\" template EN: html references undeclared field .Plan; "+
+			"event \"user.signup\" template EN: html references undeclared field .Missing; "+
+			"event \"user.signup\" template EN: preheader references undeclared field .User.Phone; "+
+			"event \"user.signup\" template EN: text references undeclared field .secret; "+
+			"event \"user.signup\" template EN: subject fragment \"hello\" references undeclared field .User.Nickname")
+	})
+
+	t.Run("checks tenant and rollout templates", func(t *testing.T) {
+		conf := Config{Events: []Event{{
+			Key:       "user.signup",
+			Templates: map[string]Template{"EN": {Subject: "Hi", Template: "Hi"}},
+			Tenants:   map[string]map[string]Template{"acme": {"EN": {Subject: "{{ .Tenant }}", Template: "Hi"}}},
+			Rollout:   &Rollout{Version: "v2", Percent: 10, Templates: map[string]Template{"EN": {Subject: "Hi", Template: "{{ .Version }}"}}},
+		}}}
+
+		assert.EqualError(t, conf.CheckFields(typeOf),
+			"event \"user.signup\" template EN of rollout v2: html references undeclared field .Version; "+
+				"event \"user.signup\" template EN of tenant acme: subject references undeclared field .Tenant")
+	})
+}
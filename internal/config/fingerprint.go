@@ -0,0 +1,51 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Fingerprint returns deterministic hex hash of everything affecting template
+// output: subject, variants with their engines, digest item, preheader and provider
+// template. Content of template files is hashed, not their paths.
+func (t *Template) Fingerprint() (string, error) {
+	html, err := variantSource(t.Template, t.TemplatePath)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := variantSource(t.Text, t.TextPath)
+	if err != nil {
+		return "", err
+	}
+
+	fields := []string{
+		"subject", t.Subject,
+		"html/template", html,
+		"text/template", text,
+		"item", t.Item,
+		"preheader", t.Preheader,
+	}
+	// Appended only when set, so hashes of local templates don't change.
+	if t.Hosted() {
+		fields = append(fields, "provider", t.ProviderTemplateID)
+	}
+
+	hash := sha256.New()
+	// Fields are length-prefixed, so moving text between them changes the hash.
+	for _, field := range fields {
+		fmt.Fprintf(hash, "%d:%s", len(field), field)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func variantSource(inline, path string) (string, error) {
+	if strings.TrimSpace(inline) != "" || strings.TrimSpace(path) == "" {
+		return inline, nil
+	}
+
+	return readRef(path)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Fingerprint(t *testing.T) {
+	fingerprint := func(tpl Template) string {
+		hash, err := tpl.Fingerprint()
+		assert.NoError(t, err)
+		return hash
+	}
+
+	tpl := Template{Subject: "Hello", Template: "<p>{{ .Test }}</p>"}
+
+	t.Run("equal templates hash the same", func(t *testing.T) {
+		same := Template{Subject: "Hello", Template: "<p>{{ .Test }}</p>"}
+
+		assert.Len(t, fingerprint(tpl), 64)
+		assert.Equal(t, fingerprint(tpl), fingerprint(same))
+	})
+
+	t.Run("changed subject changes hash", func(t *testing.T) {
+		changed := tpl
+		changed.Subject = "Hello!"
+
+		assert.NotEqual(t, fingerprint(tpl), fingerprint(changed))
+	})
+
+	t.Run("changed preheader changes hash", func(t *testing.T) {
+		changed := tpl
+		changed.Preheader = "Your deposit arrived"
+
+		assert.NotEqual(t, fingerprint(tpl), fingerprint(changed))
+	})
+
+	t.Run("engine is part of hash", func(t *testing.T) {
+		text := Template{Subject: "Hello", Text: "<p>{{ .Test }}</p>"}
+
+		assert.NotEqual(t, fingerprint(tpl), fingerprint(text))
+	})
+
+	t.Run("hashes file content, not path", func(t *testing.T) {
+		content, err := ioutil.ReadFile(templatePath)
+		assert.NoError(t, err)
+
+		file, err := ioutil.TempFile("", "postmaster-*.tpl")
+		assert.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.Write(content)
+		assert.NoError(t, err)
+		file.Close()
+
+		inline := Template{Subject: "Hello", Template: string(content)}
+		original := Template{Subject: "Hello", TemplatePath: templatePath}
+		copied := Template{Subject: "Hello", TemplatePath: file.Name()}
+
+		assert.Equal(t, fingerprint(inline), fingerprint(original))
+		assert.Equal(t, fingerprint(original), fingerprint(copied))
+	})
+
+	t.Run("fails on missing file", func(t *testing.T) {
+		missing := Template{Subject: "Hello", TemplatePath: "../../test/missing.tpl"}
+
+		_, err := missing.Fingerprint()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,46 @@
+package config
+
+// FlagProvider switches template copy on and off, e.g. backed by a flags service.
+// Templates consult it with {{ if flag "new_header" }} at render time.
+type FlagProvider interface {
+	Enabled(flag string, data interface{}) bool
+}
+
+// FlagProviderFunc adapts function to FlagProvider.
+type FlagProviderFunc func(flag string, data interface{}) bool
+
+// Enabled calls f(flag, data).
+func (f FlagProviderFunc) Enabled(flag string, data interface{}) bool {
+	return f(flag, data)
+}
+
+// noFlags is the default provider, which has every flag off.
+type noFlags struct{}
+
+func (noFlags) Enabled(string, interface{}) bool {
+	return false
+}
+
+var flagProvider FlagProvider = noFlags{}
+
+// SetFlagProvider makes templates consult provider, nil turns every flag off.
+func SetFlagProvider(provider FlagProvider) {
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+
+	if provider == nil {
+		provider = noFlags{}
+	}
+	flagProvider = provider
+}
+
+// flagFunc is "flag" template func asking provider about data being rendered.
+func flagFunc(data interface{}) func(flag string) bool {
+	funcsMu.RLock()
+	provider := flagProvider
+	funcsMu.RUnlock()
+
+	return func(flag string) bool {
+		return provider.Enabled(flag, data)
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Flags(t *testing.T) {
+	tpl := Template{
+		Subject:  `{{ if flag "new_header" }}New: {{ end }}Hello`,
+		Template: `{{ if flag "new_header" }}<h1>New</h1>{{ else }}<h1>Old</h1>{{ end }}{{ .name }}`,
+		Text:     `{{ if flag "new_header" }}NEW {{ end }}{{ .name }}`,
+	}
+	data := map[string]interface{}{"name": "John"}
+
+	t.Run("unknown flags are off by default", func(t *testing.T) {
+		content, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<h1>Old</h1>John", string(content))
+
+		subject, err := tpl.RenderSubject(data, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello", subject)
+	})
+
+	t.Run("flag is on", func(t *testing.T) {
+		var asked []interface{}
+		SetFlagProvider(FlagProviderFunc(func(flag string, data interface{}) bool {
+			asked = append(asked, data)
+			return flag == "new_header"
+		}))
+		defer SetFlagProvider(nil)
+
+		content, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<h1>New</h1>John", string(content))
+
+		text, err := tpl.TextContent(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "NEW John", string(text))
+
+		subject, err := tpl.RenderSubject(data, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "New: Hello", subject)
+
+		assert.Equal(t, []interface{}{data, data, data}, asked)
+	})
+
+	t.Run("flag is off", func(t *testing.T) {
+		SetFlagProvider(FlagProviderFunc(func(string, interface{}) bool { return false }))
+		defer SetFlagProvider(nil)
+
+		content, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<h1>Old</h1>John", string(content))
+	})
+
+	t.Run("flag func is reserved", func(t *testing.T) {
+		assert.EqualError(t, RegisterFuncs(map[string]interface{}{"flag": func() bool { return true }}),
+			"template func \"flag\" is reserved")
+	})
+
+	t.Run("validates at load", func(t *testing.T) {
+		assert.NoError(t, tpl.Validate())
+	})
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Names of functions predefined by Go templates or bound at render time, which can't be redefined.
+var reservedFuncs = map[string]bool{
+	"and": true, "call": true, "html": true, "index": true, "slice": true,
+	"js": true, "len": true, "not": true, "or": true, "print": true,
+	"printf": true, "println": true, "urlquery": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"flag": true, "t": true,
+}
+
+var (
+	funcsMu   sync.RWMutex
+	userFuncs = template.FuncMap{}
+)
+
+// StandardFuncs returns helpers available in every template.
+func StandardFuncs() template.FuncMap {
+	return template.FuncMap{
+		"default":      defaultValue,
+		"trim":         strings.TrimSpace,
+		"urlquery":     url.QueryEscape,
+		"nl2br":        nl2br,
+		"sanitizeHTML": sanitizeHTML,
+		"upper":        strings.ToUpper,
+		"lower":        strings.ToLower,
+		"date":         formatDate,
+		"number":       formatNumber,
+	}
+}
+
+// htmlPolicy allows basic formatting, links and images, but no scripts, styles or event handlers.
+var htmlPolicy = bluemonday.UGCPolicy()
+
+// defaultValue returns value, unless it's empty.
+func defaultValue(fallback, value interface{}) interface{} {
+	if value == nil {
+		return fallback
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if v.Len() == 0 {
+			return fallback
+		}
+	}
+
+	return value
+}
+
+// nl2br escapes text and replaces line breaks with <br>.
+func nl2br(text string) template.HTML {
+	escaped := template.HTMLEscapeString(strings.Replace(text, "\r\n", "\n", -1))
+	return template.HTML(strings.Replace(escaped, "\n", "<br>", -1))
+}
+
+// sanitizeHTML strips markup outside of allowlist, so user-supplied HTML
+// can be rendered unescaped. Values inserted with bare {{ .Field }} stay escaped.
+func sanitizeHTML(html string) template.HTML {
+	return template.HTML(htmlPolicy.Sanitize(html))
+}
+
+// formatDate formats time, RFC 3339 or "2006-01-02" string, or unix seconds with Go layout,
+// e.g. {{ date "02 Jan 2006" .created_at }}.
+func formatDate(layout string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout), nil
+	case *time.Time:
+		if v != nil {
+			return v.Format(layout), nil
+		}
+	case string:
+		for _, format := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.Parse(format, v); err == nil {
+				return t.Format(layout), nil
+			}
+		}
+	default:
+		if seconds, ok := toFloat(value); ok {
+			return time.Unix(int64(seconds), 0).UTC().Format(layout), nil
+		}
+	}
+
+	return "", fmt.Errorf("date: can't format %T \"%v\"", value, value)
+}
+
+// formatNumber rounds number to decimals and groups thousands with commas,
+// e.g. {{ number 2 .amount }} renders 1234.5 as "1,234.50".
+func formatNumber(decimals int, value interface{}) (string, error) {
+	number, ok := toFloat(value)
+	if !ok {
+		return "", fmt.Errorf("number: can't format %T \"%v\"", value, value)
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+
+	formatted := strconv.FormatFloat(math.Abs(number), 'f', decimals, 64)
+	whole, fraction := formatted, ""
+	if i := strings.Index(formatted, "."); i != -1 {
+		whole, fraction = formatted[:i], formatted[i:]
+	}
+
+	grouped := new(strings.Builder)
+	if number < 0 && strings.Trim(formatted, "0.") != "" {
+		grouped.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return grouped.String() + fraction, nil
+}
+
+// toFloat converts numbers and numeric strings, e.g. amounts decoded from JSON payload.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// MergeFuncs combines standard helpers with user functions.
+// User functions can add new or replace standard helpers,
+// but an error is returned for names reserved by Go templates.
+func MergeFuncs(funcs template.FuncMap) (template.FuncMap, error) {
+	merged := StandardFuncs()
+
+	for name, fn := range funcs {
+		if reservedFuncs[name] {
+			return nil, fmt.Errorf("template func \"%s\" is reserved", name)
+		}
+
+		merged[name] = fn
+	}
+
+	return merged, nil
+}
+
+// RegisterFuncs makes user functions available in every template.
+// Templates are parsed when config is loaded, so register functions before that.
+func RegisterFuncs(funcs template.FuncMap) error {
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+
+	for name := range funcs {
+		if reservedFuncs[name] {
+			return fmt.Errorf("template func \"%s\" is reserved", name)
+		}
+	}
+
+	for name, fn := range funcs {
+		userFuncs[name] = fn
+	}
+
+	return nil
+}
+
+// templateFuncs returns standard helpers merged with registered functions.
+func templateFuncs() template.FuncMap {
+	funcsMu.RLock()
+	defer funcsMu.RUnlock()
+
+	merged, _ := MergeFuncs(userFuncs)
+	// Bound to rendered data and language by render, see renderFuncs.
+	merged["flag"] = flagFunc(nil)
+	merged["t"] = catalog{}.lookup
+	return merged
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandardFuncs(t *testing.T) {
+	render := func(text string, data interface{}) string {
+		temp := Template{Subject: "Funcs", Template: text}
+		result, err := temp.Content(data)
+		assert.NoError(t, err)
+		return string(result)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		data := map[string]interface{}{"Name": "", "Nick": "jd"}
+
+		assert.Equal(t, "friend", render(`{{ default "friend" .Name }}`, data))
+		assert.Equal(t, "jd", render(`{{ default "friend" .Nick }}`, data))
+		assert.Equal(t, "friend", render(`{{ default "friend" .Missing }}`, data))
+	})
+
+	t.Run("trim", func(t *testing.T) {
+		assert.Equal(t, "John", render(`{{ trim .Name }}`, map[string]string{"Name": "  John \n"}))
+	})
+
+	t.Run("urlquery", func(t *testing.T) {
+		assert.Equal(t, "a%2Bb%3Dc", render(`{{ urlquery .Q }}`, map[string]string{"Q": "a+b=c"}))
+	})
+
+	t.Run("nl2br", func(t *testing.T) {
+		assert.Equal(t,
+			"&lt;b&gt;Hi&lt;/b&gt;<br>there",
+			render(`{{ nl2br .Text }}`, map[string]string{"Text": "<b>Hi</b>\nthere"}),
+		)
+	})
+
+	t.Run("sanitizeHTML", func(t *testing.T) {
+		comment := map[string]string{"Text": `<b>Nice</b> post<script>alert("xss")</script> <a href="javascript:alert(1)" onclick="steal()">link</a>`}
+
+		assert.Equal(t, "<b>Nice</b> post link", render(`{{ sanitizeHTML .Text }}`, comment))
+		assert.Equal(t,
+			"&lt;b&gt;Nice&lt;/b&gt;",
+			render(`{{ .Text }}`, map[string]string{"Text": "<b>Nice</b>"}),
+		)
+	})
+
+	t.Run("upper and lower", func(t *testing.T) {
+		data := map[string]string{"Code": "usd", "Name": "JOHN"}
+
+		assert.Equal(t, "USD john", render(`{{ upper .Code }} {{ lower .Name }}`, data))
+	})
+
+	t.Run("date", func(t *testing.T) {
+		for _, value := range []interface{}{
+			"2021-03-04T15:04:05Z",
+			"2021-03-04",
+			time.Date(2021, 3, 4, 15, 4, 5, 0, time.UTC),
+			float64(1614870245),
+			json.Number("1614870245"),
+		} {
+			assert.Equal(t, "04 Mar 2021", render(`{{ date "02 Jan 2006" .At }}`, map[string]interface{}{"At": value}), value)
+		}
+
+		_, err := (&Template{Template: `{{ date "2006" .At }}`}).Content(map[string]interface{}{"At": "yesterday"})
+		assert.Error(t, err)
+	})
+
+	t.Run("number", func(t *testing.T) {
+		for expected, value := range map[string]interface{}{
+			"1,234.50":      1234.5,
+			"-1,234,567.00": -1234567,
+			"0.00":          -0.001,
+			"999.00":        "999",
+			"1.26":          json.Number("1.255001"),
+		} {
+			assert.Equal(t, expected, render(`{{ number 2 .Amount }}`, map[string]interface{}{"Amount": value}), expected)
+		}
+
+		assert.Equal(t, "12,346", render(`{{ number 0 .Amount }}`, map[string]interface{}{"Amount": 12345.6}))
+
+		_, err := (&Template{Template: `{{ number 2 .Amount }}`}).Content(map[string]interface{}{"Amount": "lots"})
+		assert.Error(t, err)
+	})
+}
+
+func TestMergeFuncs(t *testing.T) {
+	t.Run("extends standard helpers", func(t *testing.T) {
+		funcs, err := MergeFuncs(template.FuncMap{"upper": strings.ToUpper})
+
+		assert.NoError(t, err)
+		assert.Contains(t, funcs, "upper")
+		assert.Contains(t, funcs, "default")
+	})
+
+	t.Run("overrides standard helper", func(t *testing.T) {
+		trim := func(s string) string { return strings.Trim(s, "-") }
+		funcs, err := MergeFuncs(template.FuncMap{"trim": trim})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "x", funcs["trim"].(func(string) string)("--x--"))
+	})
+
+	t.Run("reports reserved name collision", func(t *testing.T) {
+		funcs, err := MergeFuncs(template.FuncMap{"printf": strings.ToUpper})
+
+		assert.Nil(t, funcs)
+		assert.EqualError(t, err, "template func \"printf\" is reserved")
+	})
+}
+
+func TestRegisterFuncs(t *testing.T) {
+	defer func() { userFuncs = template.FuncMap{} }()
+
+	assert.EqualError(t, RegisterFuncs(template.FuncMap{"len": strings.ToUpper}), "template func \"len\" is reserved")
+	assert.NoError(t, RegisterFuncs(template.FuncMap{"upper": strings.ToUpper}))
+
+	temp := Template{Subject: "Funcs", Template: "{{ upper .Name }}"}
+	result, err := temp.Content(map[string]string{"Name": "john"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "JOHN", string(result))
+}
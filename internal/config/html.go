@@ -0,0 +1,55 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/html"
+)
+
+// balancedTags must be closed explicitly. HTML parsers close them implicitly,
+// but email clients render the rest of the message inside, e.g. of a <table>.
+var balancedTags = map[string]bool{
+	"a": true, "div": true, "span": true, "ul": true, "ol": true, "center": true,
+	"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "td": true, "th": true,
+}
+
+// ValidateHTML returns error, if rendered HTML has unclosed or mismatched tags,
+// which must be balanced, see Config.ValidateHTML.
+func ValidateHTML(content []byte) error {
+	var open []string
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() != io.EOF {
+				return tokenizer.Err()
+			}
+
+			if len(open) != 0 {
+				return fmt.Errorf("html: <%s> is not closed", open[len(open)-1])
+			}
+			return nil
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); balancedTags[string(name)] {
+				open = append(open, string(name))
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if !balancedTags[string(name)] {
+				continue
+			}
+
+			if len(open) == 0 {
+				return fmt.Errorf("html: </%s> closes no tag", name)
+			}
+
+			if last := open[len(open)-1]; last != string(name) {
+				return fmt.Errorf("html: </%s> closes <%s>", name, last)
+			}
+			open = open[:len(open)-1]
+		}
+	}
+}
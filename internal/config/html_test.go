@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateHTML(t *testing.T) {
+	valid := map[string]string{
+		"balanced layout": `<html><body><div class="wrap"><table><tbody><tr><td>Hi<br>John</td></tr></tbody></table></div></body></html>`,
+		"unchecked tags":  `<p>One<p>Two<img src="logo.png"><li>item`,
+		"self closing":    `<div><span/></div>`,
+		"plain text":      `Hello, John`,
+	}
+
+	for name, content := range valid {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, ValidateHTML([]byte(content)))
+		})
+	}
+
+	broken := map[string]struct {
+		content string
+		err     string
+	}{
+		"unclosed table": {`<div><table><tr><td>Hi</td></tr></div>`, "html: </div> closes <table>"},
+		"unclosed div":   {`<div><div>Hi</div>`, "html: <div> is not closed"},
+		"extra close":    {`<div>Hi</div></div>`, "html: </div> closes no tag"},
+		"mismatched":     {`<table><tr><td>Hi</th></tr></table>`, "html: </th> closes <td>"},
+	}
+
+	for name, tc := range broken {
+		t.Run(name, func(t *testing.T) {
+			assert.EqualError(t, ValidateHTML([]byte(tc.content)), tc.err)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Identity overrides sender of emails in a language, e.g. with regional sending domain.
+type Identity struct {
+	// FromAddress replaces default from address.
+	FromAddress string `yaml:"from_address,omitempty"`
+	// Domain replaces domain of default from address, when from address isn't set.
+	Domain string `yaml:"domain,omitempty"`
+	// ReplyTo is written into Reply-To header.
+	ReplyTo string `yaml:"reply_to,omitempty"`
+}
+
+// Identity returns sender identity of language, if configured.
+func (config *Config) Identity(code string) (*Identity, bool) {
+	for _, lang := range config.Languages {
+		if strings.EqualFold(lang.Code, code) && lang.Sender != nil {
+			return lang.Sender, true
+		}
+	}
+
+	return nil, false
+}
+
+// From returns from address of identity, which overrides fallback.
+func (id *Identity) From(fallback string) string {
+	if id.FromAddress != "" {
+		return id.FromAddress
+	}
+
+	if at := strings.LastIndex(fallback, "@"); id.Domain != "" && at != -1 {
+		return fallback[:at+1] + id.Domain
+	}
+
+	return fallback
+}
+
+func (id *Identity) validate() error {
+	if id == nil {
+		return nil
+	}
+
+	if id.FromAddress != "" && id.Domain != "" {
+		return errors.New("from address and domain is specified")
+	}
+
+	for name, address := range map[string]string{"from address": id.FromAddress, "reply to": id.ReplyTo} {
+		if address == "" {
+			continue
+		}
+
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return fmt.Errorf("%s %q: %s", name, address, err.Error())
+		}
+
+		// Address is written into headers as is, so it must be bare.
+		if parsed.Address != address {
+			return fmt.Errorf("%s %q should be a bare address", name, address)
+		}
+	}
+
+	if id.Domain != "" {
+		if _, err := idna.Lookup.ToASCII(id.Domain); err != nil || strings.ContainsAny(id.Domain, "@ \r\n\"<>") {
+			return fmt.Errorf("domain %q is not valid", id.Domain)
+		}
+	}
+
+	return nil
+}
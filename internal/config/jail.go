@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// jail rejects path resolving outside of root once cleaned, e.g. "../../etc/passwd".
+// Relative paths resolve against working directory like templates are read.
+// Any path is allowed without root.
+func jail(root, kind, path string) error {
+	if root == "" || strings.TrimSpace(path) == "" {
+		return nil
+	}
+
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("template root \"%s\": %s", root, err.Error())
+	}
+
+	pathAbs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("%s \"%s\": %s", kind, path, err.Error())
+	}
+
+	rel, err := filepath.Rel(rootAbs, pathAbs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s \"%s\" is outside template root \"%s\"", kind, path, root)
+	}
+
+	return nil
+}
+
+// jailTemplate rejects template, which files resolve outside of root.
+func jailTemplate(root string, tpl Template) error {
+	if err := jail(root, "template_path", tpl.TemplatePath); err != nil {
+		return err
+	}
+
+	return jail(root, "text_path", tpl.TextPath)
+}
+
+// validateTemplateRoot checks files of templates and preview data resolve
+// inside template root. It goes before checks, which read the files.
+func validateTemplateRoot(conf Config) error {
+	for _, event := range conf.Events {
+		if err := jail(conf.TemplateRoot, "preview_data_path", event.PreviewPath); err != nil {
+			return fmt.Errorf("event \"%s\": %s", event.Name, err.Error())
+		}
+
+		templates := labeledTemplates(event)
+		labels := make([]string, 0, len(templates))
+		for label := range templates {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+
+		for _, label := range labels {
+			if err := jailTemplate(conf.TemplateRoot, templates[label]); err != nil {
+				return fmt.Errorf("event \"%s\" template %s: %s", event.Name, label, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
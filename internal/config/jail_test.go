@@ -0,0 +1,80 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJail(t *testing.T) {
+	assert.NoError(t, jail("", "template_path", "/etc/passwd"))
+	assert.NoError(t, jail("templates", "template_path", "templates/email.tpl"))
+	assert.NoError(t, jail("templates", "template_path", "./templates/en/../email.tpl"))
+	assert.NoError(t, jail("/srv/templates/", "template_path", "/srv/templates/en/email.tpl"))
+
+	for _, path := range []string{"../../etc/passwd", "templates/../../etc/passwd", "/etc/passwd", "templates-old/email.tpl", "templates/.."} {
+		assert.EqualError(t, jail("templates", "template_path", path),
+			"template_path \""+path+"\" is outside template root \"templates\"", path)
+	}
+}
+
+func TestValidate_TemplateRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "templates")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	inside := filepath.Join(root, "email.tpl")
+	assert.NoError(t, ioutil.WriteFile(inside, []byte("<p>{{ .user.email }}</p>"), 0644))
+
+	validate := func(tpl Template) error {
+		tmp := SampleConfig()
+		tmp.TemplateRoot = root
+		tmp.Events[0].Templates["EN"] = tpl
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		return err
+	}
+
+	t.Run("rejects traversal path", func(t *testing.T) {
+		path := filepath.Join(root, "../../etc/passwd")
+		assert.EqualError(t, validate(Template{Subject: "Example", TemplatePath: path}),
+			"event \"Example\" template EN: template_path \""+path+"\" is outside template root \""+root+"\"")
+	})
+
+	t.Run("rejects text outside", func(t *testing.T) {
+		assert.EqualError(t, validate(Template{Subject: "Example", TemplatePath: inside, TextPath: "/etc/passwd"}),
+			"event \"Example\" template EN: text_path \"/etc/passwd\" is outside template root \""+root+"\"")
+	})
+
+	t.Run("accepts path inside", func(t *testing.T) {
+		assert.NoError(t, validate(Template{Subject: "Example", TemplatePath: filepath.Join(root, "en", "..", "email.tpl")}))
+	})
+
+	t.Run("rejects preview data outside", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.TemplateRoot = root
+		tmp.Events[0].PreviewPath = "../../etc/passwd"
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "event \"Example\": preview_data_path \"../../etc/passwd\" is outside template root \""+root+"\"")
+	})
+
+	t.Run("rejects override outside", func(t *testing.T) {
+		conf := SampleConfig()
+		conf.TemplateRoot = root
+
+		err := conf.Override(conf.Events[0].Key, "EN", Template{Subject: "Example", TemplatePath: "/etc/passwd"})
+		assert.EqualError(t, err, "override of event \""+conf.Events[0].Key+"\" in EN: template_path \"/etc/passwd\" is outside template root \""+root+"\"")
+	})
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DefaultRoutingHeader carries event key of messages of a shared Kafka topic.
+const DefaultRoutingHeader = "routing_key"
+
+// Kafka consumes events from Kafka topics as a consumer group, along with
+// AMQP, unless amqp.disabled is set.
+type Kafka struct {
+	// Addresses of brokers, e.g. "kafka:9092".
+	Brokers []string `yaml:"brokers"`
+	// Consumer group, offsets of which are committed once messages are processed.
+	Group string `yaml:"group"`
+	// Topic shared by events, messages of which carry event key in RoutingHeader.
+	// When empty, every event has its own topic: event key prefixed with TopicPrefix.
+	Topic         string `yaml:"topic,omitempty"`
+	RoutingHeader string `yaml:"routing_header,omitempty"`
+	TopicPrefix   string `yaml:"topic_prefix,omitempty"`
+}
+
+// Topics returns topics consumed for events with keys.
+func (k *Kafka) Topics(keys []string) []string {
+	if k.Topic != "" {
+		return []string{k.Topic}
+	}
+
+	topics := make([]string, 0, len(keys))
+	for _, key := range keys {
+		topics = append(topics, k.TopicPrefix+key)
+	}
+
+	return topics
+}
+
+// EventKey returns key of event, which message of topic with headers belongs to.
+func (k *Kafka) EventKey(topic string, headers map[string][]byte) (string, bool) {
+	if k.Topic == "" {
+		if !strings.HasPrefix(topic, k.TopicPrefix) {
+			return "", false
+		}
+		return strings.TrimPrefix(topic, k.TopicPrefix), true
+	}
+
+	if topic != k.Topic {
+		return "", false
+	}
+
+	header := k.RoutingHeader
+	if header == "" {
+		header = DefaultRoutingHeader
+	}
+
+	key, ok := headers[header]
+	return string(key), ok && len(key) > 0
+}
+
+func validateKafka(conf Config) error {
+	k := conf.Kafka
+	if k == nil {
+		if conf.AMQP.Disabled {
+			return errors.New("amqp is disabled, but kafka isn't configured")
+		}
+		return nil
+	}
+
+	if len(k.Brokers) == 0 {
+		return errors.New("kafka brokers are not set")
+	}
+
+	for _, broker := range k.Brokers {
+		if _, _, err := net.SplitHostPort(broker); err != nil {
+			return fmt.Errorf("kafka broker \"%s\" is not valid", broker)
+		}
+	}
+
+	if strings.TrimSpace(k.Group) == "" {
+		return errors.New("kafka group is not set")
+	}
+
+	if k.Topic == "" && k.RoutingHeader != "" {
+		return errors.New("kafka routing header needs topic")
+	}
+
+	if k.Topic != "" && k.TopicPrefix != "" {
+		return errors.New("kafka topic and topic prefix are both set")
+	}
+
+	// Kafka commits offsets in order, so a held record would stall the ones after it.
+	for _, event := range conf.Events {
+		if event.Coalesce != "" {
+			return fmt.Errorf("event \"%s\" can't be coalesced with kafka", event.Key)
+		}
+		if event.QuietHours != nil {
+			return fmt.Errorf("event \"%s\" can't have quiet hours with kafka", event.Key)
+		}
+	}
+
+	return nil
+}
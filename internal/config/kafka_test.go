@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafka_Routing(t *testing.T) {
+	t.Run("topic per event", func(t *testing.T) {
+		k := &Kafka{TopicPrefix: "events."}
+
+		assert.Equal(t, []string{"events.user.reset", "events.user.confirm"}, k.Topics([]string{"user.reset", "user.confirm"}))
+
+		key, ok := k.EventKey("events.user.reset", nil)
+		assert.True(t, ok)
+		assert.Equal(t, "user.reset", key)
+
+		_, ok = k.EventKey("other.user.reset", nil)
+		assert.False(t, ok)
+	})
+
+	t.Run("shared topic", func(t *testing.T) {
+		k := &Kafka{Topic: "barong.events"}
+
+		assert.Equal(t, []string{"barong.events"}, k.Topics([]string{"user.reset", "user.confirm"}))
+
+		key, ok := k.EventKey("barong.events", map[string][]byte{"routing_key": []byte("user.reset")})
+		assert.True(t, ok)
+		assert.Equal(t, "user.reset", key)
+
+		_, ok = k.EventKey("barong.events", map[string][]byte{"event": []byte("user.reset")})
+		assert.False(t, ok)
+
+		k.RoutingHeader = "event"
+		key, ok = k.EventKey("barong.events", map[string][]byte{"event": []byte("user.reset")})
+		assert.True(t, ok)
+		assert.Equal(t, "user.reset", key)
+	})
+}
+
+func TestValidate_Kafka(t *testing.T) {
+	validate := func(k *Kafka, amqpDisabled bool) error {
+		tmp := SampleConfig()
+		tmp.Kafka = k
+		tmp.AMQP.Disabled = amqpDisabled
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, _, err = Load(bytes.NewReader(configAsBytes), LoadOptions{Kafka: true})
+		return err
+	}
+
+	valid := Kafka{Brokers: []string{"kafka:9092"}, Group: "postmaster"}
+	assert.NoError(t, validate(&valid, false))
+	assert.NoError(t, validate(&valid, true))
+
+	t.Run("without client", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Kafka = &valid
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "kafka is not supported without a client, see consumer.WithKafkaDialer")
+		assert.False(t, res)
+	})
+
+	for expected, change := range map[string]func(k *Kafka){
+		"kafka brokers are not set":                 func(k *Kafka) { k.Brokers = nil },
+		"kafka broker \"kafka\" is not valid":       func(k *Kafka) { k.Brokers = []string{"kafka"} },
+		"kafka group is not set":                    func(k *Kafka) { k.Group = " " },
+		"kafka routing header needs topic":          func(k *Kafka) { k.RoutingHeader = "event" },
+		"kafka topic and topic prefix are both set": func(k *Kafka) { k.Topic, k.TopicPrefix = "events", "events." },
+	} {
+		k := valid
+		change(&k)
+		assert.EqualError(t, validate(&k, false), expected)
+	}
+
+	assert.EqualError(t, validate(nil, true), "amqp is disabled, but kafka isn't configured")
+
+	t.Run("coalesced event", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Kafka = &valid
+		tmp.Events[0].Coalesce = "1m"
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, _, err = Load(bytes.NewReader(configAsBytes), LoadOptions{Kafka: true})
+		assert.EqualError(t, err, "event \"example\" can't be coalesced with kafka")
+	})
+
+	t.Run("event with quiet hours", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Kafka = &valid
+		tmp.AMQP.DeadLetterExchange = "postmaster.dlx"
+		tmp.AMQP.Retry = &Retry{Exchange: "postmaster.retry", MaxAttempts: 5, Backoff: "1s"}
+		tmp.Events[0].QuietHours = &QuietHours{Start: "22:00", End: "08:00"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, _, err = Load(bytes.NewReader(configAsBytes), LoadOptions{Kafka: true})
+		assert.EqualError(t, err, "event \"example\" can't have quiet hours with kafka")
+	})
+}
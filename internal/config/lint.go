@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// LintTrimMarkers reports the first likely-unintended use of "{{-" and "-}}"
+// trim markers in template source:
+//
+//   - marker not separated from action by space, e.g. "{{-.Name}}";
+//   - marker trimming space between text and action on the same line,
+//     e.g. "Dear {{- .Name }}" renders "DearJohn".
+func LintTrimMarkers(source string) error {
+	line := func(i int) int {
+		return strings.Count(source[:i], "\n") + 1
+	}
+
+	for i := strings.Index(source, "{{-"); i != -1; i = next(source, "{{-", i) {
+		if end := i + 3; end == len(source) || !isSpace(source[end]) {
+			return fmt.Errorf("line %d: \"{{-\" should be followed by space", line(i))
+		}
+
+		j := i
+		for j > 0 && isInlineSpace(source[j-1]) {
+			j--
+		}
+		if j < i && j > 0 && isWord(source[j-1]) {
+			return fmt.Errorf("line %d: \"{{-\" trims space after text", line(i))
+		}
+	}
+
+	for i := strings.Index(source, "-}}"); i != -1; i = next(source, "-}}", i) {
+		if i == 0 || !isSpace(source[i-1]) {
+			return fmt.Errorf("line %d: \"-}}\" should be preceded by space", line(i))
+		}
+
+		j := i + 3
+		for j < len(source) && isInlineSpace(source[j]) {
+			j++
+		}
+		if j > i+3 && j < len(source) && isWord(source[j]) {
+			return fmt.Errorf("line %d: \"-}}\" trims space before text", line(i))
+		}
+	}
+
+	return nil
+}
+
+func next(source, marker string, i int) int {
+	j := strings.Index(source[i+1:], marker)
+	if j == -1 {
+		return -1
+	}
+
+	return i + 1 + j
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isInlineSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+func isWord(c byte) bool {
+	return c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// source returns body of inline template or content of template file.
+func (t *Template) source() (string, error) {
+	return variantSource(t.Template, t.TemplatePath)
+}
+
+func lintTemplates(name string, templates map[string]Template) error {
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		tpl := templates[key]
+		if !tpl.HasHTML() {
+			continue
+		}
+
+		source, err := tpl.source()
+		if err != nil {
+			return fmt.Errorf("event \"%s\" template %s: %s", name, key, err.Error())
+		}
+
+		if err := LintTrimMarkers(source); err != nil {
+			return fmt.Errorf("event \"%s\" template %s: %s", name, key, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func lint(conf Config) error {
+	for _, event := range conf.Events {
+		if err := lintTemplates(event.Name, event.Templates); err != nil {
+			return err
+		}
+
+		if event.Rollout != nil {
+			if err := lintTemplates(event.Name, event.Rollout.Templates); err != nil {
+				return err
+			}
+		}
+
+		for _, overrides := range event.Tenants {
+			if err := lintTemplates(event.Name, overrides); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintTrimMarkers(t *testing.T) {
+	t.Run("passes correct trim markers", func(t *testing.T) {
+		sources := []string{
+			"Hello {{ .Name }}",
+			"<ul>\n  {{- range .Items }}\n  <li>{{ . }}</li>\n  {{- end }}\n</ul>",
+			"{{- /* comment */ -}}\nHello",
+			"Total: {{ .Amount -}}\n EUR",
+		}
+
+		for _, source := range sources {
+			assert.NoError(t, LintTrimMarkers(source), source)
+		}
+	})
+
+	t.Run("flags marker without space", func(t *testing.T) {
+		assert.EqualError(t, LintTrimMarkers("Hello\n{{-.Name }}"), "line 2: \"{{-\" should be followed by space")
+		assert.EqualError(t, LintTrimMarkers("Hello {{ .Name-}}"), "line 1: \"-}}\" should be preceded by space")
+	})
+
+	t.Run("flags trimmed space between words", func(t *testing.T) {
+		assert.EqualError(t, LintTrimMarkers("Dear {{- .Name }},"), "line 1: \"{{-\" trims space after text")
+		assert.EqualError(t, LintTrimMarkers("{{ .Amount -}} EUR"), "line 1: \"-}}\" trims space before text")
+	})
+}
+
+func TestLoad_LintTemplates(t *testing.T) {
+	tmp := SampleConfig()
+	tmp.Events[0].Templates["EN"] = Template{Subject: "Hello", Template: "Dear {{- .Name }}"}
+
+	configAsBytes, err := yaml.Marshal(tmp)
+	assert.NoError(t, err)
+
+	_, _, err = Load(bytes.NewReader(configAsBytes), LoadOptions{})
+	assert.NoError(t, err)
+
+	_, _, err = Load(bytes.NewReader(configAsBytes), LoadOptions{LintTemplates: true})
+	assert.EqualError(t, err, "event \"Example\" template EN: line 1: \"{{-\" trims space after text")
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// eventRef matches event named by validation errors, e.g. `in event "Example"`.
+var eventRef = regexp.MustCompile(`event "([^"]*)"`)
+
+// Locate returns line of config content, which validation error err is about,
+// zero when it's unknown. Errors naming an event are located at its "name" or
+// "key" entry. Decoding errors carry their lines in the message already.
+func Locate(content []byte, err error) int {
+	match := eventRef.FindStringSubmatch(err.Error())
+	if match == nil || match[1] == "" {
+		return 0
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "-"))
+
+		for _, field := range []string{"name:", "key:"} {
+			if !strings.HasPrefix(entry, field) {
+				continue
+			}
+
+			value := strings.TrimSpace(strings.TrimPrefix(entry, field))
+			if strings.Trim(value, `"'`) == match[1] {
+				return line
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocate(t *testing.T) {
+	content := []byte(`languages:
+- code: EN
+  name: English
+events:
+- name: Email Confirmation
+  key: user.email.confirmation
+- key: user.password.reset
+  name: "Password Reset"
+`)
+
+	t.Run("event name", func(t *testing.T) {
+		err := errors.New("language \"EN\" in event \"Password Reset\" is not defined")
+		assert.Equal(t, 8, Locate(content, err))
+	})
+
+	t.Run("event key", func(t *testing.T) {
+		err := errors.New("sender \"ses\" of event \"user.email.confirmation\" is not configured")
+		assert.Equal(t, 6, Locate(content, err))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		assert.Equal(t, 0, Locate(content, errors.New("event \"Welcome\" has no templates")))
+		assert.Equal(t, 0, Locate(content, errors.New("amqp retry exchange is not set")))
+	})
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// Monitoring is HTTP server of metrics and health checks.
+type Monitoring struct {
+	// Listen address, e.g. ":9090".
+	Addr string `yaml:"addr"`
+}
+
+func validateMonitoring(monitoring *Monitoring) error {
+	if monitoring == nil {
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(monitoring.Addr); err != nil {
+		return fmt.Errorf("monitoring addr \"%s\" is not valid", monitoring.Addr)
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMonitoring(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		assert.NoError(t, validateMonitoring(nil))
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, validateMonitoring(&Monitoring{Addr: ":9090"}))
+		assert.NoError(t, validateMonitoring(&Monitoring{Addr: "127.0.0.1:9090"}))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.EqualError(t, validateMonitoring(&Monitoring{}), "monitoring addr \"\" is not valid")
+		assert.EqualError(t, validateMonitoring(&Monitoring{Addr: "9090"}), "monitoring addr \"9090\" is not valid")
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		conf := SampleConfig()
+		conf.Monitoring = &Monitoring{Addr: "localhost"}
+		assert.EqualError(t, validate(conf), "monitoring addr \"localhost\" is not valid")
+	})
+}
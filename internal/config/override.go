@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// OverrideVersion names the version of templates set with Override.
+const OverrideVersion = "override"
+
+// overridesMu guards overrides of every config.
+var overridesMu sync.RWMutex
+
+// overrideKey keys template override of event in language.
+type overrideKey struct {
+	event    string
+	language string
+}
+
+// Override makes event use tpl for recipients in language, e.g. to canary
+// a template without config change. Overrides live in memory only,
+// so they are lost once config is reloaded.
+func (c *Config) Override(eventKey, lang string, tpl Template) error {
+	if _, ok := c.Event(eventKey); !ok {
+		return fmt.Errorf("event \"%s\" is not configured", eventKey)
+	}
+
+	if !c.ContainsLanguage(lang) {
+		return fmt.Errorf("language \"%s\" is not configured", lang)
+	}
+
+	if err := tpl.expandVariants(); err != nil {
+		return fmt.Errorf("override of event \"%s\" in %s: %s", eventKey, lang, err.Error())
+	}
+
+	if err := jailTemplate(c.TemplateRoot, tpl); err != nil {
+		return fmt.Errorf("override of event \"%s\" in %s: %s", eventKey, lang, err.Error())
+	}
+
+	tpl.registry = c.registry
+	if err := tpl.Validate(); err != nil {
+		return fmt.Errorf("override of event \"%s\" in %s: %s", eventKey, lang, err.Error())
+	}
+
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+
+	if c.overrides == nil {
+		c.overrides = make(map[overrideKey]Template)
+	}
+	c.overrides[overrideKey{eventKey, strings.ToUpper(lang)}] = tpl
+
+	return nil
+}
+
+// ClearOverride restores configured template of event in language.
+// It reports whether there was an override.
+func (c *Config) ClearOverride(eventKey, lang string) bool {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+
+	key := overrideKey{eventKey, strings.ToUpper(lang)}
+	if _, ok := c.overrides[key]; !ok {
+		return false
+	}
+
+	delete(c.overrides, key)
+	return true
+}
+
+// override returns template override of event in language, if any.
+func (c *Config) override(eventKey, lang string) (Template, bool) {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+
+	tpl, ok := c.overrides[overrideKey{eventKey, strings.ToUpper(lang)}]
+	return tpl, ok
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Override(t *testing.T) {
+	canary := Template{Subject: "Canary", Template: "New"}
+
+	t.Run("takes effect and clears", func(t *testing.T) {
+		conf := SampleConfig()
+		conf.Languages = append(conf.Languages, Language{Code: "DE", Name: "German"})
+		conf.Events[0].Templates["DE"] = Template{Subject: "Beispiel", Template: "Hallo"}
+		conf.Events[0].Tenants = map[string]map[string]Template{"acme": {"EN": {Subject: "Acme", Template: "Acme"}}}
+
+		assert.NoError(t, conf.Override("example", "en", canary))
+
+		tpl, version := conf.ResolveTemplate(&conf.Events[0], "acme", "EN", "premium", "john@doe.com")
+		assert.Equal(t, canary, tpl)
+		assert.Equal(t, OverrideVersion, version)
+
+		tpl, version = conf.ResolveTemplate(&conf.Events[0], "", "DE", "", "john@doe.com")
+		assert.Equal(t, "Beispiel", tpl.Subject)
+		assert.Equal(t, BaseVersion, version)
+
+		assert.True(t, conf.ClearOverride("example", "EN"))
+		assert.False(t, conf.ClearOverride("example", "EN"))
+
+		tpl, version = conf.ResolveTemplate(&conf.Events[0], "", "EN", "", "john@doe.com")
+		assert.Equal(t, conf.Events[0].Templates["EN"], tpl)
+		assert.Equal(t, BaseVersion, version)
+	})
+
+	t.Run("is lost on reload", func(t *testing.T) {
+		conf := SampleConfig()
+		assert.NoError(t, conf.Override("example", "EN", canary))
+
+		reloaded := SampleConfig()
+		tpl, _ := reloaded.ResolveTemplate(&reloaded.Events[0], "", "EN", "", "john@doe.com")
+		assert.Equal(t, "Example", tpl.Subject)
+	})
+
+	t.Run("rejects unknown event, language and invalid template", func(t *testing.T) {
+		conf := SampleConfig()
+
+		assert.EqualError(t, conf.Override("missing", "EN", canary), "event \"missing\" is not configured")
+		assert.EqualError(t, conf.Override("example", "DE", canary), "language \"DE\" is not configured")
+		assert.EqualError(t, conf.Override("example", "EN", Template{Subject: "Canary", Template: "{{ .x"}),
+			"override of event \"example\" in EN: template: Canary:1: unclosed action")
+
+		tpl, version := conf.ResolveTemplate(&conf.Events[0], "", "EN", "", "")
+		assert.Equal(t, "Example", tpl.Subject)
+		assert.Equal(t, BaseVersion, version)
+	})
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"html/template"
+	"text/template/parse"
+)
+
+// templatePart is parsed part of template, e.g. subject or html body.
+// Root is executed with rendered data, trees include templates it defines.
+type templatePart struct {
+	name  string
+	root  *parse.Tree
+	trees []*parse.Tree
+}
+
+// parts parses every part of template: html body, digest item, preheader,
+// text body and subject, in that order. Absent parts are skipped.
+func (t *Template) parts() ([]templatePart, error) {
+	var parts []templatePart
+
+	if t.HasHTML() {
+		funcs := templateFuncs()
+		funcs["items"] = func() template.HTML { return "" }
+
+		tpl, err := t.parse(funcs)
+		if err != nil {
+			return nil, err
+		}
+		html := templatePart{name: "html", root: tpl.Tree, trees: htmlTrees(tpl)}
+		parts = append(parts, html)
+
+		if t.Item != "" {
+			item, err := template.New("item").Funcs(funcs).Parse(t.Item)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, templatePart{name: "item", root: item.Tree, trees: []*parse.Tree{item.Tree}})
+		}
+
+		if t.Preheader != "" {
+			preheader, err := t.parsePreheader()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, templatePart{name: "preheader", root: preheader.Tree, trees: []*parse.Tree{preheader.Tree}})
+		}
+	}
+
+	if t.HasText() {
+		tpl, err := t.parseText()
+		if err != nil {
+			return nil, err
+		}
+		text := templatePart{name: "text", root: tpl.Tree, trees: textTrees(tpl)}
+		parts = append(parts, text)
+	}
+
+	subject, err := subjectTemplate(t.Subject, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	parts = append(parts, templatePart{name: "subject", root: subject.Tree, trees: []*parse.Tree{subject.Tree}})
+
+	return parts, nil
+}
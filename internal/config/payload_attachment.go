@@ -0,0 +1,239 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Default limits of attachments carried by messages, see PayloadAttachments.
+const (
+	DefaultAttachmentCount     = 10
+	DefaultAttachmentSize      = 10 << 20
+	DefaultAttachmentTotalSize = 25 << 20
+)
+
+// PayloadAttachments lets messages of event carry files to attach in their
+// "attachments" field, either base64 content or https URL of an allowed host.
+type PayloadAttachments struct {
+	// Hosts URLs are fetched from, e.g. "invoices.example.com".
+	// URL attachments are rejected, when empty.
+	AllowedHosts []string `yaml:"allowed_hosts,omitempty"`
+	// Media types files may have, e.g. "application/pdf". Any, when empty.
+	ContentTypes []string `yaml:"content_types,omitempty"`
+	// Limits of files per message, of bytes per file and of bytes of all files.
+	// Zero is the default, see DefaultAttachmentCount.
+	MaxCount     int   `yaml:"max_count,omitempty"`
+	MaxSize      int64 `yaml:"max_size,omitempty"`
+	MaxTotalSize int64 `yaml:"max_total_size,omitempty"`
+}
+
+// PayloadAttachment is a file of the message. Body is empty, when file is fetched from URL.
+type PayloadAttachment struct {
+	Filename    string
+	ContentType string
+	URL         *url.URL
+	Body        []byte
+}
+
+// CountLimit is MaxCount or its default.
+func (p *PayloadAttachments) CountLimit() int {
+	if p.MaxCount > 0 {
+		return p.MaxCount
+	}
+
+	return DefaultAttachmentCount
+}
+
+// SizeLimit is MaxSize or its default.
+func (p *PayloadAttachments) SizeLimit() int64 {
+	if p.MaxSize > 0 {
+		return p.MaxSize
+	}
+
+	return DefaultAttachmentSize
+}
+
+// TotalSizeLimit is MaxTotalSize or its default.
+func (p *PayloadAttachments) TotalSizeLimit() int64 {
+	if p.MaxTotalSize > 0 {
+		return p.MaxTotalSize
+	}
+
+	return DefaultAttachmentTotalSize
+}
+
+// AllowsURL reports whether file can be fetched from u: https URL of an allowed host.
+func (p *PayloadAttachments) AllowsURL(u *url.URL) bool {
+	if u.Scheme != "https" || u.User != nil {
+		return false
+	}
+
+	for _, host := range p.AllowedHosts {
+		if strings.EqualFold(host, u.Hostname()) || strings.EqualFold(host, u.Host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ContentType normalizes media type of file, which must be allowed,
+// e.g. "application/pdf" for "Application/PDF; name=x".
+func (p *PayloadAttachments) ContentType(contentType string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("content type %q: %s", contentType, err.Error())
+	}
+
+	if len(p.ContentTypes) > 0 && !containsFold(p.ContentTypes, mediaType) {
+		return "", fmt.Errorf("content type %q is not allowed", mediaType)
+	}
+
+	// Only charset is kept, other parameters are of no use to mail clients.
+	kept := make(map[string]string)
+	if charset, ok := params["charset"]; ok {
+		kept["charset"] = charset
+	}
+
+	return mime.FormatMediaType(mediaType, kept), nil
+}
+
+// Decode reads attachments of message field value: list of objects with filename,
+// optional content_type and either base64 content or url. Content type of
+// base64 content is guessed from filename, of URL it's taken from response.
+func (p *PayloadAttachments) Decode(value interface{}) ([]PayloadAttachment, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("attachments are %T, not a list", value)
+	}
+
+	if len(entries) > p.CountLimit() {
+		return nil, fmt.Errorf("%d attachments, at most %d allowed", len(entries), p.CountLimit())
+	}
+
+	var attachments []PayloadAttachment
+	var total int64
+	for i, entry := range entries {
+		attachment, err := p.decode(entry)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %d: %s", i, err.Error())
+		}
+
+		if total += int64(len(attachment.Body)); total > p.TotalSizeLimit() {
+			return nil, fmt.Errorf("attachments exceed %d bytes", p.TotalSizeLimit())
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+func (p *PayloadAttachments) decode(entry interface{}) (PayloadAttachment, error) {
+	fields, ok := entry.(map[string]interface{})
+	if !ok {
+		return PayloadAttachment{}, fmt.Errorf("attachment is %T, not an object", entry)
+	}
+
+	str := func(name string) string {
+		s, _ := fields[name].(string)
+		return strings.TrimSpace(s)
+	}
+
+	attachment := PayloadAttachment{Filename: str("filename")}
+	if err := validFilename(attachment.Filename); err != nil {
+		return PayloadAttachment{}, err
+	}
+
+	content, rawURL := str("content"), str("url")
+	switch {
+	case content != "" && rawURL != "":
+		return PayloadAttachment{}, errors.New("both content and url are set")
+	case content != "":
+		// Encoded length bounds the size, so huge content isn't decoded.
+		if int64(len(content)) > int64(base64.StdEncoding.EncodedLen(int(p.SizeLimit()))) {
+			return PayloadAttachment{}, fmt.Errorf("attachment exceeds %d bytes", p.SizeLimit())
+		}
+
+		body, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return PayloadAttachment{}, fmt.Errorf("content is not base64: %s", err.Error())
+		}
+		if int64(len(body)) > p.SizeLimit() {
+			return PayloadAttachment{}, fmt.Errorf("attachment exceeds %d bytes", p.SizeLimit())
+		}
+		attachment.Body = body
+	case rawURL != "":
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return PayloadAttachment{}, fmt.Errorf("url is not valid: %s", err.Error())
+		}
+		if !p.AllowsURL(u) {
+			return PayloadAttachment{}, fmt.Errorf("url host \"%s\" is not allowed", u.Host)
+		}
+		attachment.URL = u
+	default:
+		return PayloadAttachment{}, errors.New("neither content nor url is set")
+	}
+
+	contentType := str("content_type")
+	if contentType == "" && attachment.URL == nil {
+		contentType = mime.TypeByExtension(path.Ext(attachment.Filename))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+
+	if contentType != "" {
+		var err error
+		if attachment.ContentType, err = p.ContentType(contentType); err != nil {
+			return PayloadAttachment{}, err
+		}
+	}
+
+	return attachment, nil
+}
+
+func (p *PayloadAttachments) validate() error {
+	if p == nil {
+		return nil
+	}
+
+	for _, host := range p.AllowedHosts {
+		u, err := url.Parse("https://" + host)
+		if err != nil || host == "" || u.Host != host || u.Hostname() == "" {
+			return fmt.Errorf("allowed host \"%s\" is not valid", host)
+		}
+	}
+
+	for _, contentType := range p.ContentTypes {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != strings.ToLower(contentType) {
+			return fmt.Errorf("content type \"%s\" is not valid", contentType)
+		}
+	}
+
+	if p.MaxCount < 0 || p.MaxSize < 0 || p.MaxTotalSize < 0 {
+		return errors.New("limits must not be negative")
+	}
+
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
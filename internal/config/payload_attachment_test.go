@@ -0,0 +1,133 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayloadAttachments_Decode(t *testing.T) {
+	conf := &PayloadAttachments{
+		AllowedHosts: []string{"invoices.example.com", "127.0.0.1:8443"},
+		ContentTypes: []string{"application/pdf", "text/plain"},
+		MaxCount:     2,
+		MaxSize:      16,
+		MaxTotalSize: 24,
+	}
+	pdf := base64.StdEncoding.EncodeToString([]byte("%PDF-1.4"))
+
+	t.Run("base64 content", func(t *testing.T) {
+		attachments, err := conf.Decode([]interface{}{
+			map[string]interface{}{"filename": "invoice-42.pdf", "content": pdf},
+			map[string]interface{}{"filename": "notes", "content_type": "Text/Plain; charset=utf-8; name=x", "content": pdf},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []PayloadAttachment{
+			{Filename: "invoice-42.pdf", ContentType: "application/pdf", Body: []byte("%PDF-1.4")},
+			{Filename: "notes", ContentType: "text/plain; charset=utf-8", Body: []byte("%PDF-1.4")},
+		}, attachments)
+	})
+
+	t.Run("url", func(t *testing.T) {
+		attachments, err := conf.Decode([]interface{}{
+			map[string]interface{}{"filename": "ticket.pdf", "url": "https://invoices.example.com/tickets/42.pdf"},
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, attachments, 1)
+		assert.Equal(t, "invoices.example.com", attachments[0].URL.Host)
+		assert.Empty(t, attachments[0].ContentType)
+		assert.Nil(t, attachments[0].Body)
+	})
+
+	t.Run("no attachments", func(t *testing.T) {
+		attachments, err := conf.Decode(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, attachments)
+	})
+
+	t.Run("rejects", func(t *testing.T) {
+		for expected, value := range map[string]interface{}{
+			"attachments are string, not a list":                "invoice.pdf",
+			"3 attachments, at most 2 allowed":                  []interface{}{nil, nil, nil},
+			"attachment 0: attachment is string, not an object": []interface{}{"invoice.pdf"},
+			"attachment 0: attachment filename \"../passwd\" is not valid": []interface{}{
+				map[string]interface{}{"filename": "../passwd", "content": pdf},
+			},
+			"attachment 0: both content and url are set": []interface{}{
+				map[string]interface{}{"filename": "a.pdf", "content": pdf, "url": "https://invoices.example.com/a.pdf"},
+			},
+			"attachment 0: neither content nor url is set": []interface{}{
+				map[string]interface{}{"filename": "a.pdf"},
+			},
+			"attachment 0: content is not base64: illegal base64 data at input byte 0": []interface{}{
+				map[string]interface{}{"filename": "a.pdf", "content": "%PDF"},
+			},
+			"attachment 0: attachment exceeds 16 bytes": []interface{}{
+				map[string]interface{}{"filename": "a.pdf", "content": base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 17)))},
+			},
+			"attachments exceed 24 bytes": []interface{}{
+				map[string]interface{}{"filename": "a.pdf", "content": base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 16)))},
+				map[string]interface{}{"filename": "b.pdf", "content": base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 16)))},
+			},
+			"attachment 0: content type \"image/svg+xml\" is not allowed": []interface{}{
+				map[string]interface{}{"filename": "logo.svg", "content": pdf},
+			},
+			"attachment 0: url host \"evil.example.com\" is not allowed": []interface{}{
+				map[string]interface{}{"filename": "a.pdf", "url": "https://evil.example.com/a.pdf"},
+			},
+			"attachment 0: url host \"invoices.example.com\" is not allowed": []interface{}{
+				map[string]interface{}{"filename": "a.pdf", "url": "http://invoices.example.com/a.pdf"},
+			},
+		} {
+			_, err := conf.Decode(value)
+			assert.EqualError(t, err, expected)
+		}
+	})
+}
+
+func TestPayloadAttachments_AllowsURL(t *testing.T) {
+	conf := &PayloadAttachments{AllowedHosts: []string{"invoices.example.com", "127.0.0.1:8443"}}
+
+	for rawURL, allowed := range map[string]bool{
+		"https://invoices.example.com/a.pdf":      true,
+		"https://INVOICES.example.com:443/a.pdf":  true,
+		"https://127.0.0.1:8443/a.pdf":            true,
+		"https://127.0.0.1:9000/a.pdf":            false,
+		"http://invoices.example.com/a.pdf":       false,
+		"https://user@invoices.example.com/a.pdf": false,
+		"https://invoices.example.com.evil/a.pdf": false,
+	} {
+		u, err := url.Parse(rawURL)
+		assert.NoError(t, err)
+		assert.Equal(t, allowed, conf.AllowsURL(u), rawURL)
+	}
+}
+
+func TestValidate_PayloadAttachments(t *testing.T) {
+	validate := func(conf *PayloadAttachments) error {
+		tmp := SampleConfig()
+		tmp.Events[0].PayloadAttachments = conf
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		return err
+	}
+
+	assert.NoError(t, validate(&PayloadAttachments{AllowedHosts: []string{"invoices.example.com"}, ContentTypes: []string{"application/pdf"}}))
+
+	assert.EqualError(t, validate(&PayloadAttachments{AllowedHosts: []string{"https://invoices.example.com"}}),
+		"event \"Example\": payload attachments: allowed host \"https://invoices.example.com\" is not valid")
+	assert.EqualError(t, validate(&PayloadAttachments{ContentTypes: []string{"application/pdf; name=x"}}),
+		"event \"Example\": payload attachments: content type \"application/pdf; name=x\" is not valid")
+	assert.EqualError(t, validate(&PayloadAttachments{MaxSize: -1}),
+		"event \"Example\": payload attachments: limits must not be negative")
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// PostProcessor transforms rendered HTML body of email in language lang,
+// e.g. minifies it or rewrites its links.
+type PostProcessor interface {
+	Process(lang string, body []byte) ([]byte, error)
+}
+
+// PostProcessorFunc adapts function to PostProcessor.
+type PostProcessorFunc func(lang string, body []byte) ([]byte, error)
+
+// Process calls f(lang, body).
+func (f PostProcessorFunc) Process(lang string, body []byte) ([]byte, error) {
+	return f(lang, body)
+}
+
+var (
+	postProcessorsMu sync.RWMutex
+	postProcessors   = map[string]PostProcessor{
+		"minify":         PostProcessorFunc(minify),
+		"strip_comments": PostProcessorFunc(stripComments),
+	}
+)
+
+// RegisterPostProcessor makes processor available under name in post_process lists.
+// Names are checked when config is loaded, so register processors before that.
+func RegisterPostProcessor(name string, processor PostProcessor) error {
+	postProcessorsMu.Lock()
+	defer postProcessorsMu.Unlock()
+
+	if name == "" || processor == nil {
+		return fmt.Errorf("post-processor \"%s\" should have name and processor", name)
+	}
+
+	if _, exists := postProcessors[name]; exists {
+		return fmt.Errorf("post-processor \"%s\" is already registered", name)
+	}
+
+	postProcessors[name] = processor
+	return nil
+}
+
+func postProcessor(name string) (PostProcessor, bool) {
+	postProcessorsMu.RLock()
+	defer postProcessorsMu.RUnlock()
+
+	processor, ok := postProcessors[name]
+	return processor, ok
+}
+
+// PostProcessors returns names of post-processors of event,
+// falling back to global ones, when event has none.
+func (c *Config) PostProcessors(e *Event) []string {
+	if len(e.PostProcess) != 0 {
+		return e.PostProcess
+	}
+
+	return c.PostProcess
+}
+
+// ProcessHTML runs post-processors of event over HTML body in order,
+// each getting output of the previous one.
+func (c *Config) ProcessHTML(e *Event, lang string, body []byte) ([]byte, error) {
+	for _, name := range c.PostProcessors(e) {
+		processor, ok := postProcessor(name)
+		if !ok {
+			return nil, fmt.Errorf("post-processor \"%s\" is not registered", name)
+		}
+
+		var err error
+		if body, err = processor.Process(lang, body); err != nil {
+			return nil, fmt.Errorf("post-processor \"%s\": %s", name, err.Error())
+		}
+	}
+
+	return body, nil
+}
+
+func validatePostProcessors(names []string) error {
+	for _, name := range names {
+		if _, ok := postProcessor(name); !ok {
+			return fmt.Errorf("post-processor \"%s\" is not registered", name)
+		}
+	}
+
+	return nil
+}
+
+// minify trims indentation and drops blank lines. Lines are kept,
+// since SMTP limits their length, so preformatted text loses indentation.
+func minify(_ string, body []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if out.Len() != 0 {
+			out.WriteByte('\n')
+		}
+		out.Write(line)
+	}
+
+	return out.Bytes(), nil
+}
+
+// stripComments removes HTML comments, except for conditional ones,
+// which Outlook renders, e.g. "<!--[if mso]>...<![endif]-->".
+func stripComments(_ string, body []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for {
+		start := bytes.Index(body, []byte("<!--"))
+		if start < 0 {
+			break
+		}
+
+		end := bytes.Index(body[start:], []byte("-->"))
+		if end < 0 {
+			break
+		}
+		end += start + len("-->")
+
+		out.Write(body[:start])
+		if comment := body[start+len("<!--") : end]; bytes.HasPrefix(comment, []byte("[if")) || bytes.HasPrefix(comment, []byte("<![endif]")) {
+			out.Write(body[start:end])
+		}
+		body = body[end:]
+	}
+
+	out.Write(body)
+	return out.Bytes(), nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_ProcessHTML(t *testing.T) {
+	assert.NoError(t, RegisterPostProcessor("test_footer", PostProcessorFunc(func(lang string, body []byte) ([]byte, error) {
+		return append(body, []byte("<p>footer "+lang+"</p>")...), nil
+	})))
+	assert.NoError(t, RegisterPostProcessor("test_upper", PostProcessorFunc(func(_ string, body []byte) ([]byte, error) {
+		return bytes.ToUpper(body), nil
+	})))
+	assert.NoError(t, RegisterPostProcessor("test_broken", PostProcessorFunc(func(string, []byte) ([]byte, error) {
+		return nil, errors.New("broken")
+	})))
+
+	body := []byte("<p>hello</p>")
+
+	t.Run("chains processors in order", func(t *testing.T) {
+		conf := Config{PostProcess: []string{"test_footer", "test_upper"}}
+		out, err := conf.ProcessHTML(&Event{}, "EN", body)
+		assert.NoError(t, err)
+		assert.Equal(t, "<P>HELLO</P><P>FOOTER EN</P>", string(out))
+
+		conf.PostProcess = []string{"test_upper", "test_footer"}
+		out, err = conf.ProcessHTML(&Event{}, "EN", body)
+		assert.NoError(t, err)
+		assert.Equal(t, "<P>HELLO</P><p>footer EN</p>", string(out))
+	})
+
+	t.Run("event processors replace global ones", func(t *testing.T) {
+		conf := Config{PostProcess: []string{"test_upper"}}
+		out, err := conf.ProcessHTML(&Event{PostProcess: []string{"test_footer"}}, "DE", body)
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>hello</p><p>footer DE</p>", string(out))
+	})
+
+	t.Run("leaves body without processors", func(t *testing.T) {
+		out, err := (&Config{}).ProcessHTML(&Event{}, "EN", body)
+		assert.NoError(t, err)
+		assert.Equal(t, body, out)
+	})
+
+	t.Run("names failed processor", func(t *testing.T) {
+		conf := Config{PostProcess: []string{"test_upper", "test_broken"}}
+		_, err := conf.ProcessHTML(&Event{}, "EN", body)
+		assert.EqualError(t, err, "post-processor \"test_broken\": broken")
+	})
+
+	t.Run("rejects registered names", func(t *testing.T) {
+		assert.EqualError(t, RegisterPostProcessor("minify", PostProcessorFunc(minify)), "post-processor \"minify\" is already registered")
+		assert.EqualError(t, RegisterPostProcessor("", PostProcessorFunc(minify)), "post-processor \"\" should have name and processor")
+	})
+}
+
+func TestPostProcessors_Builtin(t *testing.T) {
+	t.Run("minify", func(t *testing.T) {
+		out, err := minify("EN", []byte("<table>\n  <tr>\n\n    <td>Hi</td>  \r\n  </tr>\n</table>\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "<table>\n<tr>\n<td>Hi</td>\n</tr>\n</table>", string(out))
+	})
+
+	t.Run("strip_comments", func(t *testing.T) {
+		html := "<p>a<!-- note --></p><!--[if mso]><table><![endif]--><!--<![endif]--><p>b</p><!-- unclosed"
+		out, err := stripComments("EN", []byte(html))
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>a</p><!--[if mso]><table><![endif]--><!--<![endif]--><p>b</p><!-- unclosed", string(out))
+	})
+}
+
+func TestValidate_PostProcess(t *testing.T) {
+	t.Run("unknown global processor", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.PostProcess = []string{"minify", "inline_css"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "post-processor \"inline_css\" is not registered")
+	})
+
+	t.Run("unknown event processor", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].PostProcess = []string{"inline_css"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "event \"Example\": post-processor \"inline_css\" is not registered")
+	})
+
+	t.Run("built-in processors", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.PostProcess = []string{"strip_comments", "minify"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		valid, err := Validate(bytes.NewReader(configAsBytes))
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	})
+}
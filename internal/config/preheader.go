@@ -0,0 +1,48 @@
+package config
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// preheaderStyle hides preheader in the body, while inbox preview still shows it.
+const preheaderStyle = "display:none;font-size:1px;line-height:1px;max-height:0;max-width:0;opacity:0;overflow:hidden;mso-hide:all;"
+
+// bodyTag matches opening body tag, preheader goes right after it.
+var bodyTag = regexp.MustCompile(`(?i)<body[^>]*>`)
+
+func (t *Template) parsePreheader() (*template.Template, error) {
+	return template.New("preheader").Funcs(templateFuncs()).Parse(t.Preheader)
+}
+
+// withPreheader injects rendered preheader at the top of HTML body, which is
+// returned as is without preheader.
+func (t *Template) withPreheader(body []byte, data interface{}) ([]byte, error) {
+	if strings.TrimSpace(t.Preheader) == "" {
+		return body, nil
+	}
+
+	tpl, err := t.parsePreheader()
+	if err != nil {
+		return nil, err
+	}
+
+	div := new(bytes.Buffer)
+	div.WriteString(`<div style="` + preheaderStyle + `">`)
+	if err := tpl.Funcs(t.renderFuncs(data)).Execute(div, data); err != nil {
+		return nil, err
+	}
+	div.WriteString("</div>")
+
+	at := 0
+	if loc := bodyTag.FindIndex(body); loc != nil {
+		at = loc[1]
+	}
+
+	result := make([]byte, 0, len(body)+div.Len())
+	result = append(result, body[:at]...)
+	result = append(result, div.Bytes()...)
+	return append(result, body[at:]...), nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Preheader(t *testing.T) {
+	data := map[string]interface{}{"name": "John <3", "amount": "0.5 BTC"}
+	div := `<div style="` + preheaderStyle + `">`
+
+	t.Run("injected at the top of body", func(t *testing.T) {
+		tpl := Template{
+			Subject:   "Deposit",
+			Template:  `<html><head><title>Deposit</title></head><body class="main"><p>Hi {{ .name }}</p></body></html>`,
+			Preheader: `{{ .amount }} arrived, {{ .name }}`,
+		}
+
+		content, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, `<html><head><title>Deposit</title></head><body class="main">`+
+			div+`0.5 BTC arrived, John &lt;3</div><p>Hi John &lt;3</p></body></html>`, string(content))
+	})
+
+	t.Run("prepended to fragment without body", func(t *testing.T) {
+		tpl := Template{Subject: "Deposit", Template: `<p>Hi</p>`, Preheader: "{{ .amount }} arrived"}
+
+		content, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, div+`0.5 BTC arrived</div><p>Hi</p>`, string(content))
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		tpl := Template{Subject: "Digest", Template: `<body>{{ items }}</body>`, Item: "<li>{{ . }}</li>", Preheader: "{{ .count }} new"}
+
+		content, err := tpl.RenderDigest([]interface{}{"a"}, map[string]interface{}{"count": 1})
+		assert.NoError(t, err)
+		assert.Equal(t, `<body>`+div+`1 new</div><li>a</li></body>`, string(content))
+	})
+
+	t.Run("without preheader", func(t *testing.T) {
+		tpl := Template{Subject: "Deposit", Template: `<body>Hi</body>`}
+
+		content, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, `<body>Hi</body>`, string(content))
+	})
+
+	t.Run("validated at load", func(t *testing.T) {
+		tpl := Template{Subject: "Deposit", Template: `<p>Hi</p>`, Preheader: "{{ .amount "}
+		assert.Error(t, tpl.Validate())
+	})
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Hosted reports whether template is hosted by provider, see Template.ProviderTemplateID.
+func (t *Template) Hosted() bool {
+	return strings.TrimSpace(t.ProviderTemplateID) != ""
+}
+
+// validateHosted checks provider-hosted template has no local body.
+func (t *Template) validateHosted() error {
+	local := []string{t.Template, t.TemplatePath, t.Text, t.TextPath, t.Item, t.Preheader}
+	for _, value := range local {
+		if strings.TrimSpace(value) != "" {
+			return errors.New("provider template id and local body is specified")
+		}
+	}
+
+	if t.Subject == "" {
+		return nil
+	}
+
+	return t.validateSubject()
+}
+
+// validateProvider checks events with provider-hosted templates deliver
+// through a named sender or provider API of delivery, since it's the one rendering them.
+func validateProvider(event Event, delivery *Sender) error {
+	hosted := false
+	for _, tpl := range event.Templates {
+		hosted = hosted || tpl.Hosted()
+	}
+	for _, overrides := range event.Tenants {
+		for _, tpl := range overrides {
+			hosted = hosted || tpl.Hosted()
+		}
+	}
+
+	if !hosted {
+		return nil
+	}
+
+	if event.Sender == "" && !delivery.API() {
+		return fmt.Errorf("event \"%s\": provider templates require sender", event.Name)
+	}
+
+	if len(event.Attachments) != 0 {
+		return fmt.Errorf("event \"%s\": provider templates can't have attachments", event.Name)
+	}
+
+	return nil
+}
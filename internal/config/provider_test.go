@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Hosted(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		tpl := Template{ProviderTemplateID: "d-123"}
+		assert.True(t, tpl.Hosted())
+		assert.NoError(t, tpl.Validate())
+
+		tpl.Subject = "Hello {{ .name }}"
+		assert.NoError(t, tpl.Validate())
+	})
+
+	t.Run("rejects local body", func(t *testing.T) {
+		for _, tpl := range []Template{
+			{ProviderTemplateID: "d-123", Template: "<p>Hi</p>"},
+			{ProviderTemplateID: "d-123", TemplatePath: "../../test/test.tpl"},
+			{ProviderTemplateID: "d-123", Text: "Hi"},
+			{ProviderTemplateID: "d-123", Preheader: "Hi"},
+		} {
+			assert.EqualError(t, tpl.Validate(), "provider template id and local body is specified")
+		}
+	})
+
+	t.Run("rejects broken subject", func(t *testing.T) {
+		tpl := Template{ProviderTemplateID: "d-123", Subject: "Hi\nBcc: x@y.z"}
+		assert.EqualError(t, tpl.Validate(), "subject: subject contains line break")
+	})
+}
+
+func TestValidateProvider(t *testing.T) {
+	hosted := func() Config {
+		tmp := SampleConfig()
+		tmp.Senders = map[string]Sender{"sendgrid": {Host: "smtp.sendgrid.net", Port: "587", PasswordEnv: "SENDGRID_KEY"}}
+		tmp.Events[0].Sender = "sendgrid"
+		tmp.Events[0].Templates["EN"] = Template{ProviderTemplateID: "d-123"}
+		return tmp
+	}
+
+	cases := map[string]func(conf *Config){
+		"": func(conf *Config) {},
+		"event \"Example\": provider templates require sender": func(conf *Config) { conf.Events[0].Sender = "" },
+		"event \"Example\": provider templates can't have attachments": func(conf *Config) {
+			conf.Events[0].Attachments = []Attachment{{Filename: "a.ics", ContentType: "text/calendar", Template: "x"}}
+		},
+	}
+
+	for expected, change := range cases {
+		change := change
+		t.Run(expected, func(t *testing.T) {
+			tmp := hosted()
+			change(&tmp)
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			res, err := Validate(bytes.NewReader(configAsBytes))
+
+			if expected == "" {
+				assert.NoError(t, err)
+				assert.True(t, res)
+				return
+			}
+			assert.EqualError(t, err, expected)
+			assert.False(t, res)
+		})
+	}
+
+	t.Run("delivery through provider API", func(t *testing.T) {
+		tmp := hosted()
+		tmp.Events[0].Sender = ""
+		tmp.Delivery = &Sender{Provider: ProviderSendGrid, PasswordEnv: "SENDGRID_KEY"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.NoError(t, err)
+
+		tmp.Delivery = &Sender{Host: "smtp.sendgrid.net", Port: "587", PasswordEnv: "SENDGRID_KEY"}
+
+		configAsBytes, err = yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		assert.EqualError(t, err, "event \"Example\": provider templates require sender")
+	})
+}
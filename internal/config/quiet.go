@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// quietClock is the layout of quiet hours bounds.
+const quietClock = "15:04"
+
+// QuietHours is a daily window, when non-urgent emails of an event are deferred,
+// evaluated in time zone of recipient language. Window spans midnight,
+// when it ends before it starts, e.g. from 22:00 to 08:00.
+type QuietHours struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// Remaining returns time left until the window closes, zero when now is outside of it.
+func (q *QuietHours) Remaining(now time.Time) time.Duration {
+	if q == nil {
+		return 0
+	}
+
+	start, errStart := time.Parse(quietClock, q.Start)
+	end, errEnd := time.Parse(quietClock, q.End)
+	if errStart != nil || errEnd != nil {
+		return 0
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	at := func(day int, clock time.Time) time.Time {
+		return midnight.AddDate(0, 0, day).Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+	}
+
+	// Window, which spans midnight, is either started yesterday or today.
+	for _, day := range []int{-1, 0} {
+		opens, closes := at(day, start), at(day, end)
+		if !closes.After(opens) {
+			closes = at(day+1, end)
+		}
+
+		if !now.Before(opens) && now.Before(closes) {
+			return closes.Sub(now)
+		}
+	}
+
+	return 0
+}
+
+func (q *QuietHours) validate() error {
+	if q == nil {
+		return nil
+	}
+
+	start, err := time.Parse(quietClock, q.Start)
+	if err != nil {
+		return fmt.Errorf("start \"%s\" should be HH:MM", q.Start)
+	}
+
+	end, err := time.Parse(quietClock, q.End)
+	if err != nil {
+		return fmt.Errorf("end \"%s\" should be HH:MM", q.End)
+	}
+
+	if start.Equal(end) {
+		return errors.New("start and end should differ")
+	}
+
+	return nil
+}
+
+// Location returns time zone of language, UTC when it has none.
+func (config *Config) Location(code string) *time.Location {
+	for _, lang := range config.Languages {
+		if strings.EqualFold(lang.Code, code) && lang.TimeZone != "" {
+			if location, err := time.LoadLocation(lang.TimeZone); err == nil {
+				return location
+			}
+		}
+	}
+
+	return time.UTC
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietHours_Remaining(t *testing.T) {
+	day := func(hour, minute int) time.Time {
+		return time.Date(2020, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	t.Run("window within day", func(t *testing.T) {
+		quiet := &QuietHours{Start: "12:00", End: "14:30"}
+
+		assert.Equal(t, time.Duration(0), quiet.Remaining(day(11, 59)))
+		assert.Equal(t, 150*time.Minute, quiet.Remaining(day(12, 0)))
+		assert.Equal(t, 30*time.Minute, quiet.Remaining(day(14, 0)))
+		assert.Equal(t, time.Duration(0), quiet.Remaining(day(14, 30)))
+	})
+
+	t.Run("window spanning midnight", func(t *testing.T) {
+		quiet := &QuietHours{Start: "22:00", End: "08:00"}
+
+		assert.Equal(t, time.Duration(0), quiet.Remaining(day(21, 0)))
+		assert.Equal(t, 10*time.Hour, quiet.Remaining(day(22, 0)))
+		assert.Equal(t, 5*time.Hour, quiet.Remaining(day(3, 0)))
+		assert.Equal(t, time.Duration(0), quiet.Remaining(day(8, 0)))
+		assert.Equal(t, time.Duration(0), quiet.Remaining(day(12, 0)))
+	})
+
+	t.Run("no window", func(t *testing.T) {
+		var quiet *QuietHours
+		assert.Equal(t, time.Duration(0), quiet.Remaining(day(3, 0)))
+	})
+}
+
+func TestQuietHours_Validate(t *testing.T) {
+	cases := map[string]*QuietHours{
+		"event \"Example\": quiet hours: start \"10pm\" should be HH:MM": {Start: "10pm", End: "08:00"},
+		"event \"Example\": quiet hours: end \"\" should be HH:MM":       {Start: "22:00"},
+		"event \"Example\": quiet hours: start and end should differ":    {Start: "22:00", End: "22:00"},
+	}
+
+	for expected, quiet := range cases {
+		quiet := quiet
+		t.Run(expected, func(t *testing.T) {
+			tmp := SampleConfig()
+			tmp.Events[0].QuietHours = quiet
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			res, err := Validate(bytes.NewReader(configAsBytes))
+
+			assert.EqualError(t, err, expected)
+			assert.False(t, res)
+		})
+	}
+
+	t.Run("unknown time zone", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Languages[0].TimeZone = "Mars/Olympus"
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.EqualError(t, err, "time zone of language \"EN\": unknown time zone Mars/Olympus")
+		assert.False(t, res)
+	})
+}
+
+func TestConfig_Location(t *testing.T) {
+	conf := SampleConfig()
+	conf.Languages = append(conf.Languages, Language{Code: "DE", Name: "German", TimeZone: "Europe/Berlin"})
+
+	assert.Equal(t, "Europe/Berlin", conf.Location("de").String())
+	assert.Equal(t, time.UTC, conf.Location("EN"))
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+// RateLimit paces sends per recipient domain, since providers throttle
+// senders, which deliver too fast.
+type RateLimit struct {
+	// Messages per minute to every domain without override. Zero is unlimited.
+	PerMinute int `yaml:"per_minute,omitempty"`
+	// Domains override the limit, keyed by domain. Zero is unlimited.
+	Domains map[string]Rate `yaml:"domains,omitempty"`
+	// Global limits messages to all domains together. Zero is unlimited.
+	Global Rate `yaml:"global,omitempty"`
+	// Burst is how many messages a limit lets through at once,
+	// a second worth of them, but at least one, by default.
+	Burst int `yaml:"burst,omitempty"`
+	// MaxWait is how long throttled message waits for its turn, e.g. "5s",
+	// before it's requeued. Throttled messages are requeued at once by default.
+	MaxWait string `yaml:"max_wait,omitempty"`
+}
+
+// Rate is messages per minute, written either as a number of them
+// or with unit, e.g. "10/s" or "600/m".
+type Rate int
+
+// UnmarshalYAML parses rate with optional unit.
+func (r *Rate) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw string
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	count, unit := raw, "m"
+	if i := strings.Index(raw, "/"); i >= 0 {
+		count, unit = raw[:i], raw[i+1:]
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return fmt.Errorf("rate \"%s\" is not valid", raw)
+	}
+
+	switch strings.TrimSpace(unit) {
+	case "s":
+		*r = Rate(n * 60)
+	case "m":
+		*r = Rate(n)
+	default:
+		return fmt.Errorf("rate \"%s\" should be per second or minute", raw)
+	}
+
+	return nil
+}
+
+// Limit returns messages per minute allowed to domain.
+func (r *RateLimit) Limit(domain string) int {
+	if r == nil {
+		return 0
+	}
+
+	if limit, ok := r.Domains[DomainKey(domain)]; ok {
+		return int(limit)
+	}
+
+	return r.PerMinute
+}
+
+// Capacity returns how many messages limit of per minute lets through at once.
+// Default one is a second worth, so cold start doesn't let a minute of them through.
+func (r *RateLimit) Capacity(limit int) int {
+	if r != nil && r.Burst > 0 {
+		return r.Burst
+	}
+
+	return (limit + 59) / 60
+}
+
+// Wait returns how long throttled message may wait for its turn.
+func (r *RateLimit) Wait() time.Duration {
+	if r == nil {
+		return 0
+	}
+
+	wait, err := time.ParseDuration(r.MaxWait)
+	if err != nil {
+		return 0
+	}
+
+	return wait
+}
+
+// DomainKey returns lowercased ASCII form of domain, keying rate limits.
+func DomainKey(domain string) string {
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+
+	return strings.ToLower(domain)
+}
+
+func (r *RateLimit) validate() error {
+	if r == nil {
+		return nil
+	}
+
+	if r.PerMinute < 0 {
+		return errors.New("per minute should not be negative")
+	}
+
+	if r.Global < 0 {
+		return errors.New("global should not be negative")
+	}
+
+	if r.Burst < 0 {
+		return errors.New("burst should not be negative")
+	}
+
+	if d, err := time.ParseDuration(r.MaxWait); r.MaxWait != "" && (err != nil || d < 0) {
+		return fmt.Errorf("max wait \"%s\" is not valid", r.MaxWait)
+	}
+
+	// Domains are keyed like Limit looks them up, e.g. "Example.COM " by "example.com".
+	domains := make(map[string]Rate, len(r.Domains))
+	names := make(map[string]string, len(r.Domains))
+	for domain, limit := range r.Domains {
+		name := strings.TrimSpace(domain)
+		if _, err := idna.Lookup.ToASCII(name); err != nil || name == "" || strings.ContainsAny(name, "@ ") {
+			return fmt.Errorf("domain \"%s\" is not valid", domain)
+		}
+
+		if limit < 0 {
+			return fmt.Errorf("domain \"%s\" should not have negative limit", domain)
+		}
+
+		key := DomainKey(name)
+		if other, ok := names[key]; ok {
+			first, second := other, domain
+			if second < first {
+				first, second = second, first
+			}
+			return fmt.Errorf("domains \"%s\" and \"%s\" are the same", first, second)
+		}
+
+		names[key] = domain
+		domains[key] = limit
+	}
+
+	if r.Domains != nil {
+		r.Domains = domains
+	}
+
+	return nil
+}
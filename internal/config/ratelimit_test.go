@@ -0,0 +1,98 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit_Limit(t *testing.T) {
+	limits := &RateLimit{PerMinute: 600, Domains: map[string]Rate{"gmail.com": 120, "xn--mller-kva.de": 60}}
+
+	assert.Equal(t, 120, limits.Limit("gmail.com"))
+	assert.Equal(t, 120, limits.Limit("GMail.com"))
+	assert.Equal(t, 60, limits.Limit("müller.de"))
+	assert.Equal(t, 600, limits.Limit("yahoo.com"))
+
+	limits = nil
+	assert.Equal(t, 0, limits.Limit("gmail.com"))
+}
+
+func TestRate_UnmarshalYAML(t *testing.T) {
+	var limits RateLimit
+	assert.NoError(t, yaml.Unmarshal([]byte("global: 50/s\ndomains:\n  gmail.com: 10/s\n  yahoo.com: 60/m\n  aol.com: 30\n"), &limits))
+	assert.Equal(t, Rate(3000), limits.Global)
+	assert.Equal(t, map[string]Rate{"gmail.com": 600, "yahoo.com": 60, "aol.com": 30}, limits.Domains)
+
+	assert.EqualError(t, yaml.Unmarshal([]byte("global: fast"), &limits), `rate "fast" is not valid`)
+	assert.EqualError(t, yaml.Unmarshal([]byte("global: 10/h"), &limits), `rate "10/h" should be per second or minute`)
+}
+
+func TestRateLimit_Capacity(t *testing.T) {
+	assert.Equal(t, 10, (&RateLimit{}).Capacity(600))
+	assert.Equal(t, 1, (&RateLimit{}).Capacity(30))
+	assert.Equal(t, 20, (&RateLimit{Burst: 20}).Capacity(600))
+
+	var limits *RateLimit
+	assert.Equal(t, 10, limits.Capacity(600))
+	assert.Equal(t, time.Duration(0), limits.Wait())
+	assert.Equal(t, 5*time.Second, (&RateLimit{MaxWait: "5s"}).Wait())
+}
+
+func TestRateLimit_Validate(t *testing.T) {
+	cases := map[string]RateLimit{
+		"rate limit: per minute should not be negative":                     {PerMinute: -1},
+		"rate limit: domain \"gmail.com\" should not have negative limit":   {Domains: map[string]Rate{"gmail.com": -1}},
+		"rate limit: domain \"john@gmail.com\" is not valid":                {Domains: map[string]Rate{"john@gmail.com": 1}},
+		"rate limit: domain \"\" is not valid":                              {Domains: map[string]Rate{"": 1}},
+		"rate limit: global should not be negative":                         {Global: -1},
+		"rate limit: burst should not be negative":                          {Burst: -1},
+		"rate limit: max wait \"soon\" is not valid":                        {MaxWait: "soon"},
+		"rate limit: domains \"GMail.com\" and \"gmail.com \" are the same": {Domains: map[string]Rate{"GMail.com": 1, "gmail.com ": 2}},
+	}
+
+	for expected, limits := range cases {
+		limits := limits
+		t.Run(expected, func(t *testing.T) {
+			tmp := SampleConfig()
+			tmp.RateLimit = &limits
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			res, err := Validate(bytes.NewReader(configAsBytes))
+
+			assert.EqualError(t, err, expected)
+			assert.False(t, res)
+		})
+	}
+
+	t.Run("normalizes domains", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.RateLimit = &RateLimit{Domains: map[string]Rate{" Example.COM": 60, "Müller.de": 30}}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		conf, _, err := Load(bytes.NewReader(configAsBytes), LoadOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]Rate{"example.com": 60, "xn--mller-kva.de": 30}, conf.RateLimit.Domains)
+		assert.Equal(t, 60, conf.RateLimit.Limit("Example.com"))
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.RateLimit = &RateLimit{PerMinute: 600, Domains: map[string]Rate{"gmail.com": 120, "unlimited.com": 0}, Global: 3000, Burst: 10, MaxWait: "5s"}
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		res, err := Validate(bytes.NewReader(configAsBytes))
+
+		assert.NoError(t, err)
+		assert.True(t, res)
+	})
+}
@@ -0,0 +1,20 @@
+package config
+
+import "fmt"
+
+// Modes of sending email to each recipient separately, see Event.PerRecipient.
+const (
+	// PerRecipientAny acknowledges message once any recipient got the email.
+	PerRecipientAny = "any"
+	// PerRecipientAll fails message unless every recipient got the email.
+	PerRecipientAll = "all"
+)
+
+func validatePerRecipient(mode string) error {
+	switch mode {
+	case "", PerRecipientAny, PerRecipientAll:
+		return nil
+	default:
+		return fmt.Errorf("per recipient mode \"%s\" should be %s or %s", mode, PerRecipientAny, PerRecipientAll)
+	}
+}
@@ -0,0 +1,15 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePerRecipient(t *testing.T) {
+	for _, mode := range []string{"", PerRecipientAny, PerRecipientAll} {
+		assert.NoError(t, validatePerRecipient(mode))
+	}
+
+	assert.EqualError(t, validatePerRecipient("each"), "per recipient mode \"each\" should be any or all")
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// TemplateRefs returns sorted distinct template_path and text_path of templates
+// of all events, rollouts and tenants included, and the partials directory
+// with its files, e.g. to watch them for changes.
+func (c *Config) TemplateRefs() []string {
+	seen := make(map[string]bool)
+	add := func(templates map[string]Template) {
+		for _, tpl := range templates {
+			for _, ref := range []string{tpl.TemplatePath, tpl.TextPath} {
+				if ref = strings.TrimSpace(ref); ref != "" {
+					seen[ref] = true
+				}
+			}
+		}
+	}
+
+	for _, event := range c.Events {
+		add(event.Templates)
+		if event.Rollout != nil {
+			add(event.Rollout.Templates)
+		}
+		for _, templates := range event.Tenants {
+			add(templates)
+		}
+	}
+
+	if dir := strings.TrimSpace(c.Partials); dir != "" {
+		seen[dir] = true
+		// Unreadable directory fails load, so it's only missing here once removed.
+		files, _ := partialFiles(dir)
+		for _, file := range files {
+			seen[file] = true
+		}
+	}
+
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+
+	sort.Strings(refs)
+	return refs
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_TemplateRefs(t *testing.T) {
+	conf := Config{
+		Events: []Event{
+			{
+				Key: "user.password.reset",
+				Templates: map[string]Template{
+					"EN": {TemplatePath: "templates/reset.en.tpl", TextPath: "templates/reset.en.txt"},
+					"DE": {Template: "inline"},
+				},
+				Rollout: &Rollout{Templates: map[string]Template{
+					"EN": {TemplatePath: "templates/reset.v2.tpl"},
+				}},
+			},
+			{
+				Key: "user.email.confirmation",
+				Templates: map[string]Template{
+					"EN": {TemplatePath: "templates/reset.en.tpl"},
+				},
+				Tenants: map[string]map[string]Template{
+					"acme": {"EN": {TemplatePath: "templates/acme.tpl"}},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"templates/acme.tpl",
+		"templates/reset.en.tpl",
+		"templates/reset.en.txt",
+		"templates/reset.v2.tpl",
+	}, conf.TemplateRefs())
+
+	t.Run("includes partials", func(t *testing.T) {
+		conf := Config{Partials: "../../templates/partials"}
+
+		assert.Equal(t, []string{
+			"../../templates/partials",
+			"../../templates/partials/footer.html",
+			"../../templates/partials/footer.txt",
+			"../../templates/partials/layout.html",
+		}, conf.TemplateRefs())
+	})
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// registry parses templates of a loaded config once, with partials and
+// layouts of the partials directory available to them by name.
+// Nil registry parses templates on every call without partials.
+type registry struct {
+	html *template.Template
+	text *texttemplate.Template
+
+	mu    sync.Mutex
+	htmls map[parseKey]*template.Template
+	texts map[parseKey]*texttemplate.Template
+}
+
+// parseKey identifies parsed template: inline source or file path,
+// and whether digest "items" func is defined.
+type parseKey struct {
+	name   string
+	source string
+	file   bool
+	items  bool
+}
+
+// partialFiles lists files of partials directory, sorted by name.
+func partialFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// partialName is file name without extension.
+func partialName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// newRegistry parses partials of the directory: files ending with ".txt"
+// are available to plain text variants, others to html ones.
+func newRegistry(root, dir string) (*registry, error) {
+	r := &registry{
+		html:  template.New("partials").Funcs(templateFuncs()),
+		text:  texttemplate.New("partials").Funcs(texttemplate.FuncMap(templateFuncs())),
+		htmls: make(map[parseKey]*template.Template),
+		texts: make(map[parseKey]*texttemplate.Template),
+	}
+
+	if strings.TrimSpace(dir) == "" {
+		return r, nil
+	}
+
+	if err := jail(root, "partials", dir); err != nil {
+		return nil, err
+	}
+
+	files, err := partialFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("partials: %s", err.Error())
+	}
+
+	for _, path := range files {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("partials: %s", err.Error())
+		}
+
+		name := partialName(path)
+		if filepath.Ext(path) == ".txt" {
+			_, err = r.text.New(name).Parse(string(content))
+		} else {
+			_, err = r.html.New(name).Parse(string(content))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("partial \"%s\": %s", path, err.Error())
+		}
+	}
+
+	return r, nil
+}
+
+// parseHTML parses html template of key with funcs, once per key.
+// Returned template is a copy, which is safe to execute.
+func (r *registry) parseHTML(key parseKey, funcs template.FuncMap) (*template.Template, error) {
+	if r == nil {
+		content, err := key.content()
+		if err != nil {
+			return nil, err
+		}
+		return template.New(key.name).Funcs(funcs).Parse(content)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Cached templates are never executed, html/template can't clone them after that.
+	tpl, ok := r.htmls[key]
+	if !ok {
+		content, err := key.content()
+		if err != nil {
+			return nil, err
+		}
+
+		set, err := r.html.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		if tpl, err = set.Funcs(funcs).New(key.name).Parse(content); err != nil {
+			return nil, err
+		}
+		r.htmls[key] = tpl
+	}
+
+	clone, err := tpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return clone.Funcs(funcs), nil
+}
+
+// parseText is parseHTML of plain text variant.
+func (r *registry) parseText(key parseKey, funcs texttemplate.FuncMap) (*texttemplate.Template, error) {
+	if r == nil {
+		content, err := key.content()
+		if err != nil {
+			return nil, err
+		}
+		return texttemplate.New(key.name).Funcs(funcs).Parse(content)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tpl, ok := r.texts[key]
+	if !ok {
+		content, err := key.content()
+		if err != nil {
+			return nil, err
+		}
+
+		set, err := r.text.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		if tpl, err = set.Funcs(funcs).New(key.name).Parse(content); err != nil {
+			return nil, err
+		}
+		r.texts[key] = tpl
+	}
+
+	clone, err := tpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return clone.Funcs(funcs), nil
+}
+
+func (k parseKey) content() (string, error) {
+	if !k.file {
+		return k.source, nil
+	}
+
+	return readRef(k.source)
+}
+
+// variantKey keys inline variant, when set, or file variant at path.
+func variantKey(name, inline, path string, funcs map[string]interface{}) parseKey {
+	_, items := funcs["items"]
+	if strings.TrimSpace(inline) != "" {
+		return parseKey{name: name, source: inline, items: items}
+	}
+
+	return parseKey{name: filepath.Base(path), source: path, file: true, items: items}
+}
+
+// attachRegistry makes templates of all events parse with r.
+func (c *Config) attachRegistry(r *registry) {
+	c.registry = r
+
+	attach := func(templates map[string]Template) {
+		for key, tpl := range templates {
+			tpl.registry = r
+			templates[key] = tpl
+		}
+	}
+
+	for _, event := range c.Events {
+		attach(event.Templates)
+		if event.Rollout != nil {
+			attach(event.Rollout.Templates)
+		}
+		for _, templates := range event.Tenants {
+			attach(templates)
+		}
+	}
+}
+
+// undefinedRef returns the first name included with {{ template "name" }}
+// from root, directly or through other templates, which none of trees defines,
+// e.g. a partial missing from the directory. Unused partials aren't checked.
+func undefinedRef(root string, trees []*parse.Tree) string {
+	defined := make(map[string]*parse.Tree, len(trees))
+	for _, tree := range trees {
+		defined[tree.Name] = tree
+	}
+
+	seen := map[string]bool{root: true}
+	queue := []string{root}
+	for len(queue) > 0 {
+		tree, ok := defined[queue[0]]
+		if !ok {
+			return queue[0]
+		}
+		queue = queue[1:]
+
+		for _, ref := range templateRefs(tree.Root, nil) {
+			if !seen[ref] {
+				seen[ref] = true
+				queue = append(queue, ref)
+			}
+		}
+	}
+
+	return ""
+}
+
+func htmlTrees(tpl *template.Template) []*parse.Tree {
+	var trees []*parse.Tree
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil {
+			trees = append(trees, t.Tree)
+		}
+	}
+
+	return trees
+}
+
+func textTrees(tpl *texttemplate.Template) []*parse.Tree {
+	var trees []*parse.Tree
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil {
+			trees = append(trees, t.Tree)
+		}
+	}
+
+	return trees
+}
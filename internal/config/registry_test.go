@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "partials")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	partials := filepath.Join(dir, "partials")
+	assert.NoError(t, os.Mkdir(partials, 0755))
+	for name, content := range map[string]string{
+		"layout.html": `<main>{{ template "content" . }}</main>{{ template "footer" . }}`,
+		"footer.html": `<footer>Bye {{ upper .user.name }}</footer>`,
+		"footer.txt":  `-- Bye {{ .user.name }}`,
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(partials, name), []byte(content), 0644))
+	}
+
+	load := func(tpl Template, partials string) (*Config, error) {
+		tmp := SampleConfig()
+		tmp.TemplateRoot = dir
+		tmp.Partials = partials
+		tmp.Events[0].Templates["EN"] = tpl
+
+		content, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		conf, _, err := Load(bytes.NewReader(content), LoadOptions{})
+		return conf, err
+	}
+
+	data := map[string]interface{}{"user": map[string]interface{}{"name": "<b>jd</b>"}}
+
+	t.Run("includes partials", func(t *testing.T) {
+		conf, err := load(Template{
+			Subject:  "Example",
+			Template: `<p>Hi</p>{{ template "footer" . }}`,
+			Text:     `Hi {{ template "footer" . }}`,
+		}, partials)
+		assert.NoError(t, err)
+
+		tpl := conf.Events[0].Template("EN")
+
+		html, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>Hi</p><footer>Bye &lt;B&gt;JD&lt;/B&gt;</footer>", string(html))
+
+		text, err := tpl.TextContent(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hi -- Bye <b>jd</b>", string(text))
+	})
+
+	t.Run("renders layout", func(t *testing.T) {
+		conf, err := load(Template{
+			Subject:  "Example",
+			Template: `{{ define "content" }}<p>Hi</p>{{ end }}{{ template "layout" . }}`,
+		}, partials)
+		assert.NoError(t, err)
+
+		tpl := conf.Events[0].Template("EN")
+		for i := 0; i < 2; i++ {
+			html, err := tpl.Content(data)
+			assert.NoError(t, err)
+			assert.Equal(t, "<main><p>Hi</p></main><footer>Bye &lt;B&gt;JD&lt;/B&gt;</footer>", string(html))
+		}
+	})
+
+	t.Run("parses template files once", func(t *testing.T) {
+		path := filepath.Join(dir, "email.tpl")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(`<p>First</p>`), 0644))
+
+		conf, err := load(Template{Subject: "Example", TemplatePath: path}, "")
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(`<p>Second</p>`), 0644))
+
+		tpl := conf.Events[0].Template("EN")
+		html, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>First</p>", string(html))
+
+		reloaded, err := load(Template{Subject: "Example", TemplatePath: path}, "")
+		assert.NoError(t, err)
+
+		tpl = reloaded.Events[0].Template("EN")
+		html, err = tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>Second</p>", string(html))
+	})
+
+	t.Run("applies to overrides", func(t *testing.T) {
+		conf, err := load(Template{Subject: "Example", Template: `<p>Hi</p>`}, partials)
+		assert.NoError(t, err)
+
+		assert.NoError(t, conf.Override("example", "EN", Template{Subject: "Example", Template: `{{ template "footer" . }}`}))
+	})
+
+	t.Run("rejects undefined template", func(t *testing.T) {
+		_, err := load(Template{Subject: "Example", Template: `{{ template "header" . }}`}, partials)
+		assert.EqualError(t, err, "language \"EN\" in event \"Example\": template \"header\" is not defined")
+
+		_, err = load(Template{Subject: "Example", Text: `{{ template "layout" . }}`}, partials)
+		assert.EqualError(t, err, "language \"EN\" in event \"Example\": text: template \"layout\" is not defined")
+	})
+
+	t.Run("rejects invalid partials", func(t *testing.T) {
+		_, err := load(Template{Subject: "Example", Template: `<p>Hi</p>`}, filepath.Join(dir, "missing"))
+		assert.Error(t, err)
+
+		_, err = load(Template{Subject: "Example", Template: `<p>Hi</p>`}, os.TempDir())
+		assert.EqualError(t, err, "partials \""+os.TempDir()+"\" is outside template root \""+dir+"\"")
+
+		broken := filepath.Join(dir, "broken")
+		assert.NoError(t, os.Mkdir(broken, 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(broken, "footer.html"), []byte(`{{ if }}`), 0644))
+
+		_, err = load(Template{Subject: "Example", Template: `<p>Hi</p>`}, broken)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "partial \""+filepath.Join(broken, "footer.html")+"\"")
+	})
+}
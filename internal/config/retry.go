@@ -0,0 +1,114 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Retry republishes messages, which failed temporarily, e.g. while SMTP server
+// was unavailable, with growing delay, until attempts are exhausted.
+type Retry struct {
+	// Delayed exchange retried messages go through, only set in amqp.retry.
+	Exchange    string `yaml:"exchange,omitempty"`
+	MaxAttempts int    `yaml:"max_attempts,omitempty"`
+	// Delay before the second attempt, e.g. "5s", doubled every next one up to MaxBackoff.
+	Backoff    string `yaml:"backoff,omitempty"`
+	MaxBackoff string `yaml:"max_backoff,omitempty"`
+}
+
+// Delays returns initial and maximum delay between attempts, zero maximum is unbounded.
+func (r *Retry) Delays() (time.Duration, time.Duration) {
+	initial, _ := time.ParseDuration(r.Backoff)
+	max, _ := time.ParseDuration(r.MaxBackoff)
+
+	return initial, max
+}
+
+// RetryOf returns retry of event, fields it omits taken from amqp.retry.
+// It's nil, when temporarily failed messages are requeued as is.
+func (c *Config) RetryOf(e *Event) *Retry {
+	if c.AMQP.Retry == nil {
+		return nil
+	}
+
+	retry := *c.AMQP.Retry
+	if e.Retry == nil {
+		return &retry
+	}
+
+	if e.Retry.MaxAttempts != 0 {
+		retry.MaxAttempts = e.Retry.MaxAttempts
+	}
+	if e.Retry.Backoff != "" {
+		retry.Backoff = e.Retry.Backoff
+	}
+	if e.Retry.MaxBackoff != "" {
+		retry.MaxBackoff = e.Retry.MaxBackoff
+	}
+
+	return &retry
+}
+
+func validateRetry(conf Config) error {
+	global := conf.AMQP.Retry
+	if global != nil {
+		if global.Exchange == "" {
+			return errors.New("amqp retry exchange is not set")
+		}
+
+		if conf.AMQP.DeadLetterExchange == "" {
+			return errors.New("amqp retry needs dead letter exchange")
+		}
+
+		if conf.AMQP.AckMode == AckModeAuto {
+			return errors.New("amqp retry needs manual ack mode")
+		}
+
+		if err := global.validate("amqp retry"); err != nil {
+			return err
+		}
+	}
+
+	for i := range conf.Events {
+		event := &conf.Events[i]
+		if event.Retry == nil {
+			continue
+		}
+
+		if global == nil {
+			return fmt.Errorf("event \"%s\" has retry, but amqp retry is not set", event.Name)
+		}
+
+		if event.Retry.Exchange != "" {
+			return fmt.Errorf("event \"%s\" retry can't set exchange", event.Name)
+		}
+
+		if err := conf.RetryOf(event).validate(fmt.Sprintf("event \"%s\" retry", event.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Retry) validate(label string) error {
+	if r.MaxAttempts < 1 {
+		return fmt.Errorf("%s max attempts should be positive", label)
+	}
+
+	initial, err := time.ParseDuration(r.Backoff)
+	if err != nil || initial <= 0 {
+		return fmt.Errorf("%s backoff \"%s\" is not valid", label, r.Backoff)
+	}
+
+	if r.MaxBackoff == "" {
+		return nil
+	}
+
+	if max, err := time.ParseDuration(r.MaxBackoff); err != nil || max < initial {
+		return fmt.Errorf("%s max backoff \"%s\" is not valid", label, r.MaxBackoff)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_RetryOf(t *testing.T) {
+	conf := SampleConfig()
+	event := &conf.Events[0]
+	assert.Nil(t, conf.RetryOf(event))
+
+	conf.AMQP.Retry = &Retry{Exchange: "postmaster.retry", MaxAttempts: 5, Backoff: "1s", MaxBackoff: "1m"}
+	assert.Equal(t, conf.AMQP.Retry, conf.RetryOf(event))
+
+	event.Retry = &Retry{MaxAttempts: 10, Backoff: "10s"}
+	retry := conf.RetryOf(event)
+	assert.Equal(t, &Retry{Exchange: "postmaster.retry", MaxAttempts: 10, Backoff: "10s", MaxBackoff: "1m"}, retry)
+	assert.Equal(t, 5, conf.AMQP.Retry.MaxAttempts)
+
+	initial, max := retry.Delays()
+	assert.Equal(t, 10*time.Second, initial)
+	assert.Equal(t, time.Minute, max)
+}
+
+func TestValidateRetry(t *testing.T) {
+	retry := func() Config {
+		tmp := SampleConfig()
+		tmp.AMQP.DeadLetterExchange = "postmaster.dlx"
+		tmp.AMQP.Retry = &Retry{Exchange: "postmaster.retry", MaxAttempts: 5, Backoff: "1s"}
+		return tmp
+	}
+
+	cases := map[string]func(conf *Config){
+		"":                                      func(conf *Config) {},
+		"amqp retry exchange is not set":        func(conf *Config) { conf.AMQP.Retry.Exchange = "" },
+		"amqp retry needs dead letter exchange": func(conf *Config) { conf.AMQP.DeadLetterExchange = "" },
+		"amqp retry needs manual ack mode":      func(conf *Config) { conf.AMQP.AckMode = AckModeAuto },
+		"amqp retry max attempts should be positive":    func(conf *Config) { conf.AMQP.Retry.MaxAttempts = 0 },
+		"amqp retry backoff \"soon\" is not valid":      func(conf *Config) { conf.AMQP.Retry.Backoff = "soon" },
+		"amqp retry max backoff \"500ms\" is not valid": func(conf *Config) { conf.AMQP.Retry.MaxBackoff = "500ms" },
+		"event \"Example\" retry can't set exchange":    func(conf *Config) { conf.Events[0].Retry = &Retry{Exchange: "x"} },
+		"event \"Example\" retry backoff \"0s\" is not valid": func(conf *Config) {
+			conf.Events[0].Retry = &Retry{Backoff: "0s"}
+		},
+		"event \"Example\" has quiet hours, which need amqp retry": func(conf *Config) {
+			conf.AMQP.Retry = nil
+			conf.Events[0].QuietHours = &QuietHours{Start: "22:00", End: "08:00"}
+		},
+		"event \"Example\" has retry, but amqp retry is not set": func(conf *Config) {
+			conf.AMQP.Retry = nil
+			conf.Events[0].Retry = &Retry{MaxAttempts: 3}
+		},
+	}
+
+	for expected, change := range cases {
+		change := change
+		t.Run(expected, func(t *testing.T) {
+			tmp := retry()
+			change(&tmp)
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			_, err = Validate(bytes.NewReader(configAsBytes))
+			if expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, expected)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// TemplateSource opens templates referenced by template_path and text_path,
+// e.g. from a database instead of disk.
+type TemplateSource interface {
+	Open(ref string) (io.ReadCloser, error)
+}
+
+// FileSource opens templates from the OS filesystem. It's the default source.
+type FileSource struct{}
+
+// Open opens file at path ref.
+func (FileSource) Open(ref string) (io.ReadCloser, error) {
+	file, err := os.Open(ref)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("file %q not found", ref)
+	}
+
+	return file, err
+}
+
+var (
+	sourceMu       sync.RWMutex
+	templateSource TemplateSource = FileSource{}
+)
+
+// SetTemplateSource makes templates read from source, nil restores FileSource.
+func SetTemplateSource(source TemplateSource) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+
+	if source == nil {
+		source = FileSource{}
+	}
+	templateSource = source
+}
+
+// readRef reads template referenced by ref from the source.
+func readRef(ref string) (string, error) {
+	sourceMu.RLock()
+	source := templateSource
+	sourceMu.RUnlock()
+
+	reader, err := source.Open(ref)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("read %q: %s", ref, err.Error())
+	}
+
+	return string(content), nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySource serves templates by ref from memory.
+type memorySource map[string]string
+
+func (s memorySource) Open(ref string) (io.ReadCloser, error) {
+	content, ok := s[ref]
+	if !ok {
+		return nil, errors.New("template \"" + ref + "\" is not stored")
+	}
+
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func TestTemplateSource(t *testing.T) {
+	SetTemplateSource(memorySource{
+		"welcome/en.html": "<p>Hello {{ .name }}</p>",
+		"welcome/en.txt":  "Hello {{ .name }}",
+	})
+	defer SetTemplateSource(nil)
+
+	data := map[string]interface{}{"name": "John"}
+
+	t.Run("renders templates by ref", func(t *testing.T) {
+		tpl := Template{Subject: "Welcome", TemplatePath: "welcome/en.html", TextPath: "welcome/en.txt"}
+		assert.NoError(t, tpl.Validate())
+
+		content, err := tpl.Content(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>Hello John</p>", string(content))
+
+		text, err := tpl.TextContent(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello John", string(text))
+
+		_, err = tpl.Fingerprint()
+		assert.NoError(t, err)
+	})
+
+	t.Run("loads config referencing source", func(t *testing.T) {
+		raw := "languages:\n- code: EN\n  name: English\nevents:\n- name: Welcome\n  key: user.welcome\n" +
+			"  templates:\n    EN:\n      subject: Welcome\n      template_path: welcome/en.html\n"
+
+		conf, _, err := Load(strings.NewReader(raw), LoadOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "welcome/en.html", conf.Events[0].Templates["EN"].TemplatePath)
+	})
+
+	t.Run("fails on missing ref", func(t *testing.T) {
+		tpl := Template{Subject: "Welcome", TemplatePath: "welcome/de.html"}
+		assert.EqualError(t, tpl.Validate(), "template \"welcome/de.html\" is not stored")
+	})
+
+	t.Run("nil restores filesystem", func(t *testing.T) {
+		SetTemplateSource(nil)
+		defer SetTemplateSource(memorySource{})
+
+		tpl := Template{Subject: "Welcome", TemplatePath: "welcome/en.html"}
+		assert.EqualError(t, tpl.Validate(), "file \"welcome/en.html\" not found")
+	})
+}
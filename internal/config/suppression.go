@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Stores of suppressed addresses.
+const (
+	SuppressionMemory = "memory"
+	SuppressionFile   = "file"
+)
+
+// Suppression keeps addresses, which hard-bounced or complained, from being
+// mailed again, fed by webhooks of providers.
+type Suppression struct {
+	// Either SuppressionMemory, when empty, or SuppressionFile.
+	Store string `yaml:"store,omitempty"`
+	// File of SuppressionFile store.
+	Path string `yaml:"path,omitempty"`
+	// Listen address of webhooks and suppressions API, e.g. ":8080", not served when empty.
+	Addr string `yaml:"addr,omitempty"`
+	// Name of environment variable holding token, which webhook requests must carry.
+	TokenEnv string `yaml:"token_env,omitempty"`
+}
+
+func validateSuppression(s *Suppression) error {
+	if s == nil {
+		return nil
+	}
+
+	switch s.Store {
+	case "", SuppressionMemory:
+		if s.Path != "" {
+			return fmt.Errorf("suppression path is set for %s store", SuppressionMemory)
+		}
+	case SuppressionFile:
+		if strings.TrimSpace(s.Path) == "" {
+			return fmt.Errorf("suppression path should be set for %s store", SuppressionFile)
+		}
+	default:
+		return fmt.Errorf("suppression store \"%s\" should be either %s or %s", s.Store, SuppressionMemory, SuppressionFile)
+	}
+
+	if s.Addr == "" {
+		return nil
+	}
+
+	if err := validateMonitoring(&Monitoring{Addr: s.Addr}); err != nil {
+		return fmt.Errorf("suppression addr \"%s\" is not valid", s.Addr)
+	}
+
+	if strings.TrimSpace(s.TokenEnv) == "" {
+		return errors.New("suppression token env should be set to serve webhooks")
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSuppression(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, validateSuppression(nil))
+		assert.NoError(t, validateSuppression(&Suppression{}))
+		assert.NoError(t, validateSuppression(&Suppression{Store: SuppressionFile, Path: "suppressions.json"}))
+		assert.NoError(t, validateSuppression(&Suppression{Addr: ":8080", TokenEnv: "WEBHOOK_TOKEN"}))
+	})
+
+	t.Run("store", func(t *testing.T) {
+		assert.EqualError(t, validateSuppression(&Suppression{Store: "redis"}),
+			"suppression store \"redis\" should be either memory or file")
+		assert.EqualError(t, validateSuppression(&Suppression{Store: SuppressionFile}),
+			"suppression path should be set for file store")
+		assert.EqualError(t, validateSuppression(&Suppression{Path: "suppressions.json"}),
+			"suppression path is set for memory store")
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		assert.EqualError(t, validateSuppression(&Suppression{Addr: "8080", TokenEnv: "WEBHOOK_TOKEN"}),
+			"suppression addr \"8080\" is not valid")
+		assert.EqualError(t, validateSuppression(&Suppression{Addr: ":8080"}),
+			"suppression token env should be set to serve webhooks")
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		conf := SampleConfig()
+		conf.Suppression = &Suppression{Store: "redis"}
+		assert.EqualError(t, validate(conf), "suppression store \"redis\" should be either memory or file")
+	})
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateTenants checks tenant overrides of event are valid templates
+// of languages, which event defines.
+func validateTenants(event Event, fragments map[string]string) error {
+	for tenant, overrides := range event.Tenants {
+		if strings.TrimSpace(tenant) == "" {
+			return fmt.Errorf("tenant name in event \"%s\" is empty", event.Name)
+		}
+
+		for lang, tpl := range overrides {
+			if err := validateTemplate(tpl, fragments); err != nil {
+				return fmt.Errorf("tenant \"%s\" language \"%s\" in event \"%s\": %s", tenant, lang, event.Name, err.Error())
+			}
+
+			if _, exists := event.Templates[lang]; !exists {
+				return fmt.Errorf("tenant \"%s\" language \"%s\" in event \"%s\" is not defined", tenant, lang, event.Name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func tenantConfig() Config {
+	conf := fallbackConfig()
+	conf.Events[0].Templates = map[string]Template{
+		"EN": {Subject: "Welcome", Template: "Welcome"},
+		"PT": {Subject: "Bem-vindo", Template: "Bem-vindo"},
+	}
+	conf.Events[0].Tenants = map[string]map[string]Template{
+		"acme": {
+			"EN": {Subject: "Welcome to Acme", Template: "<img src=\"acme.png\"> Welcome"},
+		},
+	}
+
+	return conf
+}
+
+func TestConfig_ResolveTemplate_Tenant(t *testing.T) {
+	conf := tenantConfig()
+	event := &conf.Events[0]
+
+	t.Run("tenant with override", func(t *testing.T) {
+		tpl, version := conf.ResolveTemplate(event, "acme", "EN", "", "john@example.com")
+		assert.Equal(t, "Welcome to Acme", tpl.Subject)
+		assert.Equal(t, BaseVersion, version)
+	})
+
+	t.Run("tenant without override", func(t *testing.T) {
+		tpl, _ := conf.ResolveTemplate(event, "globex", "EN", "", "john@example.com")
+		assert.Equal(t, "Welcome", tpl.Subject)
+	})
+
+	t.Run("no tenant", func(t *testing.T) {
+		tpl, _ := conf.ResolveTemplate(event, "", "EN", "", "john@example.com")
+		assert.Equal(t, "Welcome", tpl.Subject)
+	})
+
+	t.Run("base template of nearer language wins", func(t *testing.T) {
+		tpl, _ := conf.ResolveTemplate(event, "acme", "PT-BR", "", "john@example.com")
+		assert.Equal(t, "Bem-vindo", tpl.Subject)
+	})
+}
+
+func TestValidate_Tenants(t *testing.T) {
+	load := func(conf Config) error {
+		configAsBytes, err := yaml.Marshal(conf)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		return err
+	}
+
+	t.Run("valid overrides", func(t *testing.T) {
+		assert.NoError(t, load(tenantConfig()))
+	})
+
+	t.Run("override of undefined language", func(t *testing.T) {
+		conf := tenantConfig()
+		conf.Events[0].Tenants["acme"]["PT-BR"] = Template{Subject: "Olá", Template: "Olá"}
+
+		assert.EqualError(t, load(conf), "tenant \"acme\" language \"PT-BR\" in event \"Example\" is not defined")
+	})
+
+	t.Run("invalid override", func(t *testing.T) {
+		conf := tenantConfig()
+		conf.Events[0].Tenants["acme"]["EN"] = Template{Subject: "Hi", Template: "{{ .name "}
+
+		assert.Error(t, load(conf))
+	})
+
+	t.Run("empty tenant name", func(t *testing.T) {
+		conf := tenantConfig()
+		conf.Events[0].Tenants[" "] = conf.Events[0].Tenants["acme"]
+
+		assert.EqualError(t, load(conf), "tenant name in event \"Example\" is empty")
+	})
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	texttemplate "text/template"
+)
+
+// HasHTML reports whether template has HTML variant.
+func (t *Template) HasHTML() bool {
+	return strings.TrimSpace(t.Template) != "" || strings.TrimSpace(t.TemplatePath) != ""
+}
+
+// HasText reports whether template has plain text variant.
+func (t *Template) HasText() bool {
+	return strings.TrimSpace(t.Text) != "" || strings.TrimSpace(t.TextPath) != ""
+}
+
+func (t *Template) parseText() (*texttemplate.Template, error) {
+	funcs := texttemplate.FuncMap(templateFuncs())
+
+	return t.registry.parseText(variantKey(t.Subject, t.Text, t.TextPath, funcs), funcs)
+}
+
+// TextContent renders plain text variant against data. Unlike Content, output isn't HTML-escaped.
+func (t *Template) TextContent(data interface{}) ([]byte, error) {
+	tpl, err := t.parseText()
+	if err != nil {
+		return nil, err
+	}
+
+	buff := new(bytes.Buffer)
+	if err := tpl.Funcs(texttemplate.FuncMap(t.renderFuncs(data))).Execute(buff, data); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_TextContent(t *testing.T) {
+	t.Run("renders without escaping", func(t *testing.T) {
+		tpl := Template{Subject: "Hello", Text: "Hi {{ .Test }}"}
+
+		content, err := tpl.TextContent(NewFakeData("<John> & co"))
+		assert.NoError(t, err)
+		assert.Equal(t, "Hi <John> & co", string(content))
+	})
+
+	t.Run("renders file", func(t *testing.T) {
+		tpl := Template{Subject: "Hello", TextPath: templatePath}
+
+		content, err := tpl.TextContent(NewFakeData("<John>"))
+		assert.NoError(t, err)
+		assert.Equal(t, "<John>", string(content))
+	})
+}
+
+func TestTemplate_Validate_Text(t *testing.T) {
+	t.Run("text only", func(t *testing.T) {
+		tpl := Template{Subject: "Hello", Text: "Hi"}
+
+		assert.True(t, tpl.HasText())
+		assert.False(t, tpl.HasHTML())
+		assert.NoError(t, tpl.Validate())
+	})
+
+	t.Run("both text and text path", func(t *testing.T) {
+		tpl := Template{Subject: "Hello", Text: "Hi", TextPath: templatePath}
+		assert.EqualError(t, tpl.Validate(), "text and text path is specified")
+	})
+
+	t.Run("broken text", func(t *testing.T) {
+		tpl := Template{Subject: "Hello", Template: "<p>Hi</p>", Text: "Hi {{ .Test"}
+		assert.EqualError(t, tpl.Validate(), "text: template: Hello:1: unclosed action")
+	})
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Tracking identifies messages, which recipients interacted with,
+// by per-message token of render data.
+type Tracking struct {
+	// Field of render data holding the token, e.g. "tracking_id" or "meta.token".
+	TokenField string `yaml:"token_field"`
+	// Links of events with link tracking are rewritten to redirects through
+	// LinkURL, getting original URL and token as "url" and "token" query parameters.
+	LinkURL string `yaml:"link_url,omitempty"`
+	// HTML bodies of events with open tracking get 1x1 image loaded from
+	// PixelURL, getting token as "token" query parameter.
+	PixelURL string `yaml:"pixel_url,omitempty"`
+}
+
+// Token returns tracking token of render data.
+func (t *Tracking) Token(data map[string]interface{}) (string, error) {
+	var value interface{} = data
+	for _, key := range strings.Split(t.TokenField, ".") {
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			value = nil
+			break
+		}
+		value = nested[key]
+	}
+
+	token := ""
+	if value != nil {
+		token = strings.TrimSpace(fmt.Sprint(value))
+	}
+
+	if token == "" {
+		return "", fmt.Errorf("tracking token field \"%s\" is missing", t.TokenField)
+	}
+
+	return token, nil
+}
+
+// TrackLinks rewrites links of HTML body to tracking redirects carrying token
+// of render data, when event has link tracking. Only absolute http(s) links
+// are rewritten, so mailto, in-page anchors and relative links are kept,
+// as well as links, which are tracked already.
+func (c *Config) TrackLinks(e *Event, body []byte, data map[string]interface{}) ([]byte, error) {
+	if !e.LinkTracking || c.Tracking == nil {
+		return body, nil
+	}
+
+	token, err := c.Tracking.Token(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		next := tokenizer.Next()
+		if next == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				return nil, tokenizer.Err()
+			}
+			return out.Bytes(), nil
+		}
+
+		// Raw is overwritten by Token, so it's written or copied first.
+		raw := tokenizer.Raw()
+		if next != html.StartTagToken && next != html.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+
+		raw = append([]byte(nil), raw...)
+		tag := tokenizer.Token()
+		if tag.Data != "a" || !c.Tracking.rewrite(tag.Attr, token) {
+			out.Write(raw)
+			continue
+		}
+		out.WriteString(tag.String())
+	}
+}
+
+// TrackOpens inserts tracking pixel carrying token of render data before </body>
+// of HTML body, when event has open tracking. Body without </body> gets it appended.
+func (c *Config) TrackOpens(e *Event, body []byte, data map[string]interface{}) ([]byte, error) {
+	if !e.OpenTracking || c.Tracking == nil {
+		return body, nil
+	}
+
+	token, err := c.Tracking.Token(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pixel, _ := url.Parse(c.Tracking.PixelURL)
+	query := pixel.Query()
+	query.Set("token", token)
+	pixel.RawQuery = query.Encode()
+
+	img := fmt.Sprintf(`<img src="%s" width="1" height="1" alt="" style="display:none">`, html.EscapeString(pixel.String()))
+
+	end := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+	if end < 0 {
+		end = len(body)
+	}
+
+	out := make([]byte, 0, len(body)+len(img))
+	out = append(out, body[:end]...)
+	out = append(out, img...)
+	return append(out, body[end:]...), nil
+}
+
+// rewrite replaces href of attrs with tracking redirect, telling whether it did.
+func (t *Tracking) rewrite(attrs []html.Attribute, token string) bool {
+	for i, attr := range attrs {
+		if attr.Namespace != "" || attr.Key != "href" {
+			continue
+		}
+
+		href := strings.TrimSpace(attr.Val)
+		link, err := url.Parse(href)
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") || strings.HasPrefix(href, t.LinkURL) {
+			return false
+		}
+
+		redirect, _ := url.Parse(t.LinkURL)
+		query := redirect.Query()
+		query.Set("url", href)
+		query.Set("token", token)
+		redirect.RawQuery = query.Encode()
+
+		attrs[i].Val = redirect.String()
+		return true
+	}
+
+	return false
+}
+
+func validateTracking(conf Config) error {
+	if t := conf.Tracking; t != nil {
+		for _, key := range strings.Split(t.TokenField, ".") {
+			if strings.TrimSpace(key) == "" {
+				return fmt.Errorf("tracking token field \"%s\" is not valid", t.TokenField)
+			}
+		}
+
+		if err := validateTrackingURL("link", t.LinkURL); err != nil {
+			return err
+		}
+
+		if err := validateTrackingURL("pixel", t.PixelURL); err != nil {
+			return err
+		}
+	}
+
+	for _, event := range conf.Events {
+		if event.LinkTracking && (conf.Tracking == nil || conf.Tracking.LinkURL == "") {
+			return fmt.Errorf("event \"%s\" has link tracking, but tracking link url is not set", event.Name)
+		}
+
+		if event.OpenTracking && (conf.Tracking == nil || conf.Tracking.PixelURL == "") {
+			return fmt.Errorf("event \"%s\" has open tracking, but tracking pixel url is not set", event.Name)
+		}
+	}
+
+	return nil
+}
+
+func validateTrackingURL(kind, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if parsed, err := url.Parse(value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("tracking %s url \"%s\" should be absolute http(s) URL", kind, value)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracking_Token(t *testing.T) {
+	tracking := Tracking{TokenField: "meta.token"}
+
+	token, err := tracking.Token(map[string]interface{}{"meta": map[string]interface{}{"token": "abc"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+
+	token, err = tracking.Token(map[string]interface{}{"meta": map[string]interface{}{"token": 42.0}})
+	assert.NoError(t, err)
+	assert.Equal(t, "42", token)
+
+	for _, data := range []map[string]interface{}{
+		{},
+		{"meta": "token"},
+		{"meta": map[string]interface{}{"token": " "}},
+	} {
+		_, err = tracking.Token(data)
+		assert.EqualError(t, err, "tracking token field \"meta.token\" is missing")
+	}
+}
+
+func TestConfig_TrackLinks(t *testing.T) {
+	conf := Config{Tracking: &Tracking{TokenField: "tracking_id", LinkURL: "https://click.example.com/r?src=email"}}
+	event := Event{LinkTracking: true}
+	data := map[string]interface{}{"tracking_id": "t 1"}
+
+	t.Run("rewrites links", func(t *testing.T) {
+		body := `<p><a class="btn" href="https://example.com/confirm?id=1&amp;lang=en">Confirm</a>` +
+			`<a href='HTTP://example.com/'>Home</a></p>`
+
+		out, err := conf.TrackLinks(&event, []byte(body), data)
+		assert.NoError(t, err)
+		assert.Equal(t, `<p><a class="btn" href="https://click.example.com/r?src=email&amp;token=t+1&amp;url=https%3A%2F%2Fexample.com%2Fconfirm%3Fid%3D1%26lang%3Den">Confirm</a>`+
+			`<a href="https://click.example.com/r?src=email&amp;token=t+1&amp;url=HTTP%3A%2F%2Fexample.com%2F">Home</a></p>`, string(out))
+	})
+
+	t.Run("keeps mailto, anchors and tracked links", func(t *testing.T) {
+		body := `<a href="mailto:help@example.com">Help</a> <a href="#top">Top</a> <a name="top">` +
+			`<a href="/relative">Relative</a> <a href="https://click.example.com/r?src=email&amp;url=x">Tracked</a>` +
+			`<img src="https://example.com/logo.png"><!-- <a href="https://example.com/"> -->`
+
+		out, err := conf.TrackLinks(&event, []byte(body), data)
+		assert.NoError(t, err)
+		assert.Equal(t, body, string(out))
+	})
+
+	t.Run("leaves events without link tracking", func(t *testing.T) {
+		body := []byte(`<a href="https://example.com/">Home</a>`)
+
+		out, err := conf.TrackLinks(&Event{}, body, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, body, out)
+	})
+
+	t.Run("fails without token", func(t *testing.T) {
+		_, err := conf.TrackLinks(&event, []byte(`<a href="https://example.com/">Home</a>`), map[string]interface{}{})
+		assert.EqualError(t, err, "tracking token field \"tracking_id\" is missing")
+	})
+}
+
+func TestConfig_TrackOpens(t *testing.T) {
+	conf := Config{Tracking: &Tracking{TokenField: "tracking_id", PixelURL: "https://open.example.com/p.gif"}}
+	event := Event{OpenTracking: true}
+	data := map[string]interface{}{"tracking_id": "a&b"}
+	pixel := `<img src="https://open.example.com/p.gif?token=a%26b" width="1" height="1" alt="" style="display:none">`
+
+	t.Run("inserts pixel before body end", func(t *testing.T) {
+		out, err := conf.TrackOpens(&event, []byte("<html><BODY><p>Hi</p></BODY></html>"), data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<html><BODY><p>Hi</p>"+pixel+"</BODY></html>", string(out))
+	})
+
+	t.Run("appends pixel to fragment", func(t *testing.T) {
+		out, err := conf.TrackOpens(&event, []byte("<p>Hi</p>"), data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>Hi</p>"+pixel, string(out))
+	})
+
+	t.Run("leaves events without open tracking", func(t *testing.T) {
+		out, err := conf.TrackOpens(&Event{}, []byte("<p>Hi</p>"), data)
+		assert.NoError(t, err)
+		assert.Equal(t, "<p>Hi</p>", string(out))
+	})
+
+	t.Run("fails without token", func(t *testing.T) {
+		_, err := conf.TrackOpens(&event, []byte("<p>Hi</p>"), nil)
+		assert.EqualError(t, err, "tracking token field \"tracking_id\" is missing")
+	})
+}
+
+func TestValidate_Tracking(t *testing.T) {
+	cases := map[string]struct {
+		tracking     *Tracking
+		linkTracking bool
+		openTracking bool
+		err          string
+	}{
+		"link tracking without url": {
+			&Tracking{TokenField: "tracking_id"}, true, false,
+			"event \"Example\" has link tracking, but tracking link url is not set",
+		},
+		"link tracking without tracking": {
+			nil, true, false,
+			"event \"Example\" has link tracking, but tracking link url is not set",
+		},
+		"relative link url": {
+			&Tracking{TokenField: "tracking_id", LinkURL: "/r"}, false, false,
+			"tracking link url \"/r\" should be absolute http(s) URL",
+		},
+		"empty token field": {
+			&Tracking{TokenField: "meta.", LinkURL: "https://click.example.com/r"}, false, false,
+			"tracking token field \"meta.\" is not valid",
+		},
+		"open tracking without url": {
+			&Tracking{TokenField: "tracking_id", LinkURL: "https://click.example.com/r"}, true, true,
+			"event \"Example\" has open tracking, but tracking pixel url is not set",
+		},
+		"invalid pixel url": {
+			&Tracking{TokenField: "tracking_id", PixelURL: "ftp://open.example.com/p.gif"}, false, false,
+			"tracking pixel url \"ftp://open.example.com/p.gif\" should be absolute http(s) URL",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tmp := SampleConfig()
+			tmp.Tracking = tc.tracking
+			tmp.Events[0].LinkTracking = tc.linkTracking
+			tmp.Events[0].OpenTracking = tc.openTracking
+
+			configAsBytes, err := yaml.Marshal(tmp)
+			assert.NoError(t, err)
+
+			_, err = Validate(bytes.NewReader(configAsBytes))
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+
+	t.Run("link and open tracking", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Tracking = &Tracking{TokenField: "tracking_id", LinkURL: "https://click.example.com/r", PixelURL: "https://open.example.com/p.gif"}
+		tmp.Events[0].LinkTracking = true
+		tmp.Events[0].OpenTracking = true
+
+		configAsBytes, err := yaml.Marshal(tmp)
+		assert.NoError(t, err)
+
+		valid, err := Validate(bytes.NewReader(configAsBytes))
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	})
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Variants names variants of template, "html" and "text", in that order.
+func (t *Template) Variants() []string {
+	var variants []string
+	if t.HasHTML() {
+		variants = append(variants, "html")
+	}
+	if t.HasText() {
+		variants = append(variants, "text")
+	}
+
+	return variants
+}
+
+// validateVariants checks every enabled language resolves to template
+// with the same variants in each event, see Config.StrictVariants.
+func validateVariants(conf Config) error {
+	for _, event := range conf.Events {
+		if event.Disabled {
+			continue
+		}
+
+		var first, expected string
+
+		for _, lang := range conf.Languages {
+			if lang.Disabled {
+				continue
+			}
+
+			tpl, _ := conf.ResolveTemplate(&event, "", lang.Code, "", "")
+			variants := strings.Join(tpl.Variants(), " and ")
+
+			if first == "" {
+				first, expected = lang.Code, variants
+			} else if variants != expected {
+				return fmt.Errorf("event \"%s\": language \"%s\" provides %s, while \"%s\" provides %s",
+					event.Name, lang.Code, variants, first, expected)
+			}
+		}
+	}
+
+	return nil
+}
+
+// templateExtensions mark values of html_template and text_template,
+// which are paths to files rather than inline templates.
+var templateExtensions = map[string]bool{
+	".tpl":  true,
+	".tmpl": true,
+	".html": true,
+	".htm":  true,
+	".txt":  true,
+}
+
+// variantPath reports whether value of html_template or text_template is path:
+// single line ending with one of templateExtensions.
+func variantPath(value string) bool {
+	value = strings.TrimSpace(value)
+	return !strings.ContainsAny(value, "\r\n") && templateExtensions[strings.ToLower(filepath.Ext(value))]
+}
+
+// expandVariants moves html_template and text_template of t into fields
+// of their inline or file source, which the rest of config reads.
+func (t *Template) expandVariants() error {
+	variants := []struct {
+		name         string
+		value        string
+		inline, path *string
+	}{
+		{"html_template", t.HTMLTemplate, &t.Template, &t.TemplatePath},
+		{"text_template", t.TextTemplate, &t.Text, &t.TextPath},
+	}
+
+	for _, v := range variants {
+		if strings.TrimSpace(v.value) == "" {
+			continue
+		}
+
+		if strings.TrimSpace(*v.inline) != "" || strings.TrimSpace(*v.path) != "" {
+			return fmt.Errorf("%s and its inline or path source is specified", v.name)
+		}
+
+		if variantPath(v.value) {
+			*v.path = strings.TrimSpace(v.value)
+		} else {
+			*v.inline = v.value
+		}
+	}
+
+	t.HTMLTemplate, t.TextTemplate = "", ""
+	return nil
+}
+
+// expandVariants expands html_template and text_template of all templates,
+// see Template.expandVariants.
+func expandVariants(conf *Config) error {
+	expand := func(templates map[string]Template) error {
+		for key, tpl := range templates {
+			if err := tpl.expandVariants(); err != nil {
+				return fmt.Errorf("template %s: %s", key, err.Error())
+			}
+			templates[key] = tpl
+		}
+
+		return nil
+	}
+
+	for _, event := range conf.Events {
+		if err := expand(event.Templates); err != nil {
+			return fmt.Errorf("event \"%s\" %s", event.Name, err.Error())
+		}
+
+		for tenant, templates := range event.Tenants {
+			if err := expand(templates); err != nil {
+				return fmt.Errorf("event \"%s\" tenant %s %s", event.Name, tenant, err.Error())
+			}
+		}
+
+		if event.Rollout != nil {
+			if err := expand(event.Rollout.Templates); err != nil {
+				return fmt.Errorf("event \"%s\" rollout %s", event.Name, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
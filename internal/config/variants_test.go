@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Variants(t *testing.T) {
+	assert.Equal(t, []string{"html"}, (&Template{Template: "Hi"}).Variants())
+	assert.Equal(t, []string{"text"}, (&Template{TextPath: "hi.txt"}).Variants())
+	assert.Equal(t, []string{"html", "text"}, (&Template{TemplatePath: "hi.tpl", Text: "Hi"}).Variants())
+}
+
+func TestValidate_StrictVariants(t *testing.T) {
+	load := func(conf Config) error {
+		configAsBytes, err := yaml.Marshal(conf)
+		assert.NoError(t, err)
+
+		_, err = Validate(bytes.NewReader(configAsBytes))
+		return err
+	}
+
+	variantsConfig := func(strict bool, de Template) Config {
+		conf := SampleConfig()
+		conf.StrictVariants = strict
+		conf.Languages = append(conf.Languages, Language{Code: "DE", Name: "German"})
+		conf.Events[0].Templates = map[string]Template{
+			"EN": {Subject: "Hello", Template: "<p>Hello</p>", Text: "Hello"},
+			"DE": de,
+		}
+
+		return conf
+	}
+
+	htmlOnly := Template{Subject: "Hallo", Template: "<p>Hallo</p>"}
+
+	t.Run("matching variants", func(t *testing.T) {
+		both := Template{Subject: "Hallo", Template: "<p>Hallo</p>", Text: "Hallo"}
+		assert.NoError(t, load(variantsConfig(true, both)))
+	})
+
+	t.Run("mismatched variants", func(t *testing.T) {
+		assert.EqualError(t, load(variantsConfig(true, htmlOnly)),
+			"event \"Example\": language \"DE\" provides html, while \"EN\" provides html and text")
+	})
+
+	t.Run("mismatch allowed without strict variants", func(t *testing.T) {
+		assert.NoError(t, load(variantsConfig(false, htmlOnly)))
+	})
+
+	t.Run("disabled language is skipped", func(t *testing.T) {
+		conf := variantsConfig(true, htmlOnly)
+		conf.Languages[1].Disabled = true
+		assert.NoError(t, load(conf))
+	})
+}
+
+func TestLoad_VariantTemplates(t *testing.T) {
+	load := func(tpl Template) (*Config, error) {
+		conf := SampleConfig()
+		conf.Events[0].Templates = map[string]Template{"EN": tpl}
+
+		configAsBytes, err := yaml.Marshal(conf)
+		assert.NoError(t, err)
+
+		loaded, _, err := Load(bytes.NewReader(configAsBytes), LoadOptions{})
+		return loaded, err
+	}
+
+	t.Run("inline and path variants", func(t *testing.T) {
+		conf, err := load(Template{
+			Subject:      "Hello",
+			HTMLTemplate: "../../test/test.tpl",
+			TextTemplate: "Hello {{ .user.email }}",
+		})
+		assert.NoError(t, err)
+
+		tpl := conf.Events[0].Templates["EN"]
+		assert.Equal(t, "../../test/test.tpl", tpl.TemplatePath)
+		assert.Equal(t, "Hello {{ .user.email }}", tpl.Text)
+		assert.Empty(t, tpl.HTMLTemplate)
+		assert.Empty(t, tpl.TextTemplate)
+		assert.Equal(t, []string{"html", "text"}, tpl.Variants())
+	})
+
+	t.Run("conflicting sources", func(t *testing.T) {
+		_, err := load(Template{Subject: "Hello", HTMLTemplate: "<p>Hello</p>", Template: "<p>Hi</p>"})
+		assert.EqualError(t, err, "event \"Example\" template EN: html_template and its inline or path source is specified")
+	})
+
+	t.Run("neither variant", func(t *testing.T) {
+		_, err := load(Template{Subject: "Hello"})
+		assert.Error(t, err)
+	})
+}
+
+func TestVariantPath(t *testing.T) {
+	assert.True(t, variantPath("templates/en/welcome.html"))
+	assert.True(t, variantPath(" welcome.TXT "))
+	assert.False(t, variantPath("Hello {{ .name }}"))
+	assert.False(t, variantPath("See you.\nvisit example.txt"))
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Warning is a recoverable problem of config, which Load reports without
+// failing, since sending isn't affected, e.g. broken preview data.
+type Warning struct {
+	// Check, which found the problem: "preview_data", "catalog", "sender"
+	// or "binding".
+	Check   string
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Check, w.Message)
+}
+
+// warnings runs checks of config, which problems are recoverable.
+func warnings(conf Config) []Warning {
+	var result []Warning
+
+	for _, event := range conf.Events {
+		// Preview data is only read by previews.
+		if _, err := event.PreviewData(); err != nil {
+			result = append(result, Warning{"preview_data", fmt.Sprintf("event \"%s\": %s", event.Name, err.Error())})
+		}
+	}
+
+	// Languages of catalogs are uppercased by validateCatalogs.
+	langs := make([]string, 0, len(conf.Catalogs))
+	for lang := range conf.Catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	for _, lang := range langs {
+		if len(conf.Catalogs[lang]) == 0 {
+			result = append(result, Warning{"catalog", fmt.Sprintf("catalog language \"%s\" is empty", lang)})
+		}
+	}
+
+	used := map[string]bool{}
+	for _, event := range conf.Events {
+		used[event.Sender] = true
+	}
+
+	names := make([]string, 0, len(conf.Senders))
+	for name := range conf.Senders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !used[name] {
+			result = append(result, Warning{"sender", fmt.Sprintf("sender \"%s\" is not used by any event", name)})
+		}
+	}
+
+	return append(result, bindingWarnings(conf)...)
+}
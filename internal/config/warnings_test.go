@@ -0,0 +1,57 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad_Warnings(t *testing.T) {
+	load := func(conf Config) (*Config, []Warning, error) {
+		configAsBytes, err := yaml.Marshal(conf)
+		assert.NoError(t, err)
+
+		return Load(bytes.NewReader(configAsBytes), LoadOptions{})
+	}
+
+	t.Run("valid config has none", func(t *testing.T) {
+		conf, warnings, err := load(SampleConfig())
+		assert.NoError(t, err)
+		assert.NotNil(t, conf)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("recoverable problems are warnings", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].PreviewPath = "missing.json"
+		tmp.Catalogs = map[string]map[string]string{"EN": {}}
+		tmp.Senders = map[string]Sender{
+			"bulk": {Host: "smtp.bulk.io", Port: "587", PasswordEnv: "BULK_SMTP_PASSWORD"},
+			"ses":  {Host: "email-smtp.eu-west-1.amazonaws.com", Port: "587", PasswordEnv: "SES_KEY"},
+		}
+		tmp.Events[0].Sender = "ses"
+
+		conf, warnings, err := load(tmp)
+		assert.NoError(t, err)
+		assert.NotNil(t, conf)
+		assert.Equal(t, []Warning{
+			{"preview_data", "event \"Example\": open missing.json: no such file or directory"},
+			{"catalog", "catalog language \"EN\" is empty"},
+			{"sender", "sender \"bulk\" is not used by any event"},
+		}, warnings)
+		assert.Equal(t, "catalog: catalog language \"EN\" is empty", warnings[1].String())
+	})
+
+	t.Run("fatal problems are errors", func(t *testing.T) {
+		tmp := SampleConfig()
+		tmp.Events[0].PreviewPath = "missing.json"
+		tmp.Events[0].Sender = "bulk"
+
+		conf, warnings, err := load(tmp)
+		assert.EqualError(t, err, "sender \"bulk\" of event \"Example\" is not configured")
+		assert.Nil(t, conf)
+		assert.Empty(t, warnings)
+	})
+}
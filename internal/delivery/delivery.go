@@ -0,0 +1,172 @@
+// Package delivery sends rendered emails through provider APIs, for
+// environments where outbound SMTP is blocked.
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// Message is rendered email handed to provider.
+type Message struct {
+	FromAddress string
+	FromName    string
+	ToAddress   string
+	CC          []string
+	Subject     string
+	// Value of Content-Type header of Body, including parameters.
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+
+	// TemplateID names template hosted by provider, which renders Data instead of Body.
+	TemplateID string
+	Data       map[string]interface{}
+}
+
+// Sender delivers messages through provider API.
+// Send should give up with ctx.Err() once ctx is done.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// StatusError is unsuccessful response of provider API.
+type StatusError struct {
+	Provider string
+	Code     int
+	Body     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: status %d: %s", e.Provider, e.Code, e.Body)
+}
+
+// Temporary reports whether sending again later may succeed,
+// i.e. provider is throttling or failing.
+func (e *StatusError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// New creates sender of provider API configured by conf, authenticated with
+// username and password resolved by caller, see config.Sender.
+func New(conf config.Sender, username, password string) (Sender, error) {
+	switch conf.Provider {
+	case config.ProviderSES:
+		return NewSES(conf.Region, username, password), nil
+	case config.ProviderSendGrid:
+		return NewSendGrid(password), nil
+	case config.ProviderMailgun:
+		return NewMailgun(conf.Region, conf.Domain, password), nil
+	}
+
+	return nil, fmt.Errorf("provider \"%s\" has no API", conf.Provider)
+}
+
+// maxErrorBody limits response body kept in StatusError.
+const maxErrorBody = 512
+
+// do sends request with client, failing with StatusError unless response is 2xx.
+func do(client *http.Client, provider string, req *http.Request) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %s", provider, err.Error())
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	if res.StatusCode/100 != 2 {
+		return &StatusError{Provider: provider, Code: res.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	return nil
+}
+
+// from formats From header of msg, quoting display name.
+func (msg Message) from() string {
+	return (&mail.Address{Name: msg.FromName, Address: msg.FromAddress}).String()
+}
+
+// MIME formats msg as RFC 5322 message with Body following the headers.
+func (msg Message) MIME() ([]byte, error) {
+	if msg.TemplateID != "" {
+		return nil, fmt.Errorf("provider template \"%s\" has no MIME form", msg.TemplateID)
+	}
+
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("subject contains line break")
+	}
+
+	headers := [][2]string{
+		{"MIME-Version", "1.0"},
+		{"From", msg.from()},
+		{"To", msg.ToAddress},
+	}
+	if len(msg.CC) != 0 {
+		headers = append(headers, [2]string{"Cc", strings.Join(msg.CC, ", ")})
+	}
+	headers = append(headers, [2]string{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)})
+
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		headers = append(headers, [2]string{name, msg.Headers[name]})
+	}
+	headers = append(headers, [2]string{"Content-Type", msg.ContentType})
+
+	// Parts of multipart body are encoded already.
+	body := msg.Body
+	if mediaType, _, _ := mime.ParseMediaType(msg.ContentType); !strings.HasPrefix(mediaType, "multipart/") {
+		encoded := bytes.Buffer{}
+		encoder := quotedprintable.NewWriter(&encoded)
+		if _, err := encoder.Write(body); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+
+		headers = append(headers, [2]string{"Content-Transfer-Encoding", "quoted-printable"})
+		body = encoded.Bytes()
+	}
+
+	buff := bytes.Buffer{}
+	for _, header := range headers {
+		// Values are written into header as is, whoever built the message.
+		if strings.ContainsAny(header[0]+header[1], "\r\n") {
+			return nil, fmt.Errorf("header %s contains line break", header[0])
+		}
+		fmt.Fprintf(&buff, "%s: %s\r\n", header[0], header[1])
+	}
+	buff.WriteString("\r\n")
+	buff.Write(body)
+
+	return buff.Bytes(), nil
+}
+
+// recipients returns envelope recipients of msg.
+func (msg Message) recipients() ([]string, error) {
+	result := make([]string, 0, 1+len(msg.CC))
+	for _, address := range append([]string{msg.ToAddress}, msg.CC...) {
+		if strings.TrimSpace(address) == "" {
+			return nil, errors.New("recipient address is empty")
+		}
+		result = append(result, address)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package delivery
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleMessage() Message {
+	return Message{
+		FromAddress: "noreply@example.com",
+		FromName:    "Example",
+		ToAddress:   "john@doe.com",
+		CC:          []string{"jane@doe.com"},
+		Subject:     "Welcome",
+		ContentType: "text/html; charset=utf-8",
+		Headers:     map[string]string{"X-Correlation-Id": "42"},
+		Body:        []byte("<p>Hi</p>"),
+	}
+}
+
+func TestMessage_MIME(t *testing.T) {
+	t.Run("headers precede body", func(t *testing.T) {
+		raw, err := sampleMessage().MIME()
+		assert.NoError(t, err)
+		assert.Equal(t, "MIME-Version: 1.0\r\n"+
+			"From: \"Example\" <noreply@example.com>\r\n"+
+			"To: john@doe.com\r\n"+
+			"Cc: jane@doe.com\r\n"+
+			"Subject: Welcome\r\n"+
+			"X-Correlation-Id: 42\r\n"+
+			"Content-Type: text/html; charset=utf-8\r\n"+
+			"Content-Transfer-Encoding: quoted-printable\r\n"+
+			"\r\n"+
+			"<p>Hi</p>", string(raw))
+	})
+
+	t.Run("encodes subject", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.Subject = "Willkommen, Jürgen"
+
+		raw, err := msg.MIME()
+		assert.NoError(t, err)
+		assert.Contains(t, string(raw), "Subject: =?utf-8?q?Willkommen,_J=C3=BCrgen?=\r\n")
+	})
+
+	t.Run("rejects line breaks", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.Subject = "Hi\r\nBcc: x@y.z"
+		_, err := msg.MIME()
+		assert.EqualError(t, err, "subject contains line break")
+
+		msg = sampleMessage()
+		msg.ToAddress = "john@doe.com\r\nBcc: x@y.z"
+		_, err = msg.MIME()
+		assert.EqualError(t, err, "header To contains line break")
+	})
+
+	t.Run("hosted template", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.TemplateID = "welcome"
+		_, err := msg.MIME()
+		assert.EqualError(t, err, "provider template \"welcome\" has no MIME form")
+	})
+}
+
+func TestStatusError(t *testing.T) {
+	for code, temporary := range map[int]bool{
+		http.StatusBadRequest:          false,
+		http.StatusUnauthorized:        false,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusServiceUnavailable:  true,
+	} {
+		err := &StatusError{Provider: "ses", Code: code, Body: "nope"}
+		assert.Equal(t, temporary, err.Temporary(), code)
+	}
+
+	assert.EqualError(t, &StatusError{Provider: "ses", Code: 400, Body: "nope"}, "ses: status 400: nope")
+}
+
+func TestNew(t *testing.T) {
+	sender, err := New(config.Sender{Provider: config.ProviderSES, Region: "eu-west-1"}, "AKID", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://email.eu-west-1.amazonaws.com", sender.(*SES).Endpoint)
+	assert.Equal(t, "AKID", sender.(*SES).KeyID)
+
+	sender, err = New(config.Sender{Provider: config.ProviderSendGrid}, "", "SG.key")
+	assert.NoError(t, err)
+	assert.Equal(t, "SG.key", sender.(*SendGrid).APIKey)
+
+	sender, err = New(config.Sender{Provider: config.ProviderMailgun, Region: config.MailgunEU, Domain: "mg.example.com"}, "", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.eu.mailgun.net", sender.(*Mailgun).Endpoint)
+
+	_, err = New(config.Sender{Host: "smtp.example.com", Port: "25"}, "", "")
+	assert.EqualError(t, err, "provider \"\" has no API")
+}
@@ -0,0 +1,113 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// Mailgun sends messages through Messages API of Mailgun.
+type Mailgun struct {
+	Domain string
+	APIKey string
+	// Endpoint defaults to API of region.
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewMailgun creates Mailgun sender of domain in region, either config.MailgunUS,
+// when empty, or config.MailgunEU, authenticated with API key.
+func NewMailgun(region, domain, apiKey string) *Mailgun {
+	endpoint := "https://api.mailgun.net"
+	if region == config.MailgunEU {
+		endpoint = "https://api.eu.mailgun.net"
+	}
+
+	return &Mailgun{Domain: domain, APIKey: apiKey, Endpoint: endpoint, Client: http.DefaultClient}
+}
+
+// Send sends msg as MIME message, or hosted template with its data.
+func (m *Mailgun) Send(ctx context.Context, msg Message) error {
+	recipients, err := msg.recipients()
+	if err != nil {
+		return err
+	}
+
+	buff := new(bytes.Buffer)
+	form := multipart.NewWriter(buff)
+
+	// MIME messages go to every envelope recipient, hosted templates are addressed by fields.
+	path := "/messages.mime"
+	fields := [][2]string{{"to", strings.Join(recipients, ",")}}
+
+	if msg.TemplateID != "" {
+		data, err := json.Marshal(msg.Data)
+		if err != nil {
+			return fmt.Errorf("mailgun: template data: %s", err.Error())
+		}
+
+		path = "/messages"
+		fields = [][2]string{
+			{"to", msg.ToAddress},
+			{"from", msg.from()},
+			{"template", msg.TemplateID},
+			{"h:X-Mailgun-Variables", string(data)},
+		}
+		if len(msg.CC) != 0 {
+			fields = append(fields, [2]string{"cc", strings.Join(msg.CC, ",")})
+		}
+		if msg.Subject != "" {
+			fields = append(fields, [2]string{"subject", msg.Subject})
+		}
+
+		names := make([]string, 0, len(msg.Headers))
+		for name := range msg.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fields = append(fields, [2]string{"h:" + name, msg.Headers[name]})
+		}
+	}
+
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	if msg.TemplateID == "" {
+		raw, err := msg.MIME()
+		if err != nil {
+			return err
+		}
+
+		part, err := form.CreateFormFile("message", "message.mime")
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(raw); err != nil {
+			return err
+		}
+	}
+
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/v3/%s%s", m.Endpoint, m.Domain, path), buff)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetBasicAuth("api", m.APIKey)
+
+	return do(m.Client, "mailgun", req.WithContext(ctx))
+}
@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailgun_Send(t *testing.T) {
+	var req *http.Request
+	var message string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+
+		message = ""
+		if file, _, err := r.FormFile("message"); err == nil {
+			raw, _ := ioutil.ReadAll(file)
+			message = string(raw)
+		}
+	}))
+	defer server.Close()
+
+	mailgun := NewMailgun("", "mg.example.com", "key")
+	assert.Equal(t, "https://api.mailgun.net", mailgun.Endpoint)
+	mailgun.Endpoint = server.URL
+
+	t.Run("MIME message", func(t *testing.T) {
+		assert.NoError(t, mailgun.Send(context.Background(), sampleMessage()))
+
+		assert.Equal(t, "/v3/mg.example.com/messages.mime", req.URL.Path)
+		username, password, _ := req.BasicAuth()
+		assert.Equal(t, "api", username)
+		assert.Equal(t, "key", password)
+		assert.Equal(t, "john@doe.com,jane@doe.com", req.FormValue("to"))
+
+		raw, _ := sampleMessage().MIME()
+		assert.Equal(t, string(raw), message)
+	})
+
+	t.Run("hosted template", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.TemplateID = "welcome"
+		msg.Data = map[string]interface{}{"name": "John"}
+
+		assert.NoError(t, mailgun.Send(context.Background(), msg))
+
+		assert.Equal(t, "/v3/mg.example.com/messages", req.URL.Path)
+		assert.Equal(t, "john@doe.com", req.FormValue("to"))
+		assert.Equal(t, "jane@doe.com", req.FormValue("cc"))
+		assert.Equal(t, "\"Example\" <noreply@example.com>", req.FormValue("from"))
+		assert.Equal(t, "welcome", req.FormValue("template"))
+		assert.Equal(t, `{"name":"John"}`, req.FormValue("h:X-Mailgun-Variables"))
+		assert.Equal(t, "Welcome", req.FormValue("subject"))
+		assert.Equal(t, "42", req.FormValue("h:X-Correlation-Id"))
+		assert.Empty(t, message)
+	})
+
+	t.Run("empty recipient", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.CC = []string{" "}
+		assert.EqualError(t, mailgun.Send(context.Background(), msg), "recipient address is empty")
+	})
+}
@@ -0,0 +1,149 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// SendGrid sends messages through v3 Mail Send API of SendGrid.
+type SendGrid struct {
+	APIKey string
+	// Endpoint defaults to SendGrid API.
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewSendGrid creates SendGrid sender authenticated with API key.
+func NewSendGrid(apiKey string) *SendGrid {
+	return &SendGrid{APIKey: apiKey, Endpoint: "https://api.sendgrid.com", Client: http.DefaultClient}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To   []sendGridAddress      `json:"to"`
+	CC   []sendGridAddress      `json:"cc,omitempty"`
+	Data map[string]interface{} `json:"dynamic_template_data,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	// Base64 encoded.
+	Content  string `json:"content"`
+	Type     string `json:"type,omitempty"`
+	Filename string `json:"filename"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	Content          []sendGridContent         `json:"content,omitempty"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	TemplateID       string                    `json:"template_id,omitempty"`
+}
+
+// Send sends parts of msg body, since SendGrid doesn't accept MIME messages,
+// or hosted template with its data.
+func (s *SendGrid) Send(ctx context.Context, msg Message) error {
+	personalization := sendGridPersonalization{To: []sendGridAddress{{Email: msg.ToAddress}}}
+	for _, address := range msg.CC {
+		personalization.CC = append(personalization.CC, sendGridAddress{Email: address})
+	}
+
+	payload := sendGridRequest{
+		From:    sendGridAddress{Email: msg.FromAddress, Name: msg.FromName},
+		Subject: msg.Subject,
+		Headers: msg.Headers,
+	}
+
+	if msg.TemplateID != "" {
+		payload.TemplateID = msg.TemplateID
+		personalization.Data = msg.Data
+	} else if err := payload.addParts(msg.ContentType, msg.Body); err != nil {
+		return err
+	}
+	payload.Personalizations = []sendGridPersonalization{personalization}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.Endpoint+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+
+	return do(s.Client, "sendgrid", req.WithContext(ctx))
+}
+
+// addParts adds body of contentType as contents, descending into multipart
+// bodies, whose parts with filename are attachments.
+func (r *sendGridRequest) addParts(contentType string, body []byte) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return errors.New("sendgrid: content type: " + err.Error())
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		r.Content = append(r.Content, sendGridContent{Type: mediaType, Value: string(body)})
+		return nil
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return errors.New("sendgrid: multipart body: " + err.Error())
+		}
+
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return errors.New("sendgrid: multipart body: " + err.Error())
+		}
+
+		partType := part.Header.Get("Content-Type")
+		if part.Header.Get("Content-Transfer-Encoding") == "base64" {
+			if data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), "")); err != nil {
+				return errors.New("sendgrid: base64 part: " + err.Error())
+			}
+		}
+
+		if filename := part.FileName(); filename != "" {
+			mediaType, _, _ := mime.ParseMediaType(partType)
+			r.Attachments = append(r.Attachments, sendGridAttachment{
+				Content:  base64.StdEncoding.EncodeToString(data),
+				Type:     mediaType,
+				Filename: filename,
+			})
+			continue
+		}
+
+		if err := r.addParts(partType, data); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendGrid_Send(t *testing.T) {
+	var req *http.Request
+	var payload map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ := ioutil.ReadAll(r.Body)
+		payload = nil
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sendGrid := NewSendGrid("SG.key")
+	sendGrid.Endpoint = server.URL
+
+	t.Run("single part", func(t *testing.T) {
+		assert.NoError(t, sendGrid.Send(context.Background(), sampleMessage()))
+
+		assert.Equal(t, "/v3/mail/send", req.URL.Path)
+		assert.Equal(t, "Bearer SG.key", req.Header.Get("Authorization"))
+		assert.Equal(t, []interface{}{map[string]interface{}{
+			"to": []interface{}{map[string]interface{}{"email": "john@doe.com"}},
+			"cc": []interface{}{map[string]interface{}{"email": "jane@doe.com"}},
+		}}, payload["personalizations"])
+		assert.Equal(t, map[string]interface{}{"email": "noreply@example.com", "name": "Example"}, payload["from"])
+		assert.Equal(t, "Welcome", payload["subject"])
+		assert.Equal(t, map[string]interface{}{"X-Correlation-Id": "42"}, payload["headers"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"type": "text/html", "value": "<p>Hi</p>"}}, payload["content"])
+	})
+
+	t.Run("multipart with attachment", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.ContentType = "multipart/mixed; boundary=outer"
+		msg.Body = []byte("--outer\r\n" +
+			"Content-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+			"--inner\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nHi\r\n" +
+			"--inner\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<p>Hi</p>\r\n" +
+			"--inner--\r\n" +
+			"\r\n--outer\r\n" +
+			"Content-Type: text/calendar\r\n" +
+			"Content-Disposition: attachment; filename=invite.ics\r\n" +
+			"Content-Transfer-Encoding: base64\r\n\r\n" +
+			"QkVHSU46VkNB\r\nTEVOREFS\r\n" +
+			"--outer--\r\n")
+
+		assert.NoError(t, sendGrid.Send(context.Background(), msg))
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"type": "text/plain", "value": "Hi"},
+			map[string]interface{}{"type": "text/html", "value": "<p>Hi</p>"},
+		}, payload["content"])
+		assert.Equal(t, []interface{}{map[string]interface{}{
+			"content":  "QkVHSU46VkNBTEVOREFS",
+			"type":     "text/calendar",
+			"filename": "invite.ics",
+		}}, payload["attachments"])
+	})
+
+	t.Run("hosted template", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.TemplateID = "d-123"
+		msg.Data = map[string]interface{}{"name": "John"}
+
+		assert.NoError(t, sendGrid.Send(context.Background(), msg))
+		assert.Equal(t, "d-123", payload["template_id"])
+		assert.Nil(t, payload["content"])
+		assert.Equal(t, map[string]interface{}{"name": "John"},
+			payload["personalizations"].([]interface{})[0].(map[string]interface{})["dynamic_template_data"])
+	})
+
+	t.Run("broken content type", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.ContentType = ""
+		assert.EqualError(t, sendGrid.Send(context.Background(), msg), "sendgrid: content type: mime: no media type")
+	})
+}
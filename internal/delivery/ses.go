@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SES sends messages through SendEmail of Amazon SES API v2.
+type SES struct {
+	Region    string
+	KeyID     string
+	SecretKey string
+	// Endpoint defaults to regional endpoint of SES.
+	Endpoint string
+	Client   *http.Client
+
+	now func() time.Time
+}
+
+// NewSES creates SES sender of region authenticated with access key.
+func NewSES(region, keyID, secretKey string) *SES {
+	return &SES{
+		Region:    region,
+		KeyID:     keyID,
+		SecretKey: secretKey,
+		Endpoint:  fmt.Sprintf("https://email.%s.amazonaws.com", region),
+		Client:    http.DefaultClient,
+		now:       time.Now,
+	}
+}
+
+type sesContent struct {
+	Raw      *sesRaw      `json:"Raw,omitempty"`
+	Template *sesTemplate `json:"Template,omitempty"`
+}
+
+type sesRaw struct {
+	// Marshaled as base64.
+	Data []byte `json:"Data"`
+}
+
+type sesTemplate struct {
+	TemplateName string `json:"TemplateName"`
+	TemplateData string `json:"TemplateData"`
+}
+
+type sesRequest struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+		CcAddresses []string `json:"CcAddresses,omitempty"`
+	} `json:"Destination"`
+	Content sesContent `json:"Content"`
+}
+
+// Send sends msg as raw MIME message, or hosted template with its data.
+func (s *SES) Send(ctx context.Context, msg Message) error {
+	payload := sesRequest{FromEmailAddress: msg.from()}
+	payload.Destination.ToAddresses = []string{msg.ToAddress}
+	payload.Destination.CcAddresses = msg.CC
+
+	if msg.TemplateID != "" {
+		data, err := json.Marshal(msg.Data)
+		if err != nil {
+			return fmt.Errorf("ses: template data: %s", err.Error())
+		}
+		payload.Content.Template = &sesTemplate{TemplateName: msg.TemplateID, TemplateData: string(data)}
+	} else {
+		raw, err := msg.MIME()
+		if err != nil {
+			return err
+		}
+		payload.Content.Raw = &sesRaw{Data: raw}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.Endpoint+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signV4(req, body, "ses", s.Region, s.KeyID, s.SecretKey, s.now())
+
+	return do(s.Client, "ses", req.WithContext(ctx))
+}
@@ -0,0 +1,70 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSES_Send(t *testing.T) {
+	var req *http.Request
+	var payload map[string]interface{}
+	status := http.StatusOK
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ := ioutil.ReadAll(r.Body)
+		payload = nil
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":"Throttling"}`))
+	}))
+	defer server.Close()
+
+	ses := NewSES("eu-west-1", "AKID", "secret")
+	ses.Endpoint = server.URL
+	ses.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	t.Run("raw message", func(t *testing.T) {
+		assert.NoError(t, ses.Send(context.Background(), sampleMessage()))
+
+		assert.Equal(t, "/v2/email/outbound-emails", req.URL.Path)
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKID/20260102/eu-west-1/ses/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature="))
+		assert.Equal(t, "\"Example\" <noreply@example.com>", payload["FromEmailAddress"])
+		assert.Equal(t, map[string]interface{}{
+			"ToAddresses": []interface{}{"john@doe.com"},
+			"CcAddresses": []interface{}{"jane@doe.com"},
+		}, payload["Destination"])
+
+		raw, _ := sampleMessage().MIME()
+		data, _ := json.Marshal(raw)
+		assert.Equal(t, map[string]interface{}{"Raw": map[string]interface{}{"Data": strings.Trim(string(data), "\"")}}, payload["Content"])
+	})
+
+	t.Run("hosted template", func(t *testing.T) {
+		msg := sampleMessage()
+		msg.TemplateID = "welcome"
+		msg.Data = map[string]interface{}{"name": "John"}
+
+		assert.NoError(t, ses.Send(context.Background(), msg))
+		assert.Equal(t, map[string]interface{}{"Template": map[string]interface{}{
+			"TemplateName": "welcome",
+			"TemplateData": `{"name":"John"}`,
+		}}, payload["Content"])
+	})
+
+	t.Run("unsuccessful status", func(t *testing.T) {
+		status = http.StatusTooManyRequests
+		err := ses.Send(context.Background(), sampleMessage())
+		assert.EqualError(t, err, `ses: status 429: {"message":"Throttling"}`)
+		assert.True(t, err.(*StatusError).Temporary())
+	})
+}
@@ -1,10 +1,14 @@
 package amqp
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/openware/postmaster/pkg/eventapi"
 	"github.com/streadway/amqp"
@@ -21,6 +25,36 @@ type ServeMux struct {
 	addr     string
 	mu       sync.RWMutex
 	m        map[string]muxEntry
+
+	listeners []listener
+	workers   sync.WaitGroup
+	stopping  chan struct{}
+	done      chan struct{}
+
+	deadLetterExchange string
+	deadLetterer       *DeadLetterer
+
+	retryExchange string
+	retrier       *DeadLetterer
+	retries       map[string]Retry
+
+	concurrency    int
+	prefetch       map[string]int
+	autoAck        bool
+	payloadDecoder eventapi.PayloadDecoder
+
+	dial        func(addr string) (*amqp.Connection, error)
+	dialBackoff Backoff
+	sleep       func(time.Duration)
+
+	metrics         *Metrics
+	metricsQueue    string
+	metricsInterval time.Duration
+
+	limit limit
+
+	// connected is 1 while broker connection is open, see Connected.
+	connected int32
 }
 
 func NewServeMux(addr, tag, exchange string) *ServeMux {
@@ -28,6 +62,20 @@ func NewServeMux(addr, tag, exchange string) *ServeMux {
 		addr:     addr,
 		tag:      tag,
 		exchange: exchange,
+
+		concurrency:    1,
+		payloadDecoder: json.Unmarshal,
+
+		dial: amqp.Dial,
+		dialBackoff: Backoff{
+			Initial: time.Second,
+			Max:     30 * time.Second,
+			Jitter:  rand.NewSource(time.Now().UnixNano()),
+		},
+		sleep: time.Sleep,
+
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 }
 
@@ -59,6 +107,13 @@ func (mux *ServeMux) declareQueue(channel *amqp.Channel, routingKey string) (*am
 		return nil, err
 	}
 
+	// Retried messages return through retry exchange.
+	if mux.retryExchange != "" {
+		if err := channel.QueueBind(queue.Name, routingKey, mux.retryExchange, false, nil); err != nil {
+			return nil, err
+		}
+	}
+
 	return &queue, nil
 }
 
@@ -80,56 +135,301 @@ func (mux *ServeMux) declareListener(chann *amqp.Channel, queue amqp.Queue, hand
 	deliveries, err := chann.Consume(
 		queue.Name,
 		mux.tag,
-		true,
+		mux.autoAck,
 		true,
 		false,
 		false,
 		nil,
 	)
 
-	go func() {
+	if err != nil {
+		log.Panicf("consuming: %s", err.Error())
+	}
 
-		if err != nil {
-			log.Panicf("consuming: %s", err.Error())
-		}
+	mux.listen(listener{channel: chann, deliveries: deliveries, handler: handler})
+}
+
+// listen starts workers processing deliveries of l.
+func (mux *ServeMux) listen(l listener) {
+	mux.mu.Lock()
+	mux.listeners = append(mux.listeners, l)
+	mux.mu.Unlock()
+
+	for i := 0; i < mux.concurrency; i++ {
+		mux.workers.Add(1)
+		go mux.work(l.deliveries, l.handler)
+	}
+}
 
-		for delivery := range deliveries {
-			jwtReader, err := eventapi.DeliveryAsJWT(delivery)
+// work processes deliveries until they are over or shutdown begins.
+func (mux *ServeMux) work(deliveries <-chan amqp.Delivery, handler Handler) {
+	defer mux.workers.Done()
 
-			if err != nil {
-				log.Println(err)
+	for {
+		select {
+		case <-mux.stopping:
+			return
+		case delivery, ok := <-deliveries:
+			if !ok {
 				return
 			}
 
-			jwt, err := ioutil.ReadAll(jwtReader)
-			if err != nil {
-				log.Println(err)
-				return
+			if mux.metrics != nil {
+				mux.metrics.observe(delivery.Timestamp)
 			}
+			mux.serveLimited(delivery, handler)
+		}
+	}
+}
 
-			log.Printf("Token: %s\n", string(jwt))
+// serve verifies Event API message and passes it to handler.
+func (mux *ServeMux) serve(delivery amqp.Delivery, handler Handler) error {
+	jwtReader, err := eventapi.DecodeDeliveryAsJWT(delivery, mux.payloadDecoder)
+	if err != nil {
+		return err
+	}
 
-			claims, err := eventapi.ParseJWT(string(jwt), eventapi.ValidateJWT)
-			if err != nil {
-				log.Println(err)
-				return
+	jwt, err := ioutil.ReadAll(jwtReader)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Token: %s\n", string(jwt))
+
+	claims, err := eventapi.ParseJWT(string(jwt), eventapi.ValidateJWT)
+	if err != nil {
+		return err
+	}
+
+	return handler.ServeAMQP(Message{
+		Event:         claims.Event,
+		Headers:       delivery.Headers,
+		CorrelationID: delivery.CorrelationId,
+		Priority:      delivery.Priority,
+	})
+}
+
+// settle acknowledges delivery once it's either processed or dead-lettered.
+// Message is requeued, when dead-lettering fails, so it's never lost silently,
+// or when handler asked for it with Requeue. Handler can pick other outcome
+// of message with Settle.
+func (mux *ServeMux) settle(delivery amqp.Delivery, err error) {
+	if h, ok := err.(*Hold); ok {
+		mux.hold(delivery, h)
+		return
+	}
+
+	if mux.autoAck {
+		// Broker considers delivery acknowledged once it's sent.
+		if err != nil {
+			log.Println(err)
+			if mux.deadLetterer != nil && deadLettered(err) {
+				mux.deadLetterer.Publish(delivery, err)
 			}
+		}
+		return
+	}
+
+	mux.settleManual(delivery, err)
+}
 
-			handler.ServeAMQP(claims.Event)
+// settleManual is settle of delivery, which broker waits acknowledgement of.
+func (mux *ServeMux) settleManual(delivery amqp.Delivery, err error) {
+	if err != nil {
+		log.Println(err)
+	}
+
+	switch OutcomeOf(err) {
+	case OutcomeAck:
+		// Copy is dead-lettered at most once, since message was processed.
+		if mux.deadLetterer != nil && deadLettered(err) {
+			mux.deadLetterer.Publish(delivery, err)
 		}
-	}()
+		ack(delivery)
+	case OutcomeRequeue:
+		if mux.retry(delivery, err) {
+			return
+		}
+		mux.wait(requeueDelay(err))
+		nack(delivery, true)
+	case OutcomeDrop:
+		nack(delivery, false)
+	default:
+		if mux.deadLetterer == nil {
+			nack(delivery, false)
+			return
+		}
+
+		// Errors are logged by dead-letterer.
+		if mux.deadLetterer.Publish(delivery, err) != nil {
+			nack(delivery, true)
+			return
+		}
+
+		ack(delivery)
+	}
+}
+
+// deadLettered tells, whether message settled with err is dead-lettered.
+// Acknowledged messages are, when err adds headers to their copy.
+func deadLettered(err error) bool {
+	switch OutcomeOf(err) {
+	case OutcomeDrop:
+		return false
+	case OutcomeAck:
+		return HeadersOf(err) != nil
+	default:
+		return err != nil
+	}
+}
+
+func ack(delivery amqp.Delivery) {
+	if err := delivery.Ack(false); err != nil {
+		log.Printf("ack: %s", err.Error())
+	}
+}
+
+func nack(delivery amqp.Delivery, requeue bool) {
+	if err := delivery.Nack(false, requeue); err != nil {
+		log.Printf("nack: %s", err.Error())
+	}
+}
+
+func (mux *ServeMux) declareDeadLetter(conn *amqp.Connection) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel %s", err.Error())
+	}
+
+	err = channel.ExchangeDeclare(mux.deadLetterExchange, "fanout", true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("dead-letter exchange %s", err.Error())
+	}
+
+	queue, err := channel.QueueDeclare(DeadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("dead-letter queue %s", err.Error())
+	}
+
+	if err := channel.QueueBind(queue.Name, "", mux.deadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("dead-letter queue %s", err.Error())
+	}
+
+	mux.deadLetterer, err = NewDeadLetterer(channel, mux.deadLetterExchange)
+	return err
+}
+
+// SetDeadLetterExchange makes messages, which failed processing,
+// published to exchange instead of being dropped.
+func (mux *ServeMux) SetDeadLetterExchange(exchange string) {
+	mux.deadLetterExchange = exchange
+}
+
+// SetConcurrency sets number of messages processed in parallel per routing key.
+func (mux *ServeMux) SetConcurrency(n int) {
+	if n < 1 {
+		panic("amqp: invalid concurrency")
+	}
+	mux.concurrency = n
+}
+
+// SetPrefetch sets number of unacknowledged messages of routing key broker
+// delivers at once, concurrency by default. Messages held by handler count
+// against it, see Hold, so it should exceed concurrency for them.
+func (mux *ServeMux) SetPrefetch(routingKey string, n int) {
+	if n < 1 {
+		panic("amqp: invalid prefetch")
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.prefetch == nil {
+		mux.prefetch = make(map[string]int)
+	}
+	mux.prefetch[routingKey] = n
+}
+
+// SetPayloadDecoder sets decoder of delivery body, JSON by default.
+func (mux *ServeMux) SetPayloadDecoder(decoder eventapi.PayloadDecoder) {
+	mux.payloadDecoder = decoder
+}
+
+// SetAutoAck makes deliveries acknowledged by broker once they are sent
+// instead of after processing. Messages of failed and crashed processing may be lost.
+func (mux *ServeMux) SetAutoAck(autoAck bool) {
+	mux.autoAck = autoAck
+}
+
+// SetMetrics makes gauges sampled every interval.
+// Depth of queue is sampled, unless it's empty.
+func (mux *ServeMux) SetMetrics(metrics *Metrics, queue string, interval time.Duration) {
+	mux.metrics = metrics
+	mux.metricsQueue = queue
+	mux.metricsInterval = interval
+}
+
+// dialAttempts bounds connection attempts to broker.
+const dialAttempts = 10
+
+// connect dials broker, retrying with jittered backoff, so replicas
+// restarted along with broker don't reconnect in lockstep.
+func (mux *ServeMux) connect() (*amqp.Connection, error) {
+	var err error
+	for attempt := 0; attempt < dialAttempts; attempt++ {
+		if attempt > 0 {
+			delay := mux.dialBackoff.Delay(attempt - 1)
+			log.Printf("Dial attempt %d failed: %s, retrying in %s", attempt, err.Error(), delay)
+			mux.sleep(delay)
+		}
+
+		var conn *amqp.Connection
+		if conn, err = mux.dial(mux.addr); err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
 }
 
 func (mux *ServeMux) ListenAndServe() error {
 	// Create listeners for each mux entry.
 
-	conn, err := amqp.Dial(mux.addr)
+	conn, err := mux.connect()
 	if err != nil {
 		log.Panicf("Dial %s", err.Error())
 	} else {
 		log.Printf("Successfully connected to %s\n", mux.addr)
 	}
 
+	atomic.StoreInt32(&mux.connected, 1)
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		<-closed
+		atomic.StoreInt32(&mux.connected, 0)
+	}()
+
+	if mux.deadLetterExchange != "" {
+		if err := mux.declareDeadLetter(conn); err != nil {
+			return err
+		}
+	}
+
+	if mux.retryExchange != "" {
+		if err := mux.declareRetry(conn); err != nil {
+			return err
+		}
+	}
+
+	if mux.metrics != nil {
+		channel, err := conn.Channel()
+		if err != nil {
+			return fmt.Errorf("metrics channel %s", err.Error())
+		}
+
+		go mux.metrics.run(channel, mux.metricsQueue, mux.metricsInterval)
+	}
+
 	// Each event will have own: channel, queue, consumer.
 	for k, v := range mux.m {
 		channel, err := conn.Channel()
@@ -138,7 +438,16 @@ func (mux *ServeMux) ListenAndServe() error {
 			return fmt.Errorf("channel %s", err.Error())
 		}
 
-		if err != mux.declareExchange(channel) {
+		prefetch := mux.concurrency
+		if n, ok := mux.prefetch[k]; ok {
+			prefetch = n
+		}
+
+		if err := channel.Qos(prefetch, 0, false); err != nil {
+			return fmt.Errorf("qos %s", err.Error())
+		}
+
+		if err := mux.declareExchange(channel); err != nil {
 			return fmt.Errorf("exchange %s", err.Error())
 		}
 
@@ -153,13 +462,17 @@ func (mux *ServeMux) ListenAndServe() error {
 
 	// @Ali: We can recover panics here.
 
-	forever := make(chan bool)
 	fmt.Printf("Waiting for events. To exit press CTRL+C")
-	<-forever
+	<-mux.done
 
 	return nil
 }
 
+// Connected reports whether connection to broker is open.
+func (mux *ServeMux) Connected() bool {
+	return atomic.LoadInt32(&mux.connected) == 1
+}
+
 func (mux *ServeMux) Handle(routingKey string, handler Handler) {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
@@ -180,7 +493,7 @@ func (mux *ServeMux) Handle(routingKey string, handler Handler) {
 	mux.m[routingKey] = muxEntry{h: handler, routingKey: routingKey}
 }
 
-func (mux *ServeMux) HandleFunc(routingKey string, handler func(event eventapi.Event)) {
+func (mux *ServeMux) HandleFunc(routingKey string, handler func(msg Message) error) {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 
@@ -201,13 +514,26 @@ func (mux *ServeMux) HandleFunc(routingKey string, handler func(event eventapi.E
 	mux.m[routingKey] = muxEntry{h: HandlerFunc(handler), routingKey: routingKey}
 }
 
+// Message is a verified Event API event together with the headers
+// of the AMQP delivery it arrived in.
+type Message struct {
+	Event   eventapi.Event
+	Headers amqp.Table
+	// CorrelationID is correlation_id property of the delivery, if set.
+	CorrelationID string
+	// Priority is priority property of the delivery, zero when unset.
+	Priority uint8
+}
+
+// Handler processes a message. Returned error makes message dead-lettered,
+// unless it's settled otherwise, see Settle.
 type Handler interface {
-	ServeAMQP(event eventapi.Event)
+	ServeAMQP(msg Message) error
 }
 
-type HandlerFunc func(eventapi.Event)
+type HandlerFunc func(Message) error
 
-// ServeHTTP calls f(event).
-func (f HandlerFunc) ServeAMQP(event eventapi.Event) {
-	f(event)
+// ServeHTTP calls f(msg).
+func (f HandlerFunc) ServeAMQP(msg Message) error {
+	return f(msg)
 }
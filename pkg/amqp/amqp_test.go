@@ -0,0 +1,137 @@
+package amqp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/pkg/eventapi"
+	"github.com/shamaton/msgpack"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleToken is signed with test/sample.key.
+const sampleToken = "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.eyJpc3MiOiJiYXJvbmciLCJqdGkiOiIwMDAwMDAwMC0wMDAwLTAwMDAtMDAwMC0wMDAwMDAwMDAwMCIsImlhdCI6MTU0NzgwMzE5MCwiZXZlbnQiOnsidXNlciI6eyJ1aWQiOiJJRDAxMjM0NTY3ODkiLCJlbWFpbCI6ImpvaG5AZG9lLmNvbSIsInJvbGUiOiJtZW1iZXIiLCJsZXZlbCI6MCwib3RwIjpmYWxzZSwic3RhdGUiOiJwZW5kaW5nIiwiY3JlYXRlZF9hdCI6IjIwMTktMDEtMThUMDk6MTk6NTBaIiwidXBkYXRlZF9hdCI6IjIwMTktMDEtMThUMDk6MTk6NTBaIn0sInRva2VuIjoiZXlKaGJHY2lPaUpTVXpJMU5pSjkuZXlKcFlYUWlPakUxTkRjNE1ETXhPVEFzSW1WNGNDSTZNVFUwTnpnd09URTVNQ3dpYzNWaUlqb2lZMjl1Wm1seWJXRjBhVzl1SWl3aWFYTnpJam9pWW1GeWIyNW5JaXdpWVhWa0lqcGJJbkJsWVhScGJ5SXNJbUpoY205dVp5SmRMQ0pxZEdraU9pSmxZelUzWkdVME1EUXlNREF6TVdSak5XUTJNQ0lzSW1WdFlXbHNJam9pWVdSdGFXNHlRR0poY205dVp5NXBieUlzSW5WcFpDSTZJa2xFTWpnMk5UZEJPVVF5T1NKOS5XUU1aS0FyR2NlMlZGb19zMW1WeHJQajdNREhhSGhSYmtzNk9IV2xTMThEcTBnZVl2UHMybmZKT3JuTnBxMk1SekpJQ0U1SE9KNHlnenIwYkFPSURCcWZ5X1ZRTEl2WFc3aENiWmdDQ1NFcjFSaVpaTGQ2R2hBY1EtbHM1RzY3WEY2S3RKNUlpOVY3djFZckIxVnpnRGhtZ1RRdUZLbmxuMGMtMWhhUlRzTVU3ZW9NUVhYT1ppdXNWM28xZ0ViemJiMUlFNTF6ZlNzb0wxMFZrXzJRNjAxZmdEc3d2V1ZQcE5CVFFVT2pQRkFZSEZWaGxGaENJOHkyV09jY3NJUGw5aWdnSEFVQ3E5ZGlZLTVLTjlTeW9WSFUyMUtSZWZ1QV8yRzJiTzBpV3lQZG9vaDBTVDI0Y2s4RXNFRG5NT2J3M0xjdlBvNFQ3LU85UFM4bkM2N09JeGciLCJuYW1lIjoic3lzdGVtLnVzZXIuZW1haWwuY29uZmlybWF0aW9uLnRva2VuIn0sImFsZyI6IlJTMjU2In0.kWPCnUdQQLNxdzRTX-NLy-kJY8qk5XpT65H2gjXv0Q4P-Q8mxkUdL3-Bdy0yy13C1bSnQS-yPnRG4jX_-G0Xdj1eKhtSk7sVf44K5ggcopCsWZ4b1eIoAWTtXpABHQo2Po5zUGjOClzljWv3uJhMKXhK4veSfPqFwSfE9IFPyvJ3FLnCVHgfD_rm5pikgqR-ya--zk6V1RjCe0442xKH5Sx-XBOlulMMr-CQ04k09Vawy-W80y2WsNugjMdDr0ZHjCnOjLcm0Hayy9kSql9UTln7o8wcSEVMDum-EIBadohM-9q_f2gXVRNuOg2gR7sbA9mGwsut-sBcR8IBmyPzuQ"
+
+func sampleDelivery() eventapi.Delivery {
+	parts := strings.Split(sampleToken, ".")
+	return eventapi.Delivery{
+		Payload:    parts[1],
+		Signatures: []eventapi.DeliverySignature{{Protected: parts[0], Signature: parts[2]}},
+	}
+}
+
+// sampleKey makes sampleToken verifiable until returned func is called.
+func sampleKey(t *testing.T) func() {
+	pub, err := ioutil.ReadFile("../../test/sample.key.pub")
+	assert.NoError(t, err)
+	os.Setenv("JWT_PUBLIC_KEY", base64.StdEncoding.EncodeToString(pub))
+
+	return func() { os.Unsetenv("JWT_PUBLIC_KEY") }
+}
+
+func TestServeMux_serve(t *testing.T) {
+	defer sampleKey(t)()
+
+	var received []Message
+	handler := HandlerFunc(func(msg Message) error {
+		received = append(received, msg)
+		return nil
+	})
+
+	jsonBody, err := json.Marshal(sampleDelivery())
+	assert.NoError(t, err)
+
+	msgpackBody, err := msgpack.Marshal(sampleDelivery())
+	assert.NoError(t, err)
+
+	t.Run("decodes json and msgpack payloads alike", func(t *testing.T) {
+		mux := NewServeMux("", "", "")
+		assert.NoError(t, mux.serve(amqp.Delivery{Body: jsonBody}, handler))
+
+		decoder, ok := eventapi.PayloadDecoderFor(eventapi.FormatMsgpack)
+		assert.True(t, ok)
+		mux.SetPayloadDecoder(decoder)
+		assert.NoError(t, mux.serve(amqp.Delivery{Body: msgpackBody}, handler))
+
+		assert.Len(t, received, 2)
+		assert.Equal(t, "system.user.email.confirmation.token", received[0].Event["name"])
+		assert.Equal(t, received[0], received[1])
+	})
+
+	t.Run("passes correlation id", func(t *testing.T) {
+		mux := NewServeMux("", "", "")
+		assert.NoError(t, mux.serve(amqp.Delivery{Body: jsonBody, CorrelationId: "c0ffee"}, handler))
+		assert.Equal(t, "c0ffee", received[len(received)-1].CorrelationID)
+	})
+
+	t.Run("passes priority", func(t *testing.T) {
+		mux := NewServeMux("", "", "")
+		assert.NoError(t, mux.serve(amqp.Delivery{Body: jsonBody, Priority: 5}, handler))
+		assert.Equal(t, uint8(5), received[len(received)-1].Priority)
+	})
+
+	t.Run("rejects payload of other format", func(t *testing.T) {
+		mux := NewServeMux("", "", "")
+		assert.Error(t, mux.serve(amqp.Delivery{Body: msgpackBody}, handler))
+	})
+}
+
+func TestServeMux_connect(t *testing.T) {
+	fakeMux := func(seed int64, failures int) (*ServeMux, *[]time.Duration) {
+		mux := NewServeMux("amqp://localhost", "", "")
+		mux.dialBackoff.Jitter = rand.NewSource(seed)
+		mux.dial = func(addr string) (*amqp.Connection, error) {
+			if failures > 0 {
+				failures--
+				return nil, errors.New("connection refused")
+			}
+			return &amqp.Connection{}, nil
+		}
+
+		var delays []time.Duration
+		mux.sleep = func(delay time.Duration) {
+			delays = append(delays, delay)
+		}
+
+		return mux, &delays
+	}
+
+	t.Run("retries with jittered backoff", func(t *testing.T) {
+		mux, delays := fakeMux(1, 3)
+
+		conn, err := mux.connect()
+		assert.NoError(t, err)
+		assert.NotNil(t, conn)
+
+		assert.Len(t, *delays, 3)
+		for attempt, delay := range *delays {
+			assert.True(t, delay <= Backoff{Initial: time.Second, Max: 30 * time.Second}.Delay(attempt))
+		}
+	})
+
+	t.Run("spreads replicas with different seeds", func(t *testing.T) {
+		first, firstDelays := fakeMux(1, 3)
+		second, secondDelays := fakeMux(2, 3)
+
+		first.connect()
+		second.connect()
+
+		assert.NotEqual(t, *firstDelays, *secondDelays)
+	})
+
+	t.Run("gives up after bounded attempts", func(t *testing.T) {
+		mux, delays := fakeMux(1, dialAttempts)
+
+		_, err := mux.connect()
+		assert.EqualError(t, err, "connection refused")
+		assert.Len(t, *delays, dialAttempts-1)
+	})
+}
@@ -0,0 +1,38 @@
+package amqp
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes exponentially growing delays between attempts.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+
+	// Jitter randomizes each delay within [0, delay], when set, so clients
+	// failed at once don't retry in lockstep. Source is not safe for concurrent use.
+	Jitter rand.Source
+}
+
+// Delay returns the delay before given attempt, starting from zero.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.ceiling(attempt)
+	if b.Jitter == nil {
+		return delay
+	}
+
+	return time.Duration(rand.New(b.Jitter).Int63n(int64(delay) + 1))
+}
+
+func (b Backoff) ceiling(attempt int) time.Duration {
+	delay := b.Initial
+	for i := 0; i < attempt; i++ {
+		delay *= 2
+		if b.Max > 0 && delay >= b.Max {
+			return b.Max
+		}
+	}
+
+	return delay
+}
@@ -0,0 +1,146 @@
+package amqp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// DeadLetterQueue receives messages published to the dead-letter exchange.
+const DeadLetterQueue = "postmaster.dead-letter"
+
+// confirmsBuffer leaves room for late confirms of timed out publishes,
+// since broker connection blocks until confirm is read.
+const confirmsBuffer = 16
+
+// publisher is the part of amqp.Channel used to publish with confirms.
+type publisher interface {
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// DeadLetterer publishes messages, which couldn't be processed,
+// to the dead-letter exchange. Publishes are retried until broker confirms them.
+type DeadLetterer struct {
+	exchange string
+	channel  publisher
+	confirms chan amqp.Confirmation
+	tag      uint64
+
+	mu             sync.Mutex
+	attempts       int
+	backoff        Backoff
+	confirmTimeout time.Duration
+	sleep          func(time.Duration)
+}
+
+// NewDeadLetterer puts channel into confirm mode and publishes to exchange over it.
+func NewDeadLetterer(channel publisher, exchange string) (*DeadLetterer, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("confirm mode: %s", err.Error())
+	}
+
+	return &DeadLetterer{
+		exchange: exchange,
+		channel:  channel,
+		confirms: channel.NotifyPublish(make(chan amqp.Confirmation, confirmsBuffer)),
+		attempts: 5,
+		backoff: Backoff{
+			Initial: 100 * time.Millisecond,
+			Max:     5 * time.Second,
+			Jitter:  rand.NewSource(time.Now().UnixNano()),
+		},
+		confirmTimeout: 5 * time.Second,
+		sleep:          time.Sleep,
+	}, nil
+}
+
+// Publish dead-letters delivery with failure reason in "x-postmaster-error" header,
+// adding headers of reason, see WithHeaders.
+func (d *DeadLetterer) Publish(delivery amqp.Delivery, reason error) error {
+	headers := amqp.Table{}
+	for k, v := range delivery.Headers {
+		headers[k] = v
+	}
+	for k, v := range HeadersOf(reason) {
+		headers[k] = v
+	}
+	headers["x-postmaster-error"] = reason.Error()
+
+	err := d.republish(delivery, headers)
+	if err != nil {
+		log.Printf("MESSAGE LOST: could not dead-letter message with routing key %s after %d attempts: %s",
+			delivery.RoutingKey, d.attempts, err.Error())
+	}
+
+	return err
+}
+
+// republish publishes copy of delivery with headers, retrying until broker confirms it.
+func (d *DeadLetterer) republish(delivery amqp.Delivery, headers amqp.Table) error {
+	msg := amqp.Publishing{
+		Headers:         headers,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        delivery.Priority,
+		CorrelationId:   delivery.CorrelationId,
+		MessageId:       delivery.MessageId,
+		Timestamp:       delivery.Timestamp,
+		Type:            delivery.Type,
+		Body:            delivery.Body,
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < d.attempts; attempt++ {
+		if attempt > 0 {
+			d.sleep(d.backoff.Delay(attempt - 1))
+		}
+
+		if err = d.publish(delivery.RoutingKey, msg); err == nil {
+			return nil
+		}
+
+		log.Printf("%s publish attempt %d failed: %s", d.exchange, attempt+1, err.Error())
+	}
+
+	return err
+}
+
+func (d *DeadLetterer) publish(key string, msg amqp.Publishing) error {
+	if err := d.channel.Publish(d.exchange, key, false, false, msg); err != nil {
+		return err
+	}
+
+	// Broker numbers publishings on the channel starting from one.
+	d.tag++
+	timeout := time.After(d.confirmTimeout)
+
+	for {
+		select {
+		case confirm, ok := <-d.confirms:
+			if !ok {
+				return errors.New("channel closed before confirm")
+			}
+			if confirm.DeliveryTag < d.tag {
+				// Late confirm of a publish, which already timed out.
+				continue
+			}
+			if !confirm.Ack {
+				return errors.New("publish nacked by broker")
+			}
+			return nil
+		case <-timeout:
+			return errors.New("publish confirm timed out")
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package amqp
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePublisher confirms publishings with given acks in order.
+type fakePublisher struct {
+	acks      []bool
+	confirms  chan amqp.Confirmation
+	published []amqp.Publishing
+	tag       uint64
+}
+
+func (p *fakePublisher) Confirm(noWait bool) error {
+	return nil
+}
+
+func (p *fakePublisher) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	p.confirms = confirm
+	return confirm
+}
+
+func (p *fakePublisher) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	p.published = append(p.published, msg)
+	p.tag++
+
+	ack := false
+	if len(p.acks) > 0 {
+		ack, p.acks = p.acks[0], p.acks[1:]
+	}
+
+	confirm := amqp.Confirmation{DeliveryTag: p.tag, Ack: ack}
+	go func() { p.confirms <- confirm }()
+
+	return nil
+}
+
+func fakeDeadLetterer(t *testing.T, acks ...bool) (*DeadLetterer, *fakePublisher, *[]time.Duration) {
+	channel := &fakePublisher{acks: acks}
+	d, err := NewDeadLetterer(channel, "postmaster.dlx")
+	assert.NoError(t, err)
+
+	// Delays are asserted exactly.
+	d.backoff.Jitter = nil
+
+	var delays []time.Duration
+	d.sleep = func(delay time.Duration) {
+		delays = append(delays, delay)
+	}
+
+	return d, channel, &delays
+}
+
+func TestDeadLetterer_Publish(t *testing.T) {
+	delivery := amqp.Delivery{
+		RoutingKey: "user.email.confirmation.token",
+		Headers:    amqp.Table{"X-Campaign-ID": "spring"},
+		Body:       []byte(`{"payload":"y"}`),
+	}
+
+	t.Run("retries nacked publish", func(t *testing.T) {
+		d, channel, delays := fakeDeadLetterer(t, false, true)
+
+		err := d.Publish(delivery, errors.New("smtp is down"))
+
+		assert.NoError(t, err)
+		assert.Len(t, channel.published, 2)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond}, *delays)
+
+		msg := channel.published[1]
+		assert.Equal(t, delivery.Body, msg.Body)
+		assert.Equal(t, "spring", msg.Headers["X-Campaign-ID"])
+		assert.Equal(t, "smtp is down", msg.Headers["x-postmaster-error"])
+	})
+
+	t.Run("gives up after bounded attempts", func(t *testing.T) {
+		d, channel, delays := fakeDeadLetterer(t)
+
+		err := d.Publish(delivery, errors.New("smtp is down"))
+
+		assert.EqualError(t, err, "publish nacked by broker")
+		assert.Len(t, channel.published, 5)
+		assert.Equal(t, []time.Duration{
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			800 * time.Millisecond,
+		}, *delays)
+	})
+
+	t.Run("ignores late confirms", func(t *testing.T) {
+		d, channel, _ := fakeDeadLetterer(t, true)
+		d.tag = 1
+		channel.tag = 1
+		d.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: false}
+
+		err := d.Publish(delivery, errors.New("smtp is down"))
+
+		assert.NoError(t, err)
+		assert.Len(t, channel.published, 1)
+	})
+}
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second}
+
+	assert.Equal(t, time.Second, b.Delay(0))
+	assert.Equal(t, 2*time.Second, b.Delay(1))
+	assert.Equal(t, 4*time.Second, b.Delay(2))
+	assert.Equal(t, 5*time.Second, b.Delay(3))
+	assert.Equal(t, 5*time.Second, b.Delay(30))
+}
+
+func TestBackoff_Delay_Jitter(t *testing.T) {
+	t.Run("stays within bounds", func(t *testing.T) {
+		b := Backoff{Initial: time.Second, Max: 5 * time.Second, Jitter: rand.NewSource(1)}
+		ceilings := Backoff{Initial: time.Second, Max: 5 * time.Second}
+
+		for attempt := 0; attempt < 100; attempt++ {
+			delay := b.Delay(attempt % 10)
+			assert.True(t, delay >= 0)
+			assert.True(t, delay <= ceilings.Delay(attempt%10))
+		}
+	})
+
+	t.Run("differs between seeds", func(t *testing.T) {
+		first := Backoff{Initial: time.Second, Max: 5 * time.Second, Jitter: rand.NewSource(1)}
+		second := Backoff{Initial: time.Second, Max: 5 * time.Second, Jitter: rand.NewSource(2)}
+
+		var firstDelays, secondDelays []time.Duration
+		for attempt := 0; attempt < 5; attempt++ {
+			firstDelays = append(firstDelays, first.Delay(attempt))
+			secondDelays = append(secondDelays, second.Delay(attempt))
+		}
+
+		assert.NotEqual(t, firstDelays, secondDelays)
+	})
+
+	t.Run("is deterministic for seed", func(t *testing.T) {
+		first := Backoff{Initial: time.Second, Jitter: rand.NewSource(7)}
+		second := Backoff{Initial: time.Second, Jitter: rand.NewSource(7)}
+
+		assert.Equal(t, first.Delay(3), second.Delay(3))
+	})
+}
+
+// fakeAcknowledger records the way delivery was settled.
+type fakeAcknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.nacked, a.requeue = true, requeue
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestServeMux_settle(t *testing.T) {
+	t.Run("acks processed message", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, nil)
+
+		assert.True(t, ack.acked)
+		assert.False(t, ack.nacked)
+	})
+
+	t.Run("drops failed message without dead-letter exchange", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, errors.New("failed"))
+
+		assert.True(t, ack.nacked)
+		assert.False(t, ack.requeue)
+	})
+
+	t.Run("acks failed message after dead-letter is confirmed", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		mux.deadLetterer, _, _ = fakeDeadLetterer(t, true)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, errors.New("failed"))
+
+		assert.True(t, ack.acked)
+		assert.False(t, ack.nacked)
+	})
+
+	t.Run("doesn't ack in auto mode", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		mux.SetAutoAck(true)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, nil)
+		mux.settle(amqp.Delivery{Acknowledger: ack}, errors.New("failed"))
+
+		assert.False(t, ack.acked)
+		assert.False(t, ack.nacked)
+	})
+
+	t.Run("dead-letters failed message in auto mode", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		mux.SetAutoAck(true)
+		var channel *fakePublisher
+		mux.deadLetterer, channel, _ = fakeDeadLetterer(t, true)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, errors.New("failed"))
+
+		assert.Len(t, channel.published, 1)
+		assert.False(t, ack.acked)
+		assert.False(t, ack.nacked)
+	})
+
+	t.Run("requeues message on temporary failure", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		var channel *fakePublisher
+		mux.deadLetterer, channel, _ = fakeDeadLetterer(t, true)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, Requeue(errors.New("timed out")))
+
+		assert.Empty(t, channel.published)
+		assert.True(t, ack.nacked)
+		assert.True(t, ack.requeue)
+	})
+
+	t.Run("holds message requeued after delay", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+
+		start := time.Now()
+		mux.settle(amqp.Delivery{Acknowledger: ack}, RequeueAfter(errors.New("throttled"), 50*time.Millisecond))
+
+		assert.True(t, time.Since(start) >= 50*time.Millisecond)
+		assert.True(t, ack.nacked)
+		assert.True(t, ack.requeue)
+	})
+
+	t.Run("cuts requeue delay short on shutdown", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		close(mux.stopping)
+
+		start := time.Now()
+		mux.settle(amqp.Delivery{Acknowledger: ack}, RequeueAfter(errors.New("throttled"), time.Hour))
+
+		assert.True(t, time.Since(start) < time.Second)
+		assert.True(t, ack.nacked)
+		assert.True(t, ack.requeue)
+	})
+
+	t.Run("requeues failed message when dead-letter fails", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		mux.deadLetterer, _, _ = fakeDeadLetterer(t)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, errors.New("failed"))
+
+		assert.False(t, ack.acked)
+		assert.True(t, ack.nacked)
+		assert.True(t, ack.requeue)
+	})
+}
@@ -0,0 +1,41 @@
+package amqp
+
+import "github.com/streadway/amqp"
+
+// Hold is handler error leaving message unsettled, until Settle of the hold
+// is called, e.g. once a batch the message joined is sent. Held messages
+// don't occupy workers, but count against prefetch of their queue.
+type Hold struct {
+	result chan error
+	done   chan struct{}
+}
+
+// NewHold creates hold, which handler returns as its error.
+func NewHold() *Hold {
+	return &Hold{result: make(chan error), done: make(chan struct{})}
+}
+
+func (h *Hold) Error() string {
+	return "held by handler"
+}
+
+// Settle settles held message as if handler returned err,
+// blocking until message is settled.
+func (h *Hold) Settle(err error) {
+	h.result <- err
+	<-h.done
+}
+
+// Release blocks until Settle of hold is called, then settles message with
+// settle, e.g. source other than ServeMux settles its record this way.
+func (h *Hold) Release(settle func(err error)) {
+	defer close(h.done)
+	settle(<-h.result)
+}
+
+// hold settles delivery held by handler once it's released.
+func (mux *ServeMux) hold(delivery amqp.Delivery, h *Hold) {
+	go h.Release(func(err error) {
+		mux.settle(delivery, err)
+	})
+}
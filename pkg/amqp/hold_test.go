@@ -0,0 +1,59 @@
+package amqp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeMux_hold(t *testing.T) {
+	t.Run("settles once released", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+
+		hold := NewHold()
+		mux.settle(amqp.Delivery{Acknowledger: ack}, hold)
+		assert.False(t, ack.acked)
+		assert.False(t, ack.nacked)
+
+		hold.Settle(nil)
+		assert.True(t, ack.acked)
+	})
+
+	t.Run("dead-letters failed message in auto mode", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		mux.SetAutoAck(true)
+		var channel *fakePublisher
+		mux.deadLetterer, channel, _ = fakeDeadLetterer(t, true)
+
+		hold := NewHold()
+		mux.settle(amqp.Delivery{Acknowledger: ack}, hold)
+		hold.Settle(errors.New("digest failed"))
+
+		assert.Len(t, channel.published, 1)
+		assert.False(t, ack.acked)
+	})
+
+	t.Run("requeues, when released with Requeue", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+
+		hold := NewHold()
+		mux.settle(amqp.Delivery{Acknowledger: ack}, hold)
+		hold.Settle(Requeue(errors.New("timed out")))
+
+		assert.True(t, ack.nacked)
+		assert.True(t, ack.requeue)
+	})
+}
+
+func TestServeMux_SetPrefetch(t *testing.T) {
+	mux := NewServeMux("", "", "")
+	mux.SetPrefetch("user.message.new", 500)
+	assert.Equal(t, map[string]int{"user.message.new": 500}, mux.prefetch)
+
+	assert.PanicsWithValue(t, "amqp: invalid prefetch", func() { mux.SetPrefetch("user.message.new", 0) })
+}
@@ -0,0 +1,69 @@
+package amqp
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// limit bounds number of messages served, see ServeMux.SetMaxMessages.
+type limit struct {
+	mu       sync.Mutex
+	max      int
+	taken    int
+	finished int
+}
+
+// take reserves slot for message, telling whether there's one left.
+func (l *limit) take() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.taken >= l.max {
+		return false
+	}
+	l.taken++
+	return true
+}
+
+// finish releases slot of served message, telling whether it was the last one.
+func (l *limit) finish() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.finished++
+	return l.max > 0 && l.finished == l.max
+}
+
+// SetMaxMessages makes mux shut down gracefully once n messages are served,
+// zero serves forever. Messages delivered beyond n are requeued, so it needs
+// manual ack mode.
+func (mux *ServeMux) SetMaxMessages(n int) {
+	if n < 0 {
+		panic("amqp: invalid max messages")
+	}
+	mux.limit.max = n
+}
+
+// serveLimited serves delivery, when limit allows, and requeues it otherwise.
+// Mux shuts down once the last allowed message is settled.
+func (mux *ServeMux) serveLimited(delivery amqp.Delivery, handler Handler) {
+	if !mux.limit.take() {
+		nack(delivery, true)
+		return
+	}
+
+	mux.settle(delivery, mux.serve(delivery, handler))
+
+	if mux.limit.finish() {
+		log.Printf("Served %d messages, shutting down", mux.limit.max)
+		// Shutdown waits for workers, this one included.
+		go func() {
+			if err := mux.Shutdown(context.Background()); err != nil {
+				log.Printf("shutdown: %s", err.Error())
+			}
+		}()
+	}
+}
@@ -0,0 +1,88 @@
+package amqp
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeMux_SetMaxMessages(t *testing.T) {
+	defer sampleKey(t)()
+
+	body, err := json.Marshal(sampleDelivery())
+	assert.NoError(t, err)
+
+	serve := func(t *testing.T, max, concurrency, fed int) (int, []*fakeAcknowledger) {
+		var mu sync.Mutex
+		processed := 0
+		handler := HandlerFunc(func(Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			processed++
+			return nil
+		})
+
+		acks := make([]*fakeAcknowledger, fed)
+		deliveries := make(chan amqp.Delivery, fed)
+		for i := range acks {
+			acks[i] = &fakeAcknowledger{}
+			deliveries <- amqp.Delivery{Acknowledger: acks[i], Body: body}
+		}
+
+		channel := &fakeCanceler{}
+		mux := NewServeMux("", "postmaster", "")
+		mux.SetConcurrency(concurrency)
+		mux.SetMaxMessages(max)
+		mux.listen(listener{channel: channel, deliveries: deliveries, handler: handler})
+
+		select {
+		case <-mux.done:
+		case <-time.After(time.Second):
+			t.Fatal("mux didn't stop")
+		}
+
+		assert.Equal(t, []string{"postmaster"}, channel.canceled)
+
+		mu.Lock()
+		defer mu.Unlock()
+		return processed, acks
+	}
+
+	count := func(acks []*fakeAcknowledger) (acked, requeued int) {
+		for _, ack := range acks {
+			if ack.acked {
+				acked++
+			}
+			if ack.nacked && ack.requeue {
+				requeued++
+			}
+		}
+		return acked, requeued
+	}
+
+	t.Run("stops after exactly max messages", func(t *testing.T) {
+		processed, acks := serve(t, 3, 1, 5)
+
+		assert.Equal(t, 3, processed)
+		acked, requeued := count(acks)
+		assert.Equal(t, 3, acked)
+		assert.Equal(t, 2, requeued)
+	})
+
+	t.Run("counts messages of concurrent workers", func(t *testing.T) {
+		processed, acks := serve(t, 3, 4, 8)
+
+		assert.Equal(t, 3, processed)
+		acked, requeued := count(acks)
+		assert.Equal(t, 3, acked)
+		assert.Equal(t, 5, requeued)
+	})
+
+	t.Run("rejects negative max", func(t *testing.T) {
+		assert.Panics(t, func() { NewServeMux("", "", "").SetMaxMessages(-1) })
+	})
+}
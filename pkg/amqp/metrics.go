@@ -0,0 +1,85 @@
+package amqp
+
+import (
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// inspector is the part of amqp.Channel used to sample queue depth.
+type inspector interface {
+	QueueInspect(name string) (amqp.Queue, error)
+}
+
+// Metrics are consumer gauges, sampled periodically.
+type Metrics struct {
+	// QueueDepth is the number of messages ready for delivery in the queue.
+	QueueDepth expvar.Int
+	// Lag is the age in seconds of the oldest message processed since previous sample.
+	Lag expvar.Float
+
+	mu     sync.Mutex
+	oldest time.Time
+	now    func() time.Time
+}
+
+// NewMetrics creates metrics with zero gauges.
+func NewMetrics() *Metrics {
+	return &Metrics{now: time.Now}
+}
+
+// Publish exports gauges with expvar as "<prefix>.queue_depth" and "<prefix>.lag_seconds".
+func (m *Metrics) Publish(prefix string) {
+	expvar.Publish(prefix+".queue_depth", &m.QueueDepth)
+	expvar.Publish(prefix+".lag_seconds", &m.Lag)
+}
+
+// observe records publish time of processed message.
+// Messages published without timestamp are not accounted.
+func (m *Metrics) observe(published time.Time) {
+	if published.IsZero() {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.oldest.IsZero() || published.Before(m.oldest) {
+		m.oldest = published
+	}
+}
+
+// sample updates gauges. Queue depth is skipped, when queue is empty.
+func (m *Metrics) sample(channel inspector, queue string) error {
+	m.mu.Lock()
+	if m.oldest.IsZero() {
+		m.Lag.Set(0)
+	} else {
+		m.Lag.Set(m.now().Sub(m.oldest).Seconds())
+	}
+	m.oldest = time.Time{}
+	m.mu.Unlock()
+
+	if queue == "" {
+		return nil
+	}
+
+	state, err := channel.QueueInspect(queue)
+	if err != nil {
+		return err
+	}
+
+	m.QueueDepth.Set(int64(state.Messages))
+	return nil
+}
+
+func (m *Metrics) run(channel inspector, queue string, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := m.sample(channel, queue); err != nil {
+			log.Printf("metrics: queue %s: %s", queue, err.Error())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package amqp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeInspector reports queue with given depth.
+type fakeInspector struct {
+	depth int
+	err   error
+	names []string
+}
+
+func (i *fakeInspector) QueueInspect(name string) (amqp.Queue, error) {
+	i.names = append(i.names, name)
+	return amqp.Queue{Name: name, Messages: i.depth}, i.err
+}
+
+func TestMetrics_sample(t *testing.T) {
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("updates queue depth", func(t *testing.T) {
+		m := NewMetrics()
+		channel := &fakeInspector{depth: 42}
+
+		assert.NoError(t, m.sample(channel, "postmaster.events"))
+		assert.Equal(t, int64(42), m.QueueDepth.Value())
+		assert.Equal(t, []string{"postmaster.events"}, channel.names)
+	})
+
+	t.Run("skips queue depth without queue", func(t *testing.T) {
+		m := NewMetrics()
+		channel := &fakeInspector{depth: 42}
+
+		assert.NoError(t, m.sample(channel, ""))
+		assert.Equal(t, int64(0), m.QueueDepth.Value())
+		assert.Empty(t, channel.names)
+	})
+
+	t.Run("returns inspect error", func(t *testing.T) {
+		m := NewMetrics()
+		m.QueueDepth.Set(7)
+
+		err := m.sample(&fakeInspector{err: errors.New("channel closed")}, "postmaster.events")
+		assert.EqualError(t, err, "channel closed")
+		assert.Equal(t, int64(7), m.QueueDepth.Value())
+	})
+
+	t.Run("measures lag of the oldest message", func(t *testing.T) {
+		m := NewMetrics()
+		m.now = func() time.Time { return now }
+
+		m.observe(now.Add(-5 * time.Second))
+		m.observe(now.Add(-30 * time.Second))
+		m.observe(time.Time{})
+
+		assert.NoError(t, m.sample(&fakeInspector{}, ""))
+		assert.Equal(t, 30.0, m.Lag.Value())
+
+		// Nothing processed since previous sample.
+		assert.NoError(t, m.sample(&fakeInspector{}, ""))
+		assert.Equal(t, 0.0, m.Lag.Value())
+	})
+}
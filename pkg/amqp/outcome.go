@@ -0,0 +1,96 @@
+package amqp
+
+import "errors"
+
+// Outcome tells how delivery is settled once handler returns.
+type Outcome int
+
+const (
+	// OutcomeAck acknowledges message, outcome of nil error.
+	OutcomeAck Outcome = iota
+	// OutcomeRequeue puts message back to the queue, see Requeue.
+	OutcomeRequeue
+	// OutcomeDrop discards message without dead-lettering or requeueing it,
+	// e.g. when it's recognized as bad but harmless.
+	OutcomeDrop
+	// OutcomeDeadLetter dead-letters message, outcome of other errors.
+	OutcomeDeadLetter
+)
+
+// outcomeError carries outcome, which handler chose for message, see Settle.
+type outcomeError struct {
+	err     error
+	outcome Outcome
+}
+
+func (e outcomeError) Error() string {
+	return e.err.Error()
+}
+
+// Settle returns handler error making message settled with outcome,
+// err is logged as the reason. OutcomeAck of nil err is nil.
+func Settle(outcome Outcome, err error) error {
+	if err == nil {
+		if outcome == OutcomeAck {
+			return nil
+		}
+		err = errors.New("settled by handler")
+	}
+
+	if outcome == OutcomeRequeue {
+		return Requeue(err)
+	}
+
+	return outcomeError{err: err, outcome: outcome}
+}
+
+// Drop is Settle with OutcomeDrop.
+func Drop(err error) error {
+	return Settle(OutcomeDrop, err)
+}
+
+// headersError adds headers to dead-lettered copy of message, see WithHeaders.
+type headersError struct {
+	err     error
+	headers map[string]string
+}
+
+func (e *headersError) Error() string {
+	return e.err.Error()
+}
+
+// WithHeaders returns handler error adding headers to message, once it's
+// dead-lettered, e.g. to tell what's left to retry. Outcome of err is kept,
+// except that copy of message settled with OutcomeAck is dead-lettered as well.
+func WithHeaders(err error, headers map[string]string) error {
+	if err == nil || len(headers) == 0 {
+		return err
+	}
+
+	return &headersError{err: err, headers: headers}
+}
+
+// deadLetterHeaders returns headers, which err adds to dead-lettered message.
+func HeadersOf(err error) map[string]string {
+	if e, ok := err.(*headersError); ok {
+		return e.headers
+	}
+
+	return nil
+}
+
+// OutcomeOf returns outcome of handler error.
+func OutcomeOf(err error) Outcome {
+	switch e := err.(type) {
+	case nil:
+		return OutcomeAck
+	case *headersError:
+		return OutcomeOf(e.err)
+	case requeueError:
+		return OutcomeRequeue
+	case outcomeError:
+		return e.outcome
+	default:
+		return OutcomeDeadLetter
+	}
+}
@@ -0,0 +1,127 @@
+package amqp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutcomeOf(t *testing.T) {
+	failed := errors.New("failed")
+
+	assert.Equal(t, OutcomeAck, OutcomeOf(nil))
+	assert.Equal(t, OutcomeDeadLetter, OutcomeOf(failed))
+	assert.Equal(t, OutcomeRequeue, OutcomeOf(Requeue(failed)))
+	assert.Equal(t, OutcomeDrop, OutcomeOf(Drop(failed)))
+
+	for _, outcome := range []Outcome{OutcomeAck, OutcomeRequeue, OutcomeDrop, OutcomeDeadLetter} {
+		assert.Equal(t, outcome, OutcomeOf(Settle(outcome, failed)))
+		assert.EqualError(t, Settle(outcome, failed), "failed")
+	}
+
+	assert.NoError(t, Settle(OutcomeAck, nil))
+	assert.True(t, IsRequeue(Settle(OutcomeRequeue, nil)))
+	assert.EqualError(t, Drop(nil), "settled by handler")
+}
+
+func TestServeMux_settleOutcome(t *testing.T) {
+	cases := []struct {
+		name      string
+		outcome   Outcome
+		acked     bool
+		nacked    bool
+		requeue   bool
+		published int
+	}{
+		{"acks", OutcomeAck, true, false, false, 0},
+		{"requeues", OutcomeRequeue, false, true, true, 0},
+		{"drops", OutcomeDrop, false, true, false, 0},
+		{"dead-letters", OutcomeDeadLetter, true, false, false, 1},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ack := &fakeAcknowledger{}
+			mux := NewServeMux("", "", "")
+			var channel *fakePublisher
+			mux.deadLetterer, channel, _ = fakeDeadLetterer(t, true)
+
+			mux.settle(amqp.Delivery{Acknowledger: ack}, Settle(c.outcome, errors.New("handled")))
+
+			assert.Equal(t, c.acked, ack.acked)
+			assert.Equal(t, c.nacked, ack.nacked)
+			assert.Equal(t, c.requeue, ack.requeue)
+			assert.Len(t, channel.published, c.published)
+		})
+	}
+
+	t.Run("drops without dead-letter in auto mode", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		mux.SetAutoAck(true)
+		var channel *fakePublisher
+		mux.deadLetterer, channel, _ = fakeDeadLetterer(t, true)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, Drop(errors.New("harmless")))
+		mux.settle(amqp.Delivery{Acknowledger: ack}, Settle(OutcomeAck, errors.New("harmless")))
+
+		assert.Empty(t, channel.published)
+		assert.False(t, ack.acked)
+		assert.False(t, ack.nacked)
+	})
+}
+
+func TestWithHeaders(t *testing.T) {
+	failed := errors.New("failed")
+	headers := map[string]string{"x-postmaster-recipients": "bob@example.com"}
+
+	assert.NoError(t, WithHeaders(nil, headers))
+	assert.Equal(t, failed, WithHeaders(failed, nil))
+
+	for _, outcome := range []Outcome{OutcomeAck, OutcomeRequeue, OutcomeDrop, OutcomeDeadLetter} {
+		err := WithHeaders(Settle(outcome, failed), headers)
+		assert.Equal(t, outcome, OutcomeOf(err))
+		assert.EqualError(t, err, "failed")
+	}
+
+	t.Run("dead-letters copy of acknowledged message", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		var channel *fakePublisher
+		mux.deadLetterer, channel, _ = fakeDeadLetterer(t, true)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, WithHeaders(Settle(OutcomeAck, failed), headers))
+
+		assert.True(t, ack.acked)
+		assert.False(t, ack.nacked)
+		assert.Len(t, channel.published, 1)
+		assert.Equal(t, "bob@example.com", channel.published[0].Headers["x-postmaster-recipients"])
+		assert.Equal(t, "failed", channel.published[0].Headers["x-postmaster-error"])
+	})
+
+	t.Run("acknowledges message, which copy isn't dead-lettered", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		mux.deadLetterer, _, _ = fakeDeadLetterer(t, false, false, false, false, false)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, WithHeaders(Settle(OutcomeAck, failed), headers))
+
+		assert.True(t, ack.acked)
+		assert.False(t, ack.nacked)
+	})
+
+	t.Run("requeues dead-lettered message, which isn't published", func(t *testing.T) {
+		ack := &fakeAcknowledger{}
+		mux := NewServeMux("", "", "")
+		mux.deadLetterer, _, _ = fakeDeadLetterer(t, false, false, false, false, false)
+
+		mux.settle(amqp.Delivery{Acknowledger: ack}, WithHeaders(failed, headers))
+
+		assert.False(t, ack.acked)
+		assert.True(t, ack.nacked)
+		assert.True(t, ack.requeue)
+	})
+}
@@ -0,0 +1,70 @@
+package amqp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// CheckExchange dials broker at uri and verifies exchange exists with
+// a passive declare. It gives up with ctx.Err() once ctx is done.
+func CheckExchange(ctx context.Context, uri, exchange string) error {
+	var mu sync.Mutex
+	var raw net.Conn
+
+	dial := func(network, addr string) (net.Conn, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		raw = conn
+		mu.Unlock()
+
+		return conn, nil
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- checkExchange(uri, exchange, dial)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		// Unblocks handshake or declare, which don't take context.
+		mu.Lock()
+		if raw != nil {
+			raw.Close()
+		}
+		mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func checkExchange(uri, exchange string, dial func(network, addr string) (net.Conn, error)) error {
+	conn, err := amqp.DialConfig(uri, amqp.Config{Dial: dial})
+	if err != nil {
+		return fmt.Errorf("dial: %s", err.Error())
+	}
+	defer conn.Close()
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel: %s", err.Error())
+	}
+	defer channel.Close()
+
+	// Type is ignored by passive declare, broker only checks the name.
+	if err := channel.ExchangeDeclarePassive(exchange, "direct", false, false, false, false, nil); err != nil {
+		return fmt.Errorf("exchange \"%s\": %s", exchange, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package amqp
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckExchange(t *testing.T) {
+	t.Run("fails on unreachable broker", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		err = CheckExchange(context.Background(), "amqp://guest:guest@"+addr+"/", "peatio.events.ranger")
+		assert.Error(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "dial: "), err.Error())
+	})
+
+	t.Run("gives up on hung broker", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		go func() {
+			// Accepts, but never speaks AMQP.
+			conn, err := listener.Accept()
+			if err == nil {
+				defer conn.Close()
+				io.Copy(ioutil.Discard, conn)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err = CheckExchange(ctx, "amqp://guest:guest@"+listener.Addr().String()+"/", "peatio.events.ranger")
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
@@ -0,0 +1,103 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/streadway/amqp"
+)
+
+// deadLetterSource is the part of amqp.Channel used to replay dead-lettered messages.
+type deadLetterSource interface {
+	QueueInspect(name string) (amqp.Queue, error)
+	Get(queue string, autoAck bool) (amqp.Delivery, bool, error)
+}
+
+// Replay reprocesses up to limit messages of DeadLetterQueue, all of them when
+// limit isn't positive, with handlers of their original routing keys. Messages
+// failing again are dead-lettered again. It returns number of messages, which
+// succeeded, and stops early once ctx is done.
+func (mux *ServeMux) Replay(ctx context.Context, limit int) (int, error) {
+	if mux.deadLetterExchange == "" {
+		return 0, errors.New("amqp: replay requires dead-letter exchange")
+	}
+
+	conn, err := mux.connect()
+	if err != nil {
+		return 0, fmt.Errorf("dial %s", err.Error())
+	}
+	defer conn.Close()
+
+	if err := mux.declareDeadLetter(conn); err != nil {
+		return 0, err
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("channel %s", err.Error())
+	}
+
+	return mux.replay(ctx, channel, limit)
+}
+
+func (mux *ServeMux) replay(ctx context.Context, source deadLetterSource, limit int) (int, error) {
+	queue, err := source.QueueInspect(DeadLetterQueue)
+	if err != nil {
+		return 0, fmt.Errorf("dead-letter queue %s", err.Error())
+	}
+
+	// Messages dead-lettered again go behind the ones waiting,
+	// so they aren't replayed twice.
+	n := queue.Messages
+	if limit > 0 && limit < n {
+		n = limit
+	}
+
+	replayed := 0
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return replayed, err
+		}
+
+		delivery, ok, err := source.Get(DeadLetterQueue, false)
+		if err != nil {
+			return replayed, fmt.Errorf("get %s", err.Error())
+		}
+		if !ok {
+			break
+		}
+
+		err = mux.replayDelivery(&delivery)
+		mux.settleManual(delivery, err)
+		if err == nil {
+			replayed++
+		}
+	}
+
+	log.Printf("Replayed %d of %d dead-lettered messages", replayed, n)
+
+	return replayed, nil
+}
+
+// replayDelivery serves dead-lettered delivery with original headers.
+func (mux *ServeMux) replayDelivery(delivery *amqp.Delivery) error {
+	headers := amqp.Table{}
+	for k, v := range delivery.Headers {
+		if k != "x-postmaster-error" {
+			headers[k] = v
+		}
+	}
+	delivery.Headers = headers
+
+	mux.mu.RLock()
+	entry, ok := mux.m[delivery.RoutingKey]
+	mux.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("no handler for routing key %s", delivery.RoutingKey)
+	}
+
+	return mux.serve(*delivery, entry.h)
+}
@@ -0,0 +1,124 @@
+package amqp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDeadLetterQueue hands out deliveries of DeadLetterQueue.
+type fakeDeadLetterQueue struct {
+	deliveries []amqp.Delivery
+}
+
+func (q *fakeDeadLetterQueue) QueueInspect(name string) (amqp.Queue, error) {
+	return amqp.Queue{Name: name, Messages: len(q.deliveries)}, nil
+}
+
+func (q *fakeDeadLetterQueue) Get(queue string, autoAck bool) (amqp.Delivery, bool, error) {
+	if len(q.deliveries) == 0 {
+		return amqp.Delivery{}, false, nil
+	}
+
+	delivery := q.deliveries[0]
+	q.deliveries = q.deliveries[1:]
+	return delivery, true, nil
+}
+
+func TestServeMux_replay(t *testing.T) {
+	defer sampleKey(t)()
+
+	body, err := json.Marshal(sampleDelivery())
+	assert.NoError(t, err)
+
+	deadLettered := func(key string, ack *fakeAcknowledger) amqp.Delivery {
+		return amqp.Delivery{
+			Acknowledger: ack,
+			RoutingKey:   key,
+			Headers:      amqp.Table{"X-Campaign-ID": "spring", "x-postmaster-error": "template: missing"},
+			Body:         body,
+		}
+	}
+
+	t.Run("sends previously failed message", func(t *testing.T) {
+		var received []Message
+		mux := NewServeMux("", "postmaster", "")
+		mux.HandleFunc("user.password.reset", func(msg Message) error {
+			received = append(received, msg)
+			return nil
+		})
+
+		ack := &fakeAcknowledger{}
+		queue := &fakeDeadLetterQueue{[]amqp.Delivery{deadLettered("user.password.reset", ack)}}
+
+		replayed, err := mux.replay(context.Background(), queue, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		assert.True(t, ack.acked)
+
+		assert.Len(t, received, 1)
+		assert.Equal(t, amqp.Table{"X-Campaign-ID": "spring"}, received[0].Headers)
+		assert.Equal(t, "john@doe.com", received[0].Event["user"].(map[string]interface{})["email"])
+	})
+
+	t.Run("dead-letters again what still fails", func(t *testing.T) {
+		mux := NewServeMux("", "postmaster", "")
+		mux.HandleFunc("user.password.reset", func(Message) error { return errors.New("template: still missing") })
+		var channel *fakePublisher
+		mux.deadLetterer, channel, _ = fakeDeadLetterer(t, true, true)
+
+		failing, unknown := &fakeAcknowledger{}, &fakeAcknowledger{}
+		queue := &fakeDeadLetterQueue{[]amqp.Delivery{
+			deadLettered("user.password.reset", failing),
+			deadLettered("user.removed", unknown),
+		}}
+
+		replayed, err := mux.replay(context.Background(), queue, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, replayed)
+
+		assert.True(t, failing.acked)
+		assert.True(t, unknown.acked)
+		assert.Len(t, channel.published, 2)
+		assert.Equal(t, body, channel.published[0].Body)
+		assert.Equal(t, "template: still missing", channel.published[0].Headers["x-postmaster-error"])
+		assert.Equal(t, "spring", channel.published[0].Headers["X-Campaign-ID"])
+		assert.Equal(t, "no handler for routing key user.removed", channel.published[1].Headers["x-postmaster-error"])
+	})
+
+	t.Run("respects limit", func(t *testing.T) {
+		mux := NewServeMux("", "postmaster", "")
+		mux.HandleFunc("user.password.reset", func(Message) error { return nil })
+
+		queue := &fakeDeadLetterQueue{[]amqp.Delivery{
+			deadLettered("user.password.reset", &fakeAcknowledger{}),
+			deadLettered("user.password.reset", &fakeAcknowledger{}),
+		}}
+
+		replayed, err := mux.replay(context.Background(), queue, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		assert.Len(t, queue.deliveries, 1)
+	})
+
+	t.Run("stops once context is done", func(t *testing.T) {
+		mux := NewServeMux("", "postmaster", "")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		queue := &fakeDeadLetterQueue{[]amqp.Delivery{deadLettered("user.password.reset", &fakeAcknowledger{})}}
+
+		_, err := mux.replay(ctx, queue, 0)
+		assert.Equal(t, context.Canceled, err)
+		assert.Len(t, queue.deliveries, 1)
+	})
+
+	t.Run("requires dead-letter exchange", func(t *testing.T) {
+		_, err := NewServeMux("", "postmaster", "").Replay(context.Background(), 0)
+		assert.EqualError(t, err, "amqp: replay requires dead-letter exchange")
+	})
+}
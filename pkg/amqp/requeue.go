@@ -0,0 +1,67 @@
+package amqp
+
+import "time"
+
+// requeueError marks handler failure as temporary, see Requeue.
+type requeueError struct {
+	err   error
+	delay time.Duration
+	// deferred messages didn't fail, so no attempt is counted, see Defer.
+	deferred bool
+}
+
+func (e requeueError) Error() string {
+	return e.err.Error()
+}
+
+// Requeue wraps handler error to put message back to the queue instead of
+// dead-lettering it, e.g. when SMTP server timed out. Auto ack mode can't
+// requeue, so such messages are dead-lettered as any other failure.
+func Requeue(err error) error {
+	return requeueError{err: err}
+}
+
+// RequeueAfter is Requeue, which holds message for delay before requeueing,
+// so it isn't redelivered right away. Shutdown cuts the delay short.
+func RequeueAfter(err error, delay time.Duration) error {
+	return requeueError{err: err, delay: delay}
+}
+
+// Defer is RequeueAfter of message, which didn't fail, but is put off,
+// e.g. until quiet hours end. Its retry attempts aren't counted, see Retry.
+func Defer(err error, delay time.Duration) error {
+	return requeueError{err: err, delay: delay, deferred: true}
+}
+
+// IsRequeue reports whether err was wrapped with Requeue.
+func IsRequeue(err error) bool {
+	_, ok := err.(requeueError)
+	return ok
+}
+
+// deferred reports whether err was wrapped with Defer.
+func deferred(err error) bool {
+	requeue, _ := err.(requeueError)
+	return requeue.deferred
+}
+
+// requeueDelay returns delay of err wrapped with RequeueAfter.
+func requeueDelay(err error) time.Duration {
+	requeue, _ := err.(requeueError)
+	return requeue.delay
+}
+
+// wait blocks for delay or until shutdown begins.
+func (mux *ServeMux) wait(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-mux.stopping:
+	}
+}
@@ -0,0 +1,144 @@
+package amqp
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// AttemptsHeader counts failed attempts of message republished for retry.
+const AttemptsHeader = "x-postmaster-attempts"
+
+// Retry republishes messages, which handler asked to requeue, through delayed
+// exchange, so they return to their queue after growing delay instead of being
+// redelivered right away. Message failed MaxAttempts times is dead-lettered.
+// Deferred messages are republished after their delay without counting attempt, see Defer.
+type Retry struct {
+	MaxAttempts int
+	// Delay before attempt following the first failed one doubles every attempt.
+	Backoff Backoff
+}
+
+// SetRetryExchange makes messages of routing keys with retry policy, see SetRetry,
+// republished through exchange. It's declared as "x-delayed-message" exchange,
+// which needs rabbitmq_delayed_message_exchange plugin of broker.
+func (mux *ServeMux) SetRetryExchange(exchange string) {
+	mux.retryExchange = exchange
+}
+
+// SetRetry sets retry policy of messages of routing key, see Retry.
+func (mux *ServeMux) SetRetry(routingKey string, retry Retry) {
+	if retry.MaxAttempts < 1 {
+		panic("amqp: invalid max attempts")
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.retries == nil {
+		mux.retries = make(map[string]Retry)
+	}
+	mux.retries[routingKey] = retry
+}
+
+func (mux *ServeMux) declareRetry(conn *amqp.Connection) error {
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel %s", err.Error())
+	}
+
+	err = channel.ExchangeDeclare(mux.retryExchange, "x-delayed-message", true, false, false, false,
+		amqp.Table{"x-delayed-type": "direct"})
+	if err != nil {
+		return fmt.Errorf("retry exchange %s", err.Error())
+	}
+
+	mux.retrier, err = NewDeadLetterer(channel, mux.retryExchange)
+	return err
+}
+
+// attempts returns number of failed attempts of delivery.
+func attempts(delivery amqp.Delivery) int {
+	switch n := delivery.Headers[AttemptsHeader].(type) {
+	case int:
+		return n
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	}
+
+	return 0
+}
+
+// retry settles delivery, which handler asked to requeue with err, by its
+// retry policy. It reports false, when delivery has none or it can't be
+// republished, so delivery is requeued as is.
+func (mux *ServeMux) retry(delivery amqp.Delivery, err error) bool {
+	mux.mu.RLock()
+	policy, ok := mux.retries[delivery.RoutingKey]
+	mux.mu.RUnlock()
+
+	if !ok || mux.retrier == nil {
+		return false
+	}
+
+	if deferred(err) {
+		return mux.republish(delivery, nil, requeueDelay(err), err)
+	}
+
+	failed := attempts(delivery) + 1
+	if failed >= policy.MaxAttempts {
+		reason := WithHeaders(fmt.Errorf("%d attempts failed, last: %s", failed, err.Error()), HeadersOf(err))
+		if mux.deadLetterer == nil {
+			log.Println(reason)
+			nack(delivery, false)
+			return true
+		}
+
+		if mux.deadLetterer.Publish(delivery, reason) != nil {
+			return false
+		}
+
+		ack(delivery)
+		return true
+	}
+
+	delay := policy.Backoff.Delay(failed - 1)
+	if requested := requeueDelay(err); requested > delay {
+		delay = requested
+	}
+
+	if !mux.republish(delivery, amqp.Table{AttemptsHeader: int64(failed)}, delay, err) {
+		return false
+	}
+
+	log.Printf("Retrying message with routing key %s in %s, attempt %d of %d", delivery.RoutingKey, delay, failed+1, policy.MaxAttempts)
+	return true
+}
+
+// republish acks delivery, once its copy with extra headers and the ones of err
+// is published through retry exchange to return after delay.
+func (mux *ServeMux) republish(delivery amqp.Delivery, extra amqp.Table, delay time.Duration, err error) bool {
+	headers := amqp.Table{}
+	for k, v := range delivery.Headers {
+		headers[k] = v
+	}
+	for k, v := range HeadersOf(err) {
+		headers[k] = v
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	headers["x-delay"] = int64(delay / time.Millisecond)
+	headers["x-postmaster-error"] = err.Error()
+
+	if mux.retrier.republish(delivery, headers) != nil {
+		return false
+	}
+
+	ack(delivery)
+	return true
+}
@@ -0,0 +1,139 @@
+package amqp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeMux_retry(t *testing.T) {
+	unavailable := Requeue(errors.New("smtp unavailable"))
+	retryMux := func(t *testing.T) (*ServeMux, *fakePublisher, *fakePublisher) {
+		mux := NewServeMux("", "", "")
+		mux.SetRetry("user.signup", Retry{MaxAttempts: 3, Backoff: Backoff{Initial: time.Second, Max: time.Minute}})
+
+		var retried, deadLettered *fakePublisher
+		mux.retrier, retried, _ = fakeDeadLetterer(t, true, true)
+		mux.deadLetterer, deadLettered, _ = fakeDeadLetterer(t, true)
+		return mux, retried, deadLettered
+	}
+	delivery := func(ack *fakeAcknowledger, headers amqp.Table) amqp.Delivery {
+		return amqp.Delivery{Acknowledger: ack, RoutingKey: "user.signup", Headers: headers, Body: []byte(`{"payload":"y"}`)}
+	}
+
+	t.Run("republishes with growing delay", func(t *testing.T) {
+		mux, retried, deadLettered := retryMux(t)
+
+		ack := &fakeAcknowledger{}
+		mux.settle(delivery(ack, amqp.Table{"X-Campaign-ID": "spring"}), unavailable)
+		assert.True(t, ack.acked)
+		assert.False(t, ack.nacked)
+
+		ack = &fakeAcknowledger{}
+		mux.settle(delivery(ack, amqp.Table{AttemptsHeader: int64(1)}), unavailable)
+		assert.True(t, ack.acked)
+
+		assert.Len(t, retried.published, 2)
+		assert.Equal(t, amqp.Table{
+			"X-Campaign-ID":      "spring",
+			AttemptsHeader:       int64(1),
+			"x-delay":            int64(1000),
+			"x-postmaster-error": "smtp unavailable",
+		}, retried.published[0].Headers)
+		assert.Equal(t, int64(2), retried.published[1].Headers[AttemptsHeader])
+		assert.Equal(t, int64(2000), retried.published[1].Headers["x-delay"])
+		assert.Equal(t, []byte(`{"payload":"y"}`), retried.published[1].Body)
+		assert.Empty(t, deadLettered.published)
+	})
+
+	t.Run("keeps longer delay of handler", func(t *testing.T) {
+		mux, retried, _ := retryMux(t)
+
+		mux.settle(delivery(&fakeAcknowledger{}, nil), RequeueAfter(errors.New("throttled"), 5*time.Second))
+		assert.Equal(t, int64(5000), retried.published[0].Headers["x-delay"])
+	})
+
+	t.Run("republishes with headers of handler", func(t *testing.T) {
+		mux, retried, _ := retryMux(t)
+
+		err := WithHeaders(unavailable, map[string]string{"x-postmaster-recipients": "jane@doe.com"})
+		mux.settle(delivery(&fakeAcknowledger{}, amqp.Table{"x-postmaster-recipients": "john@doe.com, jane@doe.com"}), err)
+		assert.Equal(t, "jane@doe.com", retried.published[0].Headers["x-postmaster-recipients"])
+		assert.Equal(t, int64(1), retried.published[0].Headers[AttemptsHeader])
+	})
+
+	t.Run("defers without counting attempts", func(t *testing.T) {
+		mux, _, deadLettered := retryMux(t)
+		var retried *fakePublisher
+		mux.retrier, retried, _ = fakeDeadLetterer(t, true, true, true, true, true)
+
+		headers := amqp.Table{AttemptsHeader: int64(1)}
+		for i := 0; i < 5; i++ {
+			ack := &fakeAcknowledger{}
+			mux.settle(delivery(ack, headers), Defer(errors.New("quiet hours"), time.Hour))
+			assert.True(t, ack.acked)
+
+			headers = retried.published[i].Headers
+			assert.Equal(t, int64(1), headers[AttemptsHeader])
+			assert.Equal(t, int64(time.Hour/time.Millisecond), headers["x-delay"])
+		}
+
+		assert.Len(t, retried.published, 5)
+		assert.Empty(t, deadLettered.published)
+	})
+
+	t.Run("dead-letters exhausted message", func(t *testing.T) {
+		mux, retried, deadLettered := retryMux(t)
+
+		ack := &fakeAcknowledger{}
+		mux.settle(delivery(ack, amqp.Table{AttemptsHeader: int32(2)}), unavailable)
+
+		assert.True(t, ack.acked)
+		assert.Empty(t, retried.published)
+		assert.Len(t, deadLettered.published, 1)
+		assert.Equal(t, "3 attempts failed, last: smtp unavailable", deadLettered.published[0].Headers["x-postmaster-error"])
+		assert.Equal(t, []byte(`{"payload":"y"}`), deadLettered.published[0].Body)
+	})
+
+	t.Run("drops exhausted message without dead-letter exchange", func(t *testing.T) {
+		mux, _, _ := retryMux(t)
+		mux.deadLetterer = nil
+
+		ack := &fakeAcknowledger{}
+		mux.settle(delivery(ack, amqp.Table{AttemptsHeader: int64(2)}), unavailable)
+		assert.True(t, ack.nacked)
+		assert.False(t, ack.requeue)
+	})
+
+	t.Run("requeues message, which can't be republished", func(t *testing.T) {
+		mux, _, _ := retryMux(t)
+		mux.retrier, _, _ = fakeDeadLetterer(t, false, false, false, false, false)
+
+		ack := &fakeAcknowledger{}
+		mux.settle(delivery(ack, nil), unavailable)
+		assert.True(t, ack.nacked)
+		assert.True(t, ack.requeue)
+	})
+
+	t.Run("requeues message without policy", func(t *testing.T) {
+		mux, retried, _ := retryMux(t)
+
+		ack := &fakeAcknowledger{}
+		d := delivery(ack, nil)
+		d.RoutingKey = "user.login"
+		mux.settle(d, unavailable)
+
+		assert.True(t, ack.nacked)
+		assert.True(t, ack.requeue)
+		assert.Empty(t, retried.published)
+	})
+
+	t.Run("rejects invalid max attempts", func(t *testing.T) {
+		assert.PanicsWithValue(t, "amqp: invalid max attempts", func() {
+			NewServeMux("", "", "").SetRetry("user.signup", Retry{})
+		})
+	})
+}
@@ -0,0 +1,123 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/streadway/amqp"
+)
+
+// canceler is the part of amqp.Channel used to stop consuming.
+type canceler interface {
+	Cancel(consumer string, noWait bool) error
+}
+
+type listener struct {
+	channel    canceler
+	deliveries <-chan amqp.Delivery
+	handler    Handler
+}
+
+// pending is a delivery received, but not processed yet.
+type pending struct {
+	delivery amqp.Delivery
+	handler  Handler
+}
+
+// Shutdown stops consuming and waits for in-flight messages. Messages received,
+// but not processed yet, are drained highest priority first until ctx is done,
+// then the rest is requeued. ListenAndServe returns once Shutdown is over.
+func (mux *ServeMux) Shutdown(ctx context.Context) error {
+	select {
+	case <-mux.stopping:
+		return errors.New("amqp: shutdown is in progress")
+	default:
+		close(mux.stopping)
+	}
+	defer close(mux.done)
+
+	mux.mu.RLock()
+	listeners := mux.listeners
+	mux.mu.RUnlock()
+
+	for _, l := range listeners {
+		if err := l.channel.Cancel(mux.tag, false); err != nil {
+			log.Printf("cancel consumer: %s", err.Error())
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		mux.workers.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var queue []pending
+	for _, l := range listeners {
+		for _, delivery := range buffered(l.deliveries) {
+			queue = append(queue, pending{delivery, l.handler})
+		}
+	}
+
+	mux.drain(ctx, queue)
+	return ctx.Err()
+}
+
+// buffered reads deliveries received until now.
+func buffered(deliveries <-chan amqp.Delivery) []amqp.Delivery {
+	var result []amqp.Delivery
+	for {
+		select {
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return result
+			}
+			result = append(result, delivery)
+		default:
+			return result
+		}
+	}
+}
+
+// drain processes deliveries highest priority first, keeping order of equal
+// priorities, and requeues the ones left, when ctx is done.
+func (mux *ServeMux) drain(ctx context.Context, queue []pending) {
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].delivery.Priority > queue[j].delivery.Priority
+	})
+
+	for i, p := range queue {
+		if ctx.Err() != nil {
+			mux.requeue(queue[i:])
+			return
+		}
+
+		// Shutdown is in progress, so the last message doesn't start another.
+		if !mux.limit.take() {
+			mux.requeue(queue[i:])
+			return
+		}
+		mux.settle(p.delivery, mux.serve(p.delivery, p.handler))
+	}
+}
+
+func (mux *ServeMux) requeue(queue []pending) {
+	if mux.autoAck {
+		log.Printf("MESSAGES LOST: %d messages abandoned on shutdown", len(queue))
+		return
+	}
+
+	for _, p := range queue {
+		if err := p.delivery.Nack(false, true); err != nil {
+			log.Printf("nack: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package amqp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCanceler struct {
+	canceled []string
+}
+
+func (c *fakeCanceler) Cancel(consumer string, noWait bool) error {
+	c.canceled = append(c.canceled, consumer)
+	return nil
+}
+
+func TestServeMux_Shutdown(t *testing.T) {
+	defer sampleKey(t)()
+
+	body, err := json.Marshal(sampleDelivery())
+	assert.NoError(t, err)
+
+	received := func(id string, priority uint8, ack *fakeAcknowledger) amqp.Delivery {
+		return amqp.Delivery{
+			Acknowledger: ack,
+			Headers:      amqp.Table{"id": id},
+			Priority:     priority,
+			Body:         body,
+		}
+	}
+
+	t.Run("finishes high priority message when deadline permits one", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var processed []interface{}
+		handler := HandlerFunc(func(msg Message) error {
+			processed = append(processed, msg.Headers["id"])
+			// Deadline is over once a single message is processed.
+			cancel()
+			return nil
+		})
+
+		newsletter, digest, otp := &fakeAcknowledger{}, &fakeAcknowledger{}, &fakeAcknowledger{}
+		deliveries := make(chan amqp.Delivery, 3)
+		deliveries <- received("newsletter", 0, newsletter)
+		deliveries <- received("digest", 1, digest)
+		deliveries <- received("otp", 9, otp)
+
+		channel := &fakeCanceler{}
+		mux := NewServeMux("", "postmaster", "")
+		mux.listeners = []listener{{channel: channel, deliveries: deliveries, handler: handler}}
+
+		err := mux.Shutdown(ctx)
+
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, []string{"postmaster"}, channel.canceled)
+		assert.Equal(t, []interface{}{"otp"}, processed)
+
+		assert.True(t, otp.acked)
+		assert.True(t, digest.nacked && digest.requeue)
+		assert.True(t, newsletter.nacked && newsletter.requeue)
+	})
+
+	t.Run("keeps order of equal priorities", func(t *testing.T) {
+		var processed []interface{}
+		handler := HandlerFunc(func(msg Message) error {
+			processed = append(processed, msg.Headers["id"])
+			return nil
+		})
+
+		deliveries := make(chan amqp.Delivery, 3)
+		deliveries <- received("first", 0, &fakeAcknowledger{})
+		deliveries <- received("second", 0, &fakeAcknowledger{})
+		deliveries <- received("urgent", 5, &fakeAcknowledger{})
+		close(deliveries)
+
+		mux := NewServeMux("", "postmaster", "")
+		mux.listeners = []listener{{channel: &fakeCanceler{}, deliveries: deliveries, handler: handler}}
+
+		assert.NoError(t, mux.Shutdown(context.Background()))
+		assert.Equal(t, []interface{}{"urgent", "first", "second"}, processed)
+	})
+
+	t.Run("stops workers", func(t *testing.T) {
+		mux := NewServeMux("", "postmaster", "")
+		deliveries := make(chan amqp.Delivery)
+
+		mux.workers.Add(1)
+		go mux.work(deliveries, HandlerFunc(func(Message) error { return nil }))
+
+		assert.NoError(t, mux.Shutdown(context.Background()))
+		assert.EqualError(t, mux.Shutdown(context.Background()), "amqp: shutdown is in progress")
+	})
+}
@@ -0,0 +1,39 @@
+package consumer
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// envelopeAddress returns address with international domain encoded to punycode,
+// e.g. "user@xn--mller-kva.de" for "user@müller.de", as SMTP envelope requires.
+// Headers keep the Unicode form.
+func envelopeAddress(address string) (string, error) {
+	at := strings.LastIndex(address, "@")
+	if at < 1 || at == len(address)-1 {
+		return "", fmt.Errorf("address %q is not valid", address)
+	}
+
+	domain, err := idna.Lookup.ToASCII(address[at+1:])
+	if err != nil {
+		return "", fmt.Errorf("address %q: %s", address, err.Error())
+	}
+
+	return address[:at+1] + domain, nil
+}
+
+// envelopeAddresses is envelopeAddress of every address.
+func envelopeAddresses(addresses []string) ([]string, error) {
+	result := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		encoded, err := envelopeAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, encoded)
+	}
+
+	return result, nil
+}
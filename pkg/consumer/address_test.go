@@ -0,0 +1,43 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelopeAddress(t *testing.T) {
+	valid := map[string]string{
+		"user@müller.de":       "user@xn--mller-kva.de",
+		"User@MÜLLER.de":       "User@xn--mller-kva.de",
+		"john@doe.com":         "john@doe.com",
+		"a@b@xn--mller-kva.de": "a@b@xn--mller-kva.de",
+	}
+
+	for address, expected := range valid {
+		encoded, err := envelopeAddress(address)
+		assert.NoError(t, err, address)
+		assert.Equal(t, expected, encoded)
+	}
+
+	for _, address := range []string{"", "john", "@doe.com", "john@", "john@-doe-.com"} {
+		_, err := envelopeAddress(address)
+		assert.Error(t, err, address)
+	}
+}
+
+func TestConsumer_IDNRecipient(t *testing.T) {
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+
+	msg := fakeMessage()
+	msg.Event["user"] = map[string]interface{}{"email": "user@müller.de"}
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "user@müller.de", sent.ToAddress)
+
+	msg.Event["user"] = map[string]interface{}{"email": "user@"}
+	assert.EqualError(t, c.handle(c.Config.Events[0], msg), "recipient: address \"user@\" is not valid")
+}
@@ -0,0 +1,174 @@
+package consumer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/openware/postmaster/pkg/eventapi"
+)
+
+// base64LineLength is the line length limit of RFC 2045 base64 encoding.
+const base64LineLength = 76
+
+// FetchTimeout bounds download of every URL attachment, when HTTPClient isn't set.
+const FetchTimeout = 10 * time.Second
+
+var defaultHTTPClient = &http.Client{Timeout: FetchTimeout}
+
+// payloadAttachments decodes files carried in "attachments" field of payload
+// and downloads the ones given by URL, see config.PayloadAttachments.
+// Failed downloads, which may succeed later, requeue message.
+func (c *Consumer) payloadAttachments(eventConf config.Event, payload eventapi.Event) ([]config.RenderedAttachment, error) {
+	conf := eventConf.PayloadAttachments
+	if conf == nil {
+		return nil, nil
+	}
+
+	decoded, err := conf.Decode(payload["attachments"])
+	if err != nil {
+		return nil, fmt.Errorf("attachments for %s: %s", eventConf.Key, err.Error())
+	}
+
+	var attachments []config.RenderedAttachment
+	var total int64
+	for i, attachment := range decoded {
+		if attachment.URL != nil {
+			var temporary bool
+			if temporary, err = c.fetch(conf, &attachment); err != nil {
+				err = fmt.Errorf("attachments for %s: attachment %d: %s", eventConf.Key, i, err.Error())
+				if temporary {
+					return nil, amqp.Requeue(err)
+				}
+				return nil, err
+			}
+		}
+
+		if total += int64(len(attachment.Body)); total > conf.TotalSizeLimit() {
+			return nil, fmt.Errorf("attachments for %s exceed %d bytes", eventConf.Key, conf.TotalSizeLimit())
+		}
+
+		attachments = append(attachments, config.RenderedAttachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Body:        attachment.Body,
+		})
+	}
+
+	return attachments, nil
+}
+
+// fetch downloads body of attachment from its URL, following redirects to
+// allowed hosts only. Content type of response is used, unless attachment has one.
+// It reports whether failure is temporary: network errors and 5xx or 429 responses.
+func (c *Consumer) fetch(conf *config.PayloadAttachments, attachment *config.PayloadAttachment) (bool, error) {
+	client := *defaultHTTPClient
+	if c.HTTPClient != nil {
+		client = *c.HTTPClient
+	}
+	var refused error
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			refused = errors.New("stopped after 10 redirects")
+		} else if !conf.AllowsURL(req.URL) {
+			refused = fmt.Errorf("redirect to host \"%s\" is not allowed", req.URL.Host)
+		}
+		return refused
+	}
+
+	resp, err := client.Get(attachment.URL.String())
+	if refused != nil {
+		return false, refused
+	}
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		temporary := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return temporary, fmt.Errorf("fetch %s: %s", attachment.URL.Redacted(), resp.Status)
+	}
+
+	if resp.ContentLength > conf.SizeLimit() {
+		return false, fmt.Errorf("attachment exceeds %d bytes", conf.SizeLimit())
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, conf.SizeLimit()+1))
+	if err != nil {
+		return true, err
+	}
+	if int64(len(body)) > conf.SizeLimit() {
+		return false, fmt.Errorf("attachment exceeds %d bytes", conf.SizeLimit())
+	}
+
+	if attachment.ContentType == "" {
+		contentType := resp.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		if attachment.ContentType, err = conf.ContentType(contentType); err != nil {
+			return false, err
+		}
+	}
+
+	attachment.Body = body
+	return false, nil
+}
+
+// attach wraps body of contentType into multipart/mixed with attachments following it.
+// Body is returned as is without attachments.
+func (c *Consumer) attach(contentType string, content []byte, attachments []config.RenderedAttachment) (string, []byte, error) {
+	if len(attachments) == 0 {
+		return contentType, content, nil
+	}
+
+	buff := new(bytes.Buffer)
+	writer, err := c.multipartWriter(buff)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := writeQuotedPrintable(writer, textproto.MIMEHeader{"Content-Type": {contentType}}, content); err != nil {
+		return "", nil, err
+	}
+
+	for _, attachment := range attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return "", nil, err
+		}
+
+		encoded := base64.StdEncoding.EncodeToString(attachment.Body)
+		for len(encoded) > 0 {
+			n := base64LineLength
+			if len(encoded) < n {
+				n = len(encoded)
+			}
+
+			if _, err := part.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+				return "", nil, err
+			}
+			encoded = encoded[n:]
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", nil, err
+	}
+
+	return "multipart/mixed; boundary=" + writer.Boundary(), buff.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+package consumer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttach(t *testing.T) {
+	t.Run("without attachments", func(t *testing.T) {
+		contentType, content, err := fakeConsumer(nil).attach("text/html", []byte("<p>Hi</p>"), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "text/html", contentType)
+		assert.Equal(t, "<p>Hi</p>", string(content))
+	})
+
+	t.Run("ics attachment", func(t *testing.T) {
+		ics := strings.Repeat("BEGIN:VCALENDAR\r\n", 10)
+		contentType, content, err := fakeConsumer(nil).attach("text/html", []byte("<p>Hi</p>"), []config.RenderedAttachment{
+			{Filename: "meetup 42.ics", ContentType: "text/calendar", Body: []byte(ics)},
+		})
+		assert.NoError(t, err)
+
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		assert.NoError(t, err)
+		assert.Equal(t, "multipart/mixed", mediaType)
+
+		reader := multipart.NewReader(bytes.NewReader(content), params["boundary"])
+
+		part, err := reader.NextPart()
+		assert.NoError(t, err)
+		assert.Equal(t, "text/html", part.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(part)
+		assert.Equal(t, "<p>Hi</p>", string(body))
+
+		part, err = reader.NextPart()
+		assert.NoError(t, err)
+		assert.Equal(t, "text/calendar", part.Header.Get("Content-Type"))
+		assert.Equal(t, "meetup 42.ics", part.FileName())
+
+		encoded, _ := ioutil.ReadAll(part)
+		for _, line := range strings.Split(strings.TrimSpace(string(encoded)), "\r\n") {
+			assert.True(t, len(line) <= base64LineLength)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.Replace(string(encoded), "\r\n", "", -1))
+		assert.NoError(t, err)
+		assert.Equal(t, ics, string(decoded))
+	})
+}
+
+func TestConsumer_Attachments(t *testing.T) {
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+	c.Config.Events[0].Attachments = []config.Attachment{{
+		Filename:    "{{ .record.id }}.ics",
+		ContentType: "text/calendar",
+		Template:    "UID:{{ .record.id }}",
+	}}
+
+	msg := fakeMessage()
+	msg.Event["record"] = map[string]interface{}{"id": "meetup-42"}
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.True(t, strings.HasPrefix(sent.ContentType, "multipart/mixed; boundary="))
+
+	content, _ := ioutil.ReadAll(sent.Reader)
+	assert.Contains(t, string(content), `filename=meetup-42.ics`)
+	assert.Contains(t, string(content), base64.StdEncoding.EncodeToString([]byte("UID:meetup-42")))
+}
+
+func TestConsumer_PayloadAttachments(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "https://evil.example.com/a.pdf", http.StatusFound)
+		case "/large.pdf":
+			w.Write([]byte(strings.Repeat("x", 64)))
+		default:
+			w.Header().Set("Content-Type", "application/pdf")
+			w.WriteHeader(status)
+			w.Write([]byte("%PDF-1.4 ticket"))
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+	WithHTTPClient(server.Client())(c)
+	c.Config.Events[0].PayloadAttachments = &config.PayloadAttachments{AllowedHosts: []string{host}, MaxSize: 32}
+
+	handle := func(attachments ...interface{}) error {
+		msg := fakeMessage()
+		msg.Event["attachments"] = attachments
+		return c.handle(c.Config.Events[0], msg)
+	}
+
+	t.Run("attaches content and url", func(t *testing.T) {
+		assert.NoError(t, handle(
+			map[string]interface{}{"filename": "invoice-42.pdf", "content": base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 invoice"))},
+			map[string]interface{}{"filename": "ticket.pdf", "url": server.URL + "/tickets/42.pdf"},
+		))
+
+		_, params, err := mime.ParseMediaType(sent.ContentType)
+		assert.NoError(t, err)
+		reader := multipart.NewReader(sent.Reader, params["boundary"])
+
+		_, err = reader.NextPart()
+		assert.NoError(t, err)
+
+		for _, expected := range []struct{ filename, body string }{
+			{"invoice-42.pdf", "%PDF-1.4 invoice"},
+			{"ticket.pdf", "%PDF-1.4 ticket"},
+		} {
+			part, err := reader.NextPart()
+			assert.NoError(t, err)
+			assert.Equal(t, "application/pdf", part.Header.Get("Content-Type"))
+			assert.Equal(t, expected.filename, part.FileName())
+
+			encoded, _ := ioutil.ReadAll(part)
+			decoded, err := base64.StdEncoding.DecodeString(strings.Replace(string(encoded), "\r\n", "", -1))
+			assert.NoError(t, err)
+			assert.Equal(t, expected.body, string(decoded))
+		}
+	})
+
+	t.Run("dead-letters invalid attachments", func(t *testing.T) {
+		err := handle(map[string]interface{}{"filename": "a.pdf", "url": "https://evil.example.com/a.pdf"})
+		assert.EqualError(t, err, "attachments for user.example: attachment 0: url host \"evil.example.com\" is not allowed")
+		assert.Equal(t, amqp.OutcomeDeadLetter, amqp.OutcomeOf(err))
+
+		err = handle(map[string]interface{}{"filename": "a.pdf", "url": server.URL + "/redirect"})
+		assert.Equal(t, amqp.OutcomeDeadLetter, amqp.OutcomeOf(err))
+		assert.Contains(t, err.Error(), "redirect to host \"evil.example.com\" is not allowed")
+
+		err = handle(map[string]interface{}{"filename": "large.pdf", "url": server.URL + "/large.pdf"})
+		assert.EqualError(t, err, "attachments for user.example: attachment 0: attachment exceeds 32 bytes")
+	})
+
+	t.Run("requeues failed downloads", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+		defer func() { status = http.StatusOK }()
+
+		err := handle(map[string]interface{}{"filename": "ticket.pdf", "url": server.URL + "/tickets/42.pdf"})
+		assert.Equal(t, amqp.OutcomeRequeue, amqp.OutcomeOf(err))
+		assert.Contains(t, err.Error(), "503 Service Unavailable")
+	})
+
+	t.Run("ignores attachments of events without opt in", func(t *testing.T) {
+		c.Config.Events[0].PayloadAttachments = nil
+		defer func() {
+			c.Config.Events[0].PayloadAttachments = &config.PayloadAttachments{AllowedHosts: []string{host}, MaxSize: 32}
+		}()
+
+		assert.NoError(t, handle(map[string]interface{}{"filename": "a.pdf", "url": "https://evil.example.com/a.pdf"}))
+		assert.False(t, strings.HasPrefix(sent.ContentType, "multipart/mixed"))
+	})
+}
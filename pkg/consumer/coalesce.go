@@ -0,0 +1,155 @@
+package consumer
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/openware/postmaster/pkg/eventapi"
+)
+
+// coalescer accumulates messages of event per recipient, until coalesce
+// window of the event closes, then sends them as one digest message.
+// Messages are held unsettled until their digest is.
+type coalescer struct {
+	send func(eventConf config.Event, msg amqp.Message) error
+	// Items are taken from data path of payloads of current config.
+	conf func() *config.Config
+
+	mu      sync.Mutex
+	pending map[coalesceKey]*batch
+	// flushing makes flushAll return once every digest is sent.
+	flushing sync.Mutex
+}
+
+type coalesceKey struct {
+	event     string
+	recipient string
+}
+
+type batch struct {
+	event config.Event
+	msgs  []amqp.Message
+	holds []*amqp.Hold
+	timer *time.Timer
+}
+
+// coalescer returns coalescer of consumer, creating it on first use.
+func (c *Consumer) coalescer() *coalescer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.batches == nil {
+		c.batches = &coalescer{send: c.sendCoalesced, conf: c.conf, pending: make(map[coalesceKey]*batch)}
+	}
+
+	return c.batches
+}
+
+// sendCoalesced sends digest of buffered messages, which are settled with its error.
+func (c *Consumer) sendCoalesced(eventConf config.Event, msg amqp.Message) error {
+	// Quiet hours were applied, when messages were buffered.
+	eventConf.Coalesce = ""
+	eventConf.QuietHours = nil
+
+	err := c.handle(eventConf, msg)
+	if err != nil {
+		log.Printf("coalesced messages of %s with correlation id %s failed: %s",
+			eventConf.Key, correlationID(msg), err.Error())
+	}
+
+	return err
+}
+
+// flushCoalesced sends every pending digest right away.
+func (c *Consumer) flushCoalesced() {
+	c.mu.Lock()
+	batches := c.batches
+	c.mu.Unlock()
+
+	if batches != nil {
+		batches.flushAll()
+	}
+}
+
+// add buffers msg for recipient, first message of recipient opens the window.
+// Returned hold is settled, once digest of msg is sent.
+func (q *coalescer) add(eventConf config.Event, recipient string, msg amqp.Message) *amqp.Hold {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := coalesceKey{eventConf.Key, strings.ToLower(recipient)}
+	b, ok := q.pending[key]
+	if !ok {
+		b = &batch{event: eventConf}
+		b.timer = time.AfterFunc(eventConf.CoalesceWindow(), func() { q.flush(key, b) })
+		q.pending[key] = b
+	}
+
+	hold := amqp.NewHold()
+	b.msgs = append(b.msgs, msg)
+	b.holds = append(b.holds, hold)
+	return hold
+}
+
+// flush sends b, unless it was already sent by flushAll.
+func (q *coalescer) flush(key coalesceKey, b *batch) {
+	q.mu.Lock()
+	if q.pending[key] != b {
+		q.mu.Unlock()
+		return
+	}
+	delete(q.pending, key)
+	q.mu.Unlock()
+
+	q.sendBatch(b)
+}
+
+func (q *coalescer) flushAll() {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+
+	q.mu.Lock()
+	batches := q.pending
+	q.pending = make(map[coalesceKey]*batch)
+	q.mu.Unlock()
+
+	for _, b := range batches {
+		b.timer.Stop()
+		q.sendBatch(b)
+	}
+}
+
+// sendBatch sends digest of b, settling its messages with the outcome.
+func (q *coalescer) sendBatch(b *batch) {
+	err := q.send(b.event, b.merge(&q.conf().AMQP))
+	for _, hold := range b.holds {
+		hold.Settle(err)
+	}
+}
+
+// merge returns the latest message of batch with data of all its messages
+// in "items" field of its data, so templates render them as digest entries.
+func (b *batch) merge(conf *config.AMQP) amqp.Message {
+	last := b.msgs[len(b.msgs)-1]
+
+	items := make([]interface{}, 0, len(b.msgs))
+	for _, msg := range b.msgs {
+		// Messages without data aren't buffered.
+		data, _ := conf.PayloadData(msg.Event)
+		items = append(items, data)
+	}
+
+	lastData, _ := conf.PayloadData(last.Event)
+	data := make(map[string]interface{}, len(lastData)+1)
+	for k, v := range lastData {
+		data[k] = v
+	}
+	data["items"] = items
+	last.Event = eventapi.Event(conf.WithPayloadData(last.Event, data))
+
+	return last
+}
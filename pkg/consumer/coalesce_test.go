@@ -0,0 +1,170 @@
+package consumer
+
+import (
+	"io/ioutil"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// released settles message held with err like ServeMux does,
+// passing error it's settled with to returned channel.
+func released(t *testing.T, err error) <-chan error {
+	settled := make(chan error, 1)
+
+	hold, ok := err.(*amqp.Hold)
+	if !assert.True(t, ok, "message is not held: %v", err) {
+		settled <- err
+		return settled
+	}
+
+	go hold.Release(func(err error) { settled <- err })
+	return settled
+}
+
+func TestConsumer_Coalesce(t *testing.T) {
+	var mu sync.Mutex
+	var sent []Email
+	var bodies []string
+	var failure error
+	done := make(chan struct{}, 10)
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		body, _ := ioutil.ReadAll(email.Reader)
+		mu.Lock()
+		sent = append(sent, email)
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		done <- struct{}{}
+		return failure
+	})
+
+	event := c.Config.Events[0]
+	event.Templates = map[string]config.Template{"EN": {
+		Subject:  "{{ len .items }} new messages",
+		Template: "{{ .user.email }}:{{ items }}",
+		Item:     " {{ .title }}",
+	}}
+
+	message := func(email, title string) amqp.Message {
+		msg := fakeMessage()
+		msg.Event["user"] = map[string]interface{}{"email": email}
+		msg.Event["title"] = title
+		return msg
+	}
+
+	reset := func() {
+		mu.Lock()
+		sent, bodies = nil, nil
+		mu.Unlock()
+		for len(done) > 0 {
+			<-done
+		}
+	}
+
+	t.Run("sends one digest per recipient, when window closes", func(t *testing.T) {
+		defer reset()
+		event.Coalesce = "1h"
+
+		var settled []<-chan error
+		for _, msg := range []amqp.Message{
+			message("john@doe.com", "one"),
+			message("jane@doe.com", "hi"),
+			message("John@doe.com", "two"),
+			message("john@doe.com", "three"),
+		} {
+			settled = append(settled, released(t, c.handle(event, msg)))
+		}
+		assert.Empty(t, sent)
+
+		c.flushCoalesced()
+
+		for _, result := range settled {
+			assert.NoError(t, <-result)
+		}
+		assert.Len(t, sent, 2)
+		assert.ElementsMatch(t, []string{"john@doe.com: one two three", "jane@doe.com: hi"}, bodies)
+		for _, email := range sent {
+			if email.ToAddress == "john@doe.com" {
+				assert.Equal(t, "3 new messages", email.Subject)
+			}
+		}
+
+		c.flushCoalesced()
+		assert.Len(t, sent, 2)
+	})
+
+	t.Run("flushes on timer", func(t *testing.T) {
+		defer reset()
+		event.Coalesce = "20ms"
+
+		first := released(t, c.handle(event, message("john@doe.com", "one")))
+		second := released(t, c.handle(event, message("john@doe.com", "two")))
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("coalesced messages were not sent")
+		}
+
+		assert.NoError(t, <-first)
+		assert.NoError(t, <-second)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"john@doe.com: one two"}, bodies)
+	})
+
+	t.Run("settles messages with failure of digest", func(t *testing.T) {
+		defer reset()
+		event.Coalesce = "1h"
+		failure = &textproto.Error{Code: 421, Msg: "try again later"}
+		defer func() { failure = nil }()
+
+		first := released(t, c.handle(event, message("john@doe.com", "one")))
+		second := released(t, c.handle(event, message("john@doe.com", "two")))
+		c.flushCoalesced()
+
+		for _, result := range []<-chan error{first, second} {
+			err := <-result
+			assert.True(t, amqp.IsRequeue(err), "not requeued: %v", err)
+		}
+	})
+
+	t.Run("sends events without window right away", func(t *testing.T) {
+		defer reset()
+		event.Coalesce = ""
+
+		msg := message("john@doe.com", "one")
+		msg.Event["items"] = []interface{}{map[string]interface{}{"title": "only"}}
+		assert.NoError(t, c.handle(event, msg))
+		assert.Equal(t, []string{"john@doe.com: only"}, bodies)
+	})
+}
+
+func TestCoalescer_currentConfig(t *testing.T) {
+	conf := &config.Config{}
+	var merged amqp.Message
+	q := &coalescer{
+		send: func(_ config.Event, msg amqp.Message) error {
+			merged = msg
+			return nil
+		},
+		conf:    func() *config.Config { return conf },
+		pending: make(map[coalesceKey]*batch),
+	}
+
+	event := config.Event{Key: "user.example", Coalesce: "1h"}
+	settled := released(t, q.add(event, "john@doe.com", fakeMessage()))
+
+	// Config is reloaded within the window.
+	conf = &config.Config{AMQP: config.AMQP{DataPath: "data"}}
+	q.flushAll()
+
+	assert.NoError(t, <-settled)
+	assert.Contains(t, merged.Event, "data")
+}
@@ -2,108 +2,891 @@ package consumer
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/go-yaml/yaml"
 	"github.com/openware/postmaster/internal/config"
 	"github.com/openware/postmaster/pkg/amqp"
 	"github.com/openware/postmaster/pkg/env"
 	"github.com/openware/postmaster/pkg/eventapi"
+	"github.com/openware/postmaster/pkg/suppression"
 )
 
-func amqpURI() string {
+// DeliveryResult describes a single send attempt.
+type DeliveryResult struct {
+	EventKey        string
+	CorrelationID   string
+	Recipient       string
+	TemplateVersion string
+	Delivered       bool
+	Err             error
+	Duration        time.Duration
+}
+
+// Consumer renders events into emails and sends them.
+type Consumer struct {
+	Config      *config.Config
+	SMTP        SMTPConf
+	FromAddress string
+	FromName    string
+
+	// Types decode events into registered structs before rendering.
+	// Events are rendered from untyped maps, when nil.
+	Types *eventapi.Registry
+
+	// OnDelivery is invoked after each send attempt, either successful or not.
+	// It runs on the worker goroutine, so it must return quickly:
+	// hand slow work (network calls, disk writes) off to another goroutine.
+	OnDelivery func(result DeliveryResult)
+
+	// LanguageResolver is consulted for events without language.
+	LanguageResolver LanguageResolver
+
+	// Metrics are sampled every MetricsInterval, when set.
+	Metrics *amqp.Metrics
+
+	// Monitor counts processed messages, when set, see MonitorHandler.
+	Monitor *Monitor
+
+	// Suppressions skip recipients, which bounced or complained, when set,
+	// see config.Config.Suppression.
+	Suppressions suppression.Store
+
+	// Senders deliver emails of events naming them, see config.Event.Sender.
+	// Emails of other events are sent through Delivery or SMTP.
+	Senders map[string]Sender
+
+	// Delivery sends emails of events without sender instead of SMTP, when set,
+	// see config.Config.Delivery.
+	Delivery Sender
+
+	// KafkaDialer connects to Kafka, when config has kafka section.
+	// No Kafka client is bundled, so it must be set to consume from Kafka.
+	KafkaDialer KafkaDialer
+
+	// HTTPClient downloads URL attachments of messages, see config.PayloadAttachments.
+	// Client with FetchTimeout is used, when nil.
+	HTTPClient *http.Client
+
+	// SendTimeout bounds every send, timed out messages are requeued.
+	// Zero disables the limit.
+	SendTimeout time.Duration
+
+	// WatchInterval makes Run reload config, when its file or template files
+	// changed since previous check, see Reload. Zero disables watching,
+	// config is reloaded on SIGHUP either way.
+	WatchInterval time.Duration
+
+	// MaxMessages makes ListenAndServe shut down gracefully and return
+	// once that many messages are processed, e.g. for cron runs. Zero runs forever.
+	MaxMessages int
+
+	// Boundary generates boundaries of multipart bodies, random ones are used when nil.
+	// It must return distinct boundary on every call, since multipart bodies nest,
+	// e.g. fixed sequence to compare output byte for byte in tests.
+	Boundary func() string
+
+	deliver func(context.Context, SMTPConf, Email) error
+
+	// secrets resolve secret credentials, see WithSecretResolver.
+	secrets SecretResolver
+	// amqpURI is broker URI with credentials resolved by NewConsumer.
+	amqpURI string
+
+	// active holds config replacing Config after Reload.
+	active atomic.Value
+
+	mu      sync.Mutex
+	sources []Source
+	limiter *domainLimiter
+	batches *coalescer
+
+	now func() time.Time
+}
+
+// DefaultSendTimeout is SendTimeout of consumers created with NewConsumer.
+const DefaultSendTimeout = 30 * time.Second
+
+// NewConsumer creates consumer with SMTP and sender settings taken from environment,
+// customized with options. Unset optional fields of conf are filled with defaults.
+// Named senders and delivery are created from config with passwords taken from environment,
+// SMTP settings aren't read with delivery.
+// SMTP senders sign emails, when config has DKIM.
+// Secret credentials, broker ones included, are resolved here, see WithSecretResolver,
+// so error names the credential, which can't be resolved, before anything is consumed.
+func NewConsumer(conf *Config, options ...Option) (*Consumer, error) {
+	probe := &Consumer{}
+	for _, option := range options {
+		option(probe)
+	}
+
+	var dkim *DKIMSigner
+	if conf.DKIM != nil {
+		signer, err := NewDKIMSigner(*conf.DKIM)
+		if err != nil {
+			return nil, err
+		}
+		dkim = signer
+	}
+
+	suppressions, err := openSuppressions(conf.Suppression)
+	if err != nil {
+		return nil, err
+	}
+
+	senders, err := configSenders(conf.Senders, dkim, probe.secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := env.Fetch("SENDER_EMAIL")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Consumer{
+		Config:       conf.WithDefaults(),
+		Suppressions: suppressions,
+		Senders:      senders,
+		FromAddress:  from,
+		FromName:     env.FetchDefault("SENDER_NAME", "postmaster"),
+		SendTimeout:  DefaultSendTimeout,
+		deliver: func(ctx context.Context, conf SMTPConf, email Email) error {
+			return NewEmailSender(conf, email).Send(ctx)
+		},
+	}
+
+	if !conf.AMQP.Disabled {
+		if c.amqpURI, err = amqpURI(probe.secrets); err != nil {
+			return nil, err
+		}
+	}
+
+	// SMTP server of environment isn't used with delivery.
+	if conf.Delivery != nil {
+		if c.Delivery, err = configSender("delivery", *conf.Delivery, dkim, probe.secrets); err != nil {
+			return nil, err
+		}
+	} else if c.SMTP, err = envSMTP(dkim, probe.secrets); err != nil {
+		return nil, err
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, nil
+}
+
+// envSMTP returns settings of SMTP server taken from environment.
+func envSMTP(dkim *DKIMSigner, secrets SecretResolver) (SMTPConf, error) {
+	password, err := env.Fetch("SMTP_PASSWORD")
+	if err != nil {
+		return SMTPConf{}, err
+	}
+
+	if password, err = credential(secrets, "SMTP_PASSWORD", password); err != nil {
+		return SMTPConf{}, err
+	}
+
+	username, err := credential(secrets, "SMTP_USER", env.FetchDefault("SMTP_USER", "apikey"))
+	if err != nil {
+		return SMTPConf{}, err
+	}
+
+	return SMTPConf{
+		Host:     env.FetchDefault("SMTP_HOST", "smtp.sendgrid.net"),
+		Port:     env.FetchDefault("SMTP_PORT", "25"),
+		Username: username,
+		Password: password,
+		DKIM:     dkim,
+	}, nil
+}
+
+func (c *Consumer) handle(eventConf config.Event, msg amqp.Message) (err error) {
+	event := msg.Event
+
+	label := eventConf.Name
+	if label == "" {
+		label = eventConf.Key
+	}
+	var language string
+	defer func() { c.Monitor.handled(label, language, err) }()
+
+	if eventConf.Disabled {
+		log.Printf("Ignoring disabled event \"%s\"\n", eventConf.Key)
+		return nil
+	}
+
+	id := correlationID(msg)
+	log.Printf("Processing event \"%s\" with correlation id %s\n", eventConf.Key, id)
+
+	usr, err := eventapi.Unmarshal(event)
+	if err != nil {
+		return err
+	}
+
+	if usr.Language, err = c.language(usr, event); err != nil {
+		return fmt.Errorf("resolve language: %s", err.Error())
+	}
+	requested := usr.Language
+	if usr.Language, err = c.conf().ResolveLanguage(requested); err != nil {
+		return err
+	}
+	if !strings.EqualFold(usr.Language, requested) {
+		log.Printf("Language \"%s\" of event \"%s\" is not configured, using %s\n", requested, eventConf.Key, usr.Language)
+	}
+	language = usr.Language
+
+	if c.conf().LanguageDisabled(usr.Language) {
+		log.Printf("Ignoring event \"%s\" in disabled language %s\n", eventConf.Key, usr.Language)
+		return nil
+	}
+
+	// Recipients sent separately are rejected one by one.
+	if _, err := envelopeAddress(usr.User.Email); err != nil && eventConf.PerRecipient == "" {
+		return fmt.Errorf("recipient: %s", err.Error())
+	}
+
+	payload, err := c.conf().AMQP.PayloadData(event)
+	if err != nil {
+		return fmt.Errorf("event \"%s\": %s", eventConf.Key, err.Error())
+	}
+
+	if wait := c.quietHours(eventConf, usr.Language, msg.Priority); wait > 0 {
+		return amqp.Defer(fmt.Errorf("event \"%s\" is deferred during quiet hours", eventConf.Key), wait)
+	}
+
+	if eventConf.CoalesceWindow() > 0 {
+		return c.coalescer().add(eventConf, usr.User.Email, msg)
+	}
+
+	// Routing fields above come from payload alone, templates get its data.
+	event = eventapi.Event(c.conf().RenderData(&eventConf, usr.Tenant, payload))
+
+	var data interface{} = event
+	if c.Types != nil {
+		if data, err = c.Types.Decode(eventConf.Key, event); err != nil {
+			return err
+		}
+	}
+
+	key := config.TemplateKey(usr.Language, usr.Segment)
+	tpl, version := c.conf().ResolveTemplate(&eventConf, usr.Tenant, usr.Language, usr.Segment, usr.User.Email)
+	files, err := c.payloadAttachments(eventConf, payload)
+	if err != nil {
+		return err
+	}
+
+	var r rendered
+	renderStart := time.Now()
+	err = c.withinRenderBudget(func() (err error) {
+		r, err = c.render(eventConf, tpl, key, usr.Language, data, event, usr.PreferredFormat, files)
+		return err
+	})
+	if err == nil {
+		c.Monitor.observeRender(label, language, time.Since(renderStart))
+	}
+	if err == ErrRenderTimeout {
+		// Rendering again would time out again, so message isn't requeued.
+		return amqp.Settle(amqp.OutcomeDeadLetter, fmt.Errorf("render %s/%s: %s", eventConf.Key, key, err.Error()))
+	}
+	if err != nil {
+		return err
+	}
+
+	// Provider renders hosted templates, so it's given data instead of body.
+	var providerData map[string]interface{}
+	if tpl.Hosted() {
+		providerData = event
+	}
+
+	renderCC := eventConf.RenderCC
+	if eventConf.PerRecipient != "" {
+		renderCC = eventConf.RenderCCUnchecked
+	}
+
+	cc, err := renderCC(data)
+	if err != nil {
+		return fmt.Errorf("cc for %s: %s", eventConf.Key, err.Error())
+	}
+
+	recipients := append([]string{usr.User.Email}, cc...)
+	if eventConf.PerRecipient != "" {
+		recipients = retryRecipients(msg.Headers, recipients)
+	}
+
+	// Guards against producers stuffing huge lists into one message.
+	if limit := c.conf().MaxRecipients; limit > 0 && len(recipients) > limit {
+		return fmt.Errorf("message of %s has %d recipients, at most %d allowed", eventConf.Key, len(recipients), limit)
+	}
+
+	for _, address := range recipients {
+		if eventConf.PerRecipient == "" && !eventConf.AllowsRecipient(address) {
+			return fmt.Errorf("recipient %s of %s is outside allowed domains", address, eventConf.Key)
+		}
+	}
+
+	if domain, wait := c.domainLimiter().wait(recipients, c.Monitor); domain == globalLimit {
+		return amqp.Defer(fmt.Errorf("sending is throttled, retrying in %s", wait), wait)
+	} else if wait > 0 {
+		return amqp.Defer(fmt.Errorf("recipient domain %s is throttled, retrying in %s", domain, wait), wait)
+	}
+
+	fromName := c.FromName
+	if name, ok := c.conf().FromName(usr.Language); ok {
+		fromName = name
+	}
+
+	fromAddress := c.FromAddress
+	identity, hasIdentity := c.conf().Identity(usr.Language)
+	if hasIdentity {
+		fromAddress = identity.From(fromAddress)
+	}
+
+	email := Email{
+		FromAddress: fromAddress,
+		FromName:    fromName,
+		ToAddress:   usr.User.Email,
+		CC:          cc,
+		Subject:     r.subject,
+		ContentType: r.contentType,
+		Headers:     passHeaders(msg.Headers, c.conf().AMQP.HeadersPrefix),
+		Reader:      bytes.NewReader(r.content),
+
+		ProviderTemplateID: tpl.ProviderTemplateID,
+		Data:               providerData,
+	}
+
+	for name, value := range eventConf.Headers {
+		email.Headers[name] = value
+	}
+
+	if hasIdentity && identity.ReplyTo != "" {
+		email.Headers["Reply-To"] = identity.ReplyTo
+	}
+
+	if eventConf.Rollout != nil {
+		email.Headers["X-Template-Version"] = version
+	}
+	email.Headers[CorrelationHeader] = id
+
+	send := func(email Email) error {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if c.SendTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, c.SendTimeout)
+		}
+		defer cancel()
+
+		email, skip, err := c.suppress(email)
+		if err != nil {
+			return amqp.Requeue(fmt.Errorf("suppression: %s", err.Error()))
+		}
+		if skip {
+			log.Printf("Skipping suppressed recipient %s of %s\n", email.ToAddress, eventConf.Key)
+			c.Monitor.skipped(label, language)
+			return nil
+		}
+
+		start := time.Now()
+		if eventConf.Sender != "" {
+			sender, ok := c.Senders[eventConf.Sender]
+			if !ok {
+				return fmt.Errorf("sender \"%s\" is not configured", eventConf.Sender)
+			}
+			err = sender.Send(ctx, email)
+		} else if c.Delivery != nil {
+			err = c.Delivery.Send(ctx, email)
+		} else {
+			err = c.deliver(ctx, c.SMTP, email)
+		}
+
+		if c.OnDelivery != nil {
+			c.OnDelivery(DeliveryResult{
+				EventKey:        eventConf.Key,
+				CorrelationID:   id,
+				Recipient:       email.ToAddress,
+				TemplateVersion: version,
+				Delivered:       err == nil,
+				Err:             err,
+				Duration:        time.Since(start),
+			})
+		}
+
+		if err == nil {
+			c.Monitor.delivered(label, language)
+		}
+
+		if err != nil && (ctx.Err() == context.DeadlineExceeded || temporarySMTP(err)) {
+			return amqp.Requeue(err)
+		}
+
+		return err
+	}
+
+	if eventConf.PerRecipient != "" {
+		// Retried copy of message names the recipients left, requeued one doesn't.
+		return sendEach(eventConf, email, r.content, recipients, c.conf().RetryOf(&eventConf) != nil, send)
+	}
+
+	return send(email)
+}
+
+// digestItems returns entries of digest carried in "items" field of event.
+func digestItems(event eventapi.Event) []interface{} {
+	items, _ := event["items"].([]interface{})
+	return items
+}
+
+// amqpURI builds broker URI from environment, resolving secret credentials through secrets.
+func amqpURI(secrets SecretResolver) (string, error) {
 	host := env.FetchDefault("RABBITMQ_HOST", "localhost")
 	port := env.FetchDefault("RABBITMQ_PORT", "5672")
-	username := env.FetchDefault("RABBITMQ_USERNAME", "guest")
-	password := env.FetchDefault("RABBITMQ_PASSWORD", "guest")
 
-	return fmt.Sprintf("amqp://%s:%s@%s:%s/", username, password, host, port)
-}
+	username, err := credential(secrets, "RABBITMQ_USERNAME", env.FetchDefault("RABBITMQ_USERNAME", "guest"))
+	if err != nil {
+		return "", err
+	}
 
-func validate(path string) {
-	file, err := os.Open(path)
+	password, err := credential(secrets, "RABBITMQ_PASSWORD", env.FetchDefault("RABBITMQ_PASSWORD", "guest"))
 	if err != nil {
-		log.Panic(err)
+		return "", err
 	}
 
-	if _, err := config.Validate(file); err != nil {
-		log.Panic(err)
+	// Resolved secrets may contain any characters, so they are escaped.
+	uri := url.URL{
+		Scheme: "amqp",
+		User:   url.UserPassword(username, password),
+		Host:   net.JoinHostPort(host, port),
+		Path:   "/",
+	}
+
+	return uri.String(), nil
+}
+
+// brokerURI returns broker URI resolved by NewConsumer, or the one of
+// environment for consumer created otherwise.
+func (c *Consumer) brokerURI() (string, error) {
+	if c.amqpURI != "" {
+		return c.amqpURI, nil
 	}
+
+	return amqpURI(c.secrets)
 }
 
-func requireEnvs() {
+// requireEnvs panics on missing environment, SMTP password isn't needed with delivery.
+func requireEnvs(conf *config.Config) {
 	env.Must(env.Fetch("JWT_PUBLIC_KEY"))
-	env.Must(env.Fetch("SMTP_PASSWORD"))
+	if conf.Delivery == nil {
+		env.Must(env.Fetch("SMTP_PASSWORD"))
+	}
 	env.Must(env.Fetch("SENDER_EMAIL"))
+	if s := conf.Suppression; s != nil && s.Addr != "" {
+		env.Must(env.Fetch(s.TokenEnv))
+	}
 }
 
-func Run(path string) {
-	conf := config.Config{}
+// MetricsInterval is how often consumer metrics are sampled.
+const MetricsInterval = 15 * time.Second
 
-	validate(path)
+// Option customizes consumer created by Run.
+type Option func(*Consumer)
 
-	content, err := ioutil.ReadFile(path)
+// WithDeliveryHook sets hook invoked after each send attempt, see Consumer.OnDelivery.
+func WithDeliveryHook(hook func(result DeliveryResult)) Option {
+	return func(c *Consumer) {
+		c.OnDelivery = hook
+	}
+}
+
+// WithTypes makes events decoded into types registered in registry, see Consumer.Types.
+func WithTypes(registry *eventapi.Registry) Option {
+	return func(c *Consumer) {
+		c.Types = registry
+	}
+}
+
+// WithLanguageResolver sets resolver of missing languages, see Consumer.LanguageResolver.
+func WithLanguageResolver(resolver LanguageResolver) Option {
+	return func(c *Consumer) {
+		c.LanguageResolver = resolver
+	}
+}
+
+// WithSender makes events naming sender deliver through it, see Consumer.Senders.
+// It replaces sender of the same name created from config.
+func WithSender(name string, sender Sender) Option {
+	return func(c *Consumer) {
+		if c.Senders == nil {
+			c.Senders = make(map[string]Sender)
+		}
+		c.Senders[name] = sender
+	}
+}
+
+// WithDelivery makes events without sender deliver through sender instead of SMTP,
+// see Consumer.Delivery.
+func WithDelivery(sender Sender) Option {
+	return func(c *Consumer) {
+		c.Delivery = sender
+	}
+}
+
+// WithSendTimeout sets limit of every send, see Consumer.SendTimeout.
+func WithSendTimeout(timeout time.Duration) Option {
+	return func(c *Consumer) {
+		c.SendTimeout = timeout
+	}
+}
+
+// WithBoundary sets generator of multipart boundaries, see Consumer.Boundary.
+func WithBoundary(boundary func() string) Option {
+	return func(c *Consumer) {
+		c.Boundary = boundary
+	}
+}
+
+// WithMetrics makes consumer sample metrics, see Consumer.Metrics.
+func WithMetrics(metrics *amqp.Metrics) Option {
+	return func(c *Consumer) {
+		c.Metrics = metrics
+	}
+}
+
+// WithMonitor makes consumer count processed messages, see Consumer.Monitor.
+func WithMonitor(monitor *Monitor) Option {
+	return func(c *Consumer) {
+		c.Monitor = monitor
+	}
+}
+
+// WithSuppressions makes consumer skip recipients suppressed in store, see Consumer.Suppressions.
+func WithSuppressions(store suppression.Store) Option {
+	return func(c *Consumer) {
+		c.Suppressions = store
+	}
+}
+
+// WithKafkaDialer makes consumer read Kafka with dialed reader, see Consumer.KafkaDialer.
+func WithKafkaDialer(dialer KafkaDialer) Option {
+	return func(c *Consumer) {
+		c.KafkaDialer = dialer
+	}
+}
+
+// WithHTTPClient makes consumer download URL attachments with client, see Consumer.HTTPClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Consumer) {
+		c.HTTPClient = client
+	}
+}
+
+// WithWatchInterval makes Run watch config for changes, see Consumer.WatchInterval.
+func WithWatchInterval(interval time.Duration) Option {
+	return func(c *Consumer) {
+		c.WatchInterval = interval
+	}
+}
+
+// WithMaxMessages makes consumer stop after n messages, see Consumer.MaxMessages.
+func WithMaxMessages(n int) Option {
+	return func(c *Consumer) {
+		c.MaxMessages = n
+	}
+}
+
+// ShutdownTimeout limits how long Run drains messages after SIGINT or SIGTERM.
+const ShutdownTimeout = 30 * time.Second
+
+// ListenAndServe consumes configured events from AMQP and Kafka, when configured,
+// until failure or Shutdown, or until MaxMessages are processed.
+func (c *Consumer) ListenAndServe() error {
+	if c.MaxMessages < 0 {
+		return fmt.Errorf("max messages %d should not be negative", c.MaxMessages)
+	}
+
+	// Messages delivered beyond the limit are requeued, which auto ack can't.
+	if c.MaxMessages > 0 && c.conf().WithDefaults().AMQP.AckMode == config.AckModeAuto {
+		return errors.New("max messages need manual ack mode")
+	}
+
+	sources, err := c.newSources()
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	if err := yaml.Unmarshal([]byte(content), &conf); err != nil {
-		log.Panic(err)
+	c.mu.Lock()
+	c.sources = sources
+	c.mu.Unlock()
+
+	if err := listenAndServe(sources); err != nil {
+		return err
 	}
 
-	requireEnvs()
+	// Mux stops by itself once MaxMessages are processed.
+	c.flushCoalesced()
+	return nil
+}
 
-	serveMux := amqp.NewServeMux(amqpURI(), conf.AMQP.Tag, conf.AMQP.Exchange)
-	for id := range conf.Events {
-		eventConf := conf.Events[id]
-		serveMux.HandleFunc(eventConf.Key, func(event eventapi.Event) {
-			log.Printf("Processing event \"%s\n", eventConf.Key)
+// Replay re-renders and sends up to limit dead-lettered messages with current
+// config, all of them when limit isn't positive. Messages failing again are
+// dead-lettered again. Config must set amqp.dead_letter_exchange.
+func (c *Consumer) Replay(ctx context.Context, limit int) error {
+	if c.conf().AMQP.Disabled {
+		return errors.New("replay needs amqp, which is disabled")
+	}
 
-			usr, err := eventapi.Unmarshal(event)
-			if err != nil {
-				log.Println(err)
-				return
-			}
+	serveMux, err := c.newServeMux()
+	if err != nil {
+		return err
+	}
 
-			// Check, that language is supported.
-			if !conf.ContainsLanguage(usr.Language) {
-				log.Printf("language %s is not supported", usr.Language)
-				return
-			}
+	_, err = serveMux.Replay(ctx, limit)
+	c.flushCoalesced()
+	return err
+}
 
-			tpl := eventConf.Template(usr.Language)
-			content, err := tpl.Content(event)
-			if err != nil {
-				log.Println(err)
-				return
-			}
+// newServeMux creates mux handling configured events with the consumer.
+func (c *Consumer) newServeMux() (*amqp.ServeMux, error) {
+	conf := c.conf().WithDefaults()
+	uri, err := c.brokerURI()
+	if err != nil {
+		return nil, err
+	}
 
-			email := Email{
-				FromAddress: env.Must(env.Fetch("SENDER_EMAIL")),
-				FromName:    env.FetchDefault("SENDER_NAME", "postmaster"),
-				ToAddress:   usr.User.Email,
-				Subject:     tpl.Subject,
-				Reader:      bytes.NewReader(content),
-			}
+	serveMux := amqp.NewServeMux(uri, conf.AMQP.Tag, conf.AMQP.Exchange)
+	serveMux.SetDeadLetterExchange(conf.AMQP.DeadLetterExchange)
+	serveMux.SetConcurrency(conf.AMQP.Concurrency)
+	serveMux.SetAutoAck(conf.AMQP.AckMode == config.AckModeAuto)
+	if decoder, ok := eventapi.PayloadDecoderFor(conf.AMQP.PayloadFormat); ok {
+		serveMux.SetPayloadDecoder(decoder)
+	}
+	if c.Metrics != nil {
+		serveMux.SetMetrics(c.Metrics, conf.AMQP.Queue, MetricsInterval)
+	}
+	if conf.AMQP.Retry != nil {
+		serveMux.SetRetryExchange(conf.AMQP.Retry.Exchange)
+	}
 
-			password := env.Must(env.Fetch("SMTP_PASSWORD"))
-			conf := SMTPConf{
-				Host:     env.FetchDefault("SMTP_HOST", "smtp.sendgrid.net"),
-				Port:     env.FetchDefault("SMTP_PORT", "25"),
-				Username: env.FetchDefault("SMTP_USER", "apikey"),
-				Password: password,
-			}
+	if err := c.handleEvents(serveMux); err != nil {
+		return nil, err
+	}
+
+	for id := range c.conf().Events {
+		eventConf := c.conf().Events[id]
+		if eventConf.Prefetch > 0 {
+			serveMux.SetPrefetch(eventConf.Key, eventConf.Prefetch)
+		}
+		if retry := c.conf().RetryOf(&eventConf); retry != nil {
+			initial, max := retry.Delays()
+			serveMux.SetRetry(eventConf.Key, amqp.Retry{
+				MaxAttempts: retry.MaxAttempts,
+				Backoff:     amqp.Backoff{Initial: initial, Max: max},
+			})
+		}
+	}
+
+	return serveMux, nil
+}
+
+// handleEvents registers handlers of configured events on source.
+func (c *Consumer) handleEvents(source Source) error {
+	// Types are registered in code, so templates are checked against them before serving.
+	if c.Types != nil {
+		if err := c.conf().CheckFields(c.Types.Type); err != nil {
+			return err
+		}
+	}
+
+	for id := range c.conf().Events {
+		eventConf := c.conf().Events[id]
+		if _, ok := c.Senders[eventConf.Sender]; eventConf.Sender != "" && !ok {
+			return fmt.Errorf("sender \"%s\" of event \"%s\" is not configured", eventConf.Sender, eventConf.Key)
+		}
 
-			if err := NewEmailSender(conf, email).Send(); err != nil {
-				log.Println(err)
+		key := eventConf.Key
+		source.HandleFunc(key, func(msg amqp.Message) error {
+			// Event is looked up on every message, since config may be reloaded.
+			eventConf, ok := eventOf(c.conf(), key)
+			if !ok {
+				return fmt.Errorf("event \"%s\" is not configured", key)
 			}
+			return c.handle(eventConf, msg)
 		})
 	}
 
-	if err := serveMux.ListenAndServe(); err != nil {
+	return nil
+}
+
+// Shutdown stops consuming and drains received messages, highest priority first,
+// until ctx is done, see amqp.ServeMux.Shutdown. Pending digests of coalesced
+// events are sent afterwards.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	sources := c.sources
+	c.mu.Unlock()
+
+	if sources == nil {
+		return errors.New("consumer is not serving")
+	}
+
+	var err error
+	for _, source := range sources {
+		if shutdownErr := source.Shutdown(ctx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	}
+	c.flushCoalesced()
+	return err
+}
+
+// Config is configuration of consumer, see LoadConfig.
+type Config = config.Config
+
+// LoadOptions relax or tighten loading of config, e.g. Lenient ignores unknown fields.
+type LoadOptions = config.LoadOptions
+
+// LoadConfig reads and validates configuration file, logging its warnings.
+func LoadConfig(path string, opts LoadOptions) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	conf, warnings, err := config.Load(file, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, warning := range warnings {
+		log.Printf("config warning: %s", warning)
+	}
+
+	return conf, nil
+}
+
+// withOptions makes opts accept config sections, which options serve:
+// the kafka one, when a Kafka client is plugged in.
+func withOptions(opts LoadOptions, options []Option) LoadOptions {
+	probe := &Consumer{}
+	for _, option := range options {
+		option(probe)
+	}
+
+	opts.Kafka = opts.Kafka || probe.KafkaDialer != nil
+	return opts
+}
+
+// ValidateConfig loads config at path, logging its warnings. Error of invalid
+// config is prefixed with path and line it's about, when it's known, see config.Locate.
+func ValidateConfig(path string, opts LoadOptions) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := LoadConfig(path, opts); err != nil {
+		if line := config.Locate(content, err); line != 0 {
+			return fmt.Errorf("%s:%d: %s", path, line, err.Error())
+		}
+		return fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return nil
+}
+
+func Run(path string, opts LoadOptions, options ...Option) {
+	opts = withOptions(opts, options)
+	conf, err := LoadConfig(path, opts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	requireEnvs(conf)
+
+	consumer, err := NewConsumer(conf, options...)
+	if err != nil {
 		log.Panic(err)
 	}
+
+	if conf.Monitoring != nil {
+		if consumer.Monitor == nil {
+			consumer.Monitor = NewMonitor()
+		}
+		if consumer.Metrics == nil {
+			consumer.Metrics = amqp.NewMetrics()
+		}
+
+		go func() {
+			log.Panic(http.ListenAndServe(conf.Monitoring.Addr, consumer.MonitorHandler()))
+		}()
+	}
+
+	if s := conf.Suppression; s != nil && s.Addr != "" && consumer.Suppressions != nil {
+		handler := suppression.NewHandler(consumer.Suppressions, env.Must(env.Fetch(s.TokenEnv)))
+
+		go func() {
+			log.Panic(http.ListenAndServe(s.Addr, handler))
+		}()
+	}
+
+	go func() {
+		hangups := make(chan os.Signal, 1)
+		signal.Notify(hangups, syscall.SIGHUP)
+		for range hangups {
+			log.Printf("received SIGHUP, reloading config")
+			consumer.reloadFile(path, opts)
+		}
+	}()
+
+	if consumer.WatchInterval > 0 {
+		go consumer.watch(path, opts, consumer.WatchInterval)
+	}
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		log.Printf("received %s, shutting down", <-signals)
+
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := consumer.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %s", err.Error())
+		}
+	}()
+
+	if err := consumer.ListenAndServe(); err != nil {
+		log.Panic(err)
+	}
+}
+
+// Replay loads config and replays dead-lettered messages, see Consumer.Replay.
+func Replay(path string, opts LoadOptions, limit int, options ...Option) error {
+	conf, err := LoadConfig(path, withOptions(opts, options))
+	if err != nil {
+		return err
+	}
+
+	requireEnvs(conf)
+
+	consumer, err := NewConsumer(conf, options...)
+	if err != nil {
+		return err
+	}
+
+	return consumer.Replay(context.Background(), limit)
 }
@@ -0,0 +1,731 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/openware/postmaster/pkg/eventapi"
+	"github.com/openware/postmaster/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeConfig() *config.Config {
+	return (&config.Config{
+		Languages: []config.Language{
+			{Code: "EN", Name: "English"},
+		},
+		Events: []config.Event{
+			{
+				Name: "Example",
+				Key:  "user.example",
+				Templates: map[string]config.Template{
+					"EN": {
+						Subject:  "Example",
+						Template: "Hello {{ .user.email }}",
+					},
+				},
+			},
+		},
+	}).WithDefaults()
+}
+
+func fakeMessage() amqp.Message {
+	return amqp.Message{
+		Event: eventapi.Event{
+			"language": "EN",
+			"user": map[string]interface{}{
+				"email": "john@doe.com",
+			},
+		},
+	}
+}
+
+func fakeConsumer(deliver func(SMTPConf, Email) error) *Consumer {
+	return &Consumer{
+		Config:      fakeConfig(),
+		FromAddress: "test@postmaster.com",
+		FromName:    "Postmaster",
+		deliver: func(_ context.Context, conf SMTPConf, email Email) error {
+			return deliver(conf, email)
+		},
+	}
+}
+
+// recordSMTP delivers emails through EmailSender with fake SMTP transport.
+func recordSMTP(errToReturn error) (func(SMTPConf, Email) error, *emailRecorder) {
+	send, req := mockSend(errToReturn)
+	return func(conf SMTPConf, email Email) error {
+		conf.Password = "secret"
+		sender := NewEmailSender(conf, email)
+		sender.send = send
+		sender.tplPath = "../../templates/email.tpl"
+		return sender.Send(context.Background())
+	}, req
+}
+
+func TestConsumer_OnDelivery(t *testing.T) {
+	t.Run("successful send", func(t *testing.T) {
+		var body []byte
+		c := fakeConsumer(func(_ SMTPConf, email Email) error {
+			body, _ = ioutil.ReadAll(email.Reader)
+			return nil
+		})
+
+		var results []DeliveryResult
+		c.OnDelivery = func(result DeliveryResult) {
+			results = append(results, result)
+		}
+
+		err := c.handle(c.Config.Events[0], fakeMessage())
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello john@doe.com", string(body))
+		assert.Len(t, results, 1)
+		assert.Equal(t, "user.example", results[0].EventKey)
+		assert.Equal(t, "john@doe.com", results[0].Recipient)
+		assert.Equal(t, config.BaseVersion, results[0].TemplateVersion)
+		assert.True(t, results[0].Delivered)
+		assert.NoError(t, results[0].Err)
+	})
+
+	t.Run("failed send", func(t *testing.T) {
+		sendErr := errors.New("connection refused")
+		c := fakeConsumer(func(SMTPConf, Email) error {
+			return sendErr
+		})
+
+		var results []DeliveryResult
+		c.OnDelivery = func(result DeliveryResult) {
+			results = append(results, result)
+		}
+
+		err := c.handle(c.Config.Events[0], fakeMessage())
+
+		assert.Equal(t, sendErr, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, "john@doe.com", results[0].Recipient)
+		assert.False(t, results[0].Delivered)
+		assert.Equal(t, sendErr, results[0].Err)
+	})
+
+	t.Run("not invoked without send attempt", func(t *testing.T) {
+		c := fakeConsumer(func(SMTPConf, Email) error {
+			return nil
+		})
+
+		called := false
+		c.OnDelivery = func(DeliveryResult) {
+			called = true
+		}
+
+		msg := fakeMessage()
+		msg.Event["user"] = map[string]interface{}{"email": "user@"}
+
+		assert.Error(t, c.handle(c.Config.Events[0], msg))
+		assert.False(t, called)
+	})
+}
+
+func TestWithDeliveryHook(t *testing.T) {
+	c := fakeConsumer(func(SMTPConf, Email) error {
+		return nil
+	})
+
+	var result DeliveryResult
+	WithDeliveryHook(func(r DeliveryResult) {
+		result = r
+	})(c)
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.Equal(t, "john@doe.com", result.Recipient)
+}
+
+func TestConsumer_Rollout(t *testing.T) {
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+
+	c.Config.Events[0].Rollout = &config.Rollout{
+		Version: "v2",
+		Percent: 100,
+		Templates: map[string]config.Template{
+			"EN": {Subject: "New example", Template: "Hi"},
+		},
+	}
+
+	var result DeliveryResult
+	c.OnDelivery = func(r DeliveryResult) {
+		result = r
+	}
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.Equal(t, "New example", sent.Subject)
+	assert.Equal(t, "v2", sent.Headers["X-Template-Version"])
+	assert.Equal(t, "v2", result.TemplateVersion)
+}
+
+func TestConsumer_FromName(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.Languages = append(c.Config.Languages, config.Language{Code: "DE", Name: "German"})
+	c.Config.Events[0].Templates["DE"] = config.Template{Subject: "Beispiel", Template: "Hallo"}
+	c.Config.FromNames = map[string]string{"EN": "Support Team", "DE": "Support-Team"}
+
+	msg := fakeMessage()
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "From: \"Support Team\" <test@postmaster.com>")
+
+	msg.Event["language"] = "DE"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "From: \"Support-Team\" <test@postmaster.com>")
+
+	c.Config.FromNames = nil
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "From: \"Postmaster\" <test@postmaster.com>")
+}
+
+func TestConsumer_EncodedHeaders(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Grüße", Template: "Hi"}
+
+	t.Run("non-ASCII subject and from name", func(t *testing.T) {
+		c.FromName = "Équipe"
+		defer func() { c.FromName = "Postmaster" }()
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.Contains(t, string(req.msg), "From: =?utf-8?q?=C3=89quipe?= <test@postmaster.com>\n")
+		assert.Contains(t, string(req.msg), "Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\n")
+	})
+
+	t.Run("from name can't break out of header", func(t *testing.T) {
+		c.FromName = "Support\" <spy@evil.com>\r\nBcc: spy@evil.com"
+		defer func() { c.FromName = "Postmaster" }()
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.NotContains(t, string(req.msg), "\r\nBcc:")
+		assert.NotContains(t, string(req.msg), "\" <spy@evil.com>")
+	})
+}
+
+func TestConsumer_SubjectLineBreak(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Order {{ .order }}", Template: "Hi"}
+
+	msg := fakeMessage()
+	msg.Event["order"] = "42\r\nBcc: victim@example.com"
+
+	assert.EqualError(t, c.handle(c.Config.Events[0], msg),
+		"subject for user.example/EN: rendered subject contains line break")
+	assert.Nil(t, req.msg)
+}
+
+func TestConsumer_AllowedDomains(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.Events[0].AllowedDomains = []string{"doe.com"}
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.NotNil(t, req.msg)
+
+	req.msg = nil
+	msg := fakeMessage()
+	msg.Event["user"] = map[string]interface{}{"email": "john@gmail.com"}
+	assert.EqualError(t, c.handle(c.Config.Events[0], msg), "recipient john@gmail.com of user.example is outside allowed domains")
+	assert.Nil(t, req.msg)
+
+	c.Config.Events[0].CC = []string{"audit@external.io"}
+	assert.EqualError(t, c.handle(c.Config.Events[0], fakeMessage()), "recipient audit@external.io of user.example is outside allowed domains")
+	assert.Nil(t, req.msg)
+}
+
+func TestConsumer_Identity(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.Languages = append(c.Config.Languages,
+		config.Language{Code: "DE", Name: "German", Sender: &config.Identity{
+			FromAddress: "kontakt@postmaster.de",
+			ReplyTo:     "hilfe@postmaster.de",
+		}},
+		config.Language{Code: "FR", Name: "French", Sender: &config.Identity{Domain: "postmaster.fr"}},
+	)
+	c.Config.Events[0].Templates["DE"] = config.Template{Subject: "Beispiel", Template: "Hallo"}
+	c.Config.Events[0].Templates["FR"] = config.Template{Subject: "Exemple", Template: "Bonjour"}
+
+	msg := fakeMessage()
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "From: \"Postmaster\" <test@postmaster.com>")
+	assert.NotContains(t, string(req.msg), "Reply-To")
+	assert.Equal(t, "test@postmaster.com", req.from)
+
+	msg.Event["language"] = "DE"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "From: \"Postmaster\" <kontakt@postmaster.de>")
+	assert.Contains(t, string(req.msg), "Reply-To: hilfe@postmaster.de\n")
+	assert.Equal(t, "kontakt@postmaster.de", req.from)
+
+	msg.Event["language"] = "FR"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "From: \"Postmaster\" <test@postmaster.fr>")
+	assert.NotContains(t, string(req.msg), "Reply-To")
+}
+
+func TestConsumer_RenderData(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.Data = map[string]interface{}{"company": map[interface{}]interface{}{"name": "Postmaster", "city": "Kyiv"}}
+	c.Config.TenantData = map[string]map[string]interface{}{"acme": {"company": map[interface{}]interface{}{"name": "Acme"}}}
+	c.Config.Events[0].Data = map[string]interface{}{"greeting": "Hello"}
+	c.Config.Events[0].Templates["EN"] = config.Template{
+		Subject:  "{{ .company.name }}",
+		Template: "{{ .greeting }} from {{ .company.name }}, {{ .company.city }}",
+	}
+
+	msg := fakeMessage()
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "Subject: Postmaster")
+	assert.Contains(t, string(req.msg), "Hello from Postmaster, Kyiv")
+
+	msg.Event["tenant"] = "acme"
+	msg.Event["greeting"] = "Hi"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "Subject: Acme")
+	assert.Contains(t, string(req.msg), "Hi from Acme, Kyiv")
+}
+
+func TestConsumer_DataPath(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.AMQP.DataPath = "data"
+	c.Config.Events[0].Templates["EN"] = config.Template{
+		Subject:  "Invoice {{ .invoice.number }}",
+		Template: "Hi {{ .name }}{{ if .meta }}, meta leaked{{ end }}",
+	}
+
+	enveloped := func() amqp.Message {
+		msg := fakeMessage()
+		msg.Event["meta"] = map[string]interface{}{"source": "billing"}
+		msg.Event["data"] = map[string]interface{}{
+			"name":    "John",
+			"invoice": map[string]interface{}{"number": "42"},
+		}
+		return msg
+	}
+
+	t.Run("renders nested object", func(t *testing.T) {
+		assert.NoError(t, c.handle(c.Config.Events[0], enveloped()))
+		assert.Equal(t, []string{"john@doe.com"}, req.to)
+		assert.Contains(t, string(req.msg), "Subject: Invoice 42")
+		assert.True(t, strings.HasSuffix(string(req.msg), "\r\n\r\nHi John"))
+	})
+
+	t.Run("rejects message without data", func(t *testing.T) {
+		req.msg = nil
+		assert.EqualError(t, c.handle(c.Config.Events[0], fakeMessage()),
+			"event \"user.example\": payload has no object at data path \"data\"")
+		assert.Nil(t, req.msg)
+	})
+
+	t.Run("coalesces data of messages", func(t *testing.T) {
+		event := c.Config.Events[0]
+		event.Coalesce = "1h"
+		event.Templates = map[string]config.Template{"EN": {
+			Subject:  "{{ len .items }} invoices",
+			Template: "{{ .name }}:{{ items }}",
+			Item:     " {{ .invoice.number }}",
+		}}
+
+		second := enveloped()
+		second.Event["data"] = map[string]interface{}{"name": "Johnny", "invoice": map[string]interface{}{"number": "43"}}
+
+		first := released(t, c.handle(event, enveloped()))
+		last := released(t, c.handle(event, second))
+		c.flushCoalesced()
+		assert.NoError(t, <-first)
+		assert.NoError(t, <-last)
+
+		assert.Contains(t, string(req.msg), "Subject: 2 invoices")
+		assert.Contains(t, string(req.msg), "Johnny: 42 43")
+	})
+}
+
+func TestConsumer_Types(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.Events[0].Templates["EN"] = config.Template{
+		Subject:  "Confirm",
+		Template: "{{ .EmailConfirmationURI }}",
+	}
+
+	registry := eventapi.NewRegistry()
+	registry.Register("user.example", func() interface{} {
+		return new(EmailConfirmationEvent)
+	})
+	WithTypes(registry)(c)
+
+	t.Run("renders registered struct", func(t *testing.T) {
+		msg := fakeMessage()
+		msg.Event["token"] = "ixj717iex"
+
+		assert.NoError(t, c.handle(c.Config.Events[0], msg))
+		assert.Contains(t, string(req.msg), "ixj717iex")
+	})
+
+	t.Run("rejects malformed payload before render", func(t *testing.T) {
+		*req = emailRecorder{}
+		msg := fakeMessage()
+		msg.Event["token"] = map[string]interface{}{"value": "ixj717iex"}
+
+		assert.Error(t, c.handle(c.Config.Events[0], msg))
+		assert.Nil(t, req.msg)
+	})
+
+	t.Run("checks templates against registered struct before serving", func(t *testing.T) {
+		_, err := c.newServeMux()
+		assert.NoError(t, err)
+
+		c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Confirm {{ .Email }}", Template: "{{ .EmailConfirmationURI }}"}
+		defer func() {
+			c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Confirm", Template: "{{ .EmailConfirmationURI }}"}
+		}()
+
+		_, err = c.newServeMux()
+		assert.EqualError(t, err, "event \"user.example\" template EN: subject references undeclared field .Email")
+	})
+}
+
+func TestConsumer_MissingTemplateFile(t *testing.T) {
+	c := fakeConsumer(func(SMTPConf, Email) error {
+		return nil
+	})
+	c.Config.Events[0].Templates["EN"] = config.Template{
+		Subject:      "Example",
+		TemplatePath: "../../templates/en/deleted.tpl",
+	}
+
+	err := c.handle(c.Config.Events[0], fakeMessage())
+	assert.EqualError(t, err, `template for user.example/EN: file "../../templates/en/deleted.tpl" not found`)
+}
+
+func TestConsumer_StaticHeaders(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.AMQP.HeadersPrefix = "X-"
+	c.Config.Events[0].Headers = map[string]string{
+		"Precedence":     "bulk",
+		"Auto-Submitted": "auto-generated",
+	}
+
+	msg := fakeMessage()
+	msg.Headers = map[string]interface{}{"X-Campaign-ID": "spring"}
+
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "Auto-Submitted: auto-generated\nPrecedence: bulk\nX-Campaign-ID: spring\n")
+}
+
+func TestConsumer_Segment(t *testing.T) {
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+	c.Config.Events[0].Templates["EN:premium"] = config.Template{Subject: "Upgrade to Pro+", Template: "Hi"}
+
+	msg := fakeMessage()
+	msg.Event["segment"] = "premium"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "Upgrade to Pro+", sent.Subject)
+
+	msg.Event["segment"] = "free"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "Example", sent.Subject)
+}
+
+func TestConsumer_FallbackLanguage(t *testing.T) {
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+	c.Config.Languages = append(c.Config.Languages,
+		config.Language{Code: "PT", Name: "Portuguese", Fallback: "EN"},
+		config.Language{Code: "PT-BR", Name: "Brazilian Portuguese", Fallback: "PT"},
+	)
+
+	msg := fakeMessage()
+	msg.Event["language"] = "PT-BR"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "Example", sent.Subject)
+
+	c.Config.Events[0].Templates["PT"] = config.Template{Subject: "Exemplo", Template: "Olá"}
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "Exemplo", sent.Subject)
+}
+
+func TestConsumer_Catalog(t *testing.T) {
+	var body []byte
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+	c.Config.Languages = append(c.Config.Languages, config.Language{Code: "UK", Name: "Ukrainian"})
+	c.Config.Catalogs = map[string]map[string]string{
+		"EN": {"cta.upgrade": "Upgrade", "subject": "Your plan"},
+		"UK": {"cta.upgrade": "Оновити"},
+	}
+	shared := config.Template{Subject: "{{ t \"subject\" }}", Template: "<button>{{ t \"cta.upgrade\" }}</button>"}
+	c.Config.Events[0].Templates = map[string]config.Template{"EN": shared, "UK": shared}
+
+	msg := fakeMessage()
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "<button>Upgrade</button>", string(body))
+	assert.Equal(t, "Your plan", sent.Subject)
+
+	msg.Event["language"] = "UK"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "<button>Оновити</button>", string(body))
+	assert.Equal(t, "Your plan", sent.Subject)
+}
+
+func TestConsumer_Tenant(t *testing.T) {
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+	c.Config.Events[0].Tenants = map[string]map[string]config.Template{
+		"acme": {"EN": {Subject: "Acme example", Template: "Hi"}},
+	}
+
+	msg := fakeMessage()
+	msg.Event["tenant"] = "acme"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "Acme example", sent.Subject)
+
+	msg.Event["tenant"] = "globex"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "Example", sent.Subject)
+}
+
+func TestConsumer_Disabled(t *testing.T) {
+	sent := false
+	c := fakeConsumer(func(SMTPConf, Email) error {
+		sent = true
+		return nil
+	})
+
+	t.Run("drops messages of disabled event", func(t *testing.T) {
+		c.Config.Events[0].Disabled = true
+		defer func() { c.Config.Events[0].Disabled = false }()
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.False(t, sent)
+	})
+
+	t.Run("drops messages in disabled language", func(t *testing.T) {
+		c.Config.Languages[0].Disabled = true
+		defer func() { c.Config.Languages[0].Disabled = false }()
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.False(t, sent)
+	})
+}
+
+func TestConsumer_Defaults(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+
+	msg := fakeMessage()
+	delete(msg.Event, "language")
+
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Contains(t, string(req.msg), "Content-type: text/html; charset=iso-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nHello john@doe.com")
+}
+
+func TestConsumer_CC(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.Events[0].CC = []string{"{{ .user.manager }}"}
+
+	msg := fakeMessage()
+	msg.Event["user"].(map[string]interface{})["manager"] = "boss@doe.com"
+
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, []string{"john@doe.com", "boss@doe.com"}, req.to)
+}
+
+func TestConsumer_Digest(t *testing.T) {
+	var body []byte
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+	c.Config.Events[0].Templates["EN"] = config.Template{
+		Subject:  "Digest",
+		Template: "{{ .user.email }}:{{ items }}",
+		Item:     " {{ .title }}",
+	}
+
+	msg := fakeMessage()
+	msg.Event["items"] = []interface{}{
+		map[string]interface{}{"title": "one"},
+		map[string]interface{}{"title": "two"},
+	}
+
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	assert.Equal(t, "john@doe.com: one two", string(body))
+}
+
+func TestConsumer_Shutdown(t *testing.T) {
+	consumer := &Consumer{Config: fakeConfig()}
+	assert.EqualError(t, consumer.Shutdown(context.Background()), "consumer is not serving")
+}
+
+func TestConsumer_MaxRecipients(t *testing.T) {
+	sent := 0
+	c := fakeConsumer(func(SMTPConf, Email) error {
+		sent++
+		return nil
+	})
+	c.Config.Events[0].CC = []string{"{{ range .watchers }}{{ . }}{{ end }}", "audit@example.com"}
+	c.Config.MaxRecipients = 3
+
+	msg := fakeMessage()
+	msg.Event["watchers"] = []interface{}{"jane@doe.com"}
+
+	t.Run("under limit", func(t *testing.T) {
+		assert.NoError(t, c.handle(c.Config.Events[0], msg))
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("over limit", func(t *testing.T) {
+		c.Config.MaxRecipients = 2
+		err := c.handle(c.Config.Events[0], msg)
+		assert.EqualError(t, err, "message of user.example has 3 recipients, at most 2 allowed")
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		c.Config.MaxRecipients = 0
+		assert.NoError(t, c.handle(c.Config.Events[0], msg))
+		assert.Equal(t, 2, sent)
+	})
+}
+
+func TestConsumer_Replay(t *testing.T) {
+	consumer := fakeConsumer(nil)
+	assert.EqualError(t, consumer.Replay(context.Background(), 0), "amqp: replay requires dead-letter exchange")
+}
+
+func TestConsumer_MaxMessages(t *testing.T) {
+	c := fakeConsumer(nil)
+
+	WithMaxMessages(3)(c)
+	assert.Equal(t, 3, c.MaxMessages)
+
+	t.Run("needs manual ack mode", func(t *testing.T) {
+		c.Config.AMQP.AckMode = config.AckModeAuto
+		defer func() { c.Config.AMQP.AckMode = "" }()
+
+		assert.EqualError(t, c.ListenAndServe(), "max messages need manual ack mode")
+	})
+
+	t.Run("rejects negative limit", func(t *testing.T) {
+		WithMaxMessages(-1)(c)
+		defer WithMaxMessages(3)(c)
+
+		assert.EqualError(t, c.ListenAndServe(), "max messages -1 should not be negative")
+	})
+}
+
+func TestConsumer_newSources(t *testing.T) {
+	c := fakeConsumer(nil)
+	c.Config.AMQP.Disabled = true
+	c.Config.Kafka = &config.Kafka{Brokers: []string{"kafka:9092"}, Group: "postmaster", TopicPrefix: "events."}
+
+	t.Run("needs kafka dialer", func(t *testing.T) {
+		_, err := c.newSources()
+		assert.EqualError(t, err, "kafka: no client to dial with, see consumer.WithKafkaDialer")
+	})
+
+	t.Run("dials topics of events", func(t *testing.T) {
+		var topics []string
+		WithKafkaDialer(func(conf config.Kafka, dialed []string) (kafka.Reader, error) {
+			topics = dialed
+			return nil, nil
+		})(c)
+		defer func() { c.KafkaDialer = nil }()
+
+		sources, err := c.newSources()
+		assert.NoError(t, err)
+		assert.Len(t, sources, 1)
+		assert.Equal(t, []string{"events.user.example"}, topics)
+
+		c.MaxMessages = 1
+		defer func() { c.MaxMessages = 0 }()
+		_, err = c.newSources()
+		assert.EqualError(t, err, "max messages are not supported by kafka")
+	})
+
+	t.Run("fails with dialer", func(t *testing.T) {
+		WithKafkaDialer(func(config.Kafka, []string) (kafka.Reader, error) {
+			return nil, errors.New("no brokers reachable")
+		})(c)
+		defer func() { c.KafkaDialer = nil }()
+
+		_, err := c.newSources()
+		assert.EqualError(t, err, "kafka: no brokers reachable")
+	})
+}
+
+func TestLoadConfig_Kafka(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postmaster")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeReloadConfig(t, dir, "Hello")
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	content = bytes.Replace(content, []byte("  exchange: events\n"), []byte("  disabled: true\nkafka:\n  brokers: [kafka:9092]\n  group: postmaster\n"), 1)
+	assert.NoError(t, ioutil.WriteFile(path, content, 0644))
+
+	t.Run("rejected without dialer", func(t *testing.T) {
+		_, err := LoadConfig(path, withOptions(LoadOptions{}, nil))
+		assert.EqualError(t, err, "kafka is not supported without a client, see consumer.WithKafkaDialer")
+	})
+
+	t.Run("served with dialer", func(t *testing.T) {
+		os.Setenv("SENDER_EMAIL", "postmaster@example.com")
+		defer os.Unsetenv("SENDER_EMAIL")
+		os.Setenv("SMTP_PASSWORD", "secret")
+		defer os.Unsetenv("SMTP_PASSWORD")
+
+		var topics []string
+		dialer := WithKafkaDialer(func(conf KafkaConfig, dialed []string) (kafka.Reader, error) {
+			topics = dialed
+			return nil, nil
+		})
+
+		conf, err := LoadConfig(path, withOptions(LoadOptions{}, []Option{dialer}))
+		assert.NoError(t, err)
+
+		c, err := NewConsumer(conf, dialer)
+		assert.NoError(t, err)
+		sources, err := c.newSources()
+		assert.NoError(t, err)
+		assert.Len(t, sources, 1)
+		assert.Equal(t, []string{"user.example"}, topics)
+	})
+}
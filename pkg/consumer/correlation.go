@@ -0,0 +1,49 @@
+package consumer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+)
+
+// CorrelationHeader carries ID tracing message from AMQP through to the email provider.
+const CorrelationHeader = "X-Correlation-ID"
+
+// correlationID takes ID from CorrelationHeader of message, then from its
+// correlation_id property. New ID is generated, when neither is usable.
+func correlationID(msg amqp.Message) string {
+	for name, value := range msg.Headers {
+		if !strings.EqualFold(name, CorrelationHeader) {
+			continue
+		}
+
+		if id, ok := value.(string); ok && validCorrelationID(id) {
+			return id
+		}
+		log.Printf("header \"%s\" is not a valid correlation id, skipping", name)
+	}
+
+	if validCorrelationID(msg.CorrelationID) {
+		return msg.CorrelationID
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Printf("correlation id: %s", err.Error())
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// maxCorrelationID bounds length of correlation id taken from message.
+const maxCorrelationID = 128
+
+// validCorrelationID reports whether id can be written into email header.
+func validCorrelationID(id string) bool {
+	return strings.TrimSpace(id) != "" && len(id) <= maxCorrelationID &&
+		config.ValidHeader(CorrelationHeader, id) == nil
+}
@@ -0,0 +1,55 @@
+package consumer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_CorrelationID(t *testing.T) {
+	var sent Email
+	var result DeliveryResult
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+	WithDeliveryHook(func(r DeliveryResult) { result = r })(c)
+
+	send := func(msg amqp.Message) string {
+		assert.NoError(t, c.handle(c.Config.Events[0], msg))
+		assert.Equal(t, sent.Headers[CorrelationHeader], result.CorrelationID)
+		return result.CorrelationID
+	}
+
+	t.Run("flows from header to email and delivery result", func(t *testing.T) {
+		msg := fakeMessage()
+		msg.Headers = map[string]interface{}{"x-correlation-id": "support-4242"}
+
+		assert.Equal(t, "support-4242", send(msg))
+	})
+
+	t.Run("taken from correlation id property", func(t *testing.T) {
+		msg := fakeMessage()
+		msg.CorrelationID = "c0ffee"
+
+		assert.Equal(t, "c0ffee", send(msg))
+	})
+
+	t.Run("generated when missing", func(t *testing.T) {
+		first, second := send(fakeMessage()), send(fakeMessage())
+
+		assert.Len(t, first, 32)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("replaced when unsafe for header", func(t *testing.T) {
+		for _, id := range []interface{}{"evil\r\nBcc: all@example.com", strings.Repeat("a", 129), 42} {
+			msg := fakeMessage()
+			msg.Headers = map[string]interface{}{CorrelationHeader: id}
+
+			assert.Len(t, send(msg), 32)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package consumer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// DKIMSigner adds DKIM-Signature header to assembled messages, see RFC 6376.
+// Headers and body are canonicalized with the "relaxed" algorithm.
+type DKIMSigner struct {
+	domain   string
+	selector string
+	headers  []string
+	key      *rsa.PrivateKey
+	now      func() time.Time
+}
+
+// DKIMConfig is signing configuration of sender domain.
+type DKIMConfig = config.DKIM
+
+// NewDKIMSigner loads private key of conf.
+func NewDKIMSigner(conf DKIMConfig) (*DKIMSigner, error) {
+	key, err := conf.PrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("dkim private key: %s", err.Error())
+	}
+
+	return &DKIMSigner{
+		domain:   conf.Domain,
+		selector: conf.Selector,
+		headers:  conf.SignedHeaders(),
+		key:      key,
+		now:      time.Now,
+	}, nil
+}
+
+// Sign returns msg with CRLF line endings, as sent over SMTP,
+// prefixed with DKIM-Signature header.
+func (s *DKIMSigner) Sign(msg []byte) ([]byte, error) {
+	msg = crlf(msg)
+
+	end := bytes.Index(msg, []byte("\r\n\r\n"))
+	if end == -1 {
+		return nil, errors.New("dkim: message has no body")
+	}
+
+	fields := headerFields(msg[:end+2])
+	bodyHash := sha256.Sum256(relaxedBody(msg[end+4:]))
+
+	// Bottom-most instance of header is signed first, see RFC 6376 5.4.2.
+	used := make(map[int]bool)
+	var signed []string
+	var names []string
+	for _, name := range s.headers {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fieldName(fields[i]), name) {
+				used[i] = true
+				signed = append(signed, fields[i])
+				names = append(names, name)
+				break
+			}
+		}
+	}
+
+	value := fmt.Sprintf("v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.domain, s.selector, s.now().Unix(), strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	hash := sha256.New()
+	for _, field := range signed {
+		hash.Write([]byte(relaxedHeader(field) + "\r\n"))
+	}
+	hash.Write([]byte(relaxedHeader("DKIM-Signature: " + value)))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("dkim: %s", err.Error())
+	}
+
+	header := "DKIM-Signature: " + value + base64.StdEncoding.EncodeToString(signature) + "\r\n"
+	return append([]byte(header), msg...), nil
+}
+
+// crlf converts bare LF line endings to CRLF, as SMTP DATA does.
+func crlf(msg []byte) []byte {
+	result := make([]byte, 0, len(msg))
+	for i, b := range msg {
+		if b == '\n' && (i == 0 || msg[i-1] != '\r') {
+			result = append(result, '\r')
+		}
+		result = append(result, b)
+	}
+
+	return result
+}
+
+// headerFields splits header block into fields with folded lines kept.
+func headerFields(header []byte) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) != 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+
+	for i := range fields {
+		fields[i] = strings.TrimSuffix(fields[i], "\r\n")
+	}
+
+	return fields
+}
+
+func fieldName(field string) string {
+	return strings.TrimSpace(strings.SplitN(field, ":", 2)[0])
+}
+
+// relaxedHeader canonicalizes header field, see RFC 6376 3.4.2.
+func relaxedHeader(field string) string {
+	parts := strings.SplitN(field, ":", 2)
+	value := ""
+	if len(parts) == 2 {
+		value = strings.Trim(collapseWSP(strings.Replace(parts[1], "\r\n", "", -1)), " ")
+	}
+
+	return strings.ToLower(strings.TrimSpace(parts[0])) + ":" + value
+}
+
+// relaxedBody canonicalizes body, see RFC 6376 3.4.4.
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(collapseWSP(line), " ")
+	}
+
+	for len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// collapseWSP replaces runs of spaces and tabs with single space.
+func collapseWSP(line string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range line {
+		if r == ' ' || r == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+
+	return b.String()
+}
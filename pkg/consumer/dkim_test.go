@@ -0,0 +1,109 @@
+package consumer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const dkimMessage = "MIME-Version: 1.0\nFrom: \"Postmaster\" <test@postmaster.com>\nTo: john@doe.com\n" +
+	"Subject:  Hello \t world \nX-Other: skip\nContent-type: text/html;\n\tcharset=iso-8859-1\r\n\r\n" +
+	"<p>Hi  there</p>  \n\n\n"
+
+// dkimSignature of dkimMessage is computed independently with openssl and test/sample.key.
+const dkimSignature = "DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed; d=postmaster.com; s=mail; " +
+	"t=1577836800; h=From:To:Subject:MIME-Version:Content-Type; bh=E6PAlLD7X8mIEnbyW7mt6/aWQGr/7JzMJHd9lClVeXQ=; " +
+	"b=NUM+sbP8d6WQpvXxD5CV4QE6Vg5GxTDg1q0FDqi2Mnz6T3KGE9Pih+mwRhff18Q79CmdWfAD8T4KV1v4tDz3nXLiOGSeCvuR4uOf5mvUKWgf" +
+	"f3NYFPsOkY+B2lN4jy+NLSNhtucPXkysmM5CL0md8OpgSD6sPcy9Zje6GXoIlZw/lg68/ms/geaiT1vXhJI4YDKYm3mSDfbN7h0tuUNzBzn3K5kI" +
+	"VULFgWW7ZpIOyTyVIl0F8IxOfZAdQcqK4CFBAWD5gNuzjJHajvX4+ADY6R+Vz1W6qPU0xW7WuQzhQiOOmdALMJ2IDjOif8S2iWGE/lBeTJFYOP9t" +
+	"17yiSV6+lQ=="
+
+func fakeDKIMSigner(t *testing.T) *DKIMSigner {
+	signer, err := NewDKIMSigner(config.DKIM{Domain: "postmaster.com", Selector: "mail", PrivateKeyPath: "../../test/sample.key"})
+	assert.NoError(t, err)
+	signer.now = func() time.Time { return time.Unix(1577836800, 0) }
+	return signer
+}
+
+func TestDKIMSigner_Sign(t *testing.T) {
+	t.Run("matches known answer", func(t *testing.T) {
+		signed, err := fakeDKIMSigner(t).Sign([]byte(dkimMessage))
+
+		assert.NoError(t, err)
+		assert.Equal(t, dkimSignature+"\r\n"+
+			"MIME-Version: 1.0\r\nFrom: \"Postmaster\" <test@postmaster.com>\r\nTo: john@doe.com\r\n"+
+			"Subject:  Hello \t world \r\nX-Other: skip\r\nContent-type: text/html;\r\n\tcharset=iso-8859-1\r\n\r\n"+
+			"<p>Hi  there</p>  \r\n\r\n\r\n", string(signed))
+	})
+
+	t.Run("verifies against public key", func(t *testing.T) {
+		signed, err := fakeDKIMSigner(t).Sign([]byte(dkimMessage))
+		assert.NoError(t, err)
+
+		content, err := ioutil.ReadFile("../../test/sample.key.pub")
+		assert.NoError(t, err)
+		block, _ := pem.Decode(content)
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		assert.NoError(t, err)
+
+		header := strings.SplitN(string(signed), "\r\n", 2)[0]
+		value := strings.TrimPrefix(header, "DKIM-Signature: ")
+		at := strings.Index(value, "b=") + len("b=")
+		signature, err := base64.StdEncoding.DecodeString(value[at:])
+		assert.NoError(t, err)
+
+		data := "from:\"Postmaster\" <test@postmaster.com>\r\nto:john@doe.com\r\nsubject:Hello world\r\n" +
+			"mime-version:1.0\r\ncontent-type:text/html; charset=iso-8859-1\r\ndkim-signature:" + value[:at]
+		hash := sha256.Sum256([]byte(data))
+		assert.NoError(t, rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, hash[:], signature))
+
+		tampered := sha256.Sum256([]byte(strings.Replace(data, "Hello", "Hallo", 1)))
+		assert.Error(t, rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, tampered[:], signature))
+	})
+
+	t.Run("signs bottom-most header instance", func(t *testing.T) {
+		signer := fakeDKIMSigner(t)
+		signer.headers = []string{"From", "X-Tag", "X-Tag", "X-Tag"}
+
+		signed, err := signer.Sign([]byte("From: a@b.c\r\nX-Tag: 1\r\nX-Tag: 2\r\n\r\n"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, string(signed), "h=From:X-Tag:X-Tag; bh=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=;")
+	})
+
+	t.Run("fails on message without body", func(t *testing.T) {
+		_, err := fakeDKIMSigner(t).Sign([]byte("From: a@b.c\r\n"))
+		assert.EqualError(t, err, "dkim: message has no body")
+	})
+}
+
+func TestEmailSender_DKIM(t *testing.T) {
+	send, req := mockSend(nil)
+	sender := NewEmailSender(SMTPConf{Password: "secret", DKIM: fakeDKIMSigner(t)}, Email{
+		FromAddress: "test@postmaster.com",
+		FromName:    "Postmaster",
+		ToAddress:   "john@doe.com",
+		Subject:     "Hello",
+		ContentType: "text/html",
+		Headers:     map[string]string{},
+		Reader:      strings.NewReader("<p>Hi</p>"),
+	})
+	sender.send = send
+	sender.tplPath = "../../templates/email.tpl"
+
+	assert.NoError(t, sender.Send(context.Background()))
+	assert.True(t, strings.HasPrefix(string(req.msg), "DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed; d=postmaster.com; s=mail; "))
+	assert.Contains(t, string(req.msg), "h=From:To:Subject:MIME-Version:Content-Type;")
+	assert.NotContains(t, strings.Replace(string(req.msg), "\r\n", "", -1), "\n")
+}
@@ -0,0 +1,61 @@
+package consumer
+
+import (
+	"html/template"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// StandardFuncs returns helpers available in every template:
+// default, trim, urlquery and nl2br.
+func StandardFuncs() template.FuncMap {
+	return config.StandardFuncs()
+}
+
+// FlagProvider switches template copy gated by {{ if flag "name" }} on and off.
+type FlagProvider = config.FlagProvider
+
+// FlagProviderFunc adapts function to FlagProvider.
+type FlagProviderFunc = config.FlagProviderFunc
+
+// SetFlagProvider makes templates consult provider at render time.
+// Every flag is off without provider.
+func SetFlagProvider(provider FlagProvider) {
+	config.SetFlagProvider(provider)
+}
+
+// TemplateSource opens templates referenced by template_path and text_path,
+// e.g. from a database instead of disk.
+type TemplateSource = config.TemplateSource
+
+// SetTemplateSource makes templates read from source, nil restores the OS filesystem.
+// Call it before LoadConfig or Run, since templates are parsed at load.
+func SetTemplateSource(source TemplateSource) {
+	config.SetTemplateSource(source)
+}
+
+// MergeData deep-merges layers of render data, later layers win,
+// see config.MergeData.
+func MergeData(layers ...map[string]interface{}) map[string]interface{} {
+	return config.MergeData(layers...)
+}
+
+// RegisterFuncs makes user functions available in every template.
+// Standard helpers can be replaced, but Go template builtins are reserved.
+// Call it before LoadConfig or Run, since templates are parsed at load.
+func RegisterFuncs(funcs template.FuncMap) error {
+	return config.RegisterFuncs(funcs)
+}
+
+// PostProcessor transforms rendered HTML body of email, see config.PostProcessor.
+type PostProcessor = config.PostProcessor
+
+// PostProcessorFunc adapts function to PostProcessor.
+type PostProcessorFunc = config.PostProcessorFunc
+
+// RegisterPostProcessor makes processor available under name in post_process lists
+// of config, next to built-in "minify" and "strip_comments".
+// Call it before LoadConfig or Run, since names are checked at load.
+func RegisterPostProcessor(name string, processor PostProcessor) error {
+	return config.RegisterPostProcessor(name, processor)
+}
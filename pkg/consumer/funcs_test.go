@@ -0,0 +1,53 @@
+package consumer
+
+import (
+	"html/template"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterFuncs(t *testing.T) {
+	assert.EqualError(t, RegisterFuncs(template.FuncMap{"printf": strings.ToUpper}), "template func \"printf\" is reserved")
+	assert.NoError(t, RegisterFuncs(template.FuncMap{"shout": strings.ToUpper}))
+
+	var body []byte
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+	c.Config.Events[0].Templates["EN"] = config.Template{
+		Subject:  "Example",
+		Template: `{{ shout .user.email }} {{ default "x" .missing }}`,
+	}
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.Equal(t, "JOHN@DOE.COM x", string(body))
+	assert.Contains(t, StandardFuncs(), "nl2br")
+}
+
+// memorySource serves templates by ref from memory.
+type memorySource map[string]string
+
+func (s memorySource) Open(ref string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(s[ref])), nil
+}
+
+func TestSetTemplateSource(t *testing.T) {
+	SetTemplateSource(memorySource{"db:welcome": "<p>Welcome {{ .user.email }}</p>"})
+	defer SetTemplateSource(nil)
+
+	var body []byte
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+	c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Welcome", TemplatePath: "db:welcome"}
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.Equal(t, "<p>Welcome john@doe.com</p>", string(body))
+}
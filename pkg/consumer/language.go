@@ -0,0 +1,48 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/openware/postmaster/pkg/eventapi"
+)
+
+// resolveTimeout bounds a single language lookup.
+const resolveTimeout = 5 * time.Second
+
+// LanguageResolver looks up preferred language of recipient, e.g. in profile service,
+// for events, which don't carry language. Empty language falls back to default one.
+type LanguageResolver interface {
+	Resolve(ctx context.Context, data eventapi.Event) (string, error)
+}
+
+// LanguageResolverFunc adapts function to LanguageResolver.
+type LanguageResolverFunc func(ctx context.Context, data eventapi.Event) (string, error)
+
+// Resolve calls f(ctx, data).
+func (f LanguageResolverFunc) Resolve(ctx context.Context, data eventapi.Event) (string, error) {
+	return f(ctx, data)
+}
+
+// language picks language of event: set in event, resolved or default one.
+func (c *Consumer) language(usr *eventapi.UserEvent, data eventapi.Event) (string, error) {
+	if usr.Language != "" {
+		return usr.Language, nil
+	}
+
+	if c.LanguageResolver != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+		defer cancel()
+
+		lang, err := c.LanguageResolver.Resolve(ctx, data)
+		if err != nil {
+			return "", err
+		}
+
+		if lang != "" {
+			return lang, nil
+		}
+	}
+
+	return c.conf().DefaultLanguage, nil
+}
@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/eventapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_LanguageResolver(t *testing.T) {
+	var body []byte
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+	c.Config.Languages = append(c.Config.Languages, config.Language{Code: "RU", Name: "Russian"})
+	c.Config.Events[0].Templates["RU"] = config.Template{Subject: "Пример", Template: "Привет {{ .user.email }}"}
+
+	msg := fakeMessage()
+	delete(msg.Event, "language")
+	msg.Event["user"].(map[string]interface{})["uid"] = "ID123"
+
+	t.Run("resolves language", func(t *testing.T) {
+		var uid interface{}
+		c.LanguageResolver = LanguageResolverFunc(func(ctx context.Context, data eventapi.Event) (string, error) {
+			uid = data["user"].(map[string]interface{})["uid"]
+			return "RU", nil
+		})
+
+		assert.NoError(t, c.handle(c.Config.Events[0], msg))
+		assert.Equal(t, "ID123", uid)
+		assert.Equal(t, "Привет john@doe.com", string(body))
+	})
+
+	t.Run("falls back to default language", func(t *testing.T) {
+		c.LanguageResolver = LanguageResolverFunc(func(context.Context, eventapi.Event) (string, error) {
+			return "", nil
+		})
+
+		assert.NoError(t, c.handle(c.Config.Events[0], msg))
+		assert.Equal(t, "Hello john@doe.com", string(body))
+	})
+
+	t.Run("fails on resolver error", func(t *testing.T) {
+		c.LanguageResolver = LanguageResolverFunc(func(context.Context, eventapi.Event) (string, error) {
+			return "", errors.New("profile service unavailable")
+		})
+
+		err := c.handle(c.Config.Events[0], msg)
+		assert.EqualError(t, err, "resolve language: profile service unavailable")
+	})
+
+	t.Run("skips resolver when event has language", func(t *testing.T) {
+		c.LanguageResolver = LanguageResolverFunc(func(context.Context, eventapi.Event) (string, error) {
+			t.Fail()
+			return "", nil
+		})
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	})
+}
+
+func TestConsumer_UnconfiguredLanguage(t *testing.T) {
+	var subject string
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		subject = email.Subject
+		return nil
+	})
+	c.Config.Languages = append(c.Config.Languages, config.Language{Code: "DE", Name: "German"})
+	c.Config.Events[0].Templates["DE"] = config.Template{Subject: "Beispiel", Template: "Hallo"}
+
+	for code, expected := range map[string]string{
+		"de_AT": "Beispiel",
+		"de-at": "Beispiel",
+		"en-GB": "Example",
+		"UA":    "Example",
+	} {
+		msg := fakeMessage()
+		msg.Event["language"] = code
+
+		assert.NoError(t, c.handle(c.Config.Events[0], msg), code)
+		assert.Equal(t, expected, subject, code)
+	}
+}
@@ -2,22 +2,86 @@ package consumer
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"mime"
+	"net/mail"
 	"net/smtp"
 	"strings"
 	"text/template"
+
+	"github.com/openware/postmaster/internal/config"
 )
 
 type Email struct {
 	FromAddress string
 	FromName    string
 	ToAddress   string
+	CC          []string
 	Subject     string
+	// Value of Content-Type header, including parameters.
+	ContentType string
+	Headers     map[string]string
 	Reader      io.Reader
+
+	// ProviderTemplateID names template hosted by provider of the sender,
+	// which renders Data instead of sending Reader, see config.Template.
+	ProviderTemplateID string
+	Data               map[string]interface{}
+}
+
+// From returns From header of email, quoting or encoding display name,
+// so it can't break out of the header.
+func (e *Email) From() string {
+	return (&mail.Address{Name: e.FromName, Address: e.FromAddress}).String()
+}
+
+// EncodedSubject returns Subject header of email, RFC 2047 encoded
+// when it isn't plain ASCII.
+func (e *Email) EncodedSubject() string {
+	return mime.QEncoding.Encode("utf-8", e.Subject)
+}
+
+// passHeaders picks message headers starting with prefix, which are safe
+// to be copied onto the email. Empty prefix disables pass-through.
+func passHeaders(headers map[string]interface{}, prefix string) map[string]string {
+	result := make(map[string]string)
+
+	if prefix == "" {
+		return result
+	}
+
+	for name, value := range headers {
+		if !strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)) {
+			continue
+		}
+
+		var str string
+		switch v := value.(type) {
+		case string:
+			str = v
+		case []byte:
+			str = string(v)
+		case bool, int8, int16, int32, int64, float32, float64:
+			str = fmt.Sprint(v)
+		default:
+			log.Printf("header \"%s\" has unsupported value type %T, skipping", name, value)
+			continue
+		}
+
+		if err := config.ValidHeader(name, str); err != nil {
+			log.Printf("%s, skipping", err.Error())
+			continue
+		}
+
+		result[name] = str
+	}
+
+	return result
 }
 
 type SMTPConf struct {
@@ -25,6 +89,8 @@ type SMTPConf struct {
 	Password string
 	Host     string
 	Port     string
+	// DKIM signs messages before transmission, unsigned when nil.
+	DKIM *DKIMSigner
 }
 
 func (conf SMTPConf) URL() string {
@@ -32,17 +98,19 @@ func (conf SMTPConf) URL() string {
 }
 
 type EmailSender struct {
-	conf  *SMTPConf
-	email *Email
-	send  func(string, smtp.Auth, string, []string, []byte) error
+	conf    *SMTPConf
+	email   *Email
+	send    func(context.Context, string, smtp.Auth, string, []string, []byte) error
+	tplPath string
 }
 
 func NewEmailSender(conf SMTPConf, email Email) *EmailSender {
-	return &EmailSender{&conf, &email, smtp.SendMail}
+	return &EmailSender{&conf, &email, sendMail, "templates/email.tpl"}
 }
 
 // Compatible with any SMTP server, either "Mailcather" or "SendGrid".
-func (e *EmailSender) Send() error {
+// Send gives up with ctx.Err() once ctx is done.
+func (e *EmailSender) Send(ctx context.Context) error {
 	// Password is required.
 	if strings.TrimSpace(e.conf.Password) == "" {
 		return errors.New("password is empty")
@@ -52,14 +120,23 @@ func (e *EmailSender) Send() error {
 		return errors.New("email is nil")
 	}
 
-	tpl, err := template.ParseFiles("templates/email.tpl")
+	if e.email.ProviderTemplateID != "" {
+		return fmt.Errorf("provider template \"%s\" can't be sent through SMTP", e.email.ProviderTemplateID)
+	}
+
+	// Subject is written into header as is, whoever built the email.
+	if strings.ContainsAny(e.email.Subject, "\r\n") {
+		return errors.New("subject contains line break")
+	}
+
+	tpl, err := template.ParseFiles(e.tplPath)
 	if err != nil {
-		log.Println(err)
+		return err
 	}
 
 	buff := bytes.Buffer{}
 	if err := tpl.Execute(&buff, e.email); err != nil {
-		log.Println(err)
+		return err
 	}
 
 	text, err := ioutil.ReadAll(e.email.Reader)
@@ -67,13 +144,37 @@ func (e *EmailSender) Send() error {
 		return err
 	}
 
-	msg := append(buff.Bytes(), "\r\n"...)
+	// Parts of multipart body are encoded already.
+	msg := buff.Bytes()
+	if !multipartType(e.email.ContentType) {
+		if text, err = quotedPrintable(text); err != nil {
+			return err
+		}
+		msg = append(msg, "\r\nContent-Transfer-Encoding: quoted-printable"...)
+	}
+
+	// Blank line separates headers from body.
+	msg = append(msg, "\r\n\r\n"...)
 	msg = append(msg, text...)
 
-	recipients := []string{e.email.ToAddress}
+	if e.conf.DKIM != nil {
+		if msg, err = e.conf.DKIM.Sign(msg); err != nil {
+			return err
+		}
+	}
+
+	recipients, err := envelopeAddresses(append([]string{e.email.ToAddress}, e.email.CC...))
+	if err != nil {
+		return err
+	}
+
+	from, err := envelopeAddress(e.email.FromAddress)
+	if err != nil {
+		return err
+	}
 
 	auth := smtp.PlainAuth("", e.conf.Username, e.conf.Password, e.conf.Host)
-	if err := e.send(e.conf.URL(), auth, e.email.FromAddress, recipients, msg); err != nil {
+	if err := e.send(ctx, e.conf.URL(), auth, from, recipients, msg); err != nil {
 		return err
 	}
 
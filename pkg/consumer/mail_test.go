@@ -1,7 +1,9 @@
 package consumer
 
 import (
+	"context"
 	"net/smtp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,9 +17,9 @@ type emailRecorder struct {
 	msg  []byte
 }
 
-func mockSend(errToReturn error) (func(string, smtp.Auth, string, []string, []byte) error, *emailRecorder) {
+func mockSend(errToReturn error) (func(context.Context, string, smtp.Auth, string, []string, []byte) error, *emailRecorder) {
 	req := new(emailRecorder)
-	return func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	return func(_ context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
 		*req = emailRecorder{addr, a, from, to, msg}
 		return errToReturn
 	}, req
@@ -35,8 +37,119 @@ func TestEmailSender_Send(t *testing.T) {
 	t.Run("returns error, if password is empty", func(t *testing.T) {
 		f, _ := mockSend(nil)
 		sender := &EmailSender{send: f, email: &fakeEmail, conf: &SMTPConf{Password: ""}}
-		err := sender.Send()
+		err := sender.Send(context.Background())
 
 		assert.Equal(t, "password is empty", err.Error())
 	})
+
+	t.Run("rejects subject with line break", func(t *testing.T) {
+		email := fakeEmail
+		email.Subject = "Test\r\nBcc: x@y.z"
+
+		f, req := mockSend(nil)
+		sender := &EmailSender{send: f, email: &email, conf: &SMTPConf{Password: "secret"}}
+
+		assert.EqualError(t, sender.Send(context.Background()), "subject contains line break")
+		assert.Nil(t, req.msg)
+	})
+
+	t.Run("rejects provider template", func(t *testing.T) {
+		email := fakeEmail
+		email.ProviderTemplateID = "d-123"
+
+		f, req := mockSend(nil)
+		sender := &EmailSender{send: f, email: &email, conf: &SMTPConf{Password: "secret"}}
+
+		assert.EqualError(t, sender.Send(context.Background()), "provider template \"d-123\" can't be sent through SMTP")
+		assert.Nil(t, req.msg)
+	})
+
+	t.Run("writes passed through headers", func(t *testing.T) {
+		email := fakeEmail
+		email.Headers = map[string]string{"X-Campaign-ID": "spring"}
+		email.Reader = strings.NewReader("Hello")
+
+		f, req := mockSend(nil)
+		sender := &EmailSender{
+			send:    f,
+			email:   &email,
+			conf:    &SMTPConf{Password: "secret"},
+			tplPath: "../../templates/email.tpl",
+		}
+
+		assert.NoError(t, sender.Send(context.Background()))
+		assert.Contains(t, string(req.msg), "Subject: Test\nX-Campaign-ID: spring\nContent-type:")
+		assert.Equal(t, []string{"johndoe@gmail.com"}, req.to)
+	})
+
+	t.Run("copies to cc addresses", func(t *testing.T) {
+		email := fakeEmail
+		email.CC = []string{"manager@gmail.com", "audit@gmail.com"}
+		email.Reader = strings.NewReader("Hello")
+
+		f, req := mockSend(nil)
+		sender := &EmailSender{
+			send:    f,
+			email:   &email,
+			conf:    &SMTPConf{Password: "secret"},
+			tplPath: "../../templates/email.tpl",
+		}
+
+		assert.NoError(t, sender.Send(context.Background()))
+		assert.Contains(t, string(req.msg), "To: johndoe@gmail.com\nCc: manager@gmail.com, audit@gmail.com\nSubject: Test")
+		assert.Equal(t, []string{"johndoe@gmail.com", "manager@gmail.com", "audit@gmail.com"}, req.to)
+	})
+
+	t.Run("encodes international domains in envelope only", func(t *testing.T) {
+		email := fakeEmail
+		email.ToAddress = "user@müller.de"
+		email.CC = []string{"büro@bäckerei.at"}
+		email.Reader = strings.NewReader("Hello")
+
+		f, req := mockSend(nil)
+		sender := &EmailSender{
+			send:    f,
+			email:   &email,
+			conf:    &SMTPConf{Password: "secret"},
+			tplPath: "../../templates/email.tpl",
+		}
+
+		assert.NoError(t, sender.Send(context.Background()))
+		assert.Equal(t, []string{"user@xn--mller-kva.de", "büro@xn--bckerei-5wa.at"}, req.to)
+		assert.Equal(t, "test@postmaster.com", req.from)
+		assert.Contains(t, string(req.msg), "To: user@müller.de\nCc: büro@bäckerei.at\n")
+	})
+}
+
+func TestPassHeaders(t *testing.T) {
+	headers := map[string]interface{}{
+		"X-Campaign-ID": "spring",
+		"x-attempt":     int32(2),
+		"Campaign":      "summer",
+		"X-Bad Name":    "value",
+		"X-Injected":    "value\r\nBcc: everyone@example.com",
+	}
+
+	t.Run("copies whitelisted headers", func(t *testing.T) {
+		res := passHeaders(headers, "X-")
+
+		assert.Equal(t, map[string]string{
+			"X-Campaign-ID": "spring",
+			"x-attempt":     "2",
+		}, res)
+	})
+
+	t.Run("skips reserved headers", func(t *testing.T) {
+		res := passHeaders(map[string]interface{}{
+			"Content-type": "text/plain",
+			"Cc":           "everyone@example.com",
+			"Campaign":     "summer",
+		}, "C")
+
+		assert.Equal(t, map[string]string{"Campaign": "summer"}, res)
+	})
+
+	t.Run("copies nothing without prefix", func(t *testing.T) {
+		assert.Empty(t, passHeaders(headers, ""))
+	})
 }
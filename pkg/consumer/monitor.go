@@ -0,0 +1,387 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openware/postmaster/pkg/amqp"
+)
+
+// renderBuckets are upper bounds in seconds of render latency histogram.
+var renderBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// monitorKey labels counters of Monitor.
+type monitorKey struct {
+	event    string
+	language string
+}
+
+type throttle struct {
+	count   uint64
+	seconds float64
+}
+
+type histogram struct {
+	// Observations per bucket of renderBuckets, the last one is +Inf.
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Monitor counts messages per event key and language, which MonitorHandler
+// serves in Prometheus text format. Methods of nil Monitor do nothing.
+type Monitor struct {
+	mu         sync.Mutex
+	consumed   map[monitorKey]uint64
+	rendered   map[monitorKey]uint64
+	sent       map[monitorKey]uint64
+	failed     map[monitorKey]uint64
+	suppressed map[monitorKey]uint64
+	renders    map[monitorKey]*histogram
+
+	// Throttles per rate limit, see limitLabel.
+	throttles map[string]*throttle
+	// Messages sleeping until rate limit lets them through.
+	waiting int
+}
+
+// NewMonitor creates monitor with zero counters.
+func NewMonitor() *Monitor {
+	return &Monitor{
+		consumed:   make(map[monitorKey]uint64),
+		rendered:   make(map[monitorKey]uint64),
+		sent:       make(map[monitorKey]uint64),
+		failed:     make(map[monitorKey]uint64),
+		suppressed: make(map[monitorKey]uint64),
+		renders:    make(map[monitorKey]*histogram),
+		throttles:  make(map[string]*throttle),
+	}
+}
+
+// handled counts message of event processed with err.
+func (m *Monitor) handled(event, language string, err error) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := monitorKey{event, language}
+	m.consumed[key]++
+	if err != nil {
+		m.failed[key]++
+	}
+}
+
+// observeRender counts body of event rendered in d.
+func (m *Monitor) observeRender(event, language string, d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := monitorKey{event, language}
+	m.rendered[key]++
+
+	h, ok := m.renders[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(renderBuckets)+1)}
+		m.renders[key] = h
+	}
+
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(renderBuckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// delivered counts email of event sent.
+func (m *Monitor) delivered(event, language string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent[monitorKey{event, language}]++
+}
+
+// skipped counts email of event skipped, since recipient is suppressed.
+func (m *Monitor) skipped(event, language string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.suppressed[monitorKey{event, language}]++
+}
+
+// throttled counts message throttled by limit for wait,
+// which is sleeping until then, unless it's requeued.
+func (m *Monitor) throttled(limit string, wait time.Duration, sleeping bool) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.throttles[limit]
+	if !ok {
+		t = &throttle{}
+		m.throttles[limit] = t
+	}
+	t.count++
+	t.seconds += wait.Seconds()
+	if sleeping {
+		m.waiting++
+	}
+}
+
+// resumed counts throttled message done sleeping.
+func (m *Monitor) resumed() {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.waiting--
+}
+
+// WritePrometheus writes counters in Prometheus text format, gauges of
+// metrics are added, when it's set, and broker connection state.
+func (m *Monitor) WritePrometheus(w io.Writer, metrics *amqp.Metrics, connected bool) error {
+	buff := new(strings.Builder)
+
+	if m != nil {
+		m.mu.Lock()
+		writeCounter(buff, "postmaster_messages_consumed_total", "Messages consumed per event and language.", m.consumed)
+		writeCounter(buff, "postmaster_messages_rendered_total", "Bodies rendered per event and language.", m.rendered)
+		writeCounter(buff, "postmaster_messages_sent_total", "Emails sent per event and language.", m.sent)
+		writeCounter(buff, "postmaster_messages_failed_total", "Messages failed per event and language.", m.failed)
+		writeCounter(buff, "postmaster_messages_suppressed_total", "Emails skipped per event and language, since recipient is suppressed.", m.suppressed)
+		writeHistogram(buff, "postmaster_render_duration_seconds", "Render latency per event and language.", m.renders)
+		writeThrottles(buff, m.throttles)
+		writeGauge(buff, "postmaster_throttle_waiting", "Messages waiting for rate limit to let them through.", float64(m.waiting))
+		m.mu.Unlock()
+	}
+
+	state := 0.0
+	if connected {
+		state = 1
+	}
+	writeGauge(buff, "postmaster_amqp_connected", "Whether broker connection is open.", state)
+
+	if metrics != nil {
+		writeGauge(buff, "postmaster_queue_depth", "Messages ready in amqp queue.", float64(metrics.QueueDepth.Value()))
+		writeGauge(buff, "postmaster_lag_seconds", "Age of the oldest message processed since previous sample.", metrics.Lag.Value())
+	}
+
+	_, err := io.WriteString(w, buff.String())
+	return err
+}
+
+func sortedKeys(keys []monitorKey) []monitorKey {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].event != keys[j].event {
+			return keys[i].event < keys[j].event
+		}
+		return keys[i].language < keys[j].language
+	})
+
+	return keys
+}
+
+// labelValue escapes label value of Prometheus text format.
+var labelValue = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (k monitorKey) labels(extra string) string {
+	labels := fmt.Sprintf(`event="%s",language="%s"`, labelValue.Replace(k.event), labelValue.Replace(k.language))
+	if extra != "" {
+		labels += "," + extra
+	}
+
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeCounter(w io.Writer, name, help string, counter map[monitorKey]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+
+	keys := make([]monitorKey, 0, len(counter))
+	for key := range counter {
+		keys = append(keys, key)
+	}
+
+	for _, key := range sortedKeys(keys) {
+		fmt.Fprintf(w, "%s%s %d\n", name, key.labels(""), counter[key])
+	}
+}
+
+func writeHistogram(w io.Writer, name, help string, histograms map[monitorKey]*histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	keys := make([]monitorKey, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+
+	for _, key := range sortedKeys(keys) {
+		h := histograms[key]
+
+		// Buckets are cumulative.
+		var cumulative uint64
+		for i, count := range h.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(renderBuckets) {
+				le = formatFloat(renderBuckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, key.labels(`le="`+le+`"`), cumulative)
+		}
+
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, key.labels(""), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, key.labels(""), h.count)
+	}
+}
+
+// writeThrottles writes counters of throttles labeled by rate limit.
+func writeThrottles(w io.Writer, throttles map[string]*throttle) {
+	limits := make([]string, 0, len(throttles))
+	for limit := range throttles {
+		limits = append(limits, limit)
+	}
+	sort.Strings(limits)
+
+	name := "postmaster_throttled_total"
+	fmt.Fprintf(w, "# HELP %s Messages throttled per rate limit.\n# TYPE %s counter\n", name, name)
+	for _, limit := range limits {
+		fmt.Fprintf(w, "%s{limit=\"%s\"} %d\n", name, labelValue.Replace(limit), throttles[limit].count)
+	}
+
+	name = "postmaster_throttle_wait_seconds_total"
+	fmt.Fprintf(w, "# HELP %s Seconds messages were throttled for per rate limit.\n# TYPE %s counter\n", name, name)
+	for _, limit := range limits {
+		fmt.Fprintf(w, "%s{limit=\"%s\"} %s\n", name, labelValue.Replace(limit), formatFloat(throttles[limit].seconds))
+	}
+}
+
+func writeGauge(w io.Writer, name, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(value))
+}
+
+// ReadinessInterval is how long result of SMTP readiness checks is reused,
+// so frequent probes don't open SMTP connections every time.
+const ReadinessInterval = 10 * time.Second
+
+// ReadinessTimeout limits every SMTP readiness check.
+const ReadinessTimeout = 2 * time.Second
+
+// connected reports whether consumer is connected to brokers of all sources.
+func (c *Consumer) connected() bool {
+	c.mu.Lock()
+	sources := c.sources
+	c.mu.Unlock()
+
+	for _, source := range sources {
+		if !source.Connected() {
+			return false
+		}
+	}
+
+	return len(sources) > 0
+}
+
+// MonitorHandler serves "/metrics" in Prometheus text format, see Monitor,
+// "/healthz" failing while broker connection is down and "/readyz", which
+// also fails while SMTP servers of default or named senders don't accept connections.
+func (c *Consumer) MonitorHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		c.Monitor.WritePrometheus(w, c.Metrics, c.connected())
+	})
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !c.connected() {
+			http.Error(w, "amqp: not connected", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	var mu sync.Mutex
+	var checked time.Time
+	var smtpErr error
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		now := time.Now
+		if c.now != nil {
+			now = c.now
+		}
+		if now().Sub(checked) >= ReadinessInterval {
+			smtpErr = c.checkSMTPServers(r.Context())
+			checked = now()
+		}
+		err := smtpErr
+		mu.Unlock()
+
+		var failed []string
+		if !c.connected() {
+			failed = append(failed, "amqp: not connected")
+		}
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+
+		if len(failed) != 0 {
+			http.Error(w, strings.Join(failed, "; "), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	return mux
+}
+
+// checkSMTPServers runs SMTP checks of preflight, error names failed ones.
+func (c *Consumer) checkSMTPServers(ctx context.Context) error {
+	var failed []string
+
+	for _, check := range c.smtpChecks() {
+		checkCtx, cancel := context.WithTimeout(ctx, ReadinessTimeout)
+		err := check.run(checkCtx)
+		cancel()
+
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.name, err.Error()))
+		}
+	}
+
+	if len(failed) != 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor_WritePrometheus(t *testing.T) {
+	m := NewMonitor()
+	m.handled("Example", "EN", nil)
+	m.handled("Example", "EN", errors.New("failed"))
+	m.handled("Ex\"ample", "RU", nil)
+	m.observeRender("Example", "EN", 30*time.Millisecond)
+	m.delivered("Example", "EN")
+	m.throttled("gmail.com", 2*time.Second, true)
+	m.throttled("gmail.com", 500*time.Millisecond, false)
+	m.throttled("global", time.Second, true)
+	m.resumed()
+
+	metrics := amqp.NewMetrics()
+	metrics.QueueDepth.Set(7)
+
+	buff := new(bytes.Buffer)
+	assert.NoError(t, m.WritePrometheus(buff, metrics, true))
+	out := buff.String()
+
+	assert.Contains(t, out, "# TYPE postmaster_messages_consumed_total counter\n"+
+		`postmaster_messages_consumed_total{event="Ex\"ample",language="RU"} 1`+"\n"+
+		`postmaster_messages_consumed_total{event="Example",language="EN"} 2`+"\n")
+	assert.Contains(t, out, `postmaster_messages_failed_total{event="Example",language="EN"} 1`+"\n")
+	assert.Contains(t, out, `postmaster_messages_rendered_total{event="Example",language="EN"} 1`+"\n")
+	assert.Contains(t, out, `postmaster_messages_sent_total{event="Example",language="EN"} 1`+"\n")
+	assert.Contains(t, out, `postmaster_render_duration_seconds_bucket{event="Example",language="EN",le="0.025"} 0`+"\n"+
+		`postmaster_render_duration_seconds_bucket{event="Example",language="EN",le="0.05"} 1`+"\n")
+	assert.Contains(t, out, `postmaster_render_duration_seconds_bucket{event="Example",language="EN",le="+Inf"} 1`+"\n"+
+		`postmaster_render_duration_seconds_sum{event="Example",language="EN"} 0.03`+"\n"+
+		`postmaster_render_duration_seconds_count{event="Example",language="EN"} 1`+"\n")
+	assert.Contains(t, out, `postmaster_throttled_total{limit="global"} 1`+"\n"+
+		`postmaster_throttled_total{limit="gmail.com"} 2`+"\n")
+	assert.Contains(t, out, `postmaster_throttle_wait_seconds_total{limit="gmail.com"} 2.5`+"\n")
+	assert.Contains(t, out, "postmaster_throttle_waiting 1\n")
+	assert.Contains(t, out, "postmaster_amqp_connected 1\n")
+	assert.Contains(t, out, "postmaster_queue_depth 7\n")
+
+	t.Run("nil monitor", func(t *testing.T) {
+		var m *Monitor
+		m.handled("Example", "EN", nil)
+
+		buff := new(bytes.Buffer)
+		assert.NoError(t, m.WritePrometheus(buff, nil, false))
+		assert.Equal(t, "# HELP postmaster_amqp_connected Whether broker connection is open.\n"+
+			"# TYPE postmaster_amqp_connected gauge\npostmaster_amqp_connected 0\n", buff.String())
+	})
+}
+
+func TestConsumer_Monitor(t *testing.T) {
+	failing := errors.New("smtp down")
+	var sendErr error
+	c := fakeConsumer(func(SMTPConf, Email) error { return sendErr })
+	WithMonitor(NewMonitor())(c)
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	sendErr = failing
+	assert.Error(t, c.handle(c.Config.Events[0], fakeMessage()))
+
+	buff := new(bytes.Buffer)
+	assert.NoError(t, c.Monitor.WritePrometheus(buff, nil, false))
+	out := buff.String()
+
+	assert.Contains(t, out, `postmaster_messages_consumed_total{event="Example",language="EN"} 2`)
+	assert.Contains(t, out, `postmaster_messages_rendered_total{event="Example",language="EN"} 2`)
+	assert.Contains(t, out, `postmaster_messages_sent_total{event="Example",language="EN"} 1`)
+	assert.Contains(t, out, `postmaster_messages_failed_total{event="Example",language="EN"} 1`)
+	assert.Contains(t, out, `postmaster_render_duration_seconds_count{event="Example",language="EN"} 2`)
+}
+
+func TestConsumer_MonitorHandler(t *testing.T) {
+	get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("metrics", func(t *testing.T) {
+		c := fakeConsumer(nil)
+		WithMonitor(NewMonitor())(c)
+
+		rec := get(c.MonitorHandler(), "/metrics")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+		assert.Contains(t, rec.Body.String(), "postmaster_amqp_connected 0\n")
+	})
+
+	t.Run("not connected", func(t *testing.T) {
+		c := fakeConsumer(nil)
+		WithDelivery(SenderFunc(func(context.Context, Email) error { return nil }))(c)
+		h := c.MonitorHandler()
+
+		rec := get(h, "/healthz")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "amqp: not connected\n", rec.Body.String())
+
+		rec = get(h, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "amqp: not connected\n", rec.Body.String())
+	})
+
+	t.Run("smtp down", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		addr := listener.Addr().(*net.TCPAddr)
+		listener.Close()
+
+		c := fakeConsumer(nil)
+		c.SMTP = SMTPConf{Host: "127.0.0.1", Port: strings.TrimPrefix(addr.String(), "127.0.0.1:")}
+
+		rec := get(c.MonitorHandler(), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Contains(t, rec.Body.String(), "amqp: not connected; smtp: ")
+	})
+
+	t.Run("readiness is cached", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		accepted := make(chan struct{}, 8)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				// Counted before close, which readiness check waits for.
+				accepted <- struct{}{}
+				conn.Close()
+			}
+		}()
+
+		now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		c := fakeConsumer(nil)
+		c.now = func() time.Time { return now }
+		c.SMTP = SMTPConf{Host: "127.0.0.1", Port: strings.TrimPrefix(listener.Addr().String(), "127.0.0.1:")}
+		h := c.MonitorHandler()
+
+		get(h, "/readyz")
+		get(h, "/readyz")
+		assert.Len(t, accepted, 1)
+
+		now = now.Add(ReadinessInterval)
+		get(h, "/readyz")
+		assert.Len(t, accepted, 2)
+	})
+}
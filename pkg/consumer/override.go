@@ -0,0 +1,30 @@
+package consumer
+
+import (
+	"log"
+	"strings"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// Template is template of event in a language, see Consumer.Override.
+type Template = config.Template
+
+// Override makes event use tpl for recipients in language until ClearOverride,
+// e.g. to canary a template without config change. Overrides live in memory,
+// so they are lost on restart or Reload.
+func (c *Consumer) Override(eventKey, lang string, tpl Template) error {
+	if err := c.conf().Override(eventKey, lang, tpl); err != nil {
+		return err
+	}
+
+	log.Printf("Template of event \"%s\" in %s is overridden\n", eventKey, strings.ToUpper(lang))
+	return nil
+}
+
+// ClearOverride restores configured template of event in language.
+func (c *Consumer) ClearOverride(eventKey, lang string) {
+	if c.conf().ClearOverride(eventKey, lang) {
+		log.Printf("Template override of event \"%s\" in %s is cleared\n", eventKey, strings.ToUpper(lang))
+	}
+}
@@ -0,0 +1,28 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_Override(t *testing.T) {
+	var sent Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		return nil
+	})
+	var result DeliveryResult
+	c.OnDelivery = func(r DeliveryResult) { result = r }
+
+	assert.NoError(t, c.Override("user.example", "EN", Template{Subject: "Canary", Template: "New"}))
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.Equal(t, "Canary", sent.Subject)
+	assert.Equal(t, config.OverrideVersion, result.TemplateVersion)
+
+	c.ClearOverride("user.example", "EN")
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.Equal(t, "Example", sent.Subject)
+	assert.Equal(t, config.BaseVersion, result.TemplateVersion)
+}
@@ -0,0 +1,118 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openware/postmaster/pkg/amqp"
+)
+
+// PreflightTimeout limits every preflight check.
+const PreflightTimeout = 10 * time.Second
+
+type preflightCheck struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Preflight verifies broker is reachable and configured exchange exists, unless
+// AMQP is disabled, and SMTP servers of the default and named senders accept connections.
+// Every check is logged, error names all failed ones.
+func (c *Consumer) Preflight(ctx context.Context) error {
+	return runPreflight(ctx, c.preflightChecks(), PreflightTimeout)
+}
+
+func (c *Consumer) preflightChecks() []preflightCheck {
+	if c.conf().AMQP.Disabled {
+		return c.smtpChecks()
+	}
+
+	uri, uriErr := c.brokerURI()
+	exchange := c.conf().AMQP.Exchange
+	checks := []preflightCheck{
+		{"amqp exchange", func(ctx context.Context) error {
+			if uriErr != nil {
+				return uriErr
+			}
+			return amqp.CheckExchange(ctx, uri, exchange)
+		}},
+	}
+
+	return append(checks, c.smtpChecks()...)
+}
+
+// smtpChecks verify SMTP servers of the default and named senders.
+func (c *Consumer) smtpChecks() []preflightCheck {
+	var checks []preflightCheck
+
+	// Only SMTP senders can be checked, custom ones are trusted.
+	if c.Delivery == nil {
+		checks = append(checks, preflightCheck{"smtp", func(ctx context.Context) error { return checkSMTP(ctx, c.SMTP.URL()) }})
+	} else if sender, ok := c.Delivery.(*SMTPSender); ok {
+		addr := sender.Conf.URL()
+		checks = append(checks, preflightCheck{"smtp delivery", func(ctx context.Context) error { return checkSMTP(ctx, addr) }})
+	}
+
+	names := make([]string, 0, len(c.Senders))
+	for name := range c.Senders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if sender, ok := c.Senders[name].(*SMTPSender); ok {
+			addr := sender.Conf.URL()
+			checks = append(checks, preflightCheck{
+				fmt.Sprintf("smtp sender \"%s\"", name),
+				func(ctx context.Context) error { return checkSMTP(ctx, addr) },
+			})
+		}
+	}
+
+	return checks
+}
+
+func runPreflight(ctx context.Context, checks []preflightCheck, timeout time.Duration) error {
+	var failed []string
+
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := check.run(checkCtx)
+		cancel()
+
+		if err != nil {
+			log.Printf("preflight %s: FAILED: %s", check.name, err.Error())
+			failed = append(failed, fmt.Sprintf("%s: %s", check.name, err.Error()))
+			continue
+		}
+
+		log.Printf("preflight %s: ok", check.name)
+	}
+
+	if len(failed) != 0 {
+		return fmt.Errorf("preflight failed: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// Preflight loads config and runs preflight checks of the consumer, see Consumer.Preflight.
+func Preflight(path string, opts LoadOptions, options ...Option) error {
+	conf, err := LoadConfig(path, withOptions(opts, options))
+	if err != nil {
+		return err
+	}
+
+	requireEnvs(conf)
+
+	consumer, err := NewConsumer(conf, options...)
+	if err != nil {
+		return err
+	}
+
+	return consumer.Preflight(context.Background())
+}
@@ -0,0 +1,103 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPreflight(t *testing.T) {
+	ok := preflightCheck{"amqp exchange", func(context.Context) error { return nil }}
+	hung := preflightCheck{"smtp", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	missing := preflightCheck{"smtp sender \"bulk\"", func(context.Context) error {
+		return errors.New("connection refused")
+	}}
+
+	t.Run("all checks pass", func(t *testing.T) {
+		assert.NoError(t, runPreflight(context.Background(), []preflightCheck{ok, ok}, time.Second))
+	})
+
+	t.Run("reports every failed check", func(t *testing.T) {
+		err := runPreflight(context.Background(), []preflightCheck{hung, ok, missing}, 10*time.Millisecond)
+		assert.EqualError(t, err,
+			"preflight failed: smtp: context deadline exceeded; smtp sender \"bulk\": connection refused")
+	})
+}
+
+func TestConsumer_preflightChecks(t *testing.T) {
+	c := fakeConsumer(nil)
+	WithSender("bulk", &SMTPSender{Conf: SMTPConf{Host: "smtp.bulk.io", Port: "587"}})(c)
+	WithSender("ses", SenderFunc(func(context.Context, Email) error { return nil }))(c)
+
+	var names []string
+	for _, check := range c.preflightChecks() {
+		names = append(names, check.name)
+	}
+
+	assert.Equal(t, []string{"amqp exchange", "smtp", "smtp sender \"bulk\""}, names)
+
+	t.Run("skips disabled amqp", func(t *testing.T) {
+		c.Config.AMQP.Disabled = true
+		defer func() { c.Config.AMQP.Disabled = false }()
+
+		assert.Equal(t, "smtp", c.preflightChecks()[0].name)
+	})
+
+	t.Run("delivery replaces smtp", func(t *testing.T) {
+		names := func() []string {
+			var names []string
+			for _, check := range c.preflightChecks() {
+				names = append(names, check.name)
+			}
+			return names
+		}
+
+		WithDelivery(&SMTPSender{Conf: SMTPConf{Host: "smtp.example.com", Port: "25"}})(c)
+		assert.Equal(t, []string{"amqp exchange", "smtp delivery", "smtp sender \"bulk\""}, names())
+
+		WithDelivery(&apiSender{})(c)
+		assert.Equal(t, []string{"amqp exchange", "smtp sender \"bulk\""}, names())
+	})
+}
+
+func TestCheckSMTP(t *testing.T) {
+	t.Run("server greets", func(t *testing.T) {
+		addr, received := fakeSMTP(t, func(line string) string {
+			switch line {
+			case "":
+				return "220 localhost ESMTP"
+			case "QUIT":
+				return "221 bye"
+			default:
+				return "250 OK"
+			}
+		})
+
+		assert.NoError(t, checkSMTP(context.Background(), addr))
+		assert.Equal(t, "EHLO localhost", (*received)[0])
+	})
+
+	t.Run("server hangs", func(t *testing.T) {
+		addr, _ := fakeSMTP(t, func(string) string { return "" })
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		assert.Equal(t, context.DeadlineExceeded, checkSMTP(ctx, addr))
+	})
+
+	t.Run("server is down", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		assert.Error(t, checkSMTP(context.Background(), addr))
+	})
+}
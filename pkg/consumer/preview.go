@@ -0,0 +1,102 @@
+package consumer
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/eventapi"
+)
+
+// Preview is subject and body of email rendered without sending it.
+type Preview struct {
+	Subject     string
+	ContentType string
+	Body        []byte
+}
+
+// WriteTo writes subject and content type headers, then body after blank line.
+func (p Preview) WriteTo(w io.Writer) (int64, error) {
+	n, err := fmt.Fprintf(w, "Subject: %s\nContent-Type: %s\n\n%s", p.Subject, p.ContentType, p.Body)
+	return int64(n), err
+}
+
+// Preview renders email of event with key in language lang from payload of message,
+// preview data of the event is used, when payload is nil. Language of payload is
+// used, when lang is empty, and resolved like the one of messages.
+// Body of provider templates is left empty, since provider renders it.
+func (c *Consumer) Preview(key, lang string, payload eventapi.Event) (Preview, error) {
+	eventConf, ok := eventOf(c.conf(), key)
+	if !ok {
+		return Preview{}, fmt.Errorf("event \"%s\" is not configured", key)
+	}
+
+	if payload == nil {
+		data, err := eventConf.PreviewData()
+		if err != nil {
+			return Preview{}, err
+		}
+		payload = eventapi.Event(data)
+	}
+
+	usr, err := eventapi.Unmarshal(payload)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	if lang == "" {
+		lang = usr.Language
+	}
+	if lang, err = c.conf().ResolveLanguage(lang); err != nil {
+		return Preview{}, err
+	}
+
+	data, err := c.conf().AMQP.PayloadData(payload)
+	if err != nil {
+		return Preview{}, fmt.Errorf("event \"%s\": %s", key, err.Error())
+	}
+
+	event := eventapi.Event(c.conf().RenderData(&eventConf, usr.Tenant, data))
+
+	var typed interface{} = event
+	if c.Types != nil {
+		if typed, err = c.Types.Decode(key, event); err != nil {
+			return Preview{}, err
+		}
+	}
+
+	files, err := c.payloadAttachments(eventConf, data)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	tpl, _ := c.conf().ResolveTemplate(&eventConf, usr.Tenant, lang, usr.Segment, usr.User.Email)
+	r, err := c.render(eventConf, tpl, config.TemplateKey(lang, usr.Segment), lang, typed, event, usr.PreferredFormat, files)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	return Preview{Subject: r.subject, ContentType: r.contentType, Body: r.content}, nil
+}
+
+// WritePreview loads config at path and writes preview of event rendered
+// from payload to w, see Consumer.Preview. Neither broker nor SMTP is used.
+func WritePreview(path string, opts LoadOptions, key, lang string, payload eventapi.Event, w io.Writer, options ...Option) error {
+	conf, err := LoadConfig(path, withOptions(opts, options))
+	if err != nil {
+		return err
+	}
+
+	consumer := &Consumer{Config: conf}
+	for _, option := range options {
+		option(consumer)
+	}
+
+	preview, err := consumer.Preview(key, lang, payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = preview.WriteTo(w)
+	return err
+}
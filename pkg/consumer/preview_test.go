@@ -0,0 +1,79 @@
+package consumer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/eventapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_Preview(t *testing.T) {
+	c := fakeConsumer(nil)
+	c.Config.Languages = append(c.Config.Languages, config.Language{Code: "DE", Name: "German"})
+	c.Config.Events[0].Templates["DE"] = config.Template{Subject: "Beispiel", Template: "Hallo {{ .user.email }}"}
+
+	t.Run("payload", func(t *testing.T) {
+		preview, err := c.Preview("user.example", "", eventapi.Event(fakeMessage().Event))
+		assert.NoError(t, err)
+		assert.Equal(t, Preview{
+			Subject:     "Example",
+			ContentType: "text/html; charset=iso-8859-1",
+			Body:        []byte("Hello john@doe.com"),
+		}, preview)
+
+		buff := new(bytes.Buffer)
+		_, err = preview.WriteTo(buff)
+		assert.NoError(t, err)
+		assert.Equal(t, "Subject: Example\nContent-Type: text/html; charset=iso-8859-1\n\nHello john@doe.com", buff.String())
+	})
+
+	t.Run("language", func(t *testing.T) {
+		preview, err := c.Preview("user.example", "de_CH", eventapi.Event(fakeMessage().Event))
+		assert.NoError(t, err)
+		assert.Equal(t, "Hallo john@doe.com", string(preview.Body))
+	})
+
+	t.Run("preview data", func(t *testing.T) {
+		c.Config.Events[0].Preview = `{"user": {"email": "jane@doe.com"}}`
+
+		preview, err := c.Preview("user.example", "EN", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello jane@doe.com", string(preview.Body))
+	})
+
+	t.Run("unknown event", func(t *testing.T) {
+		_, err := c.Preview("user.unknown", "EN", nil)
+		assert.EqualError(t, err, "event \"user.unknown\" is not configured")
+	})
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postmaster")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "postmaster.yml")
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("languages:\n- code: EN\n  name: English\n"), 0644))
+		assert.NoError(t, ValidateConfig(path, config.LoadOptions{}))
+	})
+
+	t.Run("located error", func(t *testing.T) {
+		content := "languages:\n- code: EN\n  name: English\nevents:\n- name: Broken\n  key: broken\n"
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+		assert.EqualError(t, ValidateConfig(path, config.LoadOptions{}), path+":5: event \"Broken\" has no templates")
+	})
+
+	t.Run("decode error", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("languages: {\n"), 0644))
+		err := ValidateConfig(path, config.LoadOptions{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), path+": yaml: line")
+	})
+}
@@ -0,0 +1,31 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// QuietHoursRecheck caps the delay of message deferred during quiet hours,
+// so quiet hours of reloaded config apply to it within the cap.
+const QuietHoursRecheck = 15 * time.Minute
+
+// quietHours returns how long message of event in language is deferred,
+// zero when it can be sent. Messages with priority are urgent and never deferred.
+func (c *Consumer) quietHours(eventConf config.Event, language string, priority uint8) time.Duration {
+	if eventConf.QuietHours == nil || priority > 0 {
+		return 0
+	}
+
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+
+	remaining := eventConf.QuietHours.Remaining(now().In(c.conf().Location(language)))
+	if remaining > QuietHoursRecheck {
+		return QuietHoursRecheck
+	}
+
+	return remaining
+}
@@ -0,0 +1,70 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_QuietHours(t *testing.T) {
+	quietConsumer := func(now time.Time) (*Consumer, *emailRecorder) {
+		deliver, req := recordSMTP(nil)
+		c := fakeConsumer(deliver)
+		c.Config.Languages = append(c.Config.Languages, config.Language{Code: "DE", Name: "German", TimeZone: "Europe/Berlin"})
+		c.Config.Events[0].Templates["DE"] = config.Template{Subject: "Beispiel", Template: "Hallo"}
+		c.Config.Events[0].QuietHours = &config.QuietHours{Start: "22:00", End: "08:00"}
+		c.now = func() time.Time { return now }
+		return c, req
+	}
+
+	t.Run("defers message inside quiet hours", func(t *testing.T) {
+		c, req := quietConsumer(time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC))
+
+		err := c.handle(c.Config.Events[0], fakeMessage())
+
+		assert.EqualError(t, err, "event \"user.example\" is deferred during quiet hours")
+		assert.True(t, amqp.IsRequeue(err))
+		assert.Nil(t, req.msg)
+	})
+
+	t.Run("sends message outside quiet hours", func(t *testing.T) {
+		c, req := quietConsumer(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.NotNil(t, req.msg)
+	})
+
+	t.Run("sends urgent message inside quiet hours", func(t *testing.T) {
+		c, req := quietConsumer(time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC))
+		msg := fakeMessage()
+		msg.Priority = 5
+
+		assert.NoError(t, c.handle(c.Config.Events[0], msg))
+		assert.NotNil(t, req.msg)
+	})
+
+	t.Run("evaluates quiet hours in time zone of language", func(t *testing.T) {
+		// 21:30 UTC is 22:30 in Berlin.
+		c, req := quietConsumer(time.Date(2020, 1, 1, 21, 30, 0, 0, time.UTC))
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.NotNil(t, req.msg)
+
+		req.msg = nil
+		msg := fakeMessage()
+		msg.Event["language"] = "DE"
+		assert.True(t, amqp.IsRequeue(c.handle(c.Config.Events[0], msg)))
+		assert.Nil(t, req.msg)
+	})
+
+	t.Run("holds deferred message at most recheck interval", func(t *testing.T) {
+		c, _ := quietConsumer(time.Date(2020, 1, 1, 7, 59, 30, 0, time.UTC))
+		assert.Equal(t, 30*time.Second, c.quietHours(c.Config.Events[0], "EN", 0))
+
+		c, _ = quietConsumer(time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC))
+		assert.Equal(t, QuietHoursRecheck, c.quietHours(c.Config.Events[0], "EN", 0))
+	})
+}
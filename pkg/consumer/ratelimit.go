@@ -0,0 +1,184 @@
+package consumer
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// globalLimit names the limit of all domains together.
+const globalLimit = "global"
+
+// domainLimiter keeps a token bucket per recipient domain and a global one.
+// Buckets hold a minute worth of tokens, unless burst is set, and refill
+// evenly over the minute.
+type domainLimiter struct {
+	limits *config.RateLimit
+	now    func() time.Time
+	sleep  func(time.Duration)
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	global  *bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newDomainLimiter(limits *config.RateLimit, now func() time.Time) *domainLimiter {
+	return &domainLimiter{limits: limits, now: now, sleep: time.Sleep, buckets: make(map[string]*bucket)}
+}
+
+// domainLimiter returns limiter of consumer, creating it from config on first use.
+func (c *Consumer) domainLimiter() *domainLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limiter == nil {
+		c.limiter = newDomainLimiter(c.conf().RateLimit, time.Now)
+	}
+
+	return c.limiter
+}
+
+// reserve takes a token of every domain of addresses and a global one,
+// when all of them have one. Otherwise nothing is taken, and the most
+// throttled domain, or globalLimit, is returned with the wait until it has a token.
+func (l *domainLimiter) reserve(addresses []string) (string, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	domains := recipientDomains(addresses)
+
+	var throttled string
+	var wait time.Duration
+	for _, domain := range domains {
+		limit := l.limits.Limit(domain)
+		if limit == 0 {
+			continue
+		}
+
+		b, ok := l.buckets[domain]
+		if !ok {
+			b = &bucket{tokens: float64(l.limits.Capacity(limit)), updated: now}
+			l.buckets[domain] = b
+		}
+
+		if w := l.refill(b, limit, now); w > wait {
+			throttled, wait = domain, w
+		}
+	}
+
+	if global := l.globalRate(); global != 0 {
+		if l.global == nil {
+			l.global = &bucket{tokens: float64(l.limits.Capacity(global)), updated: now}
+		}
+
+		if w := l.refill(l.global, global, now); w > wait {
+			throttled, wait = globalLimit, w
+		}
+	}
+
+	if wait > 0 {
+		return throttled, wait
+	}
+
+	for _, domain := range domains {
+		if b, ok := l.buckets[domain]; ok && l.limits.Limit(domain) != 0 {
+			b.tokens--
+		}
+	}
+	if l.global != nil {
+		l.global.tokens--
+	}
+
+	return "", 0
+}
+
+// wait reserves tokens of addresses like reserve, sleeping for its turn
+// while the total wait stays within the max wait of limits. When it doesn't,
+// throttled domain is returned with the wait left. Monitor counts every
+// throttle and messages sleeping.
+func (l *domainLimiter) wait(addresses []string, m *Monitor) (string, time.Duration) {
+	budget := l.limits.Wait()
+
+	for {
+		domain, wait := l.reserve(addresses)
+		if wait == 0 {
+			return "", 0
+		}
+
+		label := limitLabel(l.limits, domain)
+		if wait > budget {
+			m.throttled(label, wait, false)
+			return domain, wait
+		}
+
+		m.throttled(label, wait, true)
+		l.sleep(wait)
+		m.resumed()
+		budget -= wait
+	}
+}
+
+func (l *domainLimiter) globalRate() int {
+	if l.limits == nil {
+		return 0
+	}
+
+	return int(l.limits.Global)
+}
+
+// refill adds tokens accrued at limit per minute since bucket was updated,
+// returning the wait until it has a token.
+func (l *domainLimiter) refill(b *bucket, limit int, now time.Time) time.Duration {
+	elapsed := now.Sub(b.updated)
+	b.tokens = math.Min(float64(l.limits.Capacity(limit)), b.tokens+elapsed.Minutes()*float64(limit))
+	b.updated = now
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	perToken := time.Minute / time.Duration(limit)
+	return time.Duration(math.Ceil((1 - b.tokens) * float64(perToken)))
+}
+
+// limitLabel labels metrics of limit of domain, keeping domains without
+// override together, so recipients can't inflate series.
+func limitLabel(limits *config.RateLimit, domain string) string {
+	if domain == globalLimit {
+		return domain
+	}
+
+	if limits != nil {
+		if _, ok := limits.Domains[domain]; ok {
+			return domain
+		}
+	}
+
+	return "default"
+}
+
+// recipientDomains returns distinct domains of addresses in DomainKey form.
+func recipientDomains(addresses []string) []string {
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		seen[config.DomainKey(address[strings.LastIndex(address, "@")+1:])] = true
+	}
+
+	domains := make([]string, 0, len(seen))
+	for domain := range seen {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	return domains
+}
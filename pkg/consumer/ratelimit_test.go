@@ -0,0 +1,189 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is advanced by tests by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestDomainLimiter(t *testing.T) {
+	limits := &config.RateLimit{PerMinute: 2, Domains: map[string]config.Rate{"gmail.com": 1, "unlimited.com": 0}}
+
+	t.Run("limits domains independently", func(t *testing.T) {
+		clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+		limiter := newDomainLimiter(limits, clock.Now)
+
+		domain, wait := limiter.reserve([]string{"john@gmail.com"})
+		assert.Equal(t, time.Duration(0), wait)
+		domain, wait = limiter.reserve([]string{"jane@gmail.com"})
+		assert.Equal(t, "gmail.com", domain)
+		assert.Equal(t, time.Minute, wait)
+
+		_, wait = limiter.reserve([]string{"john@yahoo.com"})
+		assert.Equal(t, time.Duration(0), wait)
+		domain, wait = limiter.reserve([]string{"john@yahoo.com"})
+		assert.Equal(t, "yahoo.com", domain)
+		assert.Equal(t, 30*time.Second, wait)
+
+		clock.now = clock.now.Add(30 * time.Second)
+		_, wait = limiter.reserve([]string{"john@yahoo.com"})
+		assert.Equal(t, time.Duration(0), wait)
+		domain, wait = limiter.reserve([]string{"john@gmail.com"})
+		assert.Equal(t, "gmail.com", domain)
+		assert.Equal(t, 30*time.Second, wait)
+
+		clock.now = clock.now.Add(30 * time.Second)
+		_, wait = limiter.reserve([]string{"john@GMail.com"})
+		assert.Equal(t, time.Duration(0), wait)
+	})
+
+	t.Run("takes nothing, when any domain is throttled", func(t *testing.T) {
+		clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+		limiter := newDomainLimiter(limits, clock.Now)
+
+		_, wait := limiter.reserve([]string{"john@gmail.com"})
+		assert.Equal(t, time.Duration(0), wait)
+
+		domain, wait := limiter.reserve([]string{"john@yahoo.com", "jane@gmail.com"})
+		assert.Equal(t, "gmail.com", domain)
+		assert.Equal(t, time.Minute, wait)
+
+		_, wait = limiter.reserve([]string{"john@yahoo.com"})
+		assert.Equal(t, time.Duration(0), wait)
+	})
+
+	t.Run("lets a second worth through on cold start", func(t *testing.T) {
+		clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+		limiter := newDomainLimiter(&config.RateLimit{PerMinute: 600}, clock.Now)
+
+		for i := 0; i < 10; i++ {
+			_, wait := limiter.reserve([]string{"john@yahoo.com"})
+			assert.Equal(t, time.Duration(0), wait)
+		}
+
+		domain, wait := limiter.reserve([]string{"john@yahoo.com"})
+		assert.Equal(t, "yahoo.com", domain)
+		assert.Equal(t, 100*time.Millisecond, wait)
+	})
+
+	t.Run("doesn't limit unlimited domains", func(t *testing.T) {
+		clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+		limiter := newDomainLimiter(limits, clock.Now)
+
+		for i := 0; i < 10; i++ {
+			_, wait := limiter.reserve([]string{"john@unlimited.com"})
+			assert.Equal(t, time.Duration(0), wait)
+		}
+
+		limiter = newDomainLimiter(nil, clock.Now)
+		for i := 0; i < 10; i++ {
+			_, wait := limiter.reserve([]string{"john@gmail.com"})
+			assert.Equal(t, time.Duration(0), wait)
+		}
+	})
+}
+
+func TestDomainLimiter_Global(t *testing.T) {
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := newDomainLimiter(&config.RateLimit{Global: 120, Burst: 2}, clock.Now)
+
+	for _, address := range []string{"john@gmail.com", "john@yahoo.com"} {
+		_, wait := limiter.reserve([]string{address})
+		assert.Equal(t, time.Duration(0), wait)
+	}
+
+	domain, wait := limiter.reserve([]string{"jane@aol.com"})
+	assert.Equal(t, globalLimit, domain)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// Burst caps refill, however long limiter is idle.
+	clock.now = clock.now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		_, wait = limiter.reserve([]string{"john@gmail.com"})
+		assert.Equal(t, time.Duration(0), wait)
+	}
+	_, wait = limiter.reserve([]string{"john@gmail.com"})
+	assert.Equal(t, 500*time.Millisecond, wait)
+}
+
+func TestDomainLimiter_Wait(t *testing.T) {
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limits := &config.RateLimit{Domains: map[string]config.Rate{"gmail.com": 60}, PerMinute: 1, Burst: 1, MaxWait: "2s"}
+	limiter := newDomainLimiter(limits, clock.Now)
+
+	var slept []time.Duration
+	limiter.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		clock.now = clock.now.Add(d)
+	}
+	m := NewMonitor()
+
+	t.Run("sleeps within max wait", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			domain, wait := limiter.wait([]string{"john@gmail.com"}, m)
+			assert.Equal(t, "", domain)
+			assert.Equal(t, time.Duration(0), wait)
+		}
+		assert.Equal(t, []time.Duration{time.Second, time.Second}, slept)
+		assert.Equal(t, 0, m.waiting)
+		assert.Equal(t, uint64(2), m.throttles["gmail.com"].count)
+	})
+
+	t.Run("returns longer waits", func(t *testing.T) {
+		slept = nil
+
+		_, wait := limiter.wait([]string{"john@yahoo.com"}, m)
+		assert.Equal(t, time.Duration(0), wait)
+
+		domain, wait := limiter.wait([]string{"jane@yahoo.com"}, m)
+		assert.Equal(t, "yahoo.com", domain)
+		assert.Equal(t, time.Minute, wait)
+		assert.Nil(t, slept)
+		assert.Equal(t, uint64(1), m.throttles["default"].count)
+	})
+}
+
+func TestConsumer_RateLimit(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.limiter = newDomainLimiter(&config.RateLimit{PerMinute: 1}, clock.Now)
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.NotNil(t, req.msg)
+
+	req.msg = nil
+	err := c.handle(c.Config.Events[0], fakeMessage())
+	assert.EqualError(t, err, "recipient domain doe.com is throttled, retrying in 1m0s")
+	assert.True(t, amqp.IsRequeue(err))
+	assert.Nil(t, req.msg)
+
+	clock.now = clock.now.Add(time.Minute)
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+	assert.NotNil(t, req.msg)
+}
+
+func TestConsumer_RateLimitGlobal(t *testing.T) {
+	deliver, _ := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	clock := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.limiter = newDomainLimiter(&config.RateLimit{Global: 1}, clock.Now)
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+
+	err := c.handle(c.Config.Events[0], fakeMessage())
+	assert.EqualError(t, err, "sending is throttled, retrying in 1m0s")
+	assert.True(t, amqp.IsRequeue(err))
+}
@@ -0,0 +1,103 @@
+package consumer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+)
+
+// RecipientsHeader lists recipients, which didn't get email of dead-lettered
+// message, so it's sent only to them once replayed, see config.Event.PerRecipient.
+const RecipientsHeader = "x-postmaster-recipients"
+
+// retryRecipients narrows recipients down to the ones listed in RecipientsHeader,
+// when message has it. Unknown addresses of the header are ignored.
+func retryRecipients(headers map[string]interface{}, recipients []string) []string {
+	var listed string
+	switch v := headers[RecipientsHeader].(type) {
+	case string:
+		listed = v
+	case []byte:
+		listed = string(v)
+	default:
+		return recipients
+	}
+
+	retry := make(map[string]bool)
+	for _, address := range strings.Split(listed, ",") {
+		retry[strings.TrimSpace(address)] = true
+	}
+
+	result := make([]string, 0, len(recipients))
+	for _, address := range recipients {
+		if retry[address] {
+			result = append(result, address)
+		}
+	}
+
+	return result
+}
+
+// sendEach sends email with content to every recipient separately.
+// Recipients, which are rejected or not sent to, are named by the returned error,
+// and message is dead-lettered only for them, see config.PerRecipientAny.
+// Message is requeued instead, when any of them failed temporarily and either
+// nobody got email or retry republishes message with their names.
+func sendEach(eventConf config.Event, email Email, content []byte, recipients []string, retry bool, send func(Email) error) error {
+	var failed, reasons []string
+	var temporary bool
+	for _, address := range recipients {
+		err := checkRecipient(eventConf, address)
+		if err == nil {
+			single := email
+			single.ToAddress = address
+			single.CC = nil
+			single.Reader = bytes.NewReader(content)
+			err = send(single)
+		}
+
+		if err != nil {
+			failed = append(failed, address)
+			reasons = append(reasons, fmt.Sprintf("%s: %s", address, err.Error()))
+			temporary = temporary || amqp.OutcomeOf(err) == amqp.OutcomeRequeue
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("%s delivered to %d of %d recipients, failed %s",
+		eventConf.Key, len(recipients)-len(failed), len(recipients), strings.Join(reasons, "; "))
+	switch {
+	case temporary && (retry || len(failed) == len(recipients)):
+		err = amqp.Requeue(err)
+	case len(failed) < len(recipients) && eventConf.PerRecipient == config.PerRecipientAny:
+		err = amqp.Settle(amqp.OutcomeAck, err)
+	}
+
+	return amqp.WithHeaders(err, map[string]string{RecipientsHeader: strings.Join(failed, ", ")})
+}
+
+// checkRecipient rejects address, which email can't be sent to.
+// Address is written into To header, so it must be bare.
+func checkRecipient(eventConf config.Event, address string) error {
+	if parsed, err := mail.ParseAddress(address); err != nil || parsed.Address != address {
+		return fmt.Errorf("address %q is not valid", address)
+	}
+
+	if _, err := envelopeAddress(address); err != nil {
+		return err
+	}
+
+	if !eventConf.AllowsRecipient(address) {
+		return errors.New("outside allowed domains")
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package consumer
+
+import (
+	"errors"
+	"net/textproto"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_PerRecipient(t *testing.T) {
+	var sent []string
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		if email.ToAddress == "bounce@doe.com" {
+			return errors.New("mailbox unavailable")
+		}
+		assert.Empty(t, email.CC)
+		sent = append(sent, email.ToAddress)
+		return nil
+	})
+
+	event := c.Config.Events[0]
+	event.CC = []string{"jane@doe.com", "not-an-address", "bounce@doe.com"}
+
+	t.Run("sends the rest, when recipient fails", func(t *testing.T) {
+		sent = nil
+		event := event
+		event.PerRecipient = config.PerRecipientAny
+
+		err := c.handle(event, fakeMessage())
+		assert.EqualError(t, err, "user.example delivered to 2 of 4 recipients, failed "+
+			"not-an-address: address \"not-an-address\" is not valid; bounce@doe.com: mailbox unavailable")
+		assert.Equal(t, amqp.OutcomeAck, amqp.OutcomeOf(err))
+		assert.Equal(t, map[string]string{RecipientsHeader: "not-an-address, bounce@doe.com"}, amqp.HeadersOf(err))
+		assert.Equal(t, []string{"john@doe.com", "jane@doe.com"}, sent)
+	})
+
+	t.Run("rejects address injecting headers", func(t *testing.T) {
+		sent = nil
+		event := event
+		event.PerRecipient = config.PerRecipientAny
+		event.CC = []string{"jane@doe.com\r\nBcc: spy@evil.com"}
+
+		err := c.handle(event, fakeMessage())
+		assert.Equal(t, map[string]string{RecipientsHeader: "jane@doe.com\r\nBcc: spy@evil.com"}, amqp.HeadersOf(err))
+		assert.Equal(t, []string{"john@doe.com"}, sent)
+	})
+
+	t.Run("fails, unless all recipients got it", func(t *testing.T) {
+		sent = nil
+		event := event
+		event.PerRecipient = config.PerRecipientAll
+
+		err := c.handle(event, fakeMessage())
+		assert.Equal(t, amqp.OutcomeDeadLetter, amqp.OutcomeOf(err))
+		assert.Equal(t, map[string]string{RecipientsHeader: "not-an-address, bounce@doe.com"}, amqp.HeadersOf(err))
+		assert.Equal(t, []string{"john@doe.com", "jane@doe.com"}, sent)
+	})
+
+	t.Run("fails, when nobody got it", func(t *testing.T) {
+		sent = nil
+		event := event
+		event.PerRecipient = config.PerRecipientAny
+		event.AllowedDomains = []string{"example.com"}
+
+		err := c.handle(event, fakeMessage())
+		assert.Equal(t, amqp.OutcomeDeadLetter, amqp.OutcomeOf(err))
+		assert.Equal(t, map[string]string{
+			RecipientsHeader: "john@doe.com, jane@doe.com, not-an-address, bounce@doe.com",
+		}, amqp.HeadersOf(err))
+		assert.Empty(t, sent)
+	})
+
+	t.Run("sends replayed message only to failed recipients", func(t *testing.T) {
+		sent = nil
+		event := event
+		event.PerRecipient = config.PerRecipientAny
+		event.CC = []string{"jane@doe.com"}
+
+		msg := fakeMessage()
+		msg.Headers = map[string]interface{}{RecipientsHeader: "jane@doe.com, bounce@doe.com"}
+
+		assert.NoError(t, c.handle(event, msg))
+		assert.Equal(t, []string{"jane@doe.com"}, sent)
+	})
+
+	t.Run("retries only recipient failed temporarily", func(t *testing.T) {
+		sent = nil
+		c := fakeConsumer(func(_ SMTPConf, email Email) error {
+			if email.ToAddress == "busy@doe.com" {
+				return &textproto.Error{Code: 421, Msg: "try again later"}
+			}
+			sent = append(sent, email.ToAddress)
+			return nil
+		})
+		c.Config.AMQP.Retry = &config.Retry{Exchange: "retry", MaxAttempts: 3}
+
+		event := event
+		event.PerRecipient = config.PerRecipientAny
+		event.CC = []string{"jane@doe.com", "busy@doe.com"}
+
+		err := c.handle(event, fakeMessage())
+		assert.Equal(t, amqp.OutcomeRequeue, amqp.OutcomeOf(err))
+		assert.Equal(t, map[string]string{RecipientsHeader: "busy@doe.com"}, amqp.HeadersOf(err))
+		assert.Equal(t, []string{"john@doe.com", "jane@doe.com"}, sent)
+
+		sent = nil
+		c.Config.AMQP.Retry = nil
+		err = c.handle(event, fakeMessage())
+		assert.Equal(t, amqp.OutcomeAck, amqp.OutcomeOf(err), "requeue without retry would resend to everyone")
+		assert.Equal(t, map[string]string{RecipientsHeader: "busy@doe.com"}, amqp.HeadersOf(err))
+
+		sent = nil
+		msg := fakeMessage()
+		msg.Headers = map[string]interface{}{RecipientsHeader: "busy@doe.com"}
+		err = c.handle(event, msg)
+		assert.Equal(t, amqp.OutcomeRequeue, amqp.OutcomeOf(err), "nobody got it, so it's requeued as is")
+		assert.Empty(t, sent)
+	})
+
+	t.Run("fails whole message by default", func(t *testing.T) {
+		sent = nil
+
+		err := c.handle(event, fakeMessage())
+		assert.EqualError(t, err, "cc for user.example: cc address \"not-an-address\": mail: missing '@' or angle-addr")
+		assert.Empty(t, sent)
+	})
+}
@@ -0,0 +1,129 @@
+package consumer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// conf returns active config, which Reload replaces.
+func (c *Consumer) conf() *config.Config {
+	if conf, ok := c.active.Load().(*config.Config); ok {
+		return conf
+	}
+
+	return c.Config
+}
+
+// eventOf returns event of conf by key, disabled one included.
+func eventOf(conf *config.Config, key string) (config.Event, bool) {
+	for _, eventConf := range conf.Events {
+		if eventConf.Key == key {
+			return eventConf, true
+		}
+	}
+
+	return config.Event{}, false
+}
+
+// Reload makes messages processed from now on use conf. Conf is rejected,
+// when events name senders, which aren't configured, or templates don't fit Types.
+//
+// Broker settings, senders and delivery are applied on restart only,
+// as well as new event keys, since queue bindings are kept. Messages of
+// removed events fail. Runtime overrides are dropped.
+func (c *Consumer) Reload(conf *Config) error {
+	if c.Types != nil {
+		if err := conf.CheckFields(c.Types.Type); err != nil {
+			return err
+		}
+	}
+
+	for _, eventConf := range conf.Events {
+		if _, ok := c.Senders[eventConf.Sender]; eventConf.Sender != "" && !ok {
+			return fmt.Errorf("sender \"%s\" of event \"%s\" is not configured", eventConf.Sender, eventConf.Key)
+		}
+	}
+
+	current := c.conf()
+	if !reflect.DeepEqual(current.AMQP, conf.AMQP) {
+		log.Printf("config reload: amqp settings are applied on restart")
+	}
+	if !reflect.DeepEqual(current.Senders, conf.Senders) || !reflect.DeepEqual(current.Delivery, conf.Delivery) {
+		log.Printf("config reload: senders and delivery are applied on restart")
+	}
+	for _, eventConf := range conf.Events {
+		if _, ok := eventOf(current, eventConf.Key); !ok {
+			log.Printf("config reload: event \"%s\" is consumed after restart", eventConf.Key)
+		}
+	}
+
+	c.mu.Lock()
+	if !reflect.DeepEqual(current.RateLimit, conf.RateLimit) {
+		// Created again from new config on next use.
+		c.limiter = nil
+	}
+	c.active.Store(conf)
+	c.mu.Unlock()
+
+	log.Printf("Config reloaded\n")
+	return nil
+}
+
+// ReloadFile loads config at path and reloads consumer with it, see Reload.
+// Active config is kept, when the new one is invalid.
+func (c *Consumer) ReloadFile(path string, opts LoadOptions) error {
+	conf, err := LoadConfig(path, opts)
+	if err != nil {
+		return err
+	}
+
+	return c.Reload(conf)
+}
+
+// reloadFile is ReloadFile logging failure, since it runs in background.
+func (c *Consumer) reloadFile(path string, opts config.LoadOptions) {
+	if err := c.ReloadFile(path, opts); err != nil {
+		log.Printf("config reload: %s, keeping active config", err.Error())
+	}
+}
+
+// fileStamp identifies version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stamps returns versions of config file at path and template files of
+// active config. Templates, which aren't files on disk, are skipped.
+func (c *Consumer) stamps(path string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+
+	for _, file := range append([]string{path}, c.conf().TemplateRefs()...) {
+		if info, err := os.Stat(file); err == nil {
+			stamps[file] = fileStamp{info.ModTime(), info.Size()}
+		}
+	}
+
+	return stamps
+}
+
+// watch reloads config at path every interval, when it or template files changed.
+func (c *Consumer) watch(path string, opts config.LoadOptions, interval time.Duration) {
+	last := c.stamps(path)
+
+	for range time.Tick(interval) {
+		stamps := c.stamps(path)
+		if reflect.DeepEqual(stamps, last) {
+			continue
+		}
+
+		c.reloadFile(path, opts)
+		// Files of the reloaded config are stamped, so new templates are watched.
+		last = c.stamps(path)
+	}
+}
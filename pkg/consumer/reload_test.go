@@ -0,0 +1,116 @@
+package consumer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const reloadConfig = `
+amqp:
+  exchange: events
+languages:
+- code: EN
+  name: English
+events:
+- name: Example
+  key: user.example
+  templates:
+    EN:
+      subject: %s
+      template_path: %s
+`
+
+func writeReloadConfig(t *testing.T, dir, subject string) string {
+	tpl := filepath.Join(dir, "example.tpl")
+	assert.NoError(t, ioutil.WriteFile(tpl, []byte("Hello {{ .user.email }}"), 0644))
+
+	path := filepath.Join(dir, "postmaster.yml")
+	content := []byte(fmt.Sprintf(reloadConfig, subject, tpl))
+	assert.NoError(t, ioutil.WriteFile(path, content, 0644))
+	return path
+}
+
+func TestConsumer_Reload(t *testing.T) {
+	t.Run("swaps config", func(t *testing.T) {
+		var subjects []string
+		c := fakeConsumer(func(_ SMTPConf, email Email) error {
+			subjects = append(subjects, email.Subject)
+			return nil
+		})
+		original := c.Config
+
+		conf := fakeConfig()
+		conf.Events[0].Templates["EN"] = config.Template{Subject: "Reloaded", Template: "Hi"}
+		assert.NoError(t, c.Reload(conf))
+
+		event, ok := eventOf(c.conf(), "user.example")
+		assert.True(t, ok)
+		assert.NoError(t, c.handle(event, fakeMessage()))
+
+		assert.Equal(t, []string{"Reloaded"}, subjects)
+		assert.Equal(t, original, c.Config)
+	})
+
+	t.Run("unknown sender", func(t *testing.T) {
+		c := fakeConsumer(nil)
+
+		conf := fakeConfig()
+		conf.Events[0].Sender = "ses"
+		assert.EqualError(t, c.Reload(conf), "sender \"ses\" of event \"user.example\" is not configured")
+		assert.Equal(t, c.Config, c.conf())
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		c := fakeConsumer(nil)
+		limiter := c.domainLimiter()
+
+		assert.NoError(t, c.Reload(fakeConfig()))
+		assert.Equal(t, limiter, c.domainLimiter())
+
+		conf := fakeConfig()
+		conf.RateLimit = &config.RateLimit{PerMinute: 1}
+		assert.NoError(t, c.Reload(conf))
+		assert.NotEqual(t, limiter, c.domainLimiter())
+	})
+}
+
+func TestConsumer_ReloadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "postmaster")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := writeReloadConfig(t, dir, "First")
+	c := fakeConsumer(nil)
+
+	assert.NoError(t, c.ReloadFile(path, config.LoadOptions{}))
+	assert.Equal(t, "First", c.conf().Events[0].Templates["EN"].Subject)
+
+	t.Run("invalid config is rejected", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("events:\n- name: Broken\n  key: broken\n"), 0644))
+
+		assert.Error(t, c.ReloadFile(path, config.LoadOptions{}))
+		assert.Equal(t, "First", c.conf().Events[0].Templates["EN"].Subject)
+	})
+
+	t.Run("stamps", func(t *testing.T) {
+		path := writeReloadConfig(t, dir, "Second")
+		assert.NoError(t, c.ReloadFile(path, config.LoadOptions{}))
+		stamps := c.stamps(path)
+		assert.Len(t, stamps, 2)
+		assert.Equal(t, stamps, c.stamps(path))
+
+		// Modification time may not change within filesystem resolution, size does.
+		later := time.Now().Add(time.Minute)
+		tpl := filepath.Join(dir, "example.tpl")
+		assert.NoError(t, ioutil.WriteFile(tpl, []byte("Hello again"), 0644))
+		assert.NoError(t, os.Chtimes(tpl, later, later))
+		assert.NotEqual(t, stamps, c.stamps(path))
+	})
+}
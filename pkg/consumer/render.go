@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/eventapi"
+)
+
+// ErrRenderTimeout is reported, when rendering of email exceeds
+// render timeout of config, see config.Config.RenderBudget.
+var ErrRenderTimeout = errors.New("render timed out")
+
+// rendered is subject and body of email.
+type rendered struct {
+	subject     string
+	contentType string
+	content     []byte
+}
+
+// render renders subject and body of template in language lang with attachments
+// of event, followed by files of the message. Body of hosted template is left to provider.
+func (c *Consumer) render(eventConf config.Event, tpl config.Template, key, lang string, data interface{}, event eventapi.Event, preferred string, files []config.RenderedAttachment) (rendered, error) {
+	var r rendered
+	var err error
+
+	if r.subject, err = tpl.RenderSubject(data, c.conf().Subjects); err != nil {
+		return r, fmt.Errorf("subject for %s/%s: %s", eventConf.Key, key, err.Error())
+	}
+
+	if tpl.Hosted() {
+		return r, nil
+	}
+
+	post := func(body []byte) ([]byte, error) {
+		body, err := c.conf().ProcessHTML(&eventConf, lang, body)
+		if err != nil {
+			return nil, err
+		}
+
+		// Links added by post-processors are tracked as well.
+		if body, err = c.conf().TrackLinks(&eventConf, body, event); err != nil {
+			return nil, err
+		}
+
+		return c.conf().TrackOpens(&eventConf, body, event)
+	}
+
+	r.contentType, r.content, err = c.body(tpl, data, event, preferred, post)
+	if err != nil {
+		return r, fmt.Errorf("template for %s/%s: %s", eventConf.Key, key, err.Error())
+	}
+
+	attachments, err := eventConf.RenderAttachments(data)
+	if err != nil {
+		return r, fmt.Errorf("attachments for %s: %s", eventConf.Key, err.Error())
+	}
+
+	r.contentType, r.content, err = c.attach(r.contentType, r.content, append(attachments, files...))
+	return r, err
+}
+
+// withinRenderBudget runs render, giving up with ErrRenderTimeout once render
+// budget of config is over. Go templates can't be interrupted, so render
+// left behind finishes in background.
+func (c *Consumer) withinRenderBudget(render func() error) error {
+	budget := c.conf().RenderBudget()
+	if budget <= 0 {
+		return render()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- render()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ErrRenderTimeout
+	}
+}
@@ -0,0 +1,52 @@
+package consumer
+
+import (
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_RenderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	assert.NoError(t, RegisterFuncs(template.FuncMap{"stall": func() string {
+		<-release
+		return "stalled"
+	}}))
+
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Config.RenderTimeout = "20ms"
+
+	t.Run("dead-letters slow render", func(t *testing.T) {
+		event := c.Config.Events[0]
+		event.Templates = map[string]config.Template{"EN": {Subject: "Slow", Template: "{{ stall }}"}}
+
+		start := time.Now()
+		err := c.handle(event, fakeMessage())
+
+		assert.EqualError(t, err, "render user.example/EN: render timed out")
+		assert.Equal(t, amqp.OutcomeDeadLetter, amqp.OutcomeOf(err))
+		assert.True(t, time.Since(start) < time.Second)
+		assert.Nil(t, req.msg)
+	})
+
+	t.Run("sends render within budget", func(t *testing.T) {
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.Contains(t, string(req.msg), "Hello john@doe.com")
+	})
+
+	t.Run("reports render errors as is", func(t *testing.T) {
+		event := c.Config.Events[0]
+		event.Templates = map[string]config.Template{"EN": {Subject: "{{ index 1 2 }}", Template: "Hi"}}
+
+		err := c.handle(event, fakeMessage())
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrRenderTimeout, err)
+		assert.Contains(t, err.Error(), "subject for user.example/EN")
+	})
+}
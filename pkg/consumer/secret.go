@@ -0,0 +1,54 @@
+package consumer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openware/postmaster/internal/config"
+)
+
+// SecretResolver resolves references of "secret://<ref>" credentials,
+// e.g. backed by Vault or AWS Secrets Manager.
+type SecretResolver interface {
+	Resolve(ref string) (string, error)
+}
+
+// SecretResolverFunc adapts function to SecretResolver.
+type SecretResolverFunc func(ref string) (string, error)
+
+// Resolve calls f(ref).
+func (f SecretResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// WithSecretResolver makes "secret://<ref>" credentials resolved through resolver,
+// once consumer is created, see NewConsumer.
+func WithSecretResolver(resolver SecretResolver) Option {
+	return func(c *Consumer) {
+		c.secrets = resolver
+	}
+}
+
+// credential returns value of credential named name, resolving it through
+// secrets, when it's a secret reference. Other values are returned as is.
+func credential(secrets SecretResolver, name, value string) (string, error) {
+	if !strings.HasPrefix(value, config.SecretScheme) {
+		return value, nil
+	}
+
+	ref := strings.TrimPrefix(value, config.SecretScheme)
+	if ref == "" {
+		return "", fmt.Errorf("%s: secret reference is empty", name)
+	}
+
+	if secrets == nil {
+		return "", fmt.Errorf("%s: secret \"%s\" can't be resolved: secret resolver is not set", name, ref)
+	}
+
+	secret, err := secrets.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("%s: secret \"%s\" can't be resolved: %s", name, ref, err.Error())
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,119 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/internal/delivery"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/openware/postmaster/pkg/env"
+)
+
+// Sender delivers rendered emails, e.g. through SMTP or provider API.
+// Send should give up with ctx.Err() once ctx is done.
+type Sender interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// SenderFunc adapts function to Sender.
+type SenderFunc func(ctx context.Context, email Email) error
+
+// Send calls f(ctx, email).
+func (f SenderFunc) Send(ctx context.Context, email Email) error {
+	return f(ctx, email)
+}
+
+// SMTPSender delivers emails through SMTP server.
+type SMTPSender struct {
+	Conf SMTPConf
+}
+
+// Send delivers email through EmailSender.
+func (s *SMTPSender) Send(ctx context.Context, email Email) error {
+	return NewEmailSender(s.Conf, email).Send(ctx)
+}
+
+// apiSender delivers emails through provider API.
+type apiSender struct {
+	api delivery.Sender
+}
+
+// Send delivers email through provider API, requeuing it while provider
+// throttles or fails.
+func (s *apiSender) Send(ctx context.Context, email Email) error {
+	body, err := ioutil.ReadAll(email.Reader)
+	if err != nil {
+		return err
+	}
+
+	err = s.api.Send(ctx, delivery.Message{
+		FromAddress: email.FromAddress,
+		FromName:    email.FromName,
+		ToAddress:   email.ToAddress,
+		CC:          email.CC,
+		Subject:     email.Subject,
+		ContentType: email.ContentType,
+		Headers:     email.Headers,
+		Body:        body,
+		TemplateID:  email.ProviderTemplateID,
+		Data:        email.Data,
+	})
+	if status, ok := err.(*delivery.StatusError); ok && status.Temporary() {
+		return amqp.Requeue(err)
+	}
+
+	return err
+}
+
+// configSenders creates senders configured by name, signing SMTP ones with dkim,
+// taking their passwords from environment or secret references resolved through secrets.
+func configSenders(senders map[string]config.Sender, dkim *DKIMSigner, secrets SecretResolver) (map[string]Sender, error) {
+	result := make(map[string]Sender, len(senders))
+	for name, sender := range senders {
+		created, err := configSender(fmt.Sprintf("sender \"%s\"", name), sender, dkim, secrets)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = created
+	}
+
+	return result, nil
+}
+
+// configSender creates sender of SMTP server or provider API, label names it in errors.
+func configSender(label string, sender config.Sender, dkim *DKIMSigner, secrets SecretResolver) (Sender, error) {
+	password := sender.Password
+	if password == "" {
+		var err error
+		if password, err = env.Fetch(sender.PasswordEnv); err != nil {
+			return nil, fmt.Errorf("%s password: %s", label, err.Error())
+		}
+	}
+
+	username, err := credential(secrets, label+" username", sender.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if password, err = credential(secrets, label+" password", password); err != nil {
+		return nil, err
+	}
+
+	if sender.API() {
+		api, err := delivery.New(sender, username, password)
+		if err != nil {
+			return nil, err
+		}
+		return &apiSender{api: api}, nil
+	}
+
+	return &SMTPSender{Conf: SMTPConf{
+		Host:     sender.Host,
+		Port:     sender.Port,
+		Username: username,
+		Password: password,
+		DKIM:     dkim,
+	}}, nil
+}
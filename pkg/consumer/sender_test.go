@@ -0,0 +1,263 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/internal/delivery"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_Senders(t *testing.T) {
+	smtp := 0
+	c := fakeConsumer(func(SMTPConf, Email) error {
+		smtp++
+		return nil
+	})
+
+	var transactional, bulk []string
+	WithSender("ses", SenderFunc(func(_ context.Context, email Email) error {
+		transactional = append(transactional, email.ToAddress)
+		return nil
+	}))(c)
+	WithSender("bulk", SenderFunc(func(_ context.Context, email Email) error {
+		bulk = append(bulk, email.ToAddress)
+		return nil
+	}))(c)
+
+	confirmation := c.Config.Events[0]
+	confirmation.Sender = "ses"
+	newsletter := c.Config.Events[0]
+	newsletter.Key = "user.newsletter"
+	newsletter.Sender = "bulk"
+
+	t.Run("routes events to named senders", func(t *testing.T) {
+		assert.NoError(t, c.handle(confirmation, fakeMessage()))
+		assert.NoError(t, c.handle(newsletter, fakeMessage()))
+		assert.NoError(t, c.handle(newsletter, fakeMessage()))
+
+		assert.Equal(t, []string{"john@doe.com"}, transactional)
+		assert.Equal(t, []string{"john@doe.com", "john@doe.com"}, bulk)
+		assert.Equal(t, 0, smtp)
+	})
+
+	t.Run("sends other events through SMTP", func(t *testing.T) {
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.Equal(t, 1, smtp)
+	})
+
+	t.Run("sends other events through delivery", func(t *testing.T) {
+		var delivered []string
+		WithDelivery(SenderFunc(func(_ context.Context, email Email) error {
+			delivered = append(delivered, email.ToAddress)
+			return nil
+		}))(c)
+		defer WithDelivery(nil)(c)
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.Equal(t, []string{"john@doe.com"}, delivered)
+		assert.Equal(t, 1, smtp)
+	})
+
+	t.Run("fails on unknown sender", func(t *testing.T) {
+		event := c.Config.Events[0]
+		event.Sender = "sendgrid"
+
+		assert.EqualError(t, c.handle(event, fakeMessage()), "sender \"sendgrid\" is not configured")
+	})
+}
+
+func TestConsumer_ProviderTemplate(t *testing.T) {
+	c := fakeConsumer(func(SMTPConf, Email) error {
+		t.Fatal("provider template sent through SMTP")
+		return nil
+	})
+
+	var sent Email
+	WithSender("sendgrid", SenderFunc(func(_ context.Context, email Email) error {
+		sent = email
+		return nil
+	}))(c)
+
+	event := c.Config.Events[0]
+	event.Sender = "sendgrid"
+	event.Templates = map[string]config.Template{"EN": {ProviderTemplateID: "d-123", Subject: "Hello"}}
+
+	assert.NoError(t, c.handle(event, fakeMessage()))
+	assert.Equal(t, "d-123", sent.ProviderTemplateID)
+	assert.Equal(t, "Hello", sent.Subject)
+	assert.Empty(t, sent.ContentType)
+	assert.Equal(t, "john@doe.com", sent.Data["user"].(map[string]interface{})["email"])
+}
+
+func TestConsumer_SendTimeout(t *testing.T) {
+	c := fakeConsumer(nil)
+	c.SendTimeout = 10 * time.Millisecond
+
+	var result DeliveryResult
+	WithDeliveryHook(func(r DeliveryResult) { result = r })(c)
+	WithSender("hung", SenderFunc(func(ctx context.Context, email Email) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))(c)
+
+	event := c.Config.Events[0]
+	event.Sender = "hung"
+
+	err := c.handle(event, fakeMessage())
+	assert.EqualError(t, err, "context deadline exceeded")
+	assert.True(t, amqp.IsRequeue(err))
+	assert.Equal(t, context.DeadlineExceeded, result.Err)
+
+	t.Run("transient SMTP failures are requeued", func(t *testing.T) {
+		WithSender("hung", SenderFunc(func(context.Context, Email) error {
+			return &textproto.Error{Code: 421, Msg: "service not available"}
+		}))(c)
+
+		err := c.handle(event, fakeMessage())
+		assert.Contains(t, err.Error(), "service not available")
+		assert.True(t, amqp.IsRequeue(err))
+	})
+
+	t.Run("failures within deadline are not requeued", func(t *testing.T) {
+		WithSender("hung", SenderFunc(func(context.Context, Email) error {
+			return errors.New("mailbox unavailable")
+		}))(c)
+
+		err := c.handle(event, fakeMessage())
+		assert.EqualError(t, err, "mailbox unavailable")
+		assert.False(t, amqp.IsRequeue(err))
+	})
+}
+
+func TestConfigSenders(t *testing.T) {
+	os.Setenv("BULK_SMTP_PASSWORD", "secret")
+	defer os.Unsetenv("BULK_SMTP_PASSWORD")
+
+	senders, err := configSenders(map[string]config.Sender{
+		"bulk": {Host: "smtp.bulk.io", Port: "587", Username: "postmaster", PasswordEnv: "BULK_SMTP_PASSWORD"},
+	}, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &SMTPSender{Conf: SMTPConf{
+		Host:     "smtp.bulk.io",
+		Port:     "587",
+		Username: "postmaster",
+		Password: "secret",
+	}}, senders["bulk"])
+
+	t.Run("provider API", func(t *testing.T) {
+		senders, err := configSenders(map[string]config.Sender{
+			"sendgrid": {Provider: config.ProviderSendGrid, PasswordEnv: "BULK_SMTP_PASSWORD"},
+		}, nil, nil)
+		assert.NoError(t, err)
+
+		assert.Equal(t, &apiSender{api: delivery.NewSendGrid("secret")}, senders["sendgrid"])
+	})
+
+	t.Run("missing password", func(t *testing.T) {
+		_, err := configSenders(map[string]config.Sender{
+			"bulk": {Host: "smtp.bulk.io", Port: "587", PasswordEnv: "MISSING_SMTP_PASSWORD"},
+		}, nil, nil)
+		assert.EqualError(t, err, "sender \"bulk\" password: environment variable MISSING_SMTP_PASSWORD does not set")
+	})
+}
+
+type fakeAPI struct {
+	sent []delivery.Message
+	err  error
+}
+
+func (f *fakeAPI) Send(_ context.Context, msg delivery.Message) error {
+	f.sent = append(f.sent, msg)
+	return f.err
+}
+
+func TestAPISender(t *testing.T) {
+	api := &fakeAPI{}
+	sender := &apiSender{api: api}
+
+	email := Email{
+		FromAddress: "noreply@example.com",
+		FromName:    "Example",
+		ToAddress:   "john@doe.com",
+		CC:          []string{"jane@doe.com"},
+		Subject:     "Welcome",
+		ContentType: "text/html; charset=utf-8",
+		Headers:     map[string]string{CorrelationHeader: "42"},
+		Reader:      strings.NewReader("<p>Hi</p>"),
+	}
+
+	assert.NoError(t, sender.Send(context.Background(), email))
+	assert.Equal(t, []delivery.Message{{
+		FromAddress: "noreply@example.com",
+		FromName:    "Example",
+		ToAddress:   "john@doe.com",
+		CC:          []string{"jane@doe.com"},
+		Subject:     "Welcome",
+		ContentType: "text/html; charset=utf-8",
+		Headers:     map[string]string{CorrelationHeader: "42"},
+		Body:        []byte("<p>Hi</p>"),
+	}}, api.sent)
+
+	t.Run("requeues while provider throttles", func(t *testing.T) {
+		email.Reader = strings.NewReader("<p>Hi</p>")
+		api.err = &delivery.StatusError{Provider: "sendgrid", Code: 429, Body: "slow down"}
+
+		err := sender.Send(context.Background(), email)
+		assert.EqualError(t, err, "sendgrid: status 429: slow down")
+		assert.True(t, amqp.IsRequeue(err))
+	})
+
+	t.Run("rejected messages are not requeued", func(t *testing.T) {
+		email.Reader = strings.NewReader("<p>Hi</p>")
+		api.err = &delivery.StatusError{Provider: "sendgrid", Code: 400, Body: "bad request"}
+
+		err := sender.Send(context.Background(), email)
+		assert.EqualError(t, err, "sendgrid: status 400: bad request")
+		assert.False(t, amqp.IsRequeue(err))
+	})
+}
+
+func TestNewConsumer_Delivery(t *testing.T) {
+	os.Setenv("SENDER_EMAIL", "postmaster@example.com")
+	defer os.Unsetenv("SENDER_EMAIL")
+	os.Setenv("SES_SECRET_KEY", "secret")
+	defer os.Unsetenv("SES_SECRET_KEY")
+
+	// SMTP_PASSWORD is unset, since SMTP settings aren't read with delivery.
+	c, err := NewConsumer(&config.Config{Delivery: &config.Sender{
+		Provider:    config.ProviderSES,
+		Region:      "eu-west-1",
+		Username:    "AKID",
+		PasswordEnv: "SES_SECRET_KEY",
+	}})
+	assert.NoError(t, err)
+
+	api := c.Delivery.(*apiSender).api.(*delivery.SES)
+	assert.Equal(t, "eu-west-1", api.Region)
+	assert.Equal(t, "AKID", api.KeyID)
+	assert.Equal(t, "secret", api.SecretKey)
+	assert.Equal(t, SMTPConf{}, c.SMTP)
+}
+
+func TestRequireEnvs_Delivery(t *testing.T) {
+	os.Setenv("JWT_PUBLIC_KEY", "key")
+	defer os.Unsetenv("JWT_PUBLIC_KEY")
+	os.Setenv("SENDER_EMAIL", "postmaster@example.com")
+	defer os.Unsetenv("SENDER_EMAIL")
+
+	assert.PanicsWithValue(t, "environment variable SMTP_PASSWORD does not set", func() {
+		requireEnvs(&config.Config{})
+	})
+	assert.NotPanics(t, func() {
+		requireEnvs(&config.Config{Delivery: &config.Sender{Provider: config.ProviderSendGrid}})
+	})
+}
@@ -0,0 +1,154 @@
+package consumer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// sendMail is smtp.SendMail, which gives up once ctx is done. Connection
+// deadline is taken from ctx, so hung servers don't block the worker.
+func sendMail(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	return withSMTPConn(ctx, addr, func(conn net.Conn) error {
+		return smtpSession(conn, addr, a, from, to, msg)
+	})
+}
+
+// temporarySMTP reports whether err of SMTP send may go away on its own:
+// server is unreachable or answered with 4xx transient failure.
+func temporarySMTP(err error) bool {
+	switch e := err.(type) {
+	case *net.OpError:
+		return true
+	case *textproto.Error:
+		return e.Code/100 == 4
+	}
+
+	return false
+}
+
+// checkSMTP verifies SMTP server at addr greets and accepts EHLO.
+func checkSMTP(ctx context.Context, addr string) error {
+	return withSMTPConn(ctx, addr, func(conn net.Conn) error {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+
+		c, err := smtp.NewClient(conn, host)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		if err := c.Hello("localhost"); err != nil {
+			return err
+		}
+
+		return c.Quit()
+	})
+}
+
+// withSMTPConn runs session over connection to addr, which is
+// interrupted once ctx is done. ctx.Err() is returned then.
+func withSMTPConn(ctx context.Context, addr string, session func(conn net.Conn) error) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// Cancellation interrupts pending reads and writes.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	if err := session(conn); err != nil {
+		// Connection deadline may fire before ctx notices its own.
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return context.DeadlineExceeded
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	return nil
+}
+
+// smtpSession sends msg over conn the way smtp.SendMail does.
+func smtpSession(conn net.Conn, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	for _, line := range append([]string{from}, to...) {
+		if strings.ContainsAny(line, "\r\n") {
+			return errors.New("smtp: A line must not contain CR or LF")
+		}
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if a != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,147 @@
+package consumer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTP serves connections of listener with reply, which gets
+// command lines and returns response; no response hangs the session.
+func fakeSMTP(t *testing.T, reply func(line string) string) (string, *[]string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	var received []string
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		greeting := reply("")
+		if greeting == "" {
+			// Hung server keeps connection open until client leaves.
+			r.ReadString(0)
+			return
+		}
+		conn.Write([]byte(greeting + "\r\n"))
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			received = append(received, line)
+			if response := reply(line); response != "" {
+				conn.Write([]byte(response + "\r\n"))
+			}
+		}
+	}()
+
+	return listener.Addr().String(), &received
+}
+
+func TestSendMail(t *testing.T) {
+	hung := func(string) string { return "" }
+
+	t.Run("sends message", func(t *testing.T) {
+		data := false
+		addr, received := fakeSMTP(t, func(line string) string {
+			switch {
+			case line == "":
+				return "220 localhost ESMTP"
+			case data && line == ".":
+				data = false
+				return "250 queued"
+			case data:
+				return ""
+			case line == "DATA":
+				data = true
+				return "354 go ahead"
+			case line == "QUIT":
+				return "221 bye"
+			default:
+				return "250 OK"
+			}
+		})
+
+		err := sendMail(context.Background(), addr, nil, "from@postmaster.com", []string{"to@postmaster.com"}, []byte("Hi\r\n"))
+		assert.NoError(t, err)
+		assert.Contains(t, *received, "MAIL FROM:<from@postmaster.com>")
+		assert.Contains(t, *received, "RCPT TO:<to@postmaster.com>")
+		assert.Contains(t, *received, "Hi")
+	})
+
+	t.Run("gives up on hung server after deadline", func(t *testing.T) {
+		addr, _ := fakeSMTP(t, hung)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		err := sendMail(ctx, addr, nil, "from@postmaster.com", []string{"to@postmaster.com"}, nil)
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+
+	t.Run("gives up on hung server once canceled", func(t *testing.T) {
+		addr, _ := fakeSMTP(t, hung)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		err := sendMail(ctx, addr, nil, "from@postmaster.com", []string{"to@postmaster.com"}, nil)
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("rejects line breaks in addresses", func(t *testing.T) {
+		addr, _ := fakeSMTP(t, func(string) string { return "220 localhost ESMTP" })
+
+		err := sendMail(context.Background(), addr, nil, "from@postmaster.com", []string{"to@postmaster.com\r\nRCPT TO:<x@y.com>"}, nil)
+		assert.EqualError(t, err, "smtp: A line must not contain CR or LF")
+	})
+}
+
+func TestTemporarySMTP(t *testing.T) {
+	t.Run("server is down", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		err = sendMail(context.Background(), addr, nil, "from@postmaster.com", []string{"to@postmaster.com"}, nil)
+		assert.True(t, temporarySMTP(err), err)
+	})
+
+	t.Run("transient reply", func(t *testing.T) {
+		addr, _ := fakeSMTP(t, func(line string) string {
+			switch {
+			case line == "":
+				return "220 localhost ESMTP"
+			case strings.HasPrefix(line, "EHLO"):
+				return "250 localhost"
+			case strings.HasPrefix(line, "MAIL"):
+				return "451 4.3.0 Try again later"
+			}
+			return "250 OK"
+		})
+
+		err := sendMail(context.Background(), addr, nil, "from@postmaster.com", []string{"to@postmaster.com"}, nil)
+		assert.Equal(t, 451, err.(*textproto.Error).Code)
+		assert.True(t, temporarySMTP(err))
+	})
+
+	t.Run("permanent failures", func(t *testing.T) {
+		assert.False(t, temporarySMTP(&textproto.Error{Code: 550, Msg: "mailbox unavailable"}))
+		assert.False(t, temporarySMTP(errors.New("mailbox unavailable")))
+	})
+}
@@ -0,0 +1,113 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/openware/postmaster/pkg/eventapi"
+	"github.com/openware/postmaster/pkg/kafka"
+)
+
+// Source delivers messages of events to handlers registered by event key,
+// e.g. amqp.ServeMux or kafka.Source.
+type Source interface {
+	HandleFunc(routingKey string, handler func(msg amqp.Message) error)
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+	Connected() bool
+}
+
+// KafkaConfig is the kafka section of config.
+type KafkaConfig = config.Kafka
+
+// KafkaDialer connects reader of topics as a member of consumer group of conf,
+// e.g. adapter of a Kafka client library, see Consumer.KafkaDialer.
+type KafkaDialer func(conf KafkaConfig, topics []string) (kafka.Reader, error)
+
+// newSources creates sources of config: AMQP, unless it's disabled, and Kafka, when configured.
+func (c *Consumer) newSources() ([]Source, error) {
+	var sources []Source
+
+	if !c.conf().AMQP.Disabled {
+		serveMux, err := c.newServeMux()
+		if err != nil {
+			return nil, err
+		}
+		serveMux.SetMaxMessages(c.MaxMessages)
+		sources = append(sources, serveMux)
+	}
+
+	if c.conf().Kafka != nil {
+		// Offsets are committed in order, so records beyond the limit can't be handed back.
+		if c.MaxMessages > 0 {
+			return nil, errors.New("max messages are not supported by kafka")
+		}
+
+		source, err := c.newKafkaSource()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
+
+// newKafkaSource creates source of configured events reading topics of kafka config.
+func (c *Consumer) newKafkaSource() (*kafka.Source, error) {
+	if c.KafkaDialer == nil {
+		return nil, errors.New("kafka: no client to dial with, see consumer.WithKafkaDialer")
+	}
+
+	conf := *c.conf().Kafka
+	var keys []string
+	for _, event := range c.conf().Events {
+		keys = append(keys, event.Key)
+	}
+
+	reader, err := c.KafkaDialer(conf, conf.Topics(keys))
+	if err != nil {
+		return nil, fmt.Errorf("kafka: %s", err.Error())
+	}
+
+	source := kafka.NewSource(reader, func(record kafka.Record) (string, bool) {
+		return conf.EventKey(record.Topic, record.Headers)
+	})
+	if decoder, ok := eventapi.PayloadDecoderFor(c.conf().WithDefaults().AMQP.PayloadFormat); ok {
+		source.SetPayloadDecoder(decoder)
+	}
+
+	if err := c.handleEvents(source); err != nil {
+		return nil, err
+	}
+
+	return source, nil
+}
+
+// listenAndServe serves sources until all of them are over, shutting down
+// the rest once one fails.
+func listenAndServe(sources []Source) error {
+	errs := make(chan error, len(sources))
+	for _, source := range sources {
+		go func(source Source) {
+			errs <- source.ListenAndServe()
+		}(source)
+	}
+
+	var first error
+	for range sources {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+			for _, source := range sources {
+				ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+				source.Shutdown(ctx)
+				cancel()
+			}
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"log"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/suppression"
+)
+
+// openSuppressions opens store of suppressed addresses, nil without suppression.
+func openSuppressions(conf *config.Suppression) (suppression.Store, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	if conf.Store == config.SuppressionFile {
+		return suppression.OpenFileStore(conf.Path)
+	}
+
+	return suppression.NewMemoryStore(), nil
+}
+
+// suppress drops suppressed CC addresses of email, reporting whether
+// email must not be sent at all, since its recipient is suppressed.
+func (c *Consumer) suppress(email Email) (Email, bool, error) {
+	if c.Suppressions == nil {
+		return email, false, nil
+	}
+
+	if _, ok, err := c.Suppressions.Get(email.ToAddress); err != nil || ok {
+		return email, ok, err
+	}
+
+	var cc []string
+	for _, address := range email.CC {
+		_, ok, err := c.Suppressions.Get(address)
+		if err != nil {
+			return email, false, err
+		}
+
+		if ok {
+			log.Printf("Skipping suppressed cc address %s\n", address)
+			continue
+		}
+		cc = append(cc, address)
+	}
+	email.CC = cc
+
+	return email, false, nil
+}
@@ -0,0 +1,52 @@
+package consumer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/suppression"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_Suppressions(t *testing.T) {
+	var emails []Email
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		emails = append(emails, email)
+		return nil
+	})
+	c.Config.Events[0].CC = []string{"audit@example.com", "bounced@example.com"}
+
+	store := suppression.NewMemoryStore()
+	store.Add(suppression.Entry{Address: "bounced@example.com", Reason: suppression.ReasonBounce})
+	WithSuppressions(store)(c)
+	WithMonitor(NewMonitor())(c)
+
+	t.Run("suppressed cc", func(t *testing.T) {
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.Len(t, emails, 1)
+		assert.Equal(t, []string{"audit@example.com"}, emails[0].CC)
+	})
+
+	t.Run("suppressed recipient", func(t *testing.T) {
+		store.Add(suppression.Entry{Address: "John@doe.com", Reason: suppression.ReasonComplaint})
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.Len(t, emails, 1)
+
+		buff := new(bytes.Buffer)
+		assert.NoError(t, c.Monitor.WritePrometheus(buff, nil, false))
+		assert.Contains(t, buff.String(), `postmaster_messages_suppressed_total{event="Example",language="EN"} 1`)
+		assert.Contains(t, buff.String(), `postmaster_messages_sent_total{event="Example",language="EN"} 1`)
+	})
+}
+
+func TestOpenSuppressions(t *testing.T) {
+	store, err := openSuppressions(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, store)
+
+	store, err = openSuppressions(&config.Suppression{})
+	assert.NoError(t, err)
+	assert.IsType(t, &suppression.MemoryStore{}, store)
+}
@@ -0,0 +1,185 @@
+package consumer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/openware/postmaster/pkg/eventapi"
+)
+
+// Formats of email body, recipient can prefer with "preferred_format" field of event.
+const (
+	FormatHTML = "html"
+	FormatText = "text"
+)
+
+// body renders variant of template preferred by recipient. Template with
+// both variants is rendered as multipart/alternative without preference.
+// Single variant is labelled with charset of config and encoded on the wire,
+// see EmailSender.Send, while parts are UTF-8 rendered templates encoded here.
+func (c *Consumer) body(tpl config.Template, data interface{}, event eventapi.Event, preferred string, post postProcess) (string, []byte, error) {
+	htmlType := fmt.Sprintf("%s; charset=%s", c.conf().ContentType, c.conf().Charset)
+	textType := fmt.Sprintf("text/plain; charset=%s", c.conf().Charset)
+
+	if tpl.HasHTML() && (!tpl.HasText() || preferred == FormatHTML) {
+		content, err := c.html(tpl, data, event, post)
+		return htmlType, content, err
+	}
+
+	if tpl.HasText() && (!tpl.HasHTML() || preferred == FormatText) {
+		content, err := tpl.TextContent(data)
+		return textType, content, err
+	}
+
+	text, html, err := c.variants(tpl, data, event, post)
+	if err != nil {
+		return "", nil, err
+	}
+
+	buff := new(bytes.Buffer)
+	writer, err := c.multipartWriter(buff)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Preferred part goes last.
+	parts := []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text},
+		{c.conf().ContentType + "; charset=utf-8", html},
+	}
+
+	for _, p := range parts {
+		if err := writeQuotedPrintable(writer, textproto.MIMEHeader{"Content-Type": {p.contentType}}, p.content); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", nil, err
+	}
+
+	return "multipart/alternative; boundary=" + writer.Boundary(), buff.Bytes(), nil
+}
+
+// variants renders text and html variants of template concurrently.
+// Templates are parsed per render, so nothing is shared but read-only data.
+// Failures of both variants are reported.
+func (c *Consumer) variants(tpl config.Template, data interface{}, event eventapi.Event, post postProcess) ([]byte, []byte, error) {
+	var text []byte
+	var textErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		text, textErr = tpl.TextContent(data)
+	}()
+
+	html, htmlErr := c.html(tpl, data, event, post)
+	<-done
+
+	var failures []string
+	if textErr != nil {
+		failures = append(failures, "text variant: "+textErr.Error())
+	}
+	if htmlErr != nil {
+		failures = append(failures, "html variant: "+htmlErr.Error())
+	}
+
+	if len(failures) != 0 {
+		return nil, nil, errors.New(strings.Join(failures, "; "))
+	}
+
+	return text, html, nil
+}
+
+// multipartType reports whether contentType is multipart, which parts
+// are encoded on their own.
+func multipartType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "multipart/")
+}
+
+// quotedPrintable encodes content, so long lines and 8-bit text survive SMTP relays.
+func quotedPrintable(content []byte) ([]byte, error) {
+	buff := new(bytes.Buffer)
+	encoder := quotedprintable.NewWriter(buff)
+	if _, err := encoder.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+// writeQuotedPrintable writes part of header with content, which is quoted-printable
+// unless it's multipart itself.
+func writeQuotedPrintable(writer *multipart.Writer, header textproto.MIMEHeader, content []byte) error {
+	if !multipartType(header.Get("Content-Type")) {
+		encoded, err := quotedPrintable(content)
+		if err != nil {
+			return err
+		}
+
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		content = encoded
+	}
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = part.Write(content)
+	return err
+}
+
+// multipartWriter creates writer of multipart body with boundary of consumer.
+func (c *Consumer) multipartWriter(w io.Writer) (*multipart.Writer, error) {
+	writer := multipart.NewWriter(w)
+	if c.Boundary == nil {
+		return writer, nil
+	}
+
+	if err := writer.SetBoundary(c.Boundary()); err != nil {
+		return nil, fmt.Errorf("multipart boundary: %s", err.Error())
+	}
+
+	return writer, nil
+}
+
+// postProcess transforms rendered HTML body, see config.Config.ProcessHTML.
+type postProcess func(body []byte) ([]byte, error)
+
+// html renders HTML body of template, post-processed with post, unless it's nil.
+func (c *Consumer) html(tpl config.Template, data interface{}, event eventapi.Event, post postProcess) ([]byte, error) {
+	var content []byte
+	var err error
+	if tpl.Item != "" {
+		content, err = tpl.RenderDigest(digestItems(event), data)
+	} else {
+		content, err = tpl.Content(data)
+	}
+
+	if err == nil && post != nil {
+		content, err = post(content)
+	}
+
+	if err == nil && c.conf().ValidateHTML {
+		err = config.ValidateHTML(content)
+	}
+
+	return content, err
+}
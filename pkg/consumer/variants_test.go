@@ -0,0 +1,339 @@
+package consumer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/openware/postmaster/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumer_Variants(t *testing.T) {
+	var sent Email
+	var body []byte
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		sent = email
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+
+	both := config.Template{
+		Subject:  "Example",
+		Template: "<p>Hello {{ .user.email }}</p>",
+		Text:     "Hello {{ .user.email }} & co",
+	}
+
+	handle := func(tpl config.Template, preferred string) {
+		c.Config.Events[0].Templates["EN"] = tpl
+		msg := fakeMessage()
+		if preferred != "" {
+			msg.Event["preferred_format"] = preferred
+		}
+
+		assert.NoError(t, c.handle(c.Config.Events[0], msg))
+	}
+
+	t.Run("html preference", func(t *testing.T) {
+		handle(both, FormatHTML)
+
+		assert.Equal(t, "text/html; charset=iso-8859-1", sent.ContentType)
+		assert.Equal(t, "<p>Hello john@doe.com</p>", string(body))
+	})
+
+	t.Run("text preference", func(t *testing.T) {
+		handle(both, FormatText)
+
+		assert.Equal(t, "text/plain; charset=iso-8859-1", sent.ContentType)
+		assert.Equal(t, "Hello john@doe.com & co", string(body))
+	})
+
+	t.Run("multipart without preference", func(t *testing.T) {
+		handle(both, "")
+
+		mediaType, params, err := mime.ParseMediaType(sent.ContentType)
+		assert.NoError(t, err)
+		assert.Equal(t, "multipart/alternative", mediaType)
+
+		reader := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+		var types, contents []string
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			content, _ := ioutil.ReadAll(part)
+			types = append(types, part.Header.Get("Content-Type"))
+			contents = append(contents, string(content))
+		}
+
+		assert.Equal(t, []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}, types)
+		assert.Equal(t, []string{"Hello john@doe.com & co", "<p>Hello john@doe.com</p>"}, contents)
+	})
+
+	t.Run("multipart parts are quoted-printable", func(t *testing.T) {
+		long := strings.Repeat("Grüße ", 30)
+		handle(config.Template{Subject: "Example", Template: "<p>" + long + "</p>", Text: long}, "")
+
+		assert.Contains(t, string(body), "Content-Transfer-Encoding: quoted-printable\r\n")
+		assert.Contains(t, string(body), "Content-Type: text/plain; charset=utf-8\r\n\r\nGr=C3=BC=C3=9Fe")
+		for _, line := range strings.Split(string(body), "\r\n") {
+			assert.True(t, len(line) <= 76, line)
+		}
+
+		_, params, _ := mime.ParseMediaType(sent.ContentType)
+		reader := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+		part, err := reader.NextPart()
+		assert.NoError(t, err)
+		content, _ := ioutil.ReadAll(part)
+		assert.Equal(t, long, string(content))
+	})
+
+	t.Run("single variant regardless of preference", func(t *testing.T) {
+		handle(config.Template{Subject: "Example", Text: "Hi {{ .user.email }}"}, FormatHTML)
+		assert.Equal(t, "text/plain; charset=iso-8859-1", sent.ContentType)
+		assert.Equal(t, "Hi john@doe.com", string(body))
+
+		handle(config.Template{Subject: "Example", Template: "<p>Hi</p>"}, FormatText)
+		assert.Equal(t, "text/html; charset=iso-8859-1", sent.ContentType)
+		assert.Equal(t, "<p>Hi</p>", string(body))
+	})
+}
+
+func TestConsumer_SingleVariantEncoding(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	long := strings.Repeat("Grüße ", 30)
+	c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Example", Text: long}
+
+	t.Run("body is quoted-printable", func(t *testing.T) {
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+
+		headers, body := splitMessage(string(req.msg))
+		assert.Contains(t, headers, "Content-Transfer-Encoding: quoted-printable")
+		assert.Contains(t, body, "Gr=C3=BC=C3=9Fe")
+		for _, line := range strings.Split(body, "\r\n") {
+			assert.True(t, len(line) <= 76, line)
+		}
+	})
+
+	t.Run("body part is quoted-printable with attachments", func(t *testing.T) {
+		c.Config.Events[0].Attachments = []config.Attachment{{Filename: "note.txt", ContentType: "text/plain", Template: "note"}}
+		defer func() { c.Config.Events[0].Attachments = nil }()
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+
+		headers, body := splitMessage(string(req.msg))
+		assert.NotContains(t, headers, "Content-Transfer-Encoding")
+		assert.Contains(t, body, "Content-Transfer-Encoding: quoted-printable\r\nContent-Type: text/plain; charset=iso-8859-1\r\n\r\nGr=C3=BC=C3=9Fe")
+	})
+}
+
+// splitMessage splits SMTP message into headers and body.
+func splitMessage(msg string) (string, string) {
+	parts := strings.SplitN(msg, "\r\n\r\n", 2)
+	return parts[0], parts[1]
+}
+
+func TestConsumer_ValidateHTML(t *testing.T) {
+	c := fakeConsumer(func(SMTPConf, Email) error { return nil })
+	c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Example", Template: "<table><tr><td>{{ .user.email }}</div>"}
+
+	assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+
+	c.Config.ValidateHTML = true
+	assert.EqualError(t, c.handle(c.Config.Events[0], fakeMessage()),
+		"template for user.example/EN: html: </div> closes <td>")
+}
+
+func TestConsumer_PostProcess(t *testing.T) {
+	var body []byte
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+
+	assert.NoError(t, RegisterPostProcessor("test_signature", PostProcessorFunc(func(lang string, body []byte) ([]byte, error) {
+		return append(body, []byte("<p>"+lang+"</p>")...), nil
+	})))
+
+	c.Config.PostProcess = []string{"strip_comments", "test_signature"}
+	defer func() { c.Config.PostProcess = nil }()
+
+	t.Run("processes html", func(t *testing.T) {
+		c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Example", Template: "<p>Hi<!-- draft --></p>"}
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.Equal(t, "<p>Hi</p><p>EN</p>", string(body))
+	})
+
+	t.Run("leaves text alone", func(t *testing.T) {
+		c.Config.Events[0].Templates["EN"] = config.Template{Subject: "Example", Text: "Hi <!-- draft -->"}
+
+		assert.NoError(t, c.handle(c.Config.Events[0], fakeMessage()))
+		assert.Equal(t, "Hi <!-- draft -->", string(body))
+	})
+}
+
+func TestConsumer_LinkTracking(t *testing.T) {
+	var body []byte
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+
+	c.Config.Tracking = &config.Tracking{TokenField: "tracking_id", LinkURL: "https://click.example.com/r"}
+	event := c.Config.Events[0]
+	event.LinkTracking = true
+
+	msg := fakeMessage()
+	msg.Event["tracking_id"] = "abc"
+
+	t.Run("rewrites html links", func(t *testing.T) {
+		event.Templates = map[string]config.Template{"EN": {Subject: "Example",
+			Template: `<a href="https://example.com/confirm">Confirm</a> <a href="mailto:help@example.com">Help</a> <a href="#faq">FAQ</a>`}}
+
+		assert.NoError(t, c.handle(event, msg))
+		assert.Equal(t, `<a href="https://click.example.com/r?token=abc&amp;url=https%3A%2F%2Fexample.com%2Fconfirm">Confirm</a>`+
+			` <a href="mailto:help@example.com">Help</a> <a href="#faq">FAQ</a>`, string(body))
+	})
+
+	t.Run("leaves text alone", func(t *testing.T) {
+		event.Templates = map[string]config.Template{"EN": {Subject: "Example", Text: "Confirm at https://example.com/confirm"}}
+
+		assert.NoError(t, c.handle(event, msg))
+		assert.Equal(t, "Confirm at https://example.com/confirm", string(body))
+	})
+
+	t.Run("fails without token", func(t *testing.T) {
+		event.Templates = map[string]config.Template{"EN": {Subject: "Example", Template: `<a href="https://example.com/">Home</a>`}}
+
+		assert.EqualError(t, c.handle(event, fakeMessage()),
+			"template for user.example/EN: tracking token field \"tracking_id\" is missing")
+	})
+}
+
+func TestConsumer_OpenTracking(t *testing.T) {
+	var body []byte
+	c := fakeConsumer(func(_ SMTPConf, email Email) error {
+		body, _ = ioutil.ReadAll(email.Reader)
+		return nil
+	})
+
+	c.Config.Tracking = &config.Tracking{TokenField: "tracking_id", PixelURL: "https://open.example.com/p.gif"}
+	event := c.Config.Events[0]
+	event.Templates = map[string]config.Template{"EN": {
+		Subject:  "Example",
+		Template: "<html><body><p>Hi</p></body></html>",
+		Text:     "Hi",
+	}}
+
+	msg := fakeMessage()
+	msg.Event["tracking_id"] = "abc"
+	pixel := `<img src="https://open.example.com/p.gif?token=abc" width="1" height="1" alt="" style="display:none">`
+
+	t.Run("injects pixel when enabled", func(t *testing.T) {
+		event := event
+		event.OpenTracking = true
+		msg.Event["preferred_format"] = FormatHTML
+
+		assert.NoError(t, c.handle(event, msg))
+		assert.Equal(t, "<html><body><p>Hi</p>"+pixel+"</body></html>", string(body))
+	})
+
+	t.Run("skips text variant", func(t *testing.T) {
+		event := event
+		event.OpenTracking = true
+		msg.Event["preferred_format"] = FormatText
+
+		assert.NoError(t, c.handle(event, msg))
+		assert.Equal(t, "Hi", string(body))
+	})
+
+	t.Run("omits pixel when disabled", func(t *testing.T) {
+		msg.Event["preferred_format"] = FormatHTML
+
+		assert.NoError(t, c.handle(event, msg))
+		assert.Equal(t, "<html><body><p>Hi</p></body></html>", string(body))
+		assert.NotContains(t, string(body), "<img")
+	})
+}
+
+func TestConsumer_RenderVariants(t *testing.T) {
+	c := fakeConsumer(func(SMTPConf, Email) error { return nil })
+	tpl := config.Template{
+		Subject:  "Example",
+		Template: "<p>Hello {{ .user.email }}</p>",
+		Text:     "Hello {{ .user.email }}",
+	}
+	data := fakeMessage().Event
+
+	t.Run("renders both variants", func(t *testing.T) {
+		text, html, err := c.variants(tpl, data, data, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello john@doe.com", string(text))
+		assert.Equal(t, "<p>Hello john@doe.com</p>", string(html))
+	})
+
+	t.Run("surfaces failure of either variant", func(t *testing.T) {
+		broken := tpl
+		broken.Text = "{{ .user.email | missing }}"
+		_, _, err := c.variants(broken, data, data, nil)
+		assert.EqualError(t, err, "text variant: template: Example:1: function \"missing\" not defined")
+
+		broken = tpl
+		broken.Template = "{{ index .user 1 }}"
+		_, _, err = c.variants(broken, data, data, nil)
+		htmlErr := "html variant: template: Example:1:3: executing \"Example\" at <index .user 1>: " +
+			"error calling index: value has type int; should be string"
+		assert.EqualError(t, err, htmlErr)
+
+		broken.Text = "{{ .user.email | missing }}"
+		_, _, err = c.variants(broken, data, data, nil)
+		assert.EqualError(t, err, "text variant: template: Example:1: function \"missing\" not defined; "+htmlErr)
+	})
+}
+
+// sequentialBoundary returns boundaries numbered from one.
+func sequentialBoundary() func() string {
+	n := 0
+	return func() string {
+		n++
+		return fmt.Sprintf("postmaster-boundary-%d", n)
+	}
+}
+
+func TestConsumer_GoldenMIME(t *testing.T) {
+	deliver, req := recordSMTP(nil)
+	c := fakeConsumer(deliver)
+	c.Boundary = sequentialBoundary()
+	c.Config.Events[0].Headers = map[string]string{"X-Mailer": "postmaster", "List-Id": "examples"}
+	c.Config.Events[0].Templates["EN"] = config.Template{
+		Subject:  "Example",
+		Template: "<p>Hello {{ .user.email }}</p>",
+		Text:     "Hello {{ .user.email }}",
+	}
+	c.Config.Events[0].Attachments = []config.Attachment{{
+		Filename:    "invite.ics",
+		ContentType: "text/calendar",
+		Template:    "BEGIN:VCALENDAR",
+	}}
+
+	msg := fakeMessage()
+	msg.CorrelationID = "c0ffee"
+	assert.NoError(t, c.handle(c.Config.Events[0], msg))
+
+	golden, err := ioutil.ReadFile("../../test/multipart.eml")
+	assert.NoError(t, err)
+	assert.Equal(t, string(golden), string(req.msg))
+
+	t.Run("rejects invalid boundary", func(t *testing.T) {
+		c.Boundary = func() string { return "not!valid" }
+		assert.EqualError(t, c.handle(c.Config.Events[0], msg),
+			"template for user.example/EN: multipart boundary: mime: invalid boundary character")
+	})
+}
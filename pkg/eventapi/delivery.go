@@ -7,29 +7,60 @@ import (
 	"io"
 	"strings"
 
+	"github.com/shamaton/msgpack"
 	"github.com/streadway/amqp"
 )
 
 type DeliverySignatureHeader struct {
-	Kid string `json:"kid,omitempty"`
+	Kid string `json:"kid,omitempty" msgpack:"kid"`
 }
 
 type DeliverySignature struct {
-	Protected string                  `json:"protected"`
-	Signature string                  `json:"signature"`
-	Header    DeliverySignatureHeader `json:"header,omitempty"`
+	Protected string                  `json:"protected" msgpack:"protected"`
+	Signature string                  `json:"signature" msgpack:"signature"`
+	Header    DeliverySignatureHeader `json:"header,omitempty" msgpack:"header"`
 }
 
 // Structure of Event API Message.
 type Delivery struct {
-	Payload    string              `json:"payload"`
-	Signatures []DeliverySignature `json:"signatures"`
+	Payload    string              `json:"payload" msgpack:"payload"`
+	Signatures []DeliverySignature `json:"signatures" msgpack:"signatures"`
+}
+
+// PayloadDecoder unmarshals body of AMQP delivery.
+type PayloadDecoder func(body []byte, v interface{}) error
+
+// Formats of AMQP delivery body.
+const (
+	FormatJSON    = "json"
+	FormatMsgpack = "msgpack"
+)
+
+var payloadDecoders = map[string]PayloadDecoder{
+	FormatJSON:    json.Unmarshal,
+	FormatMsgpack: msgpack.Unmarshal,
+}
+
+// PayloadDecoderFor returns decoder of format, if it's supported.
+func PayloadDecoderFor(format string) (PayloadDecoder, bool) {
+	decoder, ok := payloadDecoders[format]
+	return decoder, ok
 }
 
 func DeliveryAsJWT(delivery amqp.Delivery) (io.Reader, error) {
+	return DecodeDeliveryAsJWT(delivery, json.Unmarshal)
+}
+
+// DecodeDeliveryAsJWT is DeliveryAsJWT for delivery body unmarshaled by decode.
+func DecodeDeliveryAsJWT(delivery amqp.Delivery, decode PayloadDecoder) (io.Reader, error) {
+	return DecodeAsJWT(delivery.Body, decode)
+}
+
+// DecodeAsJWT is DecodeDeliveryAsJWT of message body, which didn't come from AMQP, e.g. Kafka record value.
+func DecodeAsJWT(body []byte, decode PayloadDecoder) (io.Reader, error) {
 	eventMsg := Delivery{}
 
-	if err := json.Unmarshal(delivery.Body, &eventMsg); err != nil {
+	if err := decode(body, &eventMsg); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,79 @@
+package eventapi
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Registry keeps Go types events should be decoded into, keyed by event key.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]func() interface{}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]func() interface{})}
+}
+
+// Register makes events with key decoded into value returned by factory.
+// Factory should return a pointer to a new struct on every call.
+func (r *Registry) Register(key string, factory func() interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key == "" {
+		panic("eventapi: invalid event key")
+	}
+	if factory == nil {
+		panic("eventapi: nil factory")
+	}
+	if _, exist := r.factories[key]; exist {
+		panic("eventapi: multiple registrations for " + key)
+	}
+
+	r.factories[key] = factory
+}
+
+// Type returns type of values, which events with key are decoded into.
+func (r *Registry) Type(key string) (reflect.Type, bool) {
+	r.mu.RLock()
+	factory, ok := r.factories[key]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	return reflect.TypeOf(factory()), true
+}
+
+// Decode converts event into type registered for key.
+// Event is returned as is, when there is no registered type.
+func (r *Registry) Decode(key string, event Event) (interface{}, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[key]
+	r.mu.RUnlock()
+
+	if !ok {
+		return event, nil
+	}
+
+	result := factory()
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName: "json",
+		Result:  result,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dec.Decode(event); err != nil {
+		return nil, fmt.Errorf("event \"%s\": %s", key, err.Error())
+	}
+
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package eventapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tokenEvent struct {
+	User  User   `json:"user"`
+	Token string `json:"token"`
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("user.token", func() interface{} { return new(tokenEvent) })
+
+	t.Run("decodes registered type", func(t *testing.T) {
+		res, err := registry.Decode("user.token", Event{
+			"user":  map[string]interface{}{"email": "john@doe.com", "level": float64(1)},
+			"token": "xyz",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &tokenEvent{
+			User:  User{Email: "john@doe.com", Level: 1},
+			Token: "xyz",
+		}, res)
+	})
+
+	t.Run("rejects malformed payload", func(t *testing.T) {
+		res, err := registry.Decode("user.token", Event{
+			"user":  map[string]interface{}{"email": "john@doe.com"},
+			"token": []interface{}{"x", "y"},
+		})
+
+		assert.Nil(t, res)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "event \"user.token\"")
+	})
+
+	t.Run("returns unregistered event as is", func(t *testing.T) {
+		event := Event{"token": "xyz"}
+		res, err := registry.Decode("user.other", event)
+
+		assert.NoError(t, err)
+		assert.Equal(t, event, res)
+	})
+
+	t.Run("panics on duplicate registration", func(t *testing.T) {
+		assert.Panics(t, func() {
+			registry.Register("user.token", func() interface{} { return new(tokenEvent) })
+		})
+	})
+}
+
+func TestRegistry_Type(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("user.token", func() interface{} { return new(tokenEvent) })
+
+	typ, ok := registry.Type("user.token")
+	assert.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(&tokenEvent{}), typ)
+
+	_, ok = registry.Type("user.unknown")
+	assert.False(t, ok)
+}
@@ -16,6 +16,11 @@ type User struct {
 type UserEvent struct {
 	User     User   `json:"user"`
 	Language string `json:"language"`
+	Segment  string `json:"segment"`
+	// Tenant, whose template overrides are used, see config.Event.Tenants.
+	Tenant string `json:"tenant"`
+	// Either "html" or "text", both variants are sent when empty.
+	PreferredFormat string `json:"preferred_format"`
 }
 
 func Unmarshal(event Event) (*UserEvent, error) {
@@ -0,0 +1,271 @@
+// Package kafka serves Event API messages of Kafka topics to the handlers
+// of the amqp package. Kafka client is plugged in as a Reader.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/openware/postmaster/pkg/eventapi"
+)
+
+// CommitTimeout bounds every offset commit.
+const CommitTimeout = 10 * time.Second
+
+// Record is a message of a Kafka topic partition.
+type Record struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string][]byte
+}
+
+// Reader reads records of topics as a member of consumer group,
+// e.g. adapter of a Kafka client library.
+type Reader interface {
+	// Fetch blocks until next record, failing with ctx.Err() once ctx is done.
+	Fetch(ctx context.Context) (Record, error)
+	// Commit commits offset of record, once it's processed.
+	Commit(ctx context.Context, record Record) error
+	Close() error
+}
+
+// Router returns routing key of handler of record, e.g. event key of its topic.
+type Router func(record Record) (string, bool)
+
+// Source serves records of reader one by one, committing offset of each once
+// it's processed. Records, which handler fails with amqp.Requeue, are retried
+// with backoff, until they succeed or Shutdown begins; other failures are
+// logged and committed, since Kafka can't dead-letter a record.
+type Source struct {
+	reader Reader
+	route  Router
+
+	mu sync.RWMutex
+	m  map[string]amqp.Handler
+
+	payloadDecoder eventapi.PayloadDecoder
+	backoff        amqp.Backoff
+	sleep          func(ctx context.Context, d time.Duration)
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	done     chan struct{}
+
+	// connected is 1 while reader fetches records, see Connected.
+	connected int32
+}
+
+// NewSource makes source serving records of reader routed by route.
+func NewSource(reader Reader, route Router) *Source {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Source{
+		reader:         reader,
+		route:          route,
+		payloadDecoder: json.Unmarshal,
+		backoff:        amqp.Backoff{Initial: time.Second, Max: 30 * time.Second},
+		sleep:          sleep,
+		ctx:            ctx,
+		cancel:         cancel,
+		stopping:       make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+// SetPayloadDecoder sets decoder of record value, JSON by default.
+func (s *Source) SetPayloadDecoder(decoder eventapi.PayloadDecoder) {
+	s.payloadDecoder = decoder
+}
+
+// Handle registers handler of records with routing key.
+func (s *Source) Handle(routingKey string, handler amqp.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if routingKey == "" {
+		panic("kafka: invalid pattern")
+	}
+	if handler == nil {
+		panic("kafka: nil handler")
+	}
+	if _, exist := s.m[routingKey]; exist {
+		panic("kafka: multiple registrations for " + routingKey)
+	}
+
+	if s.m == nil {
+		s.m = make(map[string]amqp.Handler)
+	}
+	s.m[routingKey] = handler
+}
+
+// HandleFunc registers handler func of records with routing key.
+func (s *Source) HandleFunc(routingKey string, handler func(msg amqp.Message) error) {
+	s.Handle(routingKey, amqp.HandlerFunc(handler))
+}
+
+// ListenAndServe serves records until Shutdown. Failed fetches are retried with backoff.
+func (s *Source) ListenAndServe() error {
+	defer close(s.done)
+
+	for attempt := 0; ; {
+		record, err := s.reader.Fetch(s.ctx)
+		if s.ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			atomic.StoreInt32(&s.connected, 0)
+			log.Printf("kafka fetch: %s", err.Error())
+			s.sleep(s.ctx, s.backoff.Delay(attempt))
+			attempt++
+			continue
+		}
+		atomic.StoreInt32(&s.connected, 1)
+		attempt = 0
+
+		if !s.serve(record) {
+			// Shutdown began before record was processed, so it's left uncommitted.
+			return nil
+		}
+
+		if err := s.commit(record); err != nil {
+			log.Printf("kafka commit %s/%d@%d: %s", record.Topic, record.Partition, record.Offset, err.Error())
+		}
+	}
+}
+
+// commit commits offset of processed record, even once Shutdown began.
+func (s *Source) commit(record Record) error {
+	ctx, cancel := context.WithTimeout(context.Background(), CommitTimeout)
+	defer cancel()
+
+	return s.reader.Commit(ctx, record)
+}
+
+// serve processes record, retrying requeued failures. It reports whether
+// record is done with, either processed or failed for good.
+func (s *Source) serve(record Record) bool {
+	key, ok := s.route(record)
+	if !ok {
+		log.Printf("kafka record %s/%d@%d has no routing key, skipping", record.Topic, record.Partition, record.Offset)
+		return true
+	}
+
+	s.mu.RLock()
+	handler, ok := s.m[key]
+	s.mu.RUnlock()
+	if !ok {
+		log.Printf("kafka record %s/%d@%d of %s has no handler, skipping", record.Topic, record.Partition, record.Offset, key)
+		return true
+	}
+
+	msg, err := s.message(record)
+	if err != nil {
+		log.Printf("kafka record %s/%d@%d: %s", record.Topic, record.Partition, record.Offset, err.Error())
+		return true
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := handler.ServeAMQP(msg)
+		// Offsets are committed in order, so held record blocks the ones after it.
+		if h, ok := err.(*amqp.Hold); ok {
+			h.Release(func(held error) { err = held })
+		}
+		if amqp.OutcomeOf(err) != amqp.OutcomeRequeue {
+			if err != nil {
+				log.Printf("kafka record %s/%d@%d of %s failed: %s", record.Topic, record.Partition, record.Offset, key, err.Error())
+			}
+			return true
+		}
+
+		log.Printf("kafka record %s/%d@%d of %s failed, retrying: %s", record.Topic, record.Partition, record.Offset, key, err.Error())
+		// Headers tell retried message what's left, e.g. recipients not sent to yet.
+		for name, value := range amqp.HeadersOf(err) {
+			msg.Headers[name] = value
+		}
+		if s.sleep(s.ctx, s.backoff.Delay(attempt)); s.ctx.Err() != nil {
+			return false
+		}
+	}
+}
+
+// message verifies Event API message of record value.
+// Headers of record are passed as strings.
+func (s *Source) message(record Record) (amqp.Message, error) {
+	jwtReader, err := eventapi.DecodeAsJWT(record.Value, s.payloadDecoder)
+	if err != nil {
+		return amqp.Message{}, err
+	}
+
+	jwt, err := ioutil.ReadAll(jwtReader)
+	if err != nil {
+		return amqp.Message{}, err
+	}
+
+	claims, err := eventapi.ParseJWT(string(jwt), eventapi.ValidateJWT)
+	if err != nil {
+		return amqp.Message{}, err
+	}
+
+	headers := make(map[string]interface{}, len(record.Headers))
+	for name, value := range record.Headers {
+		headers[name] = string(value)
+	}
+
+	return amqp.Message{
+		Event:         claims.Event,
+		Headers:       headers,
+		CorrelationID: string(record.Headers["correlation_id"]),
+	}, nil
+}
+
+// Shutdown stops fetching and waits for record in processing until ctx is done,
+// then closes reader. Record, which is retried, is left uncommitted.
+func (s *Source) Shutdown(ctx context.Context) error {
+	select {
+	case <-s.stopping:
+		return errors.New("kafka: shutdown is in progress")
+	default:
+		close(s.stopping)
+	}
+	s.cancel()
+
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if err := s.reader.Close(); err != nil {
+		return fmt.Errorf("kafka close: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Connected reports whether the last fetch succeeded.
+func (s *Source) Connected() bool {
+	return atomic.LoadInt32(&s.connected) == 1
+}
+
+// sleep blocks for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,210 @@
+package kafka
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openware/postmaster/pkg/amqp"
+	"github.com/openware/postmaster/pkg/eventapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleToken is signed with test/sample.key.
+const sampleToken = "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9.eyJpc3MiOiJiYXJvbmciLCJqdGkiOiIwMDAwMDAwMC0wMDAwLTAwMDAtMDAwMC0wMDAwMDAwMDAwMCIsImlhdCI6MTU0NzgwMzE5MCwiZXZlbnQiOnsidXNlciI6eyJ1aWQiOiJJRDAxMjM0NTY3ODkiLCJlbWFpbCI6ImpvaG5AZG9lLmNvbSIsInJvbGUiOiJtZW1iZXIiLCJsZXZlbCI6MCwib3RwIjpmYWxzZSwic3RhdGUiOiJwZW5kaW5nIiwiY3JlYXRlZF9hdCI6IjIwMTktMDEtMThUMDk6MTk6NTBaIiwidXBkYXRlZF9hdCI6IjIwMTktMDEtMThUMDk6MTk6NTBaIn0sInRva2VuIjoiZXlKaGJHY2lPaUpTVXpJMU5pSjkuZXlKcFlYUWlPakUxTkRjNE1ETXhPVEFzSW1WNGNDSTZNVFUwTnpnd09URTVNQ3dpYzNWaUlqb2lZMjl1Wm1seWJXRjBhVzl1SWl3aWFYTnpJam9pWW1GeWIyNW5JaXdpWVhWa0lqcGJJbkJsWVhScGJ5SXNJbUpoY205dVp5SmRMQ0pxZEdraU9pSmxZelUzWkdVME1EUXlNREF6TVdSak5XUTJNQ0lzSW1WdFlXbHNJam9pWVdSdGFXNHlRR0poY205dVp5NXBieUlzSW5WcFpDSTZJa2xFTWpnMk5UZEJPVVF5T1NKOS5XUU1aS0FyR2NlMlZGb19zMW1WeHJQajdNREhhSGhSYmtzNk9IV2xTMThEcTBnZVl2UHMybmZKT3JuTnBxMk1SekpJQ0U1SE9KNHlnenIwYkFPSURCcWZ5X1ZRTEl2WFc3aENiWmdDQ1NFcjFSaVpaTGQ2R2hBY1EtbHM1RzY3WEY2S3RKNUlpOVY3djFZckIxVnpnRGhtZ1RRdUZLbmxuMGMtMWhhUlRzTVU3ZW9NUVhYT1ppdXNWM28xZ0ViemJiMUlFNTF6ZlNzb0wxMFZrXzJRNjAxZmdEc3d2V1ZQcE5CVFFVT2pQRkFZSEZWaGxGaENJOHkyV09jY3NJUGw5aWdnSEFVQ3E5ZGlZLTVLTjlTeW9WSFUyMUtSZWZ1QV8yRzJiTzBpV3lQZG9vaDBTVDI0Y2s4RXNFRG5NT2J3M0xjdlBvNFQ3LU85UFM4bkM2N09JeGciLCJuYW1lIjoic3lzdGVtLnVzZXIuZW1haWwuY29uZmlybWF0aW9uLnRva2VuIn0sImFsZyI6IlJTMjU2In0.kWPCnUdQQLNxdzRTX-NLy-kJY8qk5XpT65H2gjXv0Q4P-Q8mxkUdL3-Bdy0yy13C1bSnQS-yPnRG4jX_-G0Xdj1eKhtSk7sVf44K5ggcopCsWZ4b1eIoAWTtXpABHQo2Po5zUGjOClzljWv3uJhMKXhK4veSfPqFwSfE9IFPyvJ3FLnCVHgfD_rm5pikgqR-ya--zk6V1RjCe0442xKH5Sx-XBOlulMMr-CQ04k09Vawy-W80y2WsNugjMdDr0ZHjCnOjLcm0Hayy9kSql9UTln7o8wcSEVMDum-EIBadohM-9q_f2gXVRNuOg2gR7sbA9mGwsut-sBcR8IBmyPzuQ"
+
+// sampleValue is Event API message of sampleToken.
+func sampleValue(t *testing.T) []byte {
+	parts := strings.Split(sampleToken, ".")
+	value, err := json.Marshal(eventapi.Delivery{
+		Payload:    parts[1],
+		Signatures: []eventapi.DeliverySignature{{Protected: parts[0], Signature: parts[2]}},
+	})
+	assert.NoError(t, err)
+
+	return value
+}
+
+// sampleKey makes sampleToken verifiable until returned func is called.
+func sampleKey(t *testing.T) func() {
+	pub, err := ioutil.ReadFile("../../test/sample.key.pub")
+	assert.NoError(t, err)
+	os.Setenv("JWT_PUBLIC_KEY", base64.StdEncoding.EncodeToString(pub))
+
+	return func() { os.Unsetenv("JWT_PUBLIC_KEY") }
+}
+
+// fakeReader fetches records of the channel and records commits.
+type fakeReader struct {
+	records chan Record
+
+	mu        sync.Mutex
+	committed []int64
+	closed    bool
+	failures  int
+}
+
+func (r *fakeReader) Fetch(ctx context.Context) (Record, error) {
+	r.mu.Lock()
+	if r.failures > 0 {
+		r.failures--
+		r.mu.Unlock()
+		return Record{}, errors.New("broker is not available")
+	}
+	r.mu.Unlock()
+
+	select {
+	case record := <-r.records:
+		return record, nil
+	case <-ctx.Done():
+		return Record{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) Commit(ctx context.Context, record Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.committed = append(r.committed, record.Offset)
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	return nil
+}
+
+func (r *fakeReader) commits() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]int64{}, r.committed...)
+}
+
+func byTopic(record Record) (string, bool) {
+	return record.Topic, record.Topic != ""
+}
+
+// serve runs source until it committed n records or timed out, then shuts it down.
+func serve(t *testing.T, source *Source, reader *fakeReader, n int) {
+	done := make(chan error, 1)
+	go func() { done <- source.ListenAndServe() }()
+
+	deadline := time.Now().Add(time.Second)
+	for len(reader.commits()) < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.NoError(t, source.Shutdown(context.Background()))
+	assert.NoError(t, <-done)
+}
+
+func TestSource(t *testing.T) {
+	defer sampleKey(t)()
+
+	value := sampleValue(t)
+	noSleep := func(context.Context, time.Duration) {}
+
+	t.Run("serves and commits records", func(t *testing.T) {
+		reader := &fakeReader{records: make(chan Record, 4), failures: 1}
+		reader.records <- Record{Topic: "user.email.confirmation", Offset: 1, Value: value, Headers: map[string][]byte{"correlation_id": []byte("c0ffee")}}
+		reader.records <- Record{Topic: "", Offset: 2, Value: value}
+		reader.records <- Record{Topic: "user.password.reset", Offset: 3, Value: value}
+		reader.records <- Record{Topic: "user.email.confirmation", Offset: 4, Value: []byte("{}")}
+
+		var received []amqp.Message
+		source := NewSource(reader, byTopic)
+		source.sleep = noSleep
+		source.HandleFunc("user.email.confirmation", func(msg amqp.Message) error {
+			received = append(received, msg)
+			return nil
+		})
+
+		serve(t, source, reader, 4)
+
+		assert.Equal(t, []int64{1, 2, 3, 4}, reader.commits())
+		assert.Len(t, received, 1)
+		assert.Equal(t, "system.user.email.confirmation.token", received[0].Event["name"])
+		assert.Equal(t, "c0ffee", received[0].CorrelationID)
+		assert.Equal(t, "c0ffee", received[0].Headers["correlation_id"])
+		assert.True(t, source.Connected())
+		assert.True(t, reader.closed)
+	})
+
+	t.Run("retries requeued and commits failed records", func(t *testing.T) {
+		reader := &fakeReader{records: make(chan Record, 2)}
+		reader.records <- Record{Topic: "user.email.confirmation", Offset: 1, Value: value}
+		reader.records <- Record{Topic: "user.email.confirmation", Offset: 2, Value: value}
+
+		attempts := 0
+		source := NewSource(reader, byTopic)
+		source.sleep = noSleep
+		source.HandleFunc("user.email.confirmation", func(msg amqp.Message) error {
+			attempts++
+			switch attempts {
+			case 1, 2:
+				return amqp.Requeue(errors.New("smtp timed out"))
+			case 3:
+				return nil
+			default:
+				return errors.New("recipient is not valid")
+			}
+		})
+
+		serve(t, source, reader, 2)
+
+		assert.Equal(t, 4, attempts)
+		assert.Equal(t, []int64{1, 2}, reader.commits())
+	})
+
+	t.Run("commits held record once it's released", func(t *testing.T) {
+		reader := &fakeReader{records: make(chan Record, 1)}
+		reader.records <- Record{Topic: "user.email.confirmation", Offset: 1, Value: value}
+
+		source := NewSource(reader, byTopic)
+		source.sleep = noSleep
+		source.HandleFunc("user.email.confirmation", func(msg amqp.Message) error {
+			hold := amqp.NewHold()
+			go hold.Settle(nil)
+			return hold
+		})
+
+		serve(t, source, reader, 1)
+
+		assert.Equal(t, []int64{1}, reader.commits())
+	})
+
+	t.Run("leaves record retried at shutdown uncommitted", func(t *testing.T) {
+		reader := &fakeReader{records: make(chan Record, 1)}
+		reader.records <- Record{Topic: "user.email.confirmation", Offset: 1, Value: value}
+
+		retried := make(chan struct{}, 1)
+		source := NewSource(reader, byTopic)
+		source.HandleFunc("user.email.confirmation", func(msg amqp.Message) error {
+			select {
+			case retried <- struct{}{}:
+			default:
+			}
+			return amqp.Requeue(errors.New("smtp timed out"))
+		})
+
+		done := make(chan error, 1)
+		go func() { done <- source.ListenAndServe() }()
+		<-retried
+
+		assert.NoError(t, source.Shutdown(context.Background()))
+		assert.NoError(t, <-done)
+		assert.Empty(t, reader.commits())
+		assert.EqualError(t, source.Shutdown(context.Background()), "kafka: shutdown is in progress")
+	})
+}
@@ -0,0 +1,33 @@
+package suppression
+
+import (
+	"encoding/json"
+)
+
+// mailgunWebhook is event posted by Mailgun webhook.
+type mailgunWebhook struct {
+	EventData struct {
+		Event string `json:"event"`
+		// Either "permanent" or "temporary" for failed deliveries.
+		Severity  string `json:"severity"`
+		Recipient string `json:"recipient"`
+	} `json:"event-data"`
+}
+
+// parseMailgun returns entries of Mailgun webhook event.
+func parseMailgun(body []byte) ([]Entry, error) {
+	var webhook mailgunWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, err
+	}
+
+	data := webhook.EventData
+	switch {
+	case data.Event == "failed" && data.Severity == "permanent":
+		return []Entry{{Address: data.Recipient, Reason: ReasonBounce}}, nil
+	case data.Event == "complained":
+		return []Entry{{Address: data.Recipient, Reason: ReasonComplaint}}, nil
+	}
+
+	return nil, nil
+}
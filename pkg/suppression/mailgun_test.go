@@ -0,0 +1,20 @@
+package suppression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMailgun(t *testing.T) {
+	for body, expected := range map[string][]Entry{
+		`{"event-data": {"event": "failed", "severity": "permanent", "recipient": "john@doe.com"}}`: {{Address: "john@doe.com", Reason: ReasonBounce}},
+		`{"event-data": {"event": "failed", "severity": "temporary", "recipient": "john@doe.com"}}`: nil,
+		`{"event-data": {"event": "complained", "recipient": "john@doe.com"}}`:                      {{Address: "john@doe.com", Reason: ReasonComplaint}},
+		`{"event-data": {"event": "delivered", "recipient": "john@doe.com"}}`:                       nil,
+	} {
+		entries, err := parseMailgun([]byte(body))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, entries, body)
+	}
+}
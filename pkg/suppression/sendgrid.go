@@ -0,0 +1,33 @@
+package suppression
+
+import (
+	"encoding/json"
+)
+
+// sendgridEvent is event of SendGrid event webhook, which posts them in batches.
+type sendgridEvent struct {
+	Email string `json:"email"`
+	Event string `json:"event"`
+	// Either "bounce" or "blocked", the latter is temporary.
+	Type string `json:"type"`
+}
+
+// parseSendGrid returns entries of SendGrid event batch.
+func parseSendGrid(body []byte) ([]Entry, error) {
+	var events []sendgridEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, event := range events {
+		switch {
+		case event.Event == "bounce" && event.Type != "blocked":
+			entries = append(entries, Entry{Address: event.Email, Reason: ReasonBounce})
+		case event.Event == "spamreport":
+			entries = append(entries, Entry{Address: event.Email, Reason: ReasonComplaint})
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,24 @@
+package suppression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSendGrid(t *testing.T) {
+	entries, err := parseSendGrid([]byte(`[
+		{"email": "john@doe.com", "event": "bounce", "type": "bounce"},
+		{"email": "jane@doe.com", "event": "bounce", "type": "blocked"},
+		{"email": "alice@doe.com", "event": "spamreport"},
+		{"email": "bob@doe.com", "event": "delivered"}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Address: "john@doe.com", Reason: ReasonBounce},
+		{Address: "alice@doe.com", Reason: ReasonComplaint},
+	}, entries)
+
+	_, err = parseSendGrid([]byte(`{}`))
+	assert.Error(t, err)
+}
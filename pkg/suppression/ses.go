@@ -0,0 +1,101 @@
+package suppression
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// snsMessage is notification of Amazon SNS topic, which SES publishes to.
+type snsMessage struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// sesNotification is SES bounce or complaint notification, either of
+// notification or event publishing, which names type "eventType".
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Bounce           struct {
+		BounceType        string         `json:"bounceType"`
+		BouncedRecipients []sesRecipient `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplainedRecipients []sesRecipient `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+type sesRecipient struct {
+	EmailAddress string `json:"emailAddress"`
+}
+
+// parseSES returns entries of SES notification delivered through SNS,
+// raw message delivery included. Subscription confirmation is returned
+// as subscribe URL instead. Transient bounces aren't suppressed.
+func parseSES(body []byte) ([]Entry, string, error) {
+	var msg snsMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, "", err
+	}
+
+	switch msg.Type {
+	case "SubscriptionConfirmation":
+		if err := validSubscribeURL(msg.SubscribeURL); err != nil {
+			return nil, "", err
+		}
+		return nil, msg.SubscribeURL, nil
+	case "Notification":
+		body = []byte(msg.Message)
+	case "":
+		// Raw message delivery posts notification itself.
+	default:
+		return nil, "", nil
+	}
+
+	var notification sesNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, "", fmt.Errorf("ses notification: %s", err.Error())
+	}
+
+	kind := notification.NotificationType
+	if kind == "" {
+		kind = notification.EventType
+	}
+
+	var entries []Entry
+	switch kind {
+	case "Bounce":
+		if notification.Bounce.BounceType != "Permanent" {
+			return nil, "", nil
+		}
+		for _, r := range notification.Bounce.BouncedRecipients {
+			entries = append(entries, Entry{Address: r.EmailAddress, Reason: ReasonBounce})
+		}
+	case "Complaint":
+		for _, r := range notification.Complaint.ComplainedRecipients {
+			entries = append(entries, Entry{Address: r.EmailAddress, Reason: ReasonComplaint})
+		}
+	}
+
+	return entries, "", nil
+}
+
+// validSubscribeURL checks subscription is confirmed with SNS itself,
+// so webhook can't be made to request arbitrary URLs.
+func validSubscribeURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" {
+		return errors.New("sns subscribe url should be https")
+	}
+
+	host := u.Hostname()
+	if !strings.HasPrefix(host, "sns.") || !strings.HasSuffix(host, ".amazonaws.com") {
+		return fmt.Errorf("sns subscribe url host \"%s\" is not of sns", host)
+	}
+
+	return nil
+}
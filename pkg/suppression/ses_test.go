@@ -0,0 +1,65 @@
+package suppression
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func snsNotification(t *testing.T, message string) []byte {
+	body, err := json.Marshal(snsMessage{Type: "Notification", Message: message})
+	assert.NoError(t, err)
+	return body
+}
+
+func TestParseSES(t *testing.T) {
+	t.Run("permanent bounce", func(t *testing.T) {
+		entries, _, err := parseSES(snsNotification(t, `{"notificationType": "Bounce", "bounce": {
+			"bounceType": "Permanent", "bouncedRecipients": [{"emailAddress": "john@doe.com"}]}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, []Entry{{Address: "john@doe.com", Reason: ReasonBounce}}, entries)
+	})
+
+	t.Run("transient bounce", func(t *testing.T) {
+		entries, _, err := parseSES(snsNotification(t, `{"notificationType": "Bounce", "bounce": {
+			"bounceType": "Transient", "bouncedRecipients": [{"emailAddress": "john@doe.com"}]}}`))
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("raw complaint event", func(t *testing.T) {
+		entries, _, err := parseSES([]byte(`{"eventType": "Complaint", "complaint": {
+			"complainedRecipients": [{"emailAddress": "john@doe.com"}]}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, []Entry{{Address: "john@doe.com", Reason: ReasonComplaint}}, entries)
+	})
+
+	t.Run("subscription confirmation", func(t *testing.T) {
+		url := "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&Token=abc"
+		body, _ := json.Marshal(snsMessage{Type: "SubscriptionConfirmation", SubscribeURL: url})
+
+		entries, subscribeURL, err := parseSES(body)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+		assert.Equal(t, url, subscribeURL)
+	})
+
+	t.Run("foreign subscribe url", func(t *testing.T) {
+		for url, message := range map[string]string{
+			"https://evil.com/?sns.amazonaws.com":      "sns subscribe url host \"evil.com\" is not of sns",
+			"http://sns.eu-west-1.amazonaws.com/":      "sns subscribe url should be https",
+			"https://sns.eu-west-1.amazonaws.com.evil": "sns subscribe url host \"sns.eu-west-1.amazonaws.com.evil\" is not of sns",
+		} {
+			body, _ := json.Marshal(snsMessage{Type: "SubscriptionConfirmation", SubscribeURL: url})
+
+			_, _, err := parseSES(body)
+			assert.EqualError(t, err, message)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, _, err := parseSES(snsNotification(t, "{"))
+		assert.Error(t, err)
+	})
+}
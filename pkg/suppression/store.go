@@ -0,0 +1,188 @@
+// Package suppression keeps addresses, which hard-bounced or complained,
+// so they aren't mailed again, fed by webhooks of providers.
+package suppression
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons of suppression.
+const (
+	ReasonBounce    = "bounce"
+	ReasonComplaint = "complaint"
+)
+
+// Entry is suppressed address.
+type Entry struct {
+	Address string `json:"address"`
+	// Either ReasonBounce or ReasonComplaint.
+	Reason string `json:"reason"`
+	// Provider, which reported the address, e.g. "ses".
+	Provider string    `json:"provider,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Store keeps suppressed addresses, e.g. in Redis shared by consumers.
+// Addresses are compared case-insensitively.
+type Store interface {
+	// Add suppresses address of entry, replacing previous entry of it.
+	Add(entry Entry) error
+	// Remove lifts suppression of address, reporting whether it was suppressed.
+	Remove(address string) (bool, error)
+	// Get returns entry of address, if it's suppressed.
+	Get(address string) (Entry, bool, error)
+	// List returns entries sorted by address.
+	List() ([]Entry, error)
+}
+
+// normalize brings address to the form entries are keyed by.
+func normalize(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// MemoryStore keeps entries in memory, so they are lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore creates empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+func (s *MemoryStore) Add(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Address = normalize(entry.Address)
+	s.entries[entry.Address] = entry
+	return nil
+}
+
+func (s *MemoryStore) Remove(address string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	address = normalize(address)
+	_, ok := s.entries[address]
+	delete(s.entries, address)
+	return ok, nil
+}
+
+func (s *MemoryStore) Get(address string) (Entry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.entries[normalize(address)]
+	return entry, ok, nil
+}
+
+func (s *MemoryStore) List() ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Address < entries[j].Address })
+	return entries, nil
+}
+
+// FileStore is MemoryStore saved to JSON file on every change,
+// so entries survive restarts. File is written by one process only.
+type FileStore struct {
+	path   string
+	memory *MemoryStore
+	// Serializes changes with writes of the file.
+	mu sync.Mutex
+}
+
+// OpenFileStore loads entries of file at path, which is created on first change.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, memory: NewMemoryStore()}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("suppression file %s: %s", path, err.Error())
+	}
+
+	for _, entry := range entries {
+		s.memory.Add(entry)
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Add(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.memory.Add(entry)
+	return s.save()
+}
+
+func (s *FileStore) Remove(address string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ok, _ := s.memory.Remove(address)
+	if !ok {
+		return false, nil
+	}
+
+	return true, s.save()
+}
+
+func (s *FileStore) Get(address string) (Entry, bool, error) {
+	return s.memory.Get(address)
+}
+
+func (s *FileStore) List() ([]Entry, error) {
+	return s.memory.List()
+}
+
+// save writes entries to temporary file renamed over the store file,
+// so readers never see it half written.
+func (s *FileStore) save() error {
+	entries, _ := s.memory.List()
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,72 @@
+package suppression
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, store Store) {
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, store.Add(Entry{Address: "John@Doe.com", Reason: ReasonBounce, Provider: ProviderSES, At: at}))
+	assert.NoError(t, store.Add(Entry{Address: "alice@doe.com", Reason: ReasonComplaint, At: at}))
+
+	entry, ok, err := store.Get(" JOHN@doe.com")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Entry{Address: "john@doe.com", Reason: ReasonBounce, Provider: ProviderSES, At: at}, entry)
+
+	entries, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "alice@doe.com", entries[0].Address)
+
+	removed, err := store.Remove("john@doe.com")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+
+	removed, err = store.Remove("john@doe.com")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	_, ok, err = store.Get("john@doe.com")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "suppression")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "suppressions.json")
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	testStore(t, store)
+
+	t.Run("reopened", func(t *testing.T) {
+		store, err := OpenFileStore(path)
+		assert.NoError(t, err)
+
+		entries, err := store.List()
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "alice@doe.com", entries[0].Address)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+
+		_, err := OpenFileStore(path)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,230 @@
+package suppression
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Providers of webhooks.
+const (
+	ProviderSES      = "ses"
+	ProviderSendGrid = "sendgrid"
+	ProviderMailgun  = "mailgun"
+)
+
+// MaxBodySize limits body of webhook requests.
+const MaxBodySize = 1 << 20
+
+// Handler receives bounce and complaint webhooks of providers at "/webhooks/ses",
+// "/webhooks/sendgrid" and "/webhooks/mailgun", suppressing reported addresses,
+// and serves "/suppressions", listing entries as JSON on GET and lifting suppression
+// of "address" query parameter on DELETE.
+//
+// Requests must carry token as basic auth password or "token" query parameter,
+// so webhook URLs configured at providers embed it. Empty token rejects all requests.
+type Handler struct {
+	store   Store
+	token   string
+	mux     *http.ServeMux
+	now     func() time.Time
+	confirm func(url string) error
+}
+
+// NewHandler creates handler of webhooks suppressing addresses in store.
+func NewHandler(store Store, token string) *Handler {
+	h := &Handler{store: store, token: token, mux: http.NewServeMux(), now: time.Now, confirm: confirmSubscription}
+
+	h.mux.HandleFunc("/webhooks/"+ProviderSES, h.webhook(ProviderSES, func(body []byte) ([]Entry, error) {
+		entries, subscribeURL, err := parseSES(body)
+		if err != nil || subscribeURL == "" {
+			return entries, err
+		}
+		return nil, h.confirm(subscribeURL)
+	}))
+	h.mux.HandleFunc("/webhooks/"+ProviderSendGrid, h.webhook(ProviderSendGrid, parseSendGrid))
+	h.mux.HandleFunc("/webhooks/"+ProviderMailgun, h.webhook(ProviderMailgun, parseMailgun))
+	h.mux.HandleFunc("/suppressions", h.suppressions)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="postmaster"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+
+	return h.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// webhook suppresses entries, which parse returns from request body.
+func (h *Handler) webhook(provider string, parse func(body []byte) ([]Entry, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodySize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		entries, err := parse(body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s webhook: %s", provider, err.Error()), http.StatusBadRequest)
+			return
+		}
+
+		for _, entry := range entries {
+			// Payload isn't trusted to carry addresses only.
+			address, err := mail.ParseAddress(entry.Address)
+			if err != nil {
+				continue
+			}
+			entry.Address = normalize(address.Address)
+
+			entry.Provider = provider
+			entry.At = h.now()
+			// Provider retries failed webhooks, so entries added before failure are added again.
+			if err := h.store.Add(entry); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			log.Printf("Address %s is suppressed after %s reported by %s\n", entry.Address, entry.Reason, provider)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) suppressions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		entries, err := h.store.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	case http.MethodDelete:
+		address := r.URL.Query().Get("address")
+		removed, err := h.store.Remove(address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			http.Error(w, fmt.Sprintf("address %s is not suppressed", address), http.StatusNotFound)
+			return
+		}
+
+		log.Printf("Suppression of address %s is lifted\n", normalize(address))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// confirmSubscription confirms SNS subscription by requesting its subscribe URL.
+func confirmSubscription(url string) error {
+	client := http.Client{Timeout: 10 * time.Second}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sns subscription confirmation: status %d", resp.StatusCode)
+	}
+
+	log.Printf("SNS subscription is confirmed\n")
+	return nil
+}
+
+// Client lists and lifts suppressions through API of Handler at URL, e.g. "http://localhost:8080".
+type Client struct {
+	URL   string
+	Token string
+	HTTP  *http.Client
+}
+
+func (c *Client) do(method string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.URL, "/")+"/suppressions?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth("postmaster", c.Token)
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return client.Do(req)
+}
+
+// statusError reads error of unsuccessful response.
+func statusError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, MaxBodySize))
+	return fmt.Errorf("suppressions: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// List returns suppressed entries.
+func (c *Client) List() ([]Entry, error) {
+	resp, err := c.do(http.MethodGet, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var entries []Entry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Remove lifts suppression of address.
+func (c *Client) Remove(address string) error {
+	resp, err := c.do(http.MethodDelete, url.Values{"address": {address}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return statusError(resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package suppression
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeHandler() (*Handler, *MemoryStore, *[]string) {
+	store := NewMemoryStore()
+	h := NewHandler(store, "secret")
+	h.now = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	var confirmed []string
+	h.confirm = func(url string) error {
+		confirmed = append(confirmed, url)
+		return nil
+	}
+
+	return h, store, &confirmed
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("unauthorized", func(t *testing.T) {
+		h, _, _ := fakeHandler()
+
+		assert.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "/suppressions", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "/suppressions?token=wrong", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(NewHandler(NewMemoryStore(), ""), http.MethodGet, "/suppressions?token=", "").Code)
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		h, _, _ := fakeHandler()
+
+		req := httptest.NewRequest(http.MethodGet, "/suppressions", nil)
+		req.SetBasicAuth("sendgrid", "secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("webhooks", func(t *testing.T) {
+		h, store, _ := fakeHandler()
+
+		rec := serve(h, http.MethodPost, "/webhooks/mailgun?token=secret",
+			`{"event-data": {"event": "failed", "severity": "permanent", "recipient": "John Doe <John@doe.com>"}}`)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = serve(h, http.MethodPost, "/webhooks/sendgrid?token=secret",
+			`[{"email": "alice@doe.com", "event": "spamreport"}, {"email": "not an address", "event": "spamreport"}]`)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		entries, _ := store.List()
+		assert.Equal(t, []Entry{
+			{Address: "alice@doe.com", Reason: ReasonComplaint, Provider: ProviderSendGrid, At: h.now()},
+			{Address: "john@doe.com", Reason: ReasonBounce, Provider: ProviderMailgun, At: h.now()},
+		}, entries)
+
+		rec = serve(h, http.MethodPost, "/webhooks/sendgrid?token=secret", `{`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = serve(h, http.MethodGet, "/webhooks/sendgrid?token=secret", ``)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("sns subscription", func(t *testing.T) {
+		h, _, confirmed := fakeHandler()
+
+		url := "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription"
+		rec := serve(h, http.MethodPost, "/webhooks/ses?token=secret",
+			`{"Type": "SubscriptionConfirmation", "SubscribeURL": "`+url+`"}`)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []string{url}, *confirmed)
+	})
+
+	t.Run("suppressions", func(t *testing.T) {
+		h, store, _ := fakeHandler()
+		store.Add(Entry{Address: "john@doe.com", Reason: ReasonBounce, At: h.now()})
+
+		rec := serve(h, http.MethodGet, "/suppressions?token=secret", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[{"address": "john@doe.com", "reason": "bounce", "at": "2021-01-01T00:00:00Z"}]`, rec.Body.String())
+
+		rec = serve(h, http.MethodDelete, "/suppressions?token=secret&address=John@doe.com", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = serve(h, http.MethodDelete, "/suppressions?token=secret&address=john@doe.com", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestClient(t *testing.T) {
+	h, store, _ := fakeHandler()
+	store.Add(Entry{Address: "john@doe.com", Reason: ReasonBounce, At: h.now()})
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	client := &Client{URL: server.URL + "/", Token: "secret"}
+
+	entries, err := client.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{{Address: "john@doe.com", Reason: ReasonBounce, At: h.now()}}, entries)
+
+	assert.NoError(t, client.Remove("john@doe.com"))
+	assert.EqualError(t, client.Remove("john@doe.com"), "suppressions: status 404: address john@doe.com is not suppressed")
+
+	client.Token = "wrong"
+	_, err = client.List()
+	assert.EqualError(t, err, "suppressions: status 401: unauthorized")
+}
@@ -1,4 +1,7 @@
-From: "{{ .FromName }}" <{{ .FromAddress }}>
+MIME-Version: 1.0
+From: {{ .From }}
 To: {{ .ToAddress }}
-Subject: {{ .Subject }}
-Content-type: text/html; charset=iso-8859-1
+{{ if .CC }}Cc: {{ range $i, $address := .CC }}{{ if $i }}, {{ end }}{{ $address }}{{ end }}
+{{ end }}Subject: {{ .EncodedSubject }}
+{{ range $name, $value := .Headers }}{{ $name }}: {{ $value }}
+{{ end }}Content-type: {{ .ContentType }}
\ No newline at end of file